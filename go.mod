@@ -12,6 +12,7 @@ require (
 	github.com/docker/go-units v0.4.0
 	github.com/dustin/go-humanize v1.0.0
 	github.com/go-git/go-git/v5 v5.4.2
+	github.com/go-redis/redis/v7 v7.4.0
 	github.com/go-playground/validator/v10 v10.9.0
 	github.com/google/uuid v1.3.0
 	github.com/gorilla/mux v1.8.0
@@ -27,6 +28,7 @@ require (
 	github.com/msoap/byline v1.1.1
 	github.com/otiai10/copy v1.7.0
 	github.com/pborman/uuid v1.2.1
+	github.com/prometheus/client_golang v1.7.1
 	github.com/rs/xid v1.3.0
 	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
 	github.com/stretchr/testify v1.8.0
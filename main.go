@@ -1,11 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/testground/testground/pkg/cmd"
 	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
 
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap/zapcore"
@@ -22,19 +24,46 @@ func main() {
 	// Disable the built-in -v flag (version), to avoid collisions with the
 	// verbosity flags.
 	app.HideVersion = true
-	app.Before = func(c *cli.Context) error {
-		configureLogging(c)
-		return nil
-	}
+	app.Before = configureLogging
 
 	err := app.Run(os.Args)
 	if err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
-func configureLogging(c *cli.Context) {
+// exitCode maps errors returned by the daemon to distinct exit codes, so that
+// tooling can branch on the category of the failure.
+func exitCode(err error) int {
+	var rerr *rpc.Error
+	if !errors.As(err, &rerr) {
+		return 1
+	}
+	switch rerr.Code {
+	case rpc.ErrorCodeBadRequest:
+		return 2
+	case rpc.ErrorCodeNotFound:
+		return 3
+	case rpc.ErrorCodeInfrastructure:
+		return 4
+	case rpc.ErrorCodeTestFailure:
+		return 5
+	default:
+		return 1
+	}
+}
+
+func configureLogging(c *cli.Context) error {
+	// The LOG_FORMAT environment variable takes precedence.
+	format := c.String("log-format")
+	if f := os.Getenv("LOG_FORMAT"); f != "" {
+		format = f
+	}
+	if err := logging.SetFormat(format); err != nil {
+		return err
+	}
+
 	// The LOG_LEVEL environment variable takes precedence.
 	if level := os.Getenv("LOG_LEVEL"); level != "" {
 		var l zapcore.Level
@@ -42,7 +71,7 @@ func configureLogging(c *cli.Context) {
 			panic(err)
 		}
 		logging.SetLevel(l)
-		return
+		return nil
 	}
 
 	// Apply verbosity flags.
@@ -54,4 +83,5 @@ func configureLogging(c *cli.Context) {
 	default:
 		// Do nothing; level remains at default (INFO).
 	}
+	return nil
 }
@@ -30,6 +30,10 @@ type BuildInput struct {
 	// BuildID is a unique ID for this build.
 	BuildID string
 
+	// RequestID is the ID of the daemon request that created the build, if
+	// known.
+	RequestID string
+
 	// EnvConfig is the env configuration of the engine. Not a pointer to force
 	// a copy.
 	EnvConfig config.EnvConfig
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/testground/testground/pkg/task"
+)
+
+// CallbackSignatureHeader is the header carrying the signature of a task
+// callback body, when the daemon is configured with a callback secret. It
+// holds "sha256=" followed by the hex-encoded HMAC-SHA256 of the body.
+const CallbackSignatureHeader = "X-Testground-Signature"
+
+// TaskCallback is the body the daemon POSTs to the callback URL of a task once
+// it reaches a terminal state.
+type TaskCallback struct {
+	TaskID   string       `json:"task_id"`
+	Type     task.Type    `json:"type"`
+	Plan     string       `json:"plan,omitempty"`
+	Case     string       `json:"case,omitempty"`
+	State    task.State   `json:"state"`
+	Outcome  task.Outcome `json:"outcome,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	Duration float64      `json:"duration_secs"`
+	Result   interface{}  `json:"result,omitempty"`
+}
+
+// ValidateCallbackURL checks that a callback URL supplied with a request is
+// an absolute http(s) URL.
+func ValidateCallbackURL(callback string) error {
+	u, err := url.Parse(callback)
+	if err != nil {
+		return fmt.Errorf("invalid callback url: %w", err)
+	}
+	if s := strings.ToLower(u.Scheme); (s != "http" && s != "https") || u.Host == "" {
+		return fmt.Errorf("invalid callback url %q: must be an absolute http(s) url", callback)
+	}
+	return nil
+}
+
+// SignCallback returns the value of the CallbackSignatureHeader for body.
+func SignCallback(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCallback reports whether signature is a valid CallbackSignatureHeader
+// value for body. Receivers of task callbacks can use it to authenticate them.
+func VerifyCallback(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignCallback(secret, body)), []byte(signature))
+}
@@ -0,0 +1,32 @@
+package api
+
+import "testing"
+
+func TestValidateCallbackURL(t *testing.T) {
+	for u, valid := range map[string]bool{
+		"https://ci.example.com/hooks/testground": true,
+		"http://10.0.0.1:8080/done":               true,
+		"ftp://example.com/done":                  false,
+		"/relative/path":                          false,
+		"https://":                                false,
+	} {
+		if err := ValidateCallbackURL(u); (err == nil) != valid {
+			t.Errorf("ValidateCallbackURL(%q) = %v, expected valid=%t", u, err, valid)
+		}
+	}
+}
+
+func TestSignCallback(t *testing.T) {
+	body := []byte(`{"task_id":"c1"}`)
+
+	sig := SignCallback("secret", body)
+	if !VerifyCallback("secret", body, sig) {
+		t.Errorf("signature %s did not verify", sig)
+	}
+	if VerifyCallback("other", body, sig) {
+		t.Errorf("signature verified with the wrong secret")
+	}
+	if VerifyCallback("secret", []byte(`{"task_id":"c2"}`), sig) {
+		t.Errorf("signature verified for a different body")
+	}
+}
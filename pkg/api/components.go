@@ -0,0 +1,81 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+// ComponentDescription describes a runner or a builder, and the configuration
+// it accepts.
+type ComponentDescription struct {
+	ID   string        `json:"id"`
+	Type ComponentType `json:"type"`
+	// Config lists the configuration keys accepted by the component, as set in
+	// run-cfg and build-cfg, manifests and .env.toml.
+	Config []ConfigField `json:"config"`
+	// Compatible lists the IDs of the builders a runner can work with, or the
+	// runners that can work with the artifacts of a builder.
+	Compatible []string `json:"compatible"`
+}
+
+// ConfigField describes a configuration key of a runner or a builder.
+type ConfigField struct {
+	Key     string `json:"key"`
+	Type    string `json:"type"`
+	Default string `json:"default,omitempty"`
+	// Overridable is whether users may set the key in run-cfg and build-cfg;
+	// see config.OverridableTag.
+	Overridable bool `json:"overridable"`
+}
+
+// ConfigKey returns the configuration key of a struct field, i.e. its toml
+// name, and whether the field can be configured at all.
+func ConfigKey(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" && !f.Anonymous {
+		// unexported.
+		return "", false
+	}
+	name := strings.Split(f.Tag.Get("toml"), ",")[0]
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	default:
+		return name, true
+	}
+}
+
+// DescribeConfig lists the configuration keys of a runner or builder
+// configuration type. The fields of embedded structs are promoted, as they
+// are when decoding toml.
+func DescribeConfig(typ reflect.Type) []ConfigField {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []ConfigField
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Anonymous && f.Tag.Get("toml") == "" && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, DescribeConfig(f.Type)...)
+			continue
+		}
+		key, ok := ConfigKey(f)
+		if !ok {
+			continue
+		}
+		fields = append(fields, ConfigField{
+			Key:         key,
+			Type:        f.Type.String(),
+			Default:     f.Tag.Get("default"),
+			Overridable: f.Tag.Get(config.OverridableTag) != "no",
+		})
+	}
+	return fields
+}
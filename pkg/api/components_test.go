@@ -0,0 +1,33 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+type embeddedConfig struct {
+	Verbose bool `toml:"verbose"`
+}
+
+type describedConfig struct {
+	embeddedConfig
+
+	Enabled bool
+	Path    string   `toml:"path" default:"./" overridable:"no"`
+	Tags    []string `toml:"tags,omitempty"`
+	Ignored string   `toml:"-"`
+	private int
+}
+
+func TestDescribeConfig(t *testing.T) {
+	got := DescribeConfig(reflect.TypeOf(&describedConfig{}))
+	want := []ConfigField{
+		{Key: "verbose", Type: "bool", Overridable: true},
+		{Key: "Enabled", Type: "bool", Overridable: true},
+		{Key: "path", Type: "string", Default: "./"},
+		{Key: "tags", Type: "[]string", Overridable: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
@@ -72,6 +72,11 @@ type Global struct {
 
 	// DisableMetrics is used to disable metrics batching.
 	DisableMetrics bool `toml:"disable_metrics" json:"disable_metrics"`
+
+	// Matrix maps test parameters to the values to sweep them through. A
+	// composition with a matrix is run as an experiment: one run per cell of
+	// the cartesian product of the values.
+	Matrix map[string][]interface{} `toml:"matrix" json:"matrix,omitempty"`
 }
 
 type Metadata struct {
@@ -85,6 +90,9 @@ type Metadata struct {
 type Resources struct {
 	Memory string `toml:"memory" json:"memory"`
 	CPU    string `toml:"cpu" json:"cpu"`
+	// GPU is the number of GPUs requested for each instance. Only the
+	// cluster:k8s runner supports it.
+	GPU string `toml:"gpu" json:"gpu,omitempty"`
 }
 
 type Group struct {
@@ -161,6 +169,23 @@ type CompositionRunGroup struct {
 	// CPU profile for the entire duration of the test.
 	Profiles map[string]string `toml:"profiles" json:"profiles"`
 
+	// Mounts are additional read-only volumes to mount into instances. Refer
+	// to the docs on RunParams#Mounts for more info.
+	Mounts []string `toml:"mounts" json:"mounts"`
+
+	// Role is the role of the instances of this group. Refer to the docs on
+	// RunParams#Role for more info.
+	Role string `toml:"role" json:"role"`
+
+	// Dials are the IDs of the run groups whose instances the instances of
+	// this group connect to. Refer to the docs on RunParams#Dials for more
+	// info.
+	Dials []string `toml:"dials" json:"dials"`
+
+	// InitContainers are setup steps run before each instance. Refer to the
+	// docs on RunParams#InitContainers for more info.
+	InitContainers []InitContainer `toml:"init_containers" json:"init_containers,omitempty"`
+
 	// calculatedInstanceCnt caches the actual number of instances in this
 	// group.
 	calculatedInstanceCnt uint
@@ -234,12 +259,25 @@ func (b Build) BuildKey() string {
 	})
 	sb.WriteString("dependencies=")
 	for _, d := range dependencies {
-		sb.WriteString(fmt.Sprintf("%s:%s|", d.Module, d.Version))
+		sb.WriteString(fmt.Sprintf("%s:%s:%s|", d.Module, d.Target, d.Version))
 	}
 
 	return sb.String()
 }
 
+// DependencyTargets returns the dependency overrides of this build, by module,
+// as they are passed to builders.
+func (b Build) DependencyTargets() map[string]DependencyTarget {
+	deps := make(map[string]DependencyTarget, len(b.Dependencies))
+	for _, dep := range b.Dependencies {
+		deps[dep.Module] = DependencyTarget{
+			Target:  dep.Target,
+			Version: dep.Version,
+		}
+	}
+	return deps
+}
+
 func (d Dependencies) AsMap() map[string]Dependency {
 	m := make(map[string]Dependency, len(d))
 	for _, dep := range d {
@@ -297,6 +335,29 @@ type RunParams struct {
 	// profile kind "cpu" is supported; it takes no frequency and it starts a
 	// CPU profile for the entire duration of the test.
 	Profiles map[string]string `toml:"profiles" json:"profiles"`
+
+	// Mounts are additional read-only volumes to mount into instances, in the
+	// source:target[:ro] format. Sources must lie within the mount roots
+	// allowed by the daemon configuration.
+	Mounts []string `toml:"mounts" json:"mounts"`
+
+	// Role is the role of the instances of this group, e.g. "initiator" or
+	// "responder", passed to them as the TestInstanceRole of the runtime
+	// environment. Plans that assign roles should rely on it rather than on
+	// the order in which instances reach the sync service.
+	Role string `toml:"role" json:"role"`
+
+	// Dials are the IDs of the run groups whose instances the instances of
+	// this group connect to, e.g. the servers of the clients. They're passed
+	// to instances, along with the groups of the run, so that the SDK can
+	// resolve the peers to dial by group rather than by instance index.
+	Dials []string `toml:"dials" json:"dials"`
+
+	// InitContainers are setup steps run before each instance, after the
+	// reserved ones preparing it, sharing its environment and volumes. They
+	// replace the ones the test plan manifest declares for the runner, if
+	// any.
+	InitContainers []InitContainer `toml:"init_containers" json:"init_containers,omitempty"`
 }
 
 type Dependency struct {
@@ -466,6 +527,11 @@ func (g *Group) DefaultRunGroup() (*CompositionRunGroup) {
 		Instances:  g.Instances,
 		TestParams: g.Run.TestParams,
 		Profiles:   g.Run.Profiles,
+		Mounts:     g.Run.Mounts,
+		Role:       g.Run.Role,
+		Dials:      g.Run.Dials,
+
+		InitContainers: g.Run.InitContainers,
 	}
 }
 
@@ -499,5 +565,22 @@ func (r *CompositionRunGroup) mergeRun(other *RunParams) (error) {
 		return err
 	}
 
+	// mergo only merges structs and maps.
+	if len(r.Mounts) == 0 {
+		r.Mounts = other.Mounts
+	}
+
+	if r.Role == "" {
+		r.Role = other.Role
+	}
+
+	if len(r.Dials) == 0 {
+		r.Dials = other.Dials
+	}
+
+	if len(r.InitContainers) == 0 {
+		r.InitContainers = other.InitContainers
+	}
+
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// MatrixCell is a cell of the parameter matrix of a composition.
+type MatrixCell struct {
+	// Case is the test case of this cell, when an experiment runs several.
+	Case string `json:"case,omitempty"`
+
+	// Params are the values of the matrix parameters in this cell.
+	Params map[string]string `json:"params,omitempty"`
+
+	// Composition is the composition to run for this cell, with Params set in
+	// all its run groups, and without a matrix.
+	Composition Composition `json:"composition"`
+}
+
+// ExpandCells expands this composition into the cells of an experiment: one
+// per test case in cases and per cell of its parameter matrix. Without any
+// test cases, the cells run the test case of the composition. A composition
+// without a matrix, run for no other test cases, yields no cells.
+func (c Composition) ExpandCells(cases []string) ([]*MatrixCell, error) {
+	cells, err := c.ExpandMatrix()
+	if err != nil || len(cases) == 0 {
+		return cells, err
+	}
+	if len(cells) == 0 {
+		cells = []*MatrixCell{{Composition: c.withParams(nil)}}
+	}
+
+	res := make([]*MatrixCell, 0, len(cases)*len(cells))
+	for _, tc := range cases {
+		for _, cell := range cells {
+			comp := cell.Composition.withParams(nil)
+			comp.Global.Case = tc
+			res = append(res, &MatrixCell{Case: tc, Params: cell.Params, Composition: comp})
+		}
+	}
+	return res, nil
+}
+
+// ExpandMatrix expands the parameter matrix of this composition into the
+// cartesian product of its values. Cells are ordered with the values of the
+// last parameter, by name, varying fastest. A composition without a matrix
+// yields no cells.
+func (c Composition) ExpandMatrix() ([]*MatrixCell, error) {
+	if len(c.Global.Matrix) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(c.Global.Matrix))
+	for name := range c.Global.Matrix {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([][]string, len(names))
+	for i, name := range names {
+		vs := c.Global.Matrix[name]
+		if len(vs) == 0 {
+			return nil, fmt.Errorf("matrix parameter %s has no values", name)
+		}
+		for _, v := range vs {
+			s, err := matrixValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("matrix parameter %s: %w", name, err)
+			}
+			values[i] = append(values[i], s)
+		}
+	}
+
+	var (
+		cells []*MatrixCell
+		idx   = make([]int, len(names))
+	)
+	for {
+		params := make(map[string]string, len(names))
+		for i, name := range names {
+			params[name] = values[i][idx[i]]
+		}
+		cells = append(cells, &MatrixCell{Params: params, Composition: c.withParams(params)})
+
+		// advance the indices like an odometer.
+		i := len(idx) - 1
+		for ; i >= 0; i-- {
+			if idx[i]++; idx[i] < len(values[i]) {
+				break
+			}
+			idx[i] = 0
+		}
+		if i < 0 {
+			return cells, nil
+		}
+	}
+}
+
+// withParams clones this composition without its matrix, setting params in
+// all its run groups, where they take precedence over any other value.
+func (c Composition) withParams(params map[string]string) Composition {
+	c = *c.GenerateDefaultRun()
+	c.Global.Matrix = nil
+
+	groups := make(Groups, 0, len(c.Groups))
+	for _, g := range c.Groups {
+		g := *g
+		groups = append(groups, &g)
+	}
+	c.Groups = groups
+
+	runs := make(Runs, 0, len(c.Runs))
+	for _, r := range c.Runs {
+		r := *r
+		rgs := make(CompositionRunGroups, 0, len(r.Groups))
+		for _, rg := range r.Groups {
+			rg := *rg
+			tp := make(map[string]string, len(rg.TestParams)+len(params))
+			for k, v := range rg.TestParams {
+				tp[k] = v
+			}
+			for k, v := range params {
+				tp[k] = v
+			}
+			rg.TestParams = tp
+			rgs = append(rgs, &rg)
+		}
+		r.Groups = rgs
+		runs = append(runs, &r)
+	}
+	c.Runs = runs
+	return c
+}
+
+// matrixValue renders a matrix value as a test parameter; values other than
+// strings are JSON-encoded, as are test parameter defaults in manifests.
+func matrixValue(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("invalid value %v: %w", v, err)
+	}
+	return string(data), nil
+}
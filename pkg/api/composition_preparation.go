@@ -195,6 +195,23 @@ func (r *Run) recalculateInstanceCounts() error {
 	return nil
 }
 
+// validateDials checks that the groups dialed by the groups of the run are
+// part of the run.
+func (r *Run) validateDials() error {
+	ids := make(map[string]struct{}, len(r.Groups))
+	for _, g := range r.Groups {
+		ids[g.ID] = struct{}{}
+	}
+	for _, g := range r.Groups {
+		for _, d := range g.Dials {
+			if _, ok := ids[d]; !ok {
+				return fmt.Errorf("group %s dials group %s, which is not part of run %s", g.ID, d, r.ID)
+			}
+		}
+	}
+	return nil
+}
+
 func (r Run) PrepareForRun(manifest *TestPlanManifest, composition *Composition) (*Run, error) {
 	// Prepare run groups with default values.
 	newGroups := make(CompositionRunGroups, len(r.Groups))
@@ -209,6 +226,16 @@ func (r Run) PrepareForRun(manifest *TestPlanManifest, composition *Composition)
 	}
 	r.Groups = newGroups
 
+	if err := r.validateDials(); err != nil {
+		return nil, err
+	}
+
+	for _, g := range r.Groups {
+		if err := ValidateInitContainers(g.InitContainers); err != nil {
+			return nil, fmt.Errorf("group %s: %w", g.ID, err)
+		}
+	}
+
 	err := r.recalculateInstanceCounts()
 	if err != nil {
 		return nil, err
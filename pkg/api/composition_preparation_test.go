@@ -559,3 +559,78 @@ func TestRunConfigTrickleDown(t *testing.T) {
 	require.EqualValues(t, map[string]string{"test_param_global": "overriden_by_run", "test_param_group": "overriden_by_run", "test_param_runs": "overriden_by_run", "test_param_run": "test_param_run"}, ret.Runs[1].Groups[2].TestParams)
 
 }
+
+func TestRoleAndDialsTrickleDown(t *testing.T) {
+	manifest := &TestPlanManifest{
+		Name: "foo_plan",
+		TestCases: []*TestCase{
+			{
+				Name:      "foo_case",
+				Instances: InstanceConstraints{Minimum: 1, Maximum: 100},
+			},
+		},
+		Builders: map[string]config.ConfigMap{
+			"docker:go": {},
+		},
+		Runners: map[string]config.ConfigMap{
+			"local:docker": {},
+		},
+	}
+
+	c := &Composition{
+		Metadata: Metadata{},
+		Global: Global{
+			Plan:    "foo_plan",
+			Case:    "foo_case",
+			Builder: "docker:go",
+			Runner:  "local:docker",
+		},
+		Groups: []*Group{
+			{
+				ID:  "initiators",
+				Run: RunParams{Role: "initiator"},
+			},
+			{
+				ID:  "responders",
+				Run: RunParams{Role: "responder", Dials: []string{"initiators"}},
+			},
+		},
+		Runs: []*Run{
+			{
+				ID: "handshake",
+				Groups: []*CompositionRunGroup{
+					{
+						ID:        "initiators",
+						Instances: Instances{Count: 2},
+					},
+					{
+						ID:        "responders",
+						Instances: Instances{Count: 2},
+					},
+					{
+						ID:        "relays",
+						GroupID:   "responders",
+						Instances: Instances{Count: 1},
+						Role:      "relay",
+					},
+				},
+			},
+		},
+	}
+
+	c, err := c.PrepareForRun(manifest)
+	require.NoError(t, err)
+
+	groups := c.Runs[0].Groups
+	require.Len(t, groups, 3)
+	require.Equal(t, "initiator", groups[0].Role)
+	require.Equal(t, "responder", groups[1].Role)
+	require.Equal(t, "relay", groups[2].Role)
+	require.Equal(t, []string{"initiators"}, groups[1].Dials)
+	require.Equal(t, []string{"initiators"}, groups[2].Dials)
+
+	// dialing a group that isn't part of the run fails.
+	c.Runs[0].Groups[0].Dials = []string{"observers"}
+	_, err = c.PrepareForRun(manifest)
+	require.Error(t, err)
+}
@@ -588,3 +588,100 @@ func TestMarshalIsIdempotent(t *testing.T) {
 	require.Equal(t, c, &composition)
 	require.Equal(t, uint(4), composition.Runs[1].TotalInstances)
 }
+
+func TestExpandMatrix(t *testing.T) {
+	c := Composition{
+		Global: Global{
+			Plan:   "foo_plan",
+			Case:   "foo_case",
+			Runner: "local:docker",
+			Matrix: map[string][]interface{}{
+				"payload_size": {int64(1024), int64(4096), int64(16384)},
+				"transport":    {"tcp", "quic"},
+			},
+		},
+		Groups: []*Group{
+			{ID: "a", Run: RunParams{TestParams: map[string]string{"transport": "ws", "peers": "4"}}},
+		},
+	}
+
+	cells, err := c.ExpandMatrix()
+	require.NoError(t, err)
+	require.Len(t, cells, 6)
+
+	require.Equal(t, map[string]string{"payload_size": "1024", "transport": "tcp"}, cells[0].Params)
+	require.Equal(t, map[string]string{"payload_size": "1024", "transport": "quic"}, cells[1].Params)
+	require.Equal(t, map[string]string{"payload_size": "16384", "transport": "quic"}, cells[5].Params)
+
+	for _, cell := range cells {
+		require.Nil(t, cell.Composition.Global.Matrix)
+		require.Len(t, cell.Composition.Runs, 1)
+
+		params := cell.Composition.Runs[0].Groups[0].TestParams
+		require.Equal(t, cell.Params["transport"], params["transport"])
+		require.Equal(t, cell.Params["payload_size"], params["payload_size"])
+		require.Equal(t, "4", params["peers"])
+	}
+
+	// the original composition is left untouched.
+	require.Empty(t, c.Runs)
+	require.Equal(t, "ws", c.Groups[0].Run.TestParams["transport"])
+
+	// compositions without a matrix have no cells.
+	c.Global.Matrix = nil
+	cells, err = c.ExpandMatrix()
+	require.NoError(t, err)
+	require.Empty(t, cells)
+
+	c.Global.Matrix = map[string][]interface{}{"payload_size": {}}
+	_, err = c.ExpandMatrix()
+	require.Error(t, err)
+}
+
+func TestExpandCells(t *testing.T) {
+	c := Composition{
+		Global: Global{
+			Plan:   "foo_plan",
+			Case:   "foo_case",
+			Runner: "local:docker",
+		},
+		Groups: []*Group{{ID: "a"}},
+	}
+
+	// a plain composition run for its own test case has no cells.
+	cells, err := c.ExpandCells(nil)
+	require.NoError(t, err)
+	require.Empty(t, cells)
+
+	cells, err = c.ExpandCells([]string{"bar_case", "baz_case"})
+	require.NoError(t, err)
+	require.Len(t, cells, 2)
+	require.Equal(t, "bar_case", cells[0].Case)
+	require.Equal(t, "bar_case", cells[0].Composition.Global.Case)
+	require.Equal(t, "baz_case", cells[1].Composition.Global.Case)
+	require.Equal(t, "foo_case", c.Global.Case)
+
+	c.Global.Matrix = map[string][]interface{}{"payload_size": {"1", "2"}}
+	cells, err = c.ExpandCells([]string{"bar_case", "baz_case"})
+	require.NoError(t, err)
+	require.Len(t, cells, 4)
+	require.Equal(t, "baz_case", cells[3].Composition.Global.Case)
+	require.Equal(t, "2", cells[3].Params["payload_size"])
+	require.Equal(t, "2", cells[3].Composition.Runs[0].Groups[0].TestParams["payload_size"])
+}
+
+func TestBuildKeyDependsOnDependencyTarget(t *testing.T) {
+	g1 := &Group{
+		ID:      "upstream",
+		Builder: "docker:go",
+		Build:   Build{Dependencies: Dependencies{{Module: "github.com/libp2p/go-libp2p", Version: "v0.15.0"}}},
+	}
+
+	g2 := &Group{
+		ID:      "fork",
+		Builder: "docker:go",
+		Build:   Build{Dependencies: Dependencies{{Module: "github.com/libp2p/go-libp2p", Target: "github.com/user/go-libp2p", Version: "v0.15.0"}}},
+	}
+
+	require.NotEqualValues(t, g1.BuildKey(), g2.BuildKey())
+}
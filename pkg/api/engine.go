@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"io"
 	"time"
 
@@ -10,6 +11,10 @@ import (
 	"github.com/testground/testground/pkg/task"
 )
 
+// ErrTestCaseNotFound is returned when a request selects a test case that the
+// manifest of the test plan doesn't declare.
+var ErrTestCaseNotFound = errors.New("test case not found")
+
 type ComponentType string
 
 const (
@@ -44,6 +49,8 @@ type TasksFilters struct {
 	Before   *time.Time
 	TestPlan string
 	TestCase string
+	// Labels restricts the tasks to those carrying all these labels.
+	Labels map[string]string
 }
 
 type Engine interface {
@@ -55,14 +62,23 @@ type Engine interface {
 	ListBuilders() map[string]Builder
 	ListRunners() map[string]Runner
 
+	DescribeBuilders() []ComponentDescription
+	DescribeRunners() []ComponentDescription
+
 	QueueBuild(request *BuildRequest, sources *UnpackedSources) (string, error)
 	QueueRun(request *RunRequest, sources *UnpackedSources) (string, error)
 
 	DoBuildPurge(ctx context.Context, builder, plan string, ow *rpc.OutputWriter) error
 	DoCollectOutputs(ctx context.Context, runID string, ow *rpc.OutputWriter) error
 	DoTerminate(ctx context.Context, ctype ComponentType, ref string, ow *rpc.OutputWriter) error
+	DoPrune(ctx context.Context, runner string, ow *rpc.OutputWriter) (*PruneReport, error)
 	DoHealthcheck(ctx context.Context, runner string, fix bool, ow *rpc.OutputWriter) (*HealthcheckReport, error)
 
+	// Ready checks that the engine can serve requests: that its task storage
+	// is usable and, if a runner is supplied, that its infrastructure is
+	// reachable.
+	Ready(ctx context.Context, runner string) error
+
 	EnvConfig() config.EnvConfig
 	Context() context.Context
 }
@@ -70,6 +86,7 @@ type Engine interface {
 type TasksManager interface {
 	Tasks(filters TasksFilters) ([]task.Task, error)
 	GetTask(id string) (*task.Task, error)
+	QueueStatus(id string) (*QueueStatus, error)
 	Kill(taskId string) error
 	DeleteTask(taskId string) error
 	Logs(ctx context.Context, taskId string, follow bool, cancel bool, w io.Writer) (*task.Task, error)
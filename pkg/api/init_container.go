@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// reservedInitContainers are the names of the init containers that runners
+// creating pods run first, to wait for the sidecar and to prepare the outputs
+// directory of instances.
+var reservedInitContainers = map[string]struct{}{
+	"wait-for-sidecar": {},
+	"mkdir-outputs":    {},
+}
+
+// initContainerNamePattern matches valid container names, which are DNS
+// labels.
+var initContainerNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// InitContainer is a setup step that runs before each instance of a group,
+// e.g. fetching fixtures or warming a cache. Init containers run in order,
+// with the environment and the volumes of the instance. Only the cluster:k8s
+// runner supports them.
+type InitContainer struct {
+	// Name is the name of the container, unique within the group.
+	Name string `toml:"name" json:"name"`
+	// Image is the image the container runs.
+	Image string `toml:"image" json:"image"`
+	// Command is the command the container runs, overriding the entrypoint
+	// of the image.
+	Command []string `toml:"command" json:"command"`
+}
+
+// ValidateInitContainers checks that init containers are complete, and that
+// their names are valid and unique, and not reserved.
+func ValidateInitContainers(ics []InitContainer) error {
+	names := make(map[string]struct{}, len(ics))
+	for _, ic := range ics {
+		switch {
+		case !initContainerNamePattern.MatchString(ic.Name):
+			return fmt.Errorf("invalid init container name %q", ic.Name)
+		case isReservedInitContainer(ic.Name):
+			return fmt.Errorf("init container name %q is reserved", ic.Name)
+		case ic.Image == "":
+			return fmt.Errorf("init container %s has no image", ic.Name)
+		case len(ic.Command) == 0:
+			return fmt.Errorf("init container %s has no command", ic.Name)
+		}
+		if _, ok := names[ic.Name]; ok {
+			return fmt.Errorf("duplicate init container %s", ic.Name)
+		}
+		names[ic.Name] = struct{}{}
+	}
+	return nil
+}
+
+func isReservedInitContainer(name string) bool {
+	_, ok := reservedInitContainers[name]
+	return ok
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestValidateInitContainers(t *testing.T) {
+	fixtures := InitContainer{Name: "fetch-fixtures", Image: "busybox", Command: []string{"wget", "http://fixtures/data"}}
+
+	var tests = []struct {
+		name     string
+		ics      []InitContainer
+		hasError bool
+	}{
+		{"none", nil, false},
+		{"valid", []InitContainer{fixtures, {Name: "warm-cache", Image: "redis", Command: []string{"true"}}}, false},
+		{"duplicate", []InitContainer{fixtures, fixtures}, true},
+		{"reserved", []InitContainer{{Name: "mkdir-outputs", Image: "busybox", Command: []string{"true"}}}, true},
+		{"invalid name", []InitContainer{{Name: "Fetch_Fixtures", Image: "busybox", Command: []string{"true"}}}, true},
+		{"no image", []InitContainer{{Name: "fetch", Command: []string{"true"}}}, true},
+		{"no command", []InitContainer{{Name: "fetch", Image: "busybox"}}, true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateInitContainers(tt.ics); (err != nil) != tt.hasError {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.hasError, err)
+		}
+	}
+}
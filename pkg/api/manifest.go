@@ -24,6 +24,26 @@ type TestPlanManifest struct {
 	//
 	// It's a mapping of builder => directories.
 	ExtraSources map[string][]string `toml:"extra_sources"`
+
+	// RunnerDefaults are the defaults the plan recommends for each runner, by
+	// runner ID.
+	RunnerDefaults map[string]RunnerDefaults `toml:"runner_defaults"`
+}
+
+// RunnerDefaults are the defaults a test plan recommends for a runner.
+type RunnerDefaults struct {
+	// Config is the configuration of the runner for this plan. Like Runners,
+	// it overrides the env configuration and may set fields that are not
+	// overridable; the composition overrides it.
+	Config config.ConfigMap `toml:"config"`
+
+	// Resources are the resources each instance of the plan needs. Runs
+	// requesting less are warned about.
+	Resources Resources `toml:"resources"`
+
+	// InitContainers are the setup steps run before each instance of the
+	// plan, for groups that don't declare their own.
+	InitContainers []InitContainer `toml:"init_containers"`
 }
 
 // TestCase represents a configuration for a test case known by the system.
@@ -40,6 +60,9 @@ type Parameter struct {
 	Description string `toml:"desc"`
 	Unit        string
 	Default     interface{}
+	// Required parameters must be set by compositions, unless they have a
+	// default.
+	Required bool `toml:"required"`
 }
 
 // InstanceConstraints expresses how many instances this test case can run.
@@ -142,6 +165,12 @@ func (tp *TestPlanManifest) Describe(w io.Writer) {
 	}()
 	p(w, "It can be run with strategies: %v.", rs)
 
+	for _, r := range rs {
+		if res := tp.RunnerDefaults[r].Resources; res != (Resources{}) {
+			p(w, "On %s, it recommends cpu %q and memory %q per instance.", r, res.CPU, res.Memory)
+		}
+	}
+
 	p(w, "It has %d test cases.", len(tp.TestCases))
 }
 
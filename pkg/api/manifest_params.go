@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckParams validates test parameters against the parameters declared by
+// this test case. It errors on undeclared parameters and on values that don't
+// match the declared type, and returns the names of required parameters that
+// are missing and have no default.
+//
+// Supported types are int, float, bool, duration, json and string; values of
+// other declared types are not checked.
+func (tc *TestCase) CheckParams(params map[string]string) (missing []string, err error) {
+	for name, value := range params {
+		p, ok := tc.Parameters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown parameter %q for test case %s", name, tc.Name)
+		}
+		if err := checkParamType(p.Type, value); err != nil {
+			return nil, fmt.Errorf("invalid value for parameter %q of test case %s: %w", name, tc.Name, err)
+		}
+	}
+
+	for name, p := range tc.Parameters {
+		if _, ok := params[name]; !ok && p.Required && p.Default == nil {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+func checkParamType(typ string, value string) error {
+	var err error
+	switch strings.ToLower(typ) {
+	case "int":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "float":
+		_, err = strconv.ParseFloat(value, 64)
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	case "duration":
+		_, err = time.ParseDuration(value)
+	case "json":
+		if !json.Valid([]byte(value)) {
+			err = fmt.Errorf("not valid JSON")
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("expected %s, got %q", typ, value)
+	}
+	return nil
+}
+
+// CheckParams validates the test parameters set anywhere in this composition
+// against the parameters declared by its test case in the manifest. It
+// returns warnings for required parameters that are missing from a run group.
+func (c *Composition) CheckParams(manifest *TestPlanManifest) (warnings []string, err error) {
+	_, tc, ok := manifest.TestCaseByName(c.Global.Case)
+	if !ok {
+		return nil, fmt.Errorf("test case %s not found in plan %s", c.Global.Case, manifest.Name)
+	}
+
+	comp := c.GenerateDefaultRun()
+
+	var global map[string]string
+	if comp.Global.Run != nil {
+		global = comp.Global.Run.TestParams
+	}
+
+	for _, r := range comp.Runs {
+		for _, g := range r.Groups {
+			// params trickle down from the global run, the group, and the
+			// run, to the run group.
+			params := make(map[string]string)
+			sources := []map[string]string{global, nil, r.TestParams, g.TestParams}
+			if grp, err := comp.GetGroup(g.EffectiveGroupId()); err == nil {
+				sources[1] = grp.Run.TestParams
+			}
+			for _, src := range sources {
+				for k, v := range src {
+					params[k] = v
+				}
+			}
+
+			missing, err := tc.CheckParams(params)
+			if err != nil {
+				return nil, fmt.Errorf("run %s, group %s: %w", r.ID, g.ID, err)
+			}
+			for _, name := range missing {
+				warnings = append(warnings, fmt.Sprintf("run %s, group %s: missing required parameter %q", r.ID, g.ID, name))
+			}
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func paramsTestManifest() *TestPlanManifest {
+	return &TestPlanManifest{
+		Name: "plan",
+		TestCases: []*TestCase{{
+			Name: "case",
+			Parameters: map[string]Parameter{
+				"count":   {Type: "int", Default: 1},
+				"timeout": {Type: "duration", Required: true},
+				"name":    {Type: "string", Required: true, Default: "peer"},
+				"enabled": {Type: "bool"},
+			},
+		}},
+	}
+}
+
+func TestTestCaseCheckParams(t *testing.T) {
+	tc := paramsTestManifest().TestCases[0]
+
+	missing, err := tc.CheckParams(map[string]string{"count": "3", "enabled": "true"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"timeout"}, missing)
+
+	missing, err = tc.CheckParams(map[string]string{"timeout": "10s"})
+	require.NoError(t, err)
+	require.Empty(t, missing)
+
+	_, err = tc.CheckParams(map[string]string{"cuont": "3"})
+	require.Error(t, err)
+
+	_, err = tc.CheckParams(map[string]string{"count": "three"})
+	require.Error(t, err)
+
+	_, err = tc.CheckParams(map[string]string{"timeout": "10"})
+	require.Error(t, err)
+}
+
+func TestCompositionCheckParams(t *testing.T) {
+	manifest := paramsTestManifest()
+
+	c := &Composition{
+		Global: Global{
+			Plan: "plan",
+			Case: "case",
+			Run:  &RunParams{TestParams: map[string]string{"count": "2"}},
+		},
+		Groups: Groups{
+			{ID: "a", Run: RunParams{TestParams: map[string]string{"timeout": "1m"}}},
+			{ID: "b"},
+		},
+	}
+
+	warnings, err := c.CheckParams(manifest)
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	require.Contains(t, warnings[0], "group b")
+
+	c.Groups[1].Run.TestParams = map[string]string{"timeout": "1m", "enabeld": "true"}
+	_, err = c.CheckParams(manifest)
+	require.Error(t, err)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PVCMountPrefix prefixes mount sources that refer to a path within a
+// Kubernetes persistent volume claim, e.g. pvc://fixtures/datasets.
+const PVCMountPrefix = "pvc://"
+
+// Mount is an additional read-only volume mounted into plan instances.
+type Mount struct {
+	// Source is an absolute path on the host, or a path within a persistent
+	// volume claim (pvc://<claim>/<path>).
+	Source string
+	// Target is the absolute path within the instance.
+	Target string
+}
+
+// ParseMount parses a mount declared in a composition, in the
+// source:target[:ro] format.
+func ParseMount(s string) (Mount, error) {
+	// pvc sources contain a colon themselves.
+	prefix := ""
+	if strings.HasPrefix(s, PVCMountPrefix) {
+		prefix, s = PVCMountPrefix, strings.TrimPrefix(s, PVCMountPrefix)
+	}
+
+	parts := strings.Split(s, ":")
+	switch {
+	case len(parts) == 3 && parts[2] == "ro":
+	case len(parts) == 2:
+	default:
+		return Mount{}, fmt.Errorf("invalid mount %q: expected source:target[:ro]", prefix+s)
+	}
+
+	source, target := parts[0], parts[1]
+	if prefix != "" {
+		if source == "" || path.Clean("/"+source) != "/"+source {
+			return Mount{}, fmt.Errorf("invalid mount %q: malformed claim path", prefix+s)
+		}
+		source = prefix + source
+	} else {
+		if !filepath.IsAbs(source) {
+			return Mount{}, fmt.Errorf("invalid mount %q: source must be an absolute path", s)
+		}
+		source = filepath.Clean(source)
+	}
+
+	if !path.IsAbs(target) {
+		return Mount{}, fmt.Errorf("invalid mount %q: target must be an absolute path", prefix+s)
+	}
+	return Mount{Source: source, Target: path.Clean(target)}, nil
+}
+
+// IsPVC returns whether the source of this mount is a persistent volume
+// claim.
+func (m Mount) IsPVC() bool {
+	return strings.HasPrefix(m.Source, PVCMountPrefix)
+}
+
+// PVC splits the source of a persistent volume claim mount into the claim
+// name and the path within it.
+func (m Mount) PVC() (claim string, subPath string) {
+	s := strings.TrimPrefix(m.Source, PVCMountPrefix)
+	if i := strings.Index(s, "/"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// Resolve resolves the symbolic links in the source of a host mount, so that
+// a symlink under an allowed root can't point anywhere else on the host.
+// Sources that don't exist on this host, e.g. host paths on the nodes of a
+// cluster, are returned as they are.
+func (m Mount) Resolve() (Mount, error) {
+	if m.IsPVC() {
+		return m, nil
+	}
+	src, err := filepath.EvalSymlinks(m.Source)
+	switch {
+	case os.IsNotExist(err):
+		return m, nil
+	case err != nil:
+		return Mount{}, fmt.Errorf("failed to resolve mount source %s: %w", m.Source, err)
+	}
+	m.Source = src
+	return m, nil
+}
+
+// ResolveMountRoots resolves the symbolic links in the host paths of the
+// supplied mount roots, for resolved mounts to be checked against them.
+func ResolveMountRoots(roots []string) []string {
+	res := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if !strings.HasPrefix(root, PVCMountPrefix) {
+			if r, err := filepath.EvalSymlinks(root); err == nil {
+				root = r
+			}
+		}
+		res = append(res, root)
+	}
+	return res
+}
+
+// Within returns whether the source of this mount lies within one of the
+// supplied roots. The check is lexical; resolve the mount and the roots
+// first.
+func (m Mount) Within(roots []string) bool {
+	for _, root := range roots {
+		root = strings.TrimSuffix(root, "/")
+		if root == "" || (root+"/" == PVCMountPrefix) {
+			continue
+		}
+		if m.Source == root || strings.HasPrefix(m.Source, root+"/") {
+			return true
+		}
+	}
+	return false
+}
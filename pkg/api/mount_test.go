@@ -0,0 +1,98 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMount(t *testing.T) {
+	var tests = []struct {
+		mount    string
+		expected Mount
+		hasError bool
+	}{
+		{"/data/fixtures:/fixtures", Mount{"/data/fixtures", "/fixtures"}, false},
+		{"/data/fixtures/:/fixtures/:ro", Mount{"/data/fixtures", "/fixtures"}, false},
+		{"pvc://datasets/large:/fixtures", Mount{"pvc://datasets/large", "/fixtures"}, false},
+		{"pvc://datasets:/fixtures:ro", Mount{"pvc://datasets", "/fixtures"}, false},
+		{"/data/fixtures:/fixtures:rw", Mount{}, true},
+		{"data/fixtures:/fixtures", Mount{}, true},
+		{"/data/fixtures:fixtures", Mount{}, true},
+		{"pvc://datasets/../other:/fixtures", Mount{}, true},
+		{"/data/fixtures", Mount{}, true},
+	}
+
+	for _, tt := range tests {
+		m, err := ParseMount(tt.mount)
+		if (err != nil) != tt.hasError {
+			t.Errorf("%q: expected error %v, got %v", tt.mount, tt.hasError, err)
+			continue
+		}
+		if m != tt.expected {
+			t.Errorf("%q: expected %+v, got %+v", tt.mount, tt.expected, m)
+		}
+	}
+}
+
+func TestMountWithin(t *testing.T) {
+	roots := []string{"/data/", "pvc://datasets"}
+
+	var tests = []struct {
+		source string
+		within bool
+	}{
+		{"/data", true},
+		{"/data/fixtures", true},
+		{"/database", false},
+		{"/etc", false},
+		{"pvc://datasets/large", true},
+		{"pvc://datasets-private", false},
+	}
+
+	for _, tt := range tests {
+		if got := (Mount{Source: tt.source}).Within(roots); got != tt.within {
+			t.Errorf("%s: expected within=%v, got %v", tt.source, tt.within, got)
+		}
+	}
+
+	if (Mount{Source: "/data"}).Within(nil) {
+		t.Error("expected no mounts to be allowed without roots")
+	}
+}
+
+func TestMountResolve(t *testing.T) {
+	dir := t.TempDir()
+	roots := ResolveMountRoots([]string{filepath.Join(dir, "data")})
+
+	if err := os.MkdirAll(filepath.Join(dir, "data", "fixtures"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "secret"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(dir, "data", "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Mount{Source: filepath.Join(dir, "data", "fixtures")}.Resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Within(roots) {
+		t.Errorf("expected %s to be within %v", m.Source, roots)
+	}
+
+	m, err = Mount{Source: filepath.Join(dir, "data", "escape")}.Resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Within(roots) {
+		t.Errorf("expected the symlink %s to escape %v", m.Source, roots)
+	}
+
+	missing := Mount{Source: "/nonexistent/fixtures"}
+	if m, err := missing.Resolve(); err != nil || m != missing {
+		t.Errorf("expected a missing source to be returned as is, got %+v, %v", m, err)
+	}
+}
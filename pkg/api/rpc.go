@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"time"
 
 	"github.com/testground/testground/pkg/task"
 )
@@ -21,6 +22,13 @@ type BuildRequest struct {
 	Composition Composition      `json:"composition"`
 	Manifest    TestPlanManifest `json:"manifest"`
 	CreatedBy   CreatedBy        `json:"created_by"`
+	// Callback is an optional URL the daemon POSTs a TaskCallback to once the
+	// task completes.
+	Callback string `json:"callback,omitempty"`
+	// IdempotencyKey is taken from the IdempotencyKeyHeader of the request.
+	IdempotencyKey string `json:"-"`
+	// RequestID is taken from the RequestIDHeader of the request.
+	RequestID string `json:"-"`
 }
 
 // RunRequest is the request struct for the `run` function.
@@ -31,8 +39,38 @@ type RunRequest struct {
 	Composition Composition      `json:"composition"`
 	Manifest    TestPlanManifest `json:"manifest"`
 	CreatedBy   CreatedBy        `json:"created_by"`
+	// Callback is an optional URL the daemon POSTs a TaskCallback to once the
+	// task completes.
+	Callback string `json:"callback,omitempty"`
+	// Labels are arbitrary key-value pairs recorded on the task, by which
+	// tasks can be filtered later on.
+	Labels map[string]string `json:"labels,omitempty"`
+	// TestCases are the test cases of the plan to run the composition for,
+	// instead of its own. The cases are run as the cells of an experiment,
+	// sharing the build of the composition.
+	TestCases []string `json:"test_cases,omitempty"`
+	// AllowUnderResourced runs groups requesting dangerously little resources
+	// compared to what the test plan recommends, rather than failing the run.
+	AllowUnderResourced bool `json:"allow_underresourced,omitempty"`
+	// IdempotencyKey is taken from the IdempotencyKeyHeader of the request.
+	IdempotencyKey string `json:"-"`
+	// RequestID is taken from the RequestIDHeader of the request.
+	RequestID string `json:"-"`
 }
 
+// IdempotencyKeyHeader is the header of build and run requests carrying the
+// idempotency key of the submission. The daemon resolves submissions with a
+// key it has seen already to the task created by the first one, instead of
+// queueing a duplicate. Keys are chosen by clients, e.g. with the
+// --idempotency-key flag; submissions without one are never deduplicated.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RequestIDHeader is the header carrying the ID of a request to the daemon.
+// The daemon assigns one unless the client supplied a valid one, and
+// propagates it to the tasks the request creates, down to the builders,
+// runners, plan instances and sidecars, so that their logs can be correlated.
+const RequestIDHeader = "X-Request-ID"
+
 type CreatedBy task.CreatedBy
 
 type OutputsRequest struct {
@@ -45,6 +83,10 @@ type TerminateRequest struct {
 	Builder string `json:"builder"`
 }
 
+type PruneRequest struct {
+	Runner string `json:"runner"`
+}
+
 type HealthcheckRequest struct {
 	Runner string `json:"runner"`
 	Fix    bool   `json:"fix"`
@@ -73,6 +115,15 @@ type LogsRequest struct {
 	CancelWithContext bool `json:"cancel_with_context"`
 }
 
+// SourcesRequest asks the daemon whether its source cache holds the plan
+// source with the supplied hash (see HashSourceDir). As the source can be
+// evicted before the build or run request referencing it arrives, the daemon
+// answers such requests with 412 Precondition Failed, and the client then
+// uploads the source.
+type SourcesRequest struct {
+	PlanHash string `json:"plan_hash"`
+}
+
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 // ~~~~~~ Response payloads ~~~~~~
 // ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -89,6 +140,34 @@ type CollectResponse struct {
 
 type HealthcheckResponse = HealthcheckReport
 
-type StatusResponse = task.Task
+type PruneResponse = PruneReport
+
+// StatusResponse is the response struct for the `status` function. Queue is
+// only set while the task is scheduled.
+type StatusResponse struct {
+	task.Task
+	Queue *QueueStatus `json:"queue,omitempty"`
+}
+
+// QueueStatus describes where a scheduled task stands in the queue.
+type QueueStatus struct {
+	// Position is the 1-based position of the task in the queue, out of
+	// Length tasks.
+	Position int `json:"position"`
+	Length   int `json:"length"`
+	// EstimatedWait is a rough estimate of the time until the task starts,
+	// based on the durations of recently completed tasks. It is unset when
+	// there is no history to base an estimate on.
+	EstimatedWait *time.Duration `json:"estimated_wait,omitempty"`
+}
 
 type LogsResponse = task.Task
+
+type SourcesResponse struct {
+	Cached bool `json:"cached"`
+}
+
+type ComponentsResponse struct {
+	Builders []ComponentDescription `json:"builders"`
+	Runners  []ComponentDescription `json:"runners"`
+}
@@ -2,10 +2,15 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/rpc"
+
+	"github.com/dustin/go-humanize"
 )
 
 // Runner is the interface to be implemented by all runners. A runner takes a
@@ -38,6 +43,11 @@ type RunInput struct {
 	// RunID is the run id assigned to this job by the Engine.
 	RunID string
 
+	// RequestID is the ID of the daemon request that created the run, if
+	// known. Runners label the instances with it, and pass it to them in the
+	// TEST_REQUEST_ID environment variable.
+	RequestID string
+
 	// EnvConfig is the env configuration of the engine. Not a pointer to force
 	// a copy.
 	EnvConfig config.EnvConfig
@@ -58,6 +68,20 @@ type RunInput struct {
 	// DisableMetrics disables metrics batching.
 	DisableMetrics bool
 
+	// AllowUnderResourced runs groups requesting dangerously little resources
+	// compared to their RecommendedResources, rather than failing the run.
+	AllowUnderResourced bool
+
+	// StartTime is the time at which the engine admitted the run, right
+	// before handing it to the runner; that is, after any builds and
+	// healthchecks completed. Runners must pass it unchanged to all
+	// instances as their TestStartTime, so that time-to-start measurements
+	// are comparable across runners.
+	StartTime time.Time
+
+	// Composition is the composition this run was prepared from.
+	Composition *Composition
+
 	// Groups enumerates the groups participating in this run.
 	Groups []*RunGroup
 }
@@ -72,6 +96,10 @@ type RunGroup struct {
 	// Resources for per instance in this group
 	Resources Resources
 
+	// RecommendedResources are the resources the test plan recommends for
+	// each instance, on this runner.
+	RecommendedResources Resources
+
 	// ArtifactPath can be a docker image ID or an executable path; it's
 	// runner-dependent.
 	ArtifactPath string
@@ -82,6 +110,18 @@ type RunGroup struct {
 	// Profiles specifies the profiles to capture. Refer to the docs
 	// on Run#Profiles for more info.
 	Profiles map[string]string
+
+	// Mounts are additional read-only volumes to mount into instances.
+	Mounts []Mount
+
+	// Role is the role of the instances, set as their TestInstanceRole.
+	Role string
+
+	// Dials are the IDs of the groups the instances connect to.
+	Dials []string
+
+	// InitContainers are setup steps run before each instance.
+	InitContainers []InitContainer
 }
 
 type RunOutput struct {
@@ -99,6 +139,18 @@ type RunOutput struct {
 	// -- Kubernetes pod Status
 	// -- etc.
 	Result interface{}
+
+	// Done is set by runners that return as soon as the run has started,
+	// rather than when it completes (e.g. local:docker in background mode).
+	// It delivers the final output of the run once it completes.
+	Done <-chan RunCompletion
+}
+
+// RunCompletion is the final output of a run that completed after its runner
+// returned.
+type RunCompletion struct {
+	Output *RunOutput
+	Err    error
 }
 
 type CollectionInput struct {
@@ -113,8 +165,66 @@ type CollectionInput struct {
 	RunnerConfig interface{}
 }
 
+// ConfigValidator is the interface to be implemented by runner
+// configurations that can check their values when a run is submitted, rather
+// than when it starts.
+type ConfigValidator interface {
+	Validate() error
+}
+
 // Terminatable is the interface to be implemented by a runner that can be
 // terminated.
 type Terminatable interface {
 	TerminateAll(context.Context, *rpc.OutputWriter) error
 }
+
+// Pinger is the interface to be implemented by a runner that can cheaply
+// check that its infrastructure is reachable, without running its
+// healthchecks.
+type Pinger interface {
+	Ping(context.Context) error
+}
+
+// Prunable is the interface to be implemented by a runner that can reclaim
+// resources orphaned by previous runs, such as data networks, dangling images,
+// and completed plan containers or pods.
+type Prunable interface {
+	Prune(context.Context, *rpc.OutputWriter) (*PruneReport, error)
+}
+
+// PruneReport enumerates the resources reclaimed by a prune operation.
+type PruneReport struct {
+	// Networks are the names of the networks that were removed.
+	Networks []string
+	// Images are the IDs of the images that were removed.
+	Images []string
+	// Containers are the IDs of the containers or pods that were removed.
+	Containers []string
+	// SpaceReclaimed is the disk space freed, in bytes, if known.
+	SpaceReclaimed uint64
+}
+
+func (pr *PruneReport) String() string {
+	b := new(strings.Builder)
+
+	section := func(title string, items []string) {
+		if len(items) == 0 {
+			fmt.Fprintf(b, "No %s removed.\n", title)
+			return
+		}
+		fmt.Fprintf(b, "Removed %s:\n", title)
+		for _, it := range items {
+			fmt.Fprintf(b, "- %s\n", it)
+		}
+	}
+
+	section("networks", pr.Networks)
+	section("images", pr.Images)
+	section("containers", pr.Containers)
+
+	if pr.SpaceReclaimed > 0 {
+		fmt.Fprintf(b, "Total reclaimed space: %s\n", humanize.Bytes(pr.SpaceReclaimed))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// HashSourceDir returns a hash of the contents of a source directory: the
+// relative paths, types and executable bits of its entries, the contents of
+// its files, and the targets of its symlinks. Modification times are
+// disregarded, so the hash survives the source being zipped and unpacked.
+func HashSourceDir(dir string) (string, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(p string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, p := range paths {
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return "", err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch mode := fi.Mode(); {
+		case mode.IsDir():
+			fmt.Fprintf(h, "d %s\x00", rel)
+		case mode&os.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "l %s\x00%s\x00", rel, target)
+		case mode.IsRegular():
+			fmt.Fprintf(h, "f %s\x00%t\x00%d\x00", rel, mode&0111 != 0, fi.Size())
+			if err := hashFile(h, p); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
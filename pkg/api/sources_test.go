@@ -0,0 +1,63 @@
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashSourceDir(t *testing.T) {
+	write := func(dir, name, content string, mode os.FileMode) {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, b := t.TempDir(), t.TempDir()
+	for _, dir := range []string{a, b} {
+		write(dir, "main.go", "package main", 0644)
+		write(dir, "sub/run.sh", "#!/bin/sh", 0755)
+	}
+
+	// modification times don't matter.
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(b, "main.go"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	ha, err := HashSourceDir(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, err := HashSourceDir(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ha != hb {
+		t.Errorf("expected identical hashes, got %s and %s", ha, hb)
+	}
+
+	// contents, paths and executable bits do.
+	changes := []func(dir string){
+		func(dir string) { write(dir, "main.go", "package other", 0644) },
+		func(dir string) { write(dir, "extra.go", "", 0644) },
+		func(dir string) { _ = os.Chmod(filepath.Join(dir, "sub/run.sh"), 0644) },
+	}
+	for i, change := range changes {
+		change(b)
+		hc, err := HashSourceDir(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hc == hb {
+			t.Errorf("change %d did not alter the hash", i)
+		}
+		hb = hc
+	}
+}
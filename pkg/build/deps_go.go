@@ -2,18 +2,120 @@ package build
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/otiai10/copy"
+	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/rpc"
 )
 
+// DefaultSDKModule is the module path of the Go SDK that a linked SDK
+// replaces, unless the builder is configured with another one.
+const DefaultSDKModule = "github.com/testground/sdk-go"
+
+// goModReplaces returns the `go mod edit` flags that apply the dependency
+// overrides of a build, in module order. Overrides without a version replace
+// the module with a local directory. If sdkPath is not empty, the SDK module
+// sdkModule (or DefaultSDKModule) is replaced with it too.
+func goModReplaces(deps map[string]api.DependencyTarget, sdkModule, sdkPath string) []string {
+	mods := make([]string, 0, len(deps))
+	for mod := range deps {
+		mods = append(mods, mod)
+	}
+	sort.Strings(mods)
+
+	var replaces []string
+	for _, mod := range mods {
+		ver := deps[mod]
+		if ver.Target == "" {
+			ver.Target = mod
+		}
+		if ver.Version == "" {
+			replaces = append(replaces, fmt.Sprintf("-replace=%s=%s", mod, ver.Target))
+			continue
+		}
+		replaces = append(replaces, fmt.Sprintf("-replace=%s=%s@%s", mod, ver.Target, ver.Version))
+	}
+
+	if sdkPath != "" {
+		if sdkModule == "" {
+			sdkModule = DefaultSDKModule
+		}
+		replaces = append(replaces, fmt.Sprintf("-replace=%s=%s", sdkModule, sdkPath))
+	}
+	return replaces
+}
+
+// goModuleVersionRe matches the module@version pairs that go commands report
+// in their errors.
+var goModuleVersionRe = regexp.MustCompile(`[\w.~-]+\.[\w.~/-]+@v[\w.+-]+`)
+
+// checkGoModReplaces resolves the module graph of the plan in planSrc, whose
+// modfile already has the replace directives of a build, so that dependency
+// overrides that can't be resolved together fail before the image is built.
+// It works on a temporary copy of the modfile and its sum file, leaving them
+// untouched. If goproxy is not empty, it is used as the GOPROXY of the check.
+func checkGoModReplaces(ctx context.Context, planSrc, modfile, modfileSum, goproxy string) error {
+	tmp, err := ioutil.TempDir("", "testground-gomod-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for the go.mod check: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	tmpMod := filepath.Join(tmp, "go.mod")
+	if err := copy.Copy(filepath.Join(planSrc, modfile), tmpMod); err != nil {
+		return fmt.Errorf("failed to copy %s for the go.mod check: %w", modfile, err)
+	}
+	if err := copy.Copy(filepath.Join(planSrc, modfileSum), filepath.Join(tmp, "go.sum")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to copy %s for the go.mod check: %w", modfileSum, err)
+	}
+
+	cmd := exec.CommandContext(ctx, "go", "list", "-m", "-modfile="+tmpMod, "all")
+	cmd.Dir = planSrc
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	if goproxy != "" {
+		cmd.Env = append(cmd.Env, "GOPROXY="+goproxy)
+	}
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if mods := goModConflicts(string(out)); len(mods) > 0 {
+		return fmt.Errorf("dependency overrides can't be resolved; conflicting modules: %s; output: %s", strings.Join(mods, ", "), out)
+	}
+	return fmt.Errorf("dependency overrides can't be resolved: %w; output: %s", err, out)
+}
+
+// goModConflicts returns the modules, as module@version, that the output of a
+// failed go command mentions, in order of first appearance.
+func goModConflicts(out string) []string {
+	var (
+		seen = make(map[string]struct{})
+		mods []string
+	)
+	for _, m := range goModuleVersionRe.FindAllString(out, -1) {
+		m = strings.TrimRight(m, ".:")
+		if _, ok := seen[m]; ok {
+			continue
+		}
+		seen[m] = struct{}{}
+		mods = append(mods, m)
+	}
+	return mods
+}
+
 func parseDependencies(raw string) map[string]string {
 	rawModules := strings.Split(raw, "\n")
 	modules := map[string]string{}
@@ -1,9 +1,18 @@
 package build
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
 )
 
 var testParseDependencies = []struct {
@@ -49,3 +58,120 @@ func TestParseDependencies(t *testing.T) {
 		}
 	}
 }
+
+func TestGoModReplaces(t *testing.T) {
+	deps := map[string]api.DependencyTarget{
+		"github.com/libp2p/go-libp2p": {Target: "github.com/user/fork", Version: "v0.2.8"},
+		"github.com/ipfs/go-ipfs":     {Version: "v0.4.22"},
+		"example.com/local":           {Target: "../local"},
+	}
+
+	expected := []string{
+		"-replace=example.com/local=../local",
+		"-replace=github.com/ipfs/go-ipfs=github.com/ipfs/go-ipfs@v0.4.22",
+		"-replace=github.com/libp2p/go-libp2p=github.com/user/fork@v0.2.8",
+		"-replace=github.com/testground/sdk-go=../sdk",
+	}
+
+	if val := goModReplaces(deps, "", "../sdk"); !reflect.DeepEqual(val, expected) {
+		t.Errorf("expected %v, got %v", expected, val)
+	}
+	if val := goModReplaces(nil, "example.com/sdk", "../sdk"); !reflect.DeepEqual(val, []string{"-replace=example.com/sdk=../sdk"}) {
+		t.Errorf("expected a replace directive for the custom sdk module, got %v", val)
+	}
+	if val := goModReplaces(nil, "", ""); len(val) != 0 {
+		t.Errorf("expected no replace directives, got %v", val)
+	}
+}
+
+func TestGroupDependencyReplaces(t *testing.T) {
+	manifest := &api.TestPlanManifest{
+		Name:     "interop",
+		Builders: map[string]config.ConfigMap{"docker:go": {}},
+	}
+	comp := api.Composition{
+		Global: api.Global{
+			Plan:    "interop",
+			Builder: "docker:go",
+			Build: &api.Build{
+				Dependencies: api.Dependencies{{Module: "github.com/libp2p/go-libp2p", Version: "v0.14.0"}},
+			},
+		},
+		Groups: []*api.Group{
+			{ID: "older"},
+			{ID: "newer", Build: api.Build{
+				Dependencies: api.Dependencies{{Module: "github.com/libp2p/go-libp2p", Version: "v0.15.0"}},
+			}},
+		},
+	}
+
+	prepared, err := comp.PrepareForBuild(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	older, newer := prepared.Groups[0], prepared.Groups[1]
+
+	// groups with different dependencies are built separately.
+	if older.BuildKey() == newer.BuildKey() {
+		t.Errorf("groups with different dependencies have the same build key: %s", older.BuildKey())
+	}
+
+	expected := []string{"-replace=github.com/libp2p/go-libp2p=github.com/libp2p/go-libp2p@v0.14.0"}
+	if val := goModReplaces(older.Build.DependencyTargets(), "", ""); !reflect.DeepEqual(val, expected) {
+		t.Errorf("group older: expected %v, got %v", expected, val)
+	}
+	expected = []string{"-replace=github.com/libp2p/go-libp2p=github.com/libp2p/go-libp2p@v0.15.0"}
+	if val := goModReplaces(newer.Build.DependencyTargets(), "", ""); !reflect.DeepEqual(val, expected) {
+		t.Errorf("group newer: expected %v, got %v", expected, val)
+	}
+}
+
+func TestGoModConflicts(t *testing.T) {
+	out := `go: example.com/a@v1.2.0 requires
+	example.com/b@v0.3.0: reading example.com/b/go.mod at revision v0.3.0: unknown revision v0.3.0
+go: example.com/b@v0.3.0: missing go.sum entry`
+
+	expected := []string{"example.com/a@v1.2.0", "example.com/b@v0.3.0"}
+	if val := goModConflicts(out); !reflect.DeepEqual(val, expected) {
+		t.Errorf("expected %v, got %v", expected, val)
+	}
+	if val := goModConflicts("go: updates to go.mod needed"); len(val) != 0 {
+		t.Errorf("expected no modules, got %v", val)
+	}
+}
+
+func TestCheckGoModReplaces(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	dir := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("dep/go.mod", "module example.com/dep\n\ngo 1.16\n")
+	write("plan/go.mod", "module example.com/plan\n\ngo 1.16\n\nrequire example.com/dep v1.0.0\n\nreplace example.com/dep => ../dep\n")
+	write("plan/broken.mod", "module example.com/plan\n\ngo 1.16\n\nrequire example.com/dep v1.0.0\n\nreplace example.com/dep => ../missing\n")
+
+	planSrc := filepath.Join(dir, "plan")
+	if err := checkGoModReplaces(context.Background(), planSrc, "go.mod", "go.sum", "off"); err != nil {
+		t.Fatalf("expected overrides to resolve, got: %s", err)
+	}
+
+	err := checkGoModReplaces(context.Background(), planSrc, "broken.mod", "broken.sum", "off")
+	if err == nil || !strings.Contains(err.Error(), "example.com/dep@v1.0.0") {
+		t.Fatalf("expected an error naming the conflicting module, got: %v", err)
+	}
+
+	// the modfile is checked on a copy.
+	if _, err := os.Stat(filepath.Join(planSrc, "go.sum")); !os.IsNotExist(err) {
+		t.Errorf("expected the check to leave the plan sources untouched")
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"reflect"
 	"strings"
 	"sync"
-	"text/template"
 	"time"
 
 	"github.com/testground/testground/pkg/api"
@@ -29,13 +28,15 @@ const (
 	DefaultGoBuildBaseImage = "golang:1.16-buster"
 
 	buildNetworkName = "testground-build"
+
+	// buildIDLabel labels the images produced by a build with its ID, so that
+	// they can be found and removed if the build fails.
+	buildIDLabel = "testground.build_id"
 )
 
 var (
 	_ api.Builder      = &DockerGoBuilder{}
 	_ api.Terminatable = &DockerGoBuilder{}
-
-	goDockerfileTmpl = template.Must(template.New("Dockerfile").Parse(GoDockerfileTemplate))
 )
 
 // DockerGoBuilder builds the test plan as a go-based container.
@@ -66,6 +67,17 @@ type DockerGoBuilderConfig struct {
 	// Custom modfile
 	Modfile string `toml:"modfile"`
 
+	// SkipModCheck skips resolving the module graph with the dependency
+	// overrides of a build before building the image. The check runs the go
+	// tool of the daemon host, so it requires go and access to the module
+	// proxy there; with the "local" go_proxy_mode, the GOPROXY of the host is
+	// used (default: false).
+	SkipModCheck bool `toml:"skip_mod_check"`
+
+	// SDKModule is the module path that a linked SDK replaces (default:
+	// github.com/testground/sdk-go).
+	SDKModule string `toml:"sdk_module"`
+
 	// GoProxyMode specifies one of "local", "direct", "remote".
 	//
 	//   * The "local" mode (default) will start a proxy container (if one
@@ -113,6 +125,74 @@ type DockerGoBuilderConfig struct {
 
 	// DockefileExtensions enables plans to inject custom Dockerfile directives.
 	DockerfileExtensions DockerfileExtensions `toml:"dockerfile_extensions"`
+
+	// DockerfileTemplate is the path, relative to the plan directory, of a
+	// Dockerfile template replacing the default one of the builder. It is
+	// executed with DockerfileTemplateVars.
+	DockerfileTemplate string `toml:"dockerfile_template"`
+
+	// BuildMemoryMB caps the memory of the build containers, in MiB. Only
+	// the legacy builder supports it (default: 0, unlimited). Being a limit of
+	// the daemon, users can't override it.
+	BuildMemoryMB int64 `toml:"build_memory_mb" overridable:"no"`
+
+	// BuildCPUs caps the CPUs available to the build containers, e.g. 1.5.
+	// Only the legacy builder supports it (default: 0, unlimited). Being a
+	// limit of the daemon, users can't override it.
+	BuildCPUs float64 `toml:"build_cpus" overridable:"no"`
+
+	// UseBuildKit builds the image with BuildKit rather than the legacy
+	// builder, like DOCKER_BUILDKIT=1 does for the docker CLI. It can't be
+	// combined with enable_go_build_cache, and requires the "direct" or
+	// "remote" go_proxy_mode, as BuildKit can't attach builds to the
+	// testground-build network of the local proxy (default: false).
+	UseBuildKit bool `toml:"use_buildkit"`
+}
+
+// buildCPUPeriod is the CFS period that BuildCPUs is converted against.
+const buildCPUPeriod = 100000
+
+// validateBuildOptions checks that the resource limits and the builder
+// version of the configuration can be combined with its other options.
+func validateBuildOptions(cfg *DockerGoBuilderConfig) error {
+	if cfg.BuildMemoryMB < 0 || cfg.BuildCPUs < 0 {
+		return fmt.Errorf("build resource limits must not be negative")
+	}
+	if !cfg.UseBuildKit {
+		return nil
+	}
+	if cfg.EnableGoBuildCache {
+		return fmt.Errorf("unable to use go build cache with BuildKit")
+	}
+	if cfg.BuildMemoryMB > 0 || cfg.BuildCPUs > 0 {
+		return fmt.Errorf("build resource limits are not supported with BuildKit")
+	}
+	switch strings.TrimSpace(cfg.GoProxyMode) {
+	case "direct", "remote":
+		return nil
+	default:
+		return fmt.Errorf("BuildKit requires go_proxy_mode \"direct\" or \"remote\"; it can't use the build network of the local proxy")
+	}
+}
+
+// applyBuildLimits sets the resource limits and the builder version of a
+// configuration, checked with validateBuildOptions, on the supplied build
+// options.
+func applyBuildLimits(opts *types.ImageBuildOptions, cfg *DockerGoBuilderConfig) {
+	if cfg.UseBuildKit {
+		opts.Version = types.BuilderBuildKit
+		return
+	}
+
+	if cfg.BuildMemoryMB > 0 {
+		opts.Memory = cfg.BuildMemoryMB << 20
+		// no swap on top of the memory limit.
+		opts.MemorySwap = opts.Memory
+	}
+	if cfg.BuildCPUs > 0 {
+		opts.CPUPeriod = buildCPUPeriod
+		opts.CPUQuota = int64(cfg.BuildCPUs * buildCPUPeriod)
+	}
 }
 
 type DockerfileTemplateVars struct {
@@ -129,21 +209,41 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 	if !ok {
 		return nil, fmt.Errorf("expected configuration type DockerGoBuilderConfig, was: %T", in.BuildConfig)
 	}
+	if err := validateBuildOptions(cfg); err != nil {
+		return nil, err
+	}
 
 	cliopts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
 
-	var (
-		baseSrc = in.UnpackedSources.BaseDir
-		planDir = in.UnpackedSources.PlanDir
-		sdkSrc  = in.UnpackedSources.SDKDir
+	// Build from a temporary copy of the sources, so that concurrent builds
+	// don't race on the Dockerfile and the go.mod edits below.
+	sources, cleanup, err := isolateSources(in.UnpackedSources, in.BuildID)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
 
-		cli, err = client.NewClientWithOpts(cliopts...)
+	var (
+		baseSrc = sources.BaseDir
+		planDir = sources.PlanDir
+		sdkSrc  = sources.SDKDir
 	)
 
+	cli, err := client.NewClientWithOpts(cliopts...)
 	if err != nil {
 		return nil, err
 	}
 
+	// On failure or cancellation, remove the images of this build. The go
+	// proxy container and the build network are shared, so they are left
+	// alone. The sources, including the Dockerfile, are removed by cleanup.
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			removeBuildImages(cli, ow, in.BuildID)
+		}
+	}()
+
 	planSrc := filepath.Join(planDir, cfg.Path)
 
 	// Set up the go proxy wiring. This will start a goproxy container if
@@ -153,13 +253,6 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 		ow.Warnf("warning while setting up the go proxy: %s", warn)
 	}
 
-	// Write the Dockerfile.
-	dockerfileDst := filepath.Join(baseSrc, "Dockerfile")
-	f, err := os.Create(dockerfileDst)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Dockerfile at %s: %w", dockerfileDst, err)
-	}
-
 	cgoEnabled := 0
 	if cfg.EnableCGO {
 		cgoEnabled = 1
@@ -173,8 +266,10 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 		CgoEnabled:           cgoEnabled,
 	}
 
-	if err = goDockerfileTmpl.Execute(f, &vars); err != nil {
-		return nil, fmt.Errorf("failed to execute Dockerfile template and/or write into file %s: %w", dockerfileDst, err)
+	// Write the Dockerfile.
+	dockerfileDst := filepath.Join(baseSrc, "Dockerfile")
+	if err := writeDockerfile(dockerfileDst, b.ID(), planDir, cfg.DockerfileTemplate, &vars); err != nil {
+		return nil, err
 	}
 
 	// Custom Go modfiles configuration.
@@ -219,19 +314,13 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 		cfg.BuildBaseImage = DefaultGoBuildBaseImage
 	}
 
-	// If we have version overrides, apply them.
-	var replaces []string
-	for mod, ver := range in.Dependencies {
-		if ver.Target == "" {
-			ver.Target = mod
-		}
-		replaces = append(replaces, fmt.Sprintf("-replace=%s=%s@%s", mod, ver.Target, ver.Version))
-	}
-
-	// Inject replace directives for the SDK modules.
+	// If we have version overrides, apply them, and inject replace directives
+	// for the SDK modules.
+	var sdkReplace string
 	if sdkSrc != "" {
-		replaces = append(replaces, "-replace=github.com/testground/sdk-go=../sdk")
+		sdkReplace = "../sdk"
 	}
+	replaces := goModReplaces(in.Dependencies, cfg.SDKModule, sdkReplace)
 
 	// Write replace directives.
 	if len(replaces) > 0 {
@@ -241,12 +330,24 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 
 		cmd := exec.CommandContext(ctx, "go", append([]string{"mod", "edit"}, replaces...)...)
 		cmd.Dir = planSrc
-		if err = cmd.Run(); err != nil {
-			out, _ := cmd.CombinedOutput()
+		if out, err := cmd.CombinedOutput(); err != nil {
 			return nil, fmt.Errorf("unable to add replace directives to go.mod; %w; output: %s", err, string(out))
 		}
 	}
 
+	// Fail fast if the dependency overrides conflict, rather than deep into
+	// the image build.
+	if len(in.Dependencies) > 0 && !cfg.SkipModCheck {
+		var goproxy string
+		if mode := strings.TrimSpace(cfg.GoProxyMode); mode == "direct" || mode == "remote" {
+			goproxy = proxyURL
+		}
+		ow.Infow("checking dependency overrides", "dependencies", len(in.Dependencies))
+		if err := checkGoModReplaces(ctx, planSrc, modfile, modfileSum, goproxy); err != nil {
+			return nil, err
+		}
+	}
+
 	// initial go build args.
 	var args = map[string]*string{
 		"GO_PROXY":    &proxyURL,
@@ -294,7 +395,12 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 		Tags:        []string{in.BuildID},
 		BuildArgs:   args,
 		NetworkMode: "host",
+		Labels:      map[string]string{buildIDLabel: in.BuildID},
+		// remove intermediate containers, even if the build fails.
+		Remove:      true,
+		ForceRemove: true,
 	}
+	applyBuildLimits(&opts, cfg)
 
 	// If a docker network was created for the proxy, link it to the build container
 	if buildNetworkID != "" {
@@ -354,9 +460,31 @@ func (b *DockerGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc
 		return out, err
 	}
 
+	succeeded = true
 	return out, nil
 }
 
+// removeBuildImages removes the image tagged with the supplied build ID, and
+// any dangling images labelled with it.
+func removeBuildImages(cli *client.Client, ow *rpc.OutputWriter, buildID string) {
+	// the build context may be cancelled already.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := cli.ImageRemove(ctx, buildID, types.ImageRemoveOptions{Force: true, PruneChildren: true})
+	switch {
+	case err == nil:
+		ow.Infow("removed image of failed build", "build_id", buildID)
+	case !client.IsErrNotFound(err):
+		ow.Warnw("failed to remove image of failed build", "build_id", buildID, "error", err)
+	}
+
+	f := filters.NewArgs(filters.Arg("dangling", "true"), filters.Arg("label", buildIDLabel+"="+buildID))
+	if _, err := cli.ImagesPrune(ctx, f); err != nil {
+		ow.Warnw("failed to prune dangling images of failed build", "build_id", buildID, "error", err)
+	}
+}
+
 func (b *DockerGoBuilder) TerminateAll(ctx context.Context, ow *rpc.OutputWriter) error {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
@@ -567,6 +695,10 @@ func (b *DockerGoBuilder) Purge(ctx context.Context, testplan string, ow *rpc.Ou
 	return nil
 }
 
+func init() {
+	mustRegisterDockerfileTemplate("docker:go", GoDockerfileTemplate)
+}
+
 const GoDockerfileTemplate = `
 # BUILD_BASE_IMAGE is the base image to use for the build. It contains a rolling
 # accumulation of Go build/package caches.
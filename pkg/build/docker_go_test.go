@@ -0,0 +1,52 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestApplyBuildLimits(t *testing.T) {
+	var opts types.ImageBuildOptions
+	applyBuildLimits(&opts, &DockerGoBuilderConfig{BuildMemoryMB: 512, BuildCPUs: 1.5})
+	if opts.Memory != 512<<20 || opts.MemorySwap != opts.Memory {
+		t.Errorf("unexpected memory limits: %d, swap %d", opts.Memory, opts.MemorySwap)
+	}
+	if opts.CPUPeriod != 100000 || opts.CPUQuota != 150000 {
+		t.Errorf("unexpected cpu limits: period %d, quota %d", opts.CPUPeriod, opts.CPUQuota)
+	}
+	if opts.Version != "" {
+		t.Errorf("expected the default builder, got %q", opts.Version)
+	}
+
+	opts = types.ImageBuildOptions{}
+	applyBuildLimits(&opts, &DockerGoBuilderConfig{UseBuildKit: true, GoProxyMode: "direct"})
+	if opts.Version != types.BuilderBuildKit {
+		t.Errorf("expected BuildKit, got %q", opts.Version)
+	}
+}
+
+func TestValidateBuildOptions(t *testing.T) {
+	for _, cfg := range []*DockerGoBuilderConfig{
+		{BuildMemoryMB: 512, BuildCPUs: 1.5},
+		{UseBuildKit: true, GoProxyMode: "direct"},
+		{UseBuildKit: true, GoProxyMode: "remote", GoProxyURL: "http://proxy:8081"},
+	} {
+		if err := validateBuildOptions(cfg); err != nil {
+			t.Errorf("unexpected error for %+v: %s", *cfg, err)
+		}
+	}
+
+	for _, cfg := range []*DockerGoBuilderConfig{
+		{BuildMemoryMB: -1},
+		{UseBuildKit: true, GoProxyMode: "direct", EnableGoBuildCache: true},
+		{UseBuildKit: true, GoProxyMode: "direct", BuildCPUs: 2},
+		// the local proxy, the default, attaches builds to the build network.
+		{UseBuildKit: true},
+		{UseBuildKit: true, GoProxyMode: "local"},
+	} {
+		if err := validateBuildOptions(cfg); err == nil {
+			t.Errorf("expected an error for %+v", *cfg)
+		}
+	}
+}
@@ -3,9 +3,9 @@ package build
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -16,7 +16,8 @@ import (
 )
 
 const (
-	DefaultNodeBuildBaseImage = "node:16-buster"
+	DefaultNodeVersion        = "16"
+	DefaultNodeBuildBaseImage = "node:" + DefaultNodeVersion + "-buster"
 )
 
 var (
@@ -36,7 +37,15 @@ func (d DockerNodeBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rp
 	}
 
 	cliopts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
-	basesrc := in.UnpackedSources.BaseDir
+
+	// Build from a temporary copy of the sources, so that concurrent builds
+	// don't race on the Dockerfile.
+	sources, cleanup, err := isolateSources(in.UnpackedSources, in.BuildID)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	basesrc := sources.BaseDir
 
 	cli, err := client.NewClientWithOpts(cliopts...)
 	if err != nil {
@@ -45,19 +54,20 @@ func (d DockerNodeBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rp
 
 	// Write the Dockerfile.
 	dockerfileDst := filepath.Join(basesrc, "Dockerfile")
-	err = ioutil.WriteFile(dockerfileDst, []byte(NodeDockerfileTemplate), 0644)
+	err = writeDockerfile(dockerfileDst, d.ID(), sources.PlanDir, cfg.DockerfileTemplate, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Dockerfile at %s: %w", dockerfileDst, err)
+		return nil, err
 	}
 
-	// fall back to default build base image, if one is not configured explicitly.
-	if cfg.BaseImage == "" {
-		cfg.BaseImage = DefaultNodeBuildBaseImage
-	}
+	var (
+		baseImage = nodeBaseImage(cfg)
+		selectors = strings.Join(in.Selectors, ",")
+	)
 
 	// build args
 	var args = map[string]*string{
-		"BASE_IMAGE": &cfg.BaseImage,
+		"BASE_IMAGE": &baseImage,
+		"SELECTORS":  &selectors,
 	}
 
 	opts := types.ImageBuildOptions{
@@ -110,18 +120,54 @@ func (d DockerNodeBuilder) ConfigType() reflect.Type {
 	return reflect.TypeOf(DockerNodeBuilderConfig{})
 }
 
+// nodeBaseImage returns the image to build the plan from: the configured base
+// image, or else the buster image of the configured Node.js version.
+func nodeBaseImage(cfg *DockerNodeBuilderConfig) string {
+	if cfg.BaseImage != "" {
+		return cfg.BaseImage
+	}
+	if cfg.NodeVersion != "" {
+		return "node:" + cfg.NodeVersion + "-buster"
+	}
+	return DefaultNodeBuildBaseImage
+}
+
 type DockerNodeBuilderConfig struct {
-	Enabled   bool
+	Enabled bool
+
+	// BaseImage is the image the plan is built from. It takes precedence
+	// over NodeVersion.
 	BaseImage string `toml:"base_image"`
+
+	// NodeVersion is the version of Node.js the plan is built with, as a tag
+	// of the official node images, e.g. "18" or "16.20" (default: 16).
+	NodeVersion string `toml:"node_version"`
+
+	// DockerfileTemplate is the path, relative to the plan directory, of a
+	// Dockerfile template replacing the default one of the builder.
+	DockerfileTemplate string `toml:"dockerfile_template"`
+}
+
+func init() {
+	mustRegisterDockerfileTemplate("docker:node", NodeDockerfileTemplate)
 }
 
 const NodeDockerfileTemplate = `
 ARG BASE_IMAGE
 FROM ${BASE_IMAGE} AS builder
+
+# SELECTORS are the comma-separated selectors of the build, made available to
+# the install scripts and to the plan at runtime.
+ARG SELECTORS
+ENV TESTGROUND_SELECTORS ${SELECTORS}
+
 ENV PLAN_DIR /plan
 WORKDIR /plan
 COPY . /
-RUN npm ci
+
+# install the exact dependencies of the lockfile, if the plan has one.
+RUN if [ -f package-lock.json ] || [ -f npm-shrinkwrap.json ]; then npm ci; else npm install; fi
+
 EXPOSE 6060
 ENTRYPOINT [ "npm", "start"]
 `
@@ -0,0 +1,18 @@
+package build
+
+import "testing"
+
+func TestNodeBaseImage(t *testing.T) {
+	for _, c := range []struct {
+		cfg      DockerNodeBuilderConfig
+		expected string
+	}{
+		{DockerNodeBuilderConfig{}, DefaultNodeBuildBaseImage},
+		{DockerNodeBuilderConfig{NodeVersion: "18"}, "node:18-buster"},
+		{DockerNodeBuilderConfig{NodeVersion: "18", BaseImage: "my/node:latest"}, "my/node:latest"},
+	} {
+		if img := nodeBaseImage(&c.cfg); img != c.expected {
+			t.Errorf("base image of %+v: got %s, expected %s", c.cfg, img, c.expected)
+		}
+	}
+}
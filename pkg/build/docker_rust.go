@@ -0,0 +1,241 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/docker"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+const (
+	DefaultRustVersion      = "1.59"
+	DefaultRustRuntimeImage = "debian:bullseye-slim"
+	defaultRustBinName      = "testplan"
+)
+
+var (
+	_ api.Builder = &DockerRustBuilder{}
+)
+
+// DockerRustBuilder builds the test plan as a rust-based container.
+type DockerRustBuilder struct{}
+
+type DockerRustBuilderConfig struct {
+	Enabled bool
+
+	// Custom base path where we find the test source
+	Path string `toml:"path" default:"./"`
+
+	// BinName is the name of the binary target of the plan (default:
+	// testplan).
+	BinName string `toml:"bin_name"`
+
+	// RustVersion is the version of the Rust toolchain the plan is built
+	// with, as a tag of the official rust images, e.g. "1.65" (default: 1.59).
+	RustVersion string `toml:"rust_version"`
+
+	// BuildBaseImage is the image the plan is built from. It takes precedence
+	// over RustVersion.
+	BuildBaseImage string `toml:"build_base_image"`
+
+	// RuntimeImage is the runtime image that the test plan binary will be
+	// copied into. Defaults to debian:bullseye-slim.
+	RuntimeImage string `toml:"runtime_image"`
+
+	// DockerfileTemplate is the path, relative to the plan directory, of a
+	// Dockerfile template replacing the default one of the builder. It is
+	// executed with DockerRustTemplateVars.
+	DockerfileTemplate string `toml:"dockerfile_template"`
+}
+
+type DockerRustTemplateVars struct {
+	BinName string
+}
+
+// Build builds a testplan written in Rust and outputs a Docker container. The
+// selectors of the build are enabled as cargo features.
+//
+// The image is built with BuildKit, which caches the cargo registry across
+// builds, as the goproxy volume does the Go modules for the docker:go builder.
+func (b *DockerRustBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc.OutputWriter) (*api.BuildOutput, error) {
+	cfg, ok := in.BuildConfig.(*DockerRustBuilderConfig)
+	if !ok {
+		return nil, fmt.Errorf("expected configuration type DockerRustBuilderConfig, was: %T", in.BuildConfig)
+	}
+
+	cliopts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+
+	// Build from a temporary copy of the sources, so that concurrent builds
+	// don't race on the Dockerfile.
+	sources, cleanup, err := isolateSources(in.UnpackedSources, in.BuildID)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	cli, err := client.NewClientWithOpts(cliopts...)
+	if err != nil {
+		return nil, err
+	}
+
+	binName := cfg.BinName
+	if binName == "" {
+		binName = defaultRustBinName
+	}
+
+	// Write the Dockerfile.
+	dockerfileDst := filepath.Join(sources.BaseDir, "Dockerfile")
+	vars := &DockerRustTemplateVars{BinName: binName}
+	if err := writeDockerfile(dockerfileDst, b.ID(), sources.PlanDir, cfg.DockerfileTemplate, vars); err != nil {
+		return nil, err
+	}
+
+	var (
+		baseImage    = rustBaseImage(cfg)
+		runtimeImage = cfg.RuntimeImage
+		features     = cargoFeatures(in.Selectors)
+	)
+	if runtimeImage == "" {
+		runtimeImage = DefaultRustRuntimeImage
+	}
+
+	// build args
+	var args = map[string]*string{
+		"BUILD_BASE_IMAGE": &baseImage,
+		"RUNTIME_IMAGE":    &runtimeImage,
+		"PLAN_PATH":        &cfg.Path,
+		"CARGO_FEATURES":   &features,
+	}
+
+	opts := types.ImageBuildOptions{
+		Tags:        []string{in.BuildID},
+		BuildArgs:   args,
+		NetworkMode: "host",
+		Labels:      map[string]string{buildIDLabel: in.BuildID},
+		// the cache mounts of the cargo registry require BuildKit.
+		Version: types.BuilderBuildKit,
+	}
+
+	imageOpts := docker.BuildImageOpts{
+		BuildCtx:  sources.BaseDir,
+		BuildOpts: &opts,
+	}
+
+	buildStart := time.Now()
+
+	_, err = docker.BuildImage(ctx, ow, cli, &imageOpts)
+	if err != nil {
+		return nil, fmt.Errorf("docker build failed: %w", err)
+	}
+
+	ow.Infow("build completed", "default_tag", fmt.Sprintf("%s:latest", in.BuildID), "took", time.Since(buildStart).Truncate(time.Second))
+
+	imageID, err := docker.GetImageID(ctx, cli, in.BuildID)
+	if err != nil {
+		return nil, fmt.Errorf("couldnt get docker image id: %w", err)
+	}
+
+	ow.Infow("got docker image id", "image_id", imageID)
+
+	out := &api.BuildOutput{
+		ArtifactPath: imageID,
+	}
+
+	// Testplan image tag
+	testplanImageTag := fmt.Sprintf("%s:%s", in.TestPlan, imageID)
+
+	ow.Infow("tagging image", "image_id", imageID, "tag", testplanImageTag)
+	if err = cli.ImageTag(ctx, out.ArtifactPath, testplanImageTag); err != nil {
+		return out, err
+	}
+
+	return out, err
+}
+
+func (*DockerRustBuilder) ID() string {
+	return "docker:rust"
+}
+
+func (*DockerRustBuilder) ConfigType() reflect.Type {
+	return reflect.TypeOf(DockerRustBuilderConfig{})
+}
+
+func (*DockerRustBuilder) Purge(ctx context.Context, testplan string, ow *rpc.OutputWriter) error {
+	return fmt.Errorf("purge not implemented for docker:rust")
+}
+
+// rustBaseImage returns the image to build the plan from: the configured base
+// image, or else the image of the configured Rust toolchain.
+func rustBaseImage(cfg *DockerRustBuilderConfig) string {
+	if cfg.BuildBaseImage != "" {
+		return cfg.BuildBaseImage
+	}
+	version := cfg.RustVersion
+	if version == "" {
+		version = DefaultRustVersion
+	}
+	return "rust:" + version + "-bullseye"
+}
+
+// cargoFeatures returns the cargo flag enabling the supplied selectors as
+// features, if any.
+func cargoFeatures(selectors []string) string {
+	if len(selectors) == 0 {
+		return ""
+	}
+	return "--features " + strings.Join(selectors, ",")
+}
+
+func init() {
+	mustRegisterDockerfileTemplate("docker:rust", RustDockerfileTemplate)
+}
+
+const RustDockerfileTemplate = `
+# BUILD_BASE_IMAGE is the rust image to build the plan with.
+ARG BUILD_BASE_IMAGE
+
+# RUNTIME_IMAGE is the image onto which to copy the resulting binary.
+ARG RUNTIME_IMAGE
+
+#:::
+#::: BUILD CONTAINER
+#:::
+FROM ${BUILD_BASE_IMAGE} AS builder
+
+# PLAN_PATH is the path of our test's source code.
+ARG PLAN_PATH
+
+# CARGO_FEATURES is either nothing, or when expanded, it expands to
+# "--features <comma-separated features>"
+ARG CARGO_FEATURES
+
+ENV PLAN_DIR /plan/${PLAN_PATH}
+ENV CARGO_TARGET_DIR /target
+
+COPY . /
+WORKDIR ${PLAN_DIR}
+
+# The cargo registry is cached across builds.
+RUN --mount=type=cache,id=testground-cargo-registry,target=/usr/local/cargo/registry \
+    --mount=type=cache,id=testground-cargo-git,target=/usr/local/cargo/git \
+    cargo build --release --bin {{.BinName}} ${CARGO_FEATURES} \
+    && cp ${CARGO_TARGET_DIR}/release/{{.BinName}} /testplan
+
+#:::
+#::: RUNTIME CONTAINER
+#:::
+FROM ${RUNTIME_IMAGE} AS runtime
+
+COPY --from=builder /testplan /testplan
+
+EXPOSE 6060
+ENTRYPOINT [ "/testplan"]
+`
@@ -0,0 +1,40 @@
+package build
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRustBuildArgs(t *testing.T) {
+	if img := rustBaseImage(&DockerRustBuilderConfig{}); img != "rust:"+DefaultRustVersion+"-bullseye" {
+		t.Errorf("unexpected default base image: %s", img)
+	}
+	if img := rustBaseImage(&DockerRustBuilderConfig{RustVersion: "1.65"}); img != "rust:1.65-bullseye" {
+		t.Errorf("unexpected base image: %s", img)
+	}
+	if img := rustBaseImage(&DockerRustBuilderConfig{RustVersion: "1.65", BuildBaseImage: "my/rust"}); img != "my/rust" {
+		t.Errorf("unexpected base image: %s", img)
+	}
+
+	if f := cargoFeatures(nil); f != "" {
+		t.Errorf("unexpected features without selectors: %q", f)
+	}
+	if f := cargoFeatures([]string{"quic", "tcp"}); f != "--features quic,tcp" {
+		t.Errorf("unexpected features: %q", f)
+	}
+}
+
+func TestRustDockerfileTemplate(t *testing.T) {
+	tmpl, err := dockerfileTemplate("docker:rust", t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, &DockerRustTemplateVars{BinName: "ping"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "cargo build --release --bin ping ${CARGO_FEATURES}") {
+		t.Errorf("binary name not rendered into the Dockerfile:\n%s", buf.String())
+	}
+}
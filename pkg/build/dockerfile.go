@@ -0,0 +1,112 @@
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+var (
+	// dockerfileTemplates holds the default Dockerfile template of each
+	// builder, by builder ID. Builders register theirs on init.
+	dockerfileTemplates   = make(map[string]string)
+	dockerfileTemplatesLk sync.RWMutex
+)
+
+// RegisterDockerfileTemplate sets the default Dockerfile template of the
+// builder with the supplied ID, replacing any existing one. Templates are
+// parsed with text/template, and executed with variables specific to each
+// builder.
+func RegisterDockerfileTemplate(builderID string, tmpl string) error {
+	if _, err := template.New("Dockerfile").Parse(tmpl); err != nil {
+		return fmt.Errorf("invalid Dockerfile template for builder %s: %w", builderID, err)
+	}
+
+	dockerfileTemplatesLk.Lock()
+	defer dockerfileTemplatesLk.Unlock()
+	dockerfileTemplates[builderID] = tmpl
+	return nil
+}
+
+// mustRegisterDockerfileTemplate registers the default Dockerfile template of a
+// builder of this package, and panics if it doesn't parse.
+func mustRegisterDockerfileTemplate(builderID string, tmpl string) {
+	if err := RegisterDockerfileTemplate(builderID, tmpl); err != nil {
+		panic(err)
+	}
+}
+
+// dockerfileTemplate resolves the Dockerfile template of a build: the one the
+// plan supplies at path, relative to planDir, if any, or else the default one
+// registered for the builder.
+func dockerfileTemplate(builderID string, planDir string, path string) (*template.Template, error) {
+	if path != "" {
+		src, err := planFile(planDir, path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dockerfile_template: %w", err)
+		}
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the Dockerfile template of the plan: %w", err)
+		}
+		tmpl, err := template.New("Dockerfile").Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Dockerfile template %s: %w", path, err)
+		}
+		return tmpl, nil
+	}
+
+	dockerfileTemplatesLk.RLock()
+	defer dockerfileTemplatesLk.RUnlock()
+
+	tmpl, ok := dockerfileTemplates[builderID]
+	if !ok {
+		return nil, fmt.Errorf("no Dockerfile template registered for builder %s", builderID)
+	}
+	// registered templates were checked to parse.
+	return template.Must(template.New("Dockerfile").Parse(tmpl)), nil
+}
+
+// writeDockerfile writes the Dockerfile of a build to dst, from the template
+// resolved by dockerfileTemplate.
+func writeDockerfile(dst string, builderID string, planDir string, path string, vars interface{}) error {
+	tmpl, err := dockerfileTemplate(builderID, planDir, path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create Dockerfile at %s: %w", dst, err)
+	}
+	defer f.Close()
+
+	if err := tmpl.Execute(f, vars); err != nil {
+		return fmt.Errorf("failed to execute Dockerfile template and/or write into file %s: %w", dst, err)
+	}
+	return nil
+}
+
+// planFile returns the path of a file of the plan, refusing paths that lead
+// out of the plan directory, including through symlinks.
+func planFile(planDir string, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("path %s must be relative to the plan directory", path)
+	}
+	root, err := filepath.EvalSymlinks(planDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the plan directory: %w", err)
+	}
+	p, err := filepath.EvalSymlinks(filepath.Join(root, path))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	if rel, err := filepath.Rel(root, p); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s leads out of the plan directory", path)
+	}
+	return p, nil
+}
@@ -0,0 +1,60 @@
+package build
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerfileTemplate(t *testing.T) {
+	planDir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(planDir, "Dockerfile.tmpl"), []byte("FROM {{.RuntimeImage}}\n"), 0644)
+	require.NoError(t, err)
+
+	// the plan template takes precedence over the builder default.
+	tmpl, err := dockerfileTemplate("docker:go", planDir, "Dockerfile.tmpl")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Execute(&buf, &DockerfileTemplateVars{RuntimeImage: "busybox"}))
+	require.Equal(t, "FROM busybox\n", buf.String())
+
+	tmpl, err = dockerfileTemplate("docker:node", planDir, "")
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, tmpl.Execute(&buf, nil))
+	require.Equal(t, NodeDockerfileTemplate, buf.String())
+
+	_, err = dockerfileTemplate("docker:unknown", planDir, "")
+	require.Error(t, err)
+
+	_, err = dockerfileTemplate("docker:go", planDir, "../Dockerfile.tmpl")
+	require.Error(t, err)
+
+	_, err = dockerfileTemplate("docker:go", planDir, "/etc/passwd")
+	require.Error(t, err)
+
+	// symlinks are followed before checking the path.
+	outside := filepath.Join(t.TempDir(), "Dockerfile.tmpl")
+	require.NoError(t, ioutil.WriteFile(outside, []byte("FROM scratch\n"), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(planDir, "escape.tmpl")))
+	_, err = dockerfileTemplate("docker:go", planDir, "escape.tmpl")
+	require.Error(t, err)
+	require.NoError(t, os.Symlink("..", filepath.Join(planDir, "up")))
+	_, err = dockerfileTemplate("docker:go", planDir, "up/"+filepath.Base(planDir)+"/Dockerfile.tmpl")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		dockerfileTemplatesLk.Lock()
+		delete(dockerfileTemplates, "docker:unknown")
+		dockerfileTemplatesLk.Unlock()
+	})
+	require.NoError(t, RegisterDockerfileTemplate("docker:unknown", "FROM scratch\n"))
+	_, err = dockerfileTemplate("docker:unknown", planDir, "")
+	require.NoError(t, err)
+
+	require.Error(t, RegisterDockerfileTemplate("docker:broken", "FROM {{.Image"))
+}
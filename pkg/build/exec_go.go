@@ -26,6 +26,10 @@ type ExecGoBuilderConfig struct {
 	ModulePath string `toml:"module_path"`
 	ExecPkg    string `toml:"exec_pkg"`
 	FreshGomod bool   `toml:"fresh_gomod"`
+
+	// SDKModule is the module path that a linked SDK replaces (default:
+	// github.com/testground/sdk-go).
+	SDKModule string `toml:"sdk_module"`
 }
 
 // Build builds a testplan written in Go and outputs an executable.
@@ -35,10 +39,18 @@ func (b *ExecGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc.O
 		return nil, fmt.Errorf("expected configuration type ExecGoBuilderConfig, was: %T", in.BuildConfig)
 	}
 
+	// Build from a temporary copy of the sources, so that the go.mod edits
+	// below never leak into the source of other builds.
+	sources, cleanup, err := isolateSources(in.UnpackedSources, in.BuildID)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
 	var (
 		id      = in.BuildID
-		plansrc = in.UnpackedSources.PlanDir
-		sdksrc  = in.UnpackedSources.SDKDir
+		plansrc = sources.PlanDir
+		sdksrc  = sources.SDKDir
 
 		bin  = fmt.Sprintf("exec-go--%s-%s", in.TestPlan, id)
 		path = filepath.Join(in.EnvConfig.Dirs().Work(), bin)
@@ -63,27 +75,18 @@ func (b *ExecGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc.O
 		}
 	}
 
-	// If we have version overrides, apply them.
-	var replaces []string
-	for mod, ver := range in.Dependencies {
-		if ver.Target == "" {
-			ver.Target = mod
-		}
-
-		replaces = append(replaces, fmt.Sprintf("-replace=%s=%s@%s", mod, ver.Target, ver.Version))
-	}
-
+	// If we have version overrides, apply them, and inject replace directives
+	// for the SDK modules, just like the docker:go builder does.
+	var sdkReplace string
 	if sdksrc != "" {
-		// Inject replace directives for the SDK modules.
-		replaces = append(replaces, "-replace=github.com/testground/sdk-go=../sdk")
+		sdkReplace = "../sdk"
 	}
-
+	replaces := goModReplaces(in.Dependencies, cfg.SDKModule, sdkReplace)
 	if len(replaces) > 0 {
 		// Write replace directives.
 		cmd := exec.CommandContext(ctx, "go", append([]string{"mod", "edit"}, replaces...)...)
 		cmd.Dir = plansrc
-		if err := cmd.Run(); err != nil {
-			out, _ := cmd.CombinedOutput()
+		if out, err := cmd.CombinedOutput(); err != nil {
 			return nil, fmt.Errorf("unable to add replace directives to go.mod; %w; output: %s", err, string(out))
 		}
 	}
@@ -91,8 +94,7 @@ func (b *ExecGoBuilder) Build(ctx context.Context, in *api.BuildInput, ow *rpc.O
 	// go mod tidy
 	cmd := exec.CommandContext(ctx, "go", "mod", "tidy")
 	cmd.Dir = plansrc
-	if err := cmd.Run(); err != nil {
-		out, _ := cmd.CombinedOutput()
+	if out, err := cmd.CombinedOutput(); err != nil {
 		return nil, fmt.Errorf("unable to go mod tidy in build; %w; output: %s", err, string(out))
 	}
 
@@ -0,0 +1,166 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/testutil"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExecGoBuilderOverrides(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	base := t.TempDir()
+	writeFiles(t, base, map[string]string{
+		"plan/go.mod": "module example.com/plan\n\ngo 1.16\n\nrequire example.com/dep v1.0.0\n",
+		"plan/main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/dep"
+)
+
+func main() { fmt.Print(dep.Name, tag) }
+`,
+		"plan/tag_on.go":  "//go:build selected\n// +build selected\n\npackage main\n\nconst tag = \"+selected\"\n",
+		"plan/tag_off.go": "//go:build !selected\n// +build !selected\n\npackage main\n\nconst tag = \"\"\n",
+		"dep/go.mod":      "module example.com/dep\n\ngo 1.16\n",
+		"dep/dep.go":      "package dep\n\nconst Name = \"overridden\"\n",
+	})
+
+	testutil.Setenv(t, config.EnvTestgroundHomeDir, t.TempDir())
+	testutil.Setenv(t, "GOPROXY", "off")
+	testutil.Setenv(t, "GOFLAGS", "-mod=mod")
+
+	env := &config.EnvConfig{}
+	if err := env.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	in := &api.BuildInput{
+		BuildID:   "test",
+		EnvConfig: *env,
+		TestPlan:  "plan",
+		UnpackedSources: &api.UnpackedSources{
+			BaseDir: base,
+			PlanDir: filepath.Join(base, "plan"),
+		},
+		Selectors: []string{"selected"},
+		Dependencies: map[string]api.DependencyTarget{
+			"example.com/dep": {Target: filepath.Join(base, "dep")},
+		},
+		BuildConfig: &ExecGoBuilderConfig{ExecPkg: "."},
+	}
+
+	out, err := new(ExecGoBuilder).Build(context.Background(), in, rpc.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := exec.Command(out.ArtifactPath).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != "overridden+selected" {
+		t.Errorf("unexpected binary output: %q", res)
+	}
+	if v := out.Dependencies["example.com/dep"]; !strings.Contains(v, filepath.Join(base, "dep")) {
+		t.Errorf("expected dependency on the overridden module, got %q", v)
+	}
+
+	// the plan source is left untouched.
+	gomod, err := ioutil.ReadFile(filepath.Join(base, "plan", "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(gomod), "replace") {
+		t.Error("go.mod of the plan source was modified")
+	}
+}
+
+func TestExecGoBuilderConcurrentOverrides(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	base := t.TempDir()
+	writeFiles(t, base, map[string]string{
+		"plan/go.mod":  "module example.com/plan\n\ngo 1.16\n\nrequire example.com/dep v1.0.0\n",
+		"plan/main.go": "package main\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/dep\"\n)\n\nfunc main() { fmt.Print(dep.Name) }\n",
+		"a/go.mod":     "module example.com/dep\n\ngo 1.16\n",
+		"a/dep.go":     "package dep\n\nconst Name = \"a\"\n",
+		"b/go.mod":     "module example.com/dep\n\ngo 1.16\n",
+		"b/dep.go":     "package dep\n\nconst Name = \"b\"\n",
+	})
+
+	testutil.Setenv(t, config.EnvTestgroundHomeDir, t.TempDir())
+	testutil.Setenv(t, "GOPROXY", "off")
+	testutil.Setenv(t, "GOFLAGS", "-mod=mod")
+
+	env := &config.EnvConfig{}
+	if err := env.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// both builds share the same sources, as builds of one plan across runs do.
+	sources := &api.UnpackedSources{BaseDir: base, PlanDir: filepath.Join(base, "plan")}
+
+	targets := []string{"a", "b"}
+	errs := make(chan error, len(targets))
+	for _, target := range targets {
+		go func(target string) {
+			in := &api.BuildInput{
+				BuildID:         "test-" + target,
+				EnvConfig:       *env,
+				TestPlan:        "plan",
+				UnpackedSources: sources,
+				Dependencies: map[string]api.DependencyTarget{
+					"example.com/dep": {Target: filepath.Join(base, target)},
+				},
+				BuildConfig: &ExecGoBuilderConfig{ExecPkg: "."},
+			}
+
+			out, err := new(ExecGoBuilder).Build(context.Background(), in, rpc.Discard())
+			if err != nil {
+				errs <- err
+				return
+			}
+			res, err := exec.Command(out.ArtifactPath).Output()
+			if err == nil && string(res) != target {
+				err = fmt.Errorf("build with override %q produced a binary using %q", target, res)
+			}
+			errs <- err
+		}(target)
+	}
+
+	for range targets {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/testground/testground/pkg/api"
+
+	"github.com/otiai10/copy"
+)
+
+// isolateSources copies the sources of a build into a temporary directory,
+// so that builds can write into them (e.g. Dockerfiles and go.mod edits)
+// without racing with concurrent builds of the same sources. The returned
+// function removes the copy.
+func isolateSources(src *api.UnpackedSources, id string) (*api.UnpackedSources, func(), error) {
+	tmp, err := ioutil.TempDir("", "build-"+id+"-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary build directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(tmp) }
+
+	if err := copy.Copy(src.BaseDir, tmp); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to copy sources: %w", err)
+	}
+
+	// rebase the source directories onto the copy.
+	dst := &api.UnpackedSources{BaseDir: tmp}
+	for _, d := range []struct {
+		from string
+		to   *string
+	}{
+		{src.PlanDir, &dst.PlanDir},
+		{src.SDKDir, &dst.SDKDir},
+		{src.ExtraDir, &dst.ExtraDir},
+	} {
+		if d.from == "" {
+			continue
+		}
+		rel, err := filepath.Rel(src.BaseDir, d.from)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("source %s is not within the base dir: %w", d.from, err)
+		}
+		*d.to = filepath.Join(tmp, rel)
+	}
+	return dst, cleanup, nil
+}
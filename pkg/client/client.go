@@ -33,6 +33,11 @@ import (
 	"github.com/mitchellh/mapstructure"
 )
 
+// errPlanNotCached is returned when the daemon no longer holds the plan source
+// that a request references, as it was evicted from its source cache after the
+// client checked for it.
+var errPlanNotCached = errors.New("plan source is not cached by the daemon")
+
 // Client is the API client that performs all operations
 // against a Testground server.
 type Client struct {
@@ -64,11 +69,11 @@ func (c *Client) Close() error {
 }
 
 func (c *Client) Build(ctx context.Context, r *api.BuildRequest, plandir string, sdkdir string, extraSrcs []string) (io.ReadCloser, error) {
-	return c.runBuild(ctx, r, "/build", plandir, sdkdir, extraSrcs)
+	return c.runBuild(ctx, r, r.IdempotencyKey, "/build", plandir, sdkdir, extraSrcs)
 }
 
 func (c *Client) Run(ctx context.Context, r *api.RunRequest, plandir string, sdkdir string, extraSrcs []string) (io.ReadCloser, error) {
-	return c.runBuild(ctx, r, "/run", plandir, sdkdir, extraSrcs)
+	return c.runBuild(ctx, r, r.IdempotencyKey, "/run", plandir, sdkdir, extraSrcs)
 }
 
 // runBuild sends a multipart request to the daemon on a certain path.
@@ -77,14 +82,22 @@ func (c *Client) Run(ctx context.Context, r *api.RunRequest, plandir string, sdk
 //
 //   - Part 1 (Content-Type: application/json): the request json, usually composition.
 //   - Part 2 (optional for runs, mandatory for builds, Content-Type: application/zip): test plan source.
+//     If the daemon already holds the plan source in its source cache, a
+//     plan.ref part (Content-Type: text/plain) with the hash of the source is
+//     sent instead.
 //   - Part 3 (optional, Content-Type: application/zip): linked sdk.
 //
 // The Body in the response implements an io.ReadCloser and it's up to the
 // caller to close it.
 //
+// If key is not empty, the request carries it as its idempotency key, so that
+// the daemon queues a single task however many times it is sent. The key
+// must be chosen by the caller, as only it can tell a retry from a new
+// submission.
+//
 // The response is a stream of `Msg` protocol messages. See
 // `ParseBuildResponse()` for specifics.
-func (c *Client) runBuild(ctx context.Context, r interface{}, path, plandir, sdkdir string, extraSrcs []string) (io.ReadCloser, error) {
+func (c *Client) runBuild(ctx context.Context, r interface{}, key, path, plandir, sdkdir string, extraSrcs []string) (io.ReadCloser, error) {
 	var body bytes.Buffer
 	err := json.NewEncoder(&body).Encode(r)
 	if err != nil {
@@ -147,90 +160,160 @@ func (c *Client) runBuild(ctx context.Context, r interface{}, path, plandir, sdk
 		return err
 	}
 
-	var (
-		rd, wr = io.Pipe()
-		mp     = multipart.NewWriter(wr)
-	)
+	// Filter the plan source, and check whether the daemon has it cached
+	// already, in which case we only send its hash.
+	var filteredDir, planHash string
+	if plandir != "" {
+		if filteredDir, err = getFilteredDirectory(plandir); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(filteredDir)
+		planHash = c.cachedPlanHash(ctx, filteredDir)
+	}
 
-	go func() error {
+	// send sends the request, referencing the plan source by planHash if set.
+	send := func(planHash string) (io.ReadCloser, error) {
 		var (
-			hcomp  = make(textproto.MIMEHeader) // composition
-			hplan  = make(textproto.MIMEHeader) // plan source
-			hsdk   = make(textproto.MIMEHeader) // optional sdk
-			hextra = make(textproto.MIMEHeader) // optional extra dirs
+			rd, wr = io.Pipe()
+			mp     = multipart.NewWriter(wr)
 		)
 
-		hcomp.Set("Content-Type", "application/json")
-		hcomp.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "composition.json"}))
+		go func() error {
+			var (
+				hcomp  = make(textproto.MIMEHeader) // composition
+				hplan  = make(textproto.MIMEHeader) // plan source
+				href   = make(textproto.MIMEHeader) // plan source reference
+				hsdk   = make(textproto.MIMEHeader) // optional sdk
+				hextra = make(textproto.MIMEHeader) // optional extra dirs
+			)
 
-		hplan.Set("Content-Type", "application/zip")
-		hplan.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "plan.zip"}))
+			hcomp.Set("Content-Type", "application/json")
+			hcomp.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "composition.json"}))
 
-		hsdk.Set("Content-Type", "application/zip")
-		hsdk.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "sdk.zip"}))
+			hplan.Set("Content-Type", "application/zip")
+			hplan.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "plan.zip"}))
 
-		hextra.Set("Content-Type", "application/zip")
-		hextra.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "extra.zip"}))
+			href.Set("Content-Type", "text/plain")
+			href.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "plan.ref"}))
 
-		// Part 1: composition json.
-		w, err := mp.CreatePart(hcomp)
-		if err != nil {
-			return wr.CloseWithError(err)
-		}
+			hsdk.Set("Content-Type", "application/zip")
+			hsdk.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "sdk.zip"}))
 
-		if err := json.NewEncoder(w).Encode(r); err != nil {
-			return wr.CloseWithError(err)
-		}
+			hextra.Set("Content-Type", "application/zip")
+			hextra.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "extra.zip"}))
 
-		// Optional part 2: plan source directory.
-		if plandir != "" {
-			filteredDir, err := getFilteredDirectory(plandir)
-			if err != nil {
-				return err
-			}
-
-			defer func() {
-				os.RemoveAll(filteredDir)
-			}()
-
-			w, err = mp.CreatePart(hplan)
+			// Part 1: composition json.
+			w, err := mp.CreatePart(hcomp)
 			if err != nil {
 				return wr.CloseWithError(err)
 			}
-			if err = writeZippedDirs(w, false, filteredDir); err != nil {
+
+			if err := json.NewEncoder(w).Encode(r); err != nil {
 				return wr.CloseWithError(err)
 			}
-		}
 
-		// Optional part 3: sdk source directory.
-		if sdkdir != "" {
-			w, err = mp.CreatePart(hsdk)
-			if err != nil {
-				return wr.CloseWithError(err)
+			// Optional part 2: plan source directory, or its reference.
+			if planHash != "" {
+				w, err = mp.CreatePart(href)
+				if err != nil {
+					return wr.CloseWithError(err)
+				}
+				if _, err = io.WriteString(w, planHash); err != nil {
+					return wr.CloseWithError(err)
+				}
+			} else if filteredDir != "" {
+				w, err = mp.CreatePart(hplan)
+				if err != nil {
+					return wr.CloseWithError(err)
+				}
+				if err = writeZippedDirs(w, false, filteredDir); err != nil {
+					return wr.CloseWithError(err)
+				}
 			}
-			if err = writeZippedDirs(w, false, sdkdir); err != nil {
-				return wr.CloseWithError(err)
+
+			// Optional part 3: sdk source directory.
+			if sdkdir != "" {
+				w, err = mp.CreatePart(hsdk)
+				if err != nil {
+					return wr.CloseWithError(err)
+				}
+				if err = writeZippedDirs(w, false, sdkdir); err != nil {
+					return wr.CloseWithError(err)
+				}
 			}
-		}
 
-		if len(extraSrcs) != 0 {
-			w, err = mp.CreatePart(hextra)
-			if err != nil {
-				return wr.CloseWithError(err)
+			if len(extraSrcs) != 0 {
+				w, err = mp.CreatePart(hextra)
+				if err != nil {
+					return wr.CloseWithError(err)
+				}
+				if err = writeZippedDirs(w, true, extraSrcs...); err != nil {
+					return wr.CloseWithError(err)
+				}
 			}
-			if err = writeZippedDirs(w, true, extraSrcs...); err != nil {
+
+			if err := mp.Close(); err != nil {
 				return wr.CloseWithError(err)
 			}
+			return wr.Close()
+		}() //nolint:errcheck
+
+		headers := []string{"Content-Type", "multipart/related; boundary=" + mp.Boundary()}
+		if key != "" {
+			headers = append(headers, api.IdempotencyKeyHeader, key)
 		}
+		return c.request(ctx, "POST", path, rd, headers...)
+	}
+
+	rc, err := send(planHash)
+	if planHash != "" && errors.Is(err, errPlanNotCached) {
+		// the daemon evicted the plan source after we checked for it.
+		logging.S().Infow("plan source is no longer cached by the daemon; uploading it", "hash", planHash)
+		rc, err = send("")
+	}
+	return rc, err
+}
+
+// cachedPlanHash returns the hash of the plan source in dir if the daemon
+// holds it in its source cache, or an empty string otherwise, including when
+// the daemon doesn't support source caching.
+func (c *Client) cachedPlanHash(ctx context.Context, dir string) string {
+	hash, err := api.HashSourceDir(dir)
+	if err != nil {
+		logging.S().Debugw("failed to hash plan source", "err", err)
+		return ""
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(&api.SourcesRequest{PlanHash: hash}); err != nil {
+		return ""
+	}
+
+	r, err := c.request(ctx, "POST", "/sources", &body)
+	if err != nil {
+		logging.S().Debugw("failed to query the source cache", "err", err)
+		return ""
+	}
+	defer r.Close()
 
-		if err := mp.Close(); err != nil {
-			return wr.CloseWithError(err)
+	// decode the result quietly; this is a preliminary check.
+	var resp api.SourcesResponse
+	for dec := json.NewDecoder(r); ; {
+		var chunk rpc.Chunk
+		if err := dec.Decode(&chunk); err != nil || chunk.Type == rpc.ChunkTypeError {
+			return ""
+		}
+		if chunk.Type != rpc.ChunkTypeResult {
+			continue
 		}
-		return wr.Close()
-	}() //nolint:errcheck
+		if err := parseMarshalAndUnmarshal(&resp)(chunk.Payload); err != nil || !resp.Cached {
+			return ""
+		}
+		break
+	}
 
-	contentType := "multipart/related; boundary=" + mp.Boundary()
-	return c.request(ctx, "POST", path, rd, "Content-Type", contentType)
+	logging.S().Infow("plan source is cached by the daemon; skipping upload", "hash", hash)
+	return hash
 }
 
 // getFilteredDirectory filters the directory dir according to the
@@ -306,6 +389,17 @@ func (c *Client) Terminate(ctx context.Context, r *api.TerminateRequest) (io.Rea
 	return c.request(ctx, "POST", "/terminate", bytes.NewReader(body.Bytes()))
 }
 
+// Prune sends a `prune` request to the daemon.
+func (c *Client) Prune(ctx context.Context, r *api.PruneRequest) (io.ReadCloser, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.request(ctx, "POST", "/prune", bytes.NewReader(body.Bytes()))
+}
+
 // Healthcheck sends a `healthcheck` request to the daemon.
 func (c *Client) Healthcheck(ctx context.Context, r *api.HealthcheckRequest) (io.ReadCloser, error) {
 	var body bytes.Buffer
@@ -348,6 +442,12 @@ func (c *Client) Status(ctx context.Context, r *api.StatusRequest) (io.ReadClose
 	return c.request(ctx, "POST", "/status", bytes.NewReader(body.Bytes()))
 }
 
+// Components sends a `components` request to the daemon, describing its
+// runners and builders.
+func (c *Client) Components(ctx context.Context) (io.ReadCloser, error) {
+	return c.request(ctx, "POST", "/components", nil)
+}
+
 func (c *Client) Cancel(ctx context.Context, r *api.CancelRequest) (io.ReadCloser, error) {
 	var body bytes.Buffer
 	err := json.NewEncoder(&body).Encode(r)
@@ -394,9 +494,18 @@ func parseGeneric(r io.ReadCloser, progress io.Writer, fnBinary, fnResult func(i
 				return err
 			}
 
+		case rpc.ChunkTypeStage:
+			_, err = fmt.Fprintln(progress, aurora.Bold(aurora.Magenta(fmt.Sprintf(">>> Stage: %v", chunk.Payload))))
+			if err != nil {
+				return err
+			}
+
 		case rpc.ChunkTypeError:
 			fmt.Println(aurora.Bold(aurora.BrightRed("\n>>> Error:\n")))
-			return errors.New(chunk.Error.Msg)
+			if chunk.Error == nil {
+				return errors.New("unknown error")
+			}
+			return chunk.Error
 
 		case rpc.ChunkTypeResult:
 			fmt.Println(aurora.Bold(aurora.BrightGreen("\n>>> Result:\n")))
@@ -534,6 +643,20 @@ func ParseHealthcheckResponse(r io.ReadCloser, progress io.Writer) (api.Healthch
 	return resp, err
 }
 
+// ParsePruneResponse parses a response from a 'prune' call
+func ParsePruneResponse(r io.ReadCloser, progress io.Writer) (api.PruneResponse, error) {
+	var resp api.PruneResponse
+	err := parseGeneric(
+		r,
+		progress,
+		nil,
+		func(result interface{}) error {
+			return mapstructure.Decode(result, &resp)
+		},
+	)
+	return resp, err
+}
+
 // ParseTasksRequest parses a response from a 'task' call
 func ParseTasksRequest(r io.ReadCloser, progress io.Writer) ([]*task.Task, error) {
 	var resp []*task.Task
@@ -546,6 +669,18 @@ func ParseTasksRequest(r io.ReadCloser, progress io.Writer) ([]*task.Task, error
 	return resp, err
 }
 
+// ParseComponentsResponse parses a response from a 'components' call
+func ParseComponentsResponse(r io.ReadCloser, progress io.Writer) (api.ComponentsResponse, error) {
+	var resp api.ComponentsResponse
+	err := parseGeneric(
+		r,
+		progress,
+		nil,
+		parseMarshalAndUnmarshal(&resp),
+	)
+	return resp, err
+}
+
 // ParseStatusResponse parses a response from a 'status' call
 func ParseStatusResponse(r io.ReadCloser, progress io.Writer) (api.StatusResponse, error) {
 	var resp api.StatusResponse
@@ -594,6 +729,11 @@ func (c *Client) request(ctx context.Context, method string, path string, body i
 		return nil, err
 	}
 
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		resp.Body.Close()
+		return nil, errPlanNotCached
+	}
+
 	if resp.StatusCode >= 400 {
 		return nil, fmt.Errorf("unexpected status code received: %s", resp.Status)
 	}
@@ -1,10 +1,21 @@
 package client
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -58,3 +69,43 @@ func TestFilteredDirectoryWithIgnore(t *testing.T) {
 		require.NoFileExists(t, filepath.Join(dir, file))
 	}
 }
+
+func TestBuildUploadsEvictedPlan(t *testing.T) {
+	var uploads []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sources" {
+			tgw := rpc.NewOutputWriter(w, r)
+			tgw.WriteResult(api.SourcesResponse{Cached: true})
+			return
+		}
+
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			uploads = append(uploads, p.FileName())
+			_, _ = io.Copy(ioutil.Discard, p)
+		}
+
+		// the plan source was evicted after the client checked for it.
+		if uploads[len(uploads)-1] == "plan.ref" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		tgw := rpc.NewOutputWriter(w, r)
+		tgw.WriteResult(api.BuildResponse{})
+	}))
+	defer srv.Close()
+
+	c := New(&config.EnvConfig{Client: config.ClientConfig{Endpoint: srv.URL}})
+	rc, err := c.Build(context.Background(), &api.BuildRequest{}, withoutIgnoreFileDir, "", nil)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	require.Equal(t, []string{"composition.json", "plan.ref", "composition.json", "plan.zip"}, uploads)
+}
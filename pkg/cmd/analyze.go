@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/metrics"
+
+	"github.com/urfave/cli/v2"
+)
+
+// AnalyzeCommand is the specification of the `analyze` command.
+var AnalyzeCommand = cli.Command{
+	Name:      "analyze",
+	Usage:     "summarise the metrics of a run, from its collected outputs, into summary statistics per group",
+	Action:    analyzeCommand,
+	ArgsUsage: "[outputs .tgz archive or directory]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "write the summary to `FILENAME`; defaults to summary.json within the directory, or <archive>.summary.json",
+		},
+	},
+}
+
+func analyzeCommand(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("missing outputs archive or directory")
+	}
+
+	src := c.Args().First()
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	var (
+		summary *metrics.Summary
+		output  = c.String("output")
+	)
+
+	if fi.IsDir() {
+		if output == "" {
+			output = filepath.Join(src, "summary.json")
+		}
+		summary, err = metrics.SummarizeDir(src)
+	} else {
+		if output == "" {
+			output = strings.TrimSuffix(src, ".tgz") + ".summary.json"
+		}
+		var f *os.File
+		if f, err = os.Open(src); err != nil {
+			return err
+		}
+		defer f.Close()
+		summary, err = metrics.SummarizeArchive(f)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(summary.Groups) == 0 {
+		logging.S().Warnw("no metrics found", "source", src, "files", []string{metrics.ResultsFile, metrics.DiagnosticsFile})
+	}
+	if summary.Skipped > 0 {
+		logging.S().Warnw("skipped malformed metrics lines", "count", summary.Skipped)
+	}
+
+	b, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(output, b, 0644); err != nil {
+		return err
+	}
+
+	logging.S().Infof("created file: %s", output)
+	return nil
+}
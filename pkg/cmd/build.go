@@ -13,6 +13,7 @@ import (
 	"github.com/testground/testground/pkg/client"
 	"github.com/testground/testground/pkg/data"
 	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/task"
 
 	"github.com/urfave/cli/v2"
 )
@@ -20,6 +21,13 @@ import (
 var linkSdkUsage = "links the test plan against a local SDK. The full `DIR_PATH`, or the NAME can be supplied, " +
 	"in the latter case, the testground client will expect to find the SDK under $TESTGROUND_HOME/sdks/NAME"
 
+var priorityUsage = fmt.Sprintf("scheduling `PRIORITY` of the task; higher numbers run first. "+
+	"Clamped to [%d, %d]; defaults to %d, or %d when waiting for the task",
+	task.MinPriority, task.MaxPriority, task.DefaultPriority, task.WaitPriority)
+
+var idempotencyKeyUsage = "`KEY` identifying the submission; submitting again with the same key " +
+	"returns the task created the first time instead of queueing a duplicate, e.g. when retrying from CI"
+
 var BuildCommand = cli.Command{
 	Name:  "build",
 	Usage: "request the daemon to build a test plan",
@@ -42,8 +50,21 @@ var BuildCommand = cli.Command{
 					Usage:   "write the resulting build artifacts to the composition file",
 				},
 				&cli.StringFlag{
-					Name:  "link-sdk",
-					Usage: linkSdkUsage,
+					Name:    "link-sdk",
+					Aliases: []string{"sdk-path"},
+					Usage:   linkSdkUsage,
+				},
+				&cli.StringFlag{
+					Name:  "callback",
+					Usage: "`URL` the daemon notifies with a JSON summary when the task completes",
+				},
+				&cli.StringFlag{
+					Name:  "idempotency-key",
+					Usage: idempotencyKeyUsage,
+				},
+				&cli.IntFlag{
+					Name:  "priority",
+					Usage: priorityUsage,
 				},
 				&cli.BoolFlag{
 					Name:  "wait",
@@ -61,6 +82,14 @@ var BuildCommand = cli.Command{
 					Name:  "build-cfg",
 					Usage: "set a build config parameter",
 				},
+				&cli.StringFlag{
+					Name:  "callback",
+					Usage: "`URL` the daemon notifies with a JSON summary when the task completes",
+				},
+				&cli.StringFlag{
+					Name:  "idempotency-key",
+					Usage: idempotencyKeyUsage,
+				},
 				&cli.StringFlag{
 					Name:     "builder",
 					Aliases:  []string{"b"},
@@ -73,8 +102,9 @@ var BuildCommand = cli.Command{
 					Usage:   "set a dependency mapping",
 				},
 				&cli.StringFlag{
-					Name:  "link-sdk",
-					Usage: linkSdkUsage,
+					Name:    "link-sdk",
+					Aliases: []string{"sdk-path"},
+					Usage:   linkSdkUsage,
 				},
 				&cli.StringFlag{
 					Name:     "plan",
@@ -82,6 +112,10 @@ var BuildCommand = cli.Command{
 					Usage:    "specifies the plan to run",
 					Required: true,
 				},
+				&cli.IntFlag{
+					Name:  "priority",
+					Usage: priorityUsage,
+				},
 				&cli.BoolFlag{
 					Name:  "wait",
 					Usage: "Wait for the task to complete",
@@ -186,11 +220,11 @@ func build(c *cli.Context, comp *api.Composition) error {
 		CreatedBy: api.CreatedBy{
 			User: cfg.Client.User,
 		},
+		Callback:       c.String("callback"),
+		IdempotencyKey: c.String("idempotency-key"),
 	}
 
-	if wait {
-		req.Priority = 1
-	}
+	req.Priority = taskPriority(c, wait)
 
 	// Resolve the linked SDK directory, if one has been supplied.
 	if sdk := c.String("link-sdk"); sdk != "" {
@@ -304,3 +338,20 @@ func runBuildPurgeCmd(c *cli.Context) (err error) {
 	return nil
 }
 
+// taskPriority returns the scheduling priority requested through the
+// --priority flag, falling back to the default for waiting or detached tasks.
+func taskPriority(c *cli.Context, wait bool) int {
+	if !c.IsSet("priority") {
+		if wait {
+			return task.WaitPriority
+		}
+		return task.DefaultPriority
+	}
+
+	p := c.Int("priority")
+	if clamped := task.ClampPriority(p); clamped != p {
+		logging.S().Warnf("priority %d out of range; using %d", p, clamped)
+		return clamped
+	}
+	return p
+}
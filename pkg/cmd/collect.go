@@ -87,6 +87,9 @@ func collect(ctx context.Context, cl *client.Client, stdout io.Writer, runner st
 
 	cr, err := client.ParseCollectResponse(resp, file, stdout)
 	if err != nil {
+		// don't leave a partial or corrupt archive behind.
+		file.Close()
+		os.Remove(outputFile)
 		return err
 	}
 
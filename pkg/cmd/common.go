@@ -37,7 +37,7 @@ func createSingletonComposition(c *cli.Context) (*api.Composition, error) {
 	var (
 		// Global struct
 		plan           = c.String("plan")
-		testcase       = c.String("testcase")
+		testcase       string
 		instances      = c.Uint("instances")
 		builder        = c.String("builder")
 		buildcfg       = c.StringSlice("build-cfg")
@@ -53,6 +53,12 @@ func createSingletonComposition(c *cli.Context) (*api.Composition, error) {
 		testparams = c.StringSlice("test-param")
 	)
 
+	// run single takes several test cases; the first one is the test case of
+	// the composition.
+	if cases := c.StringSlice("testcase"); len(cases) > 0 {
+		testcase = cases[0]
+	}
+
 	comp := &api.Composition{
 		Global: api.Global{
 			Plan:           plan,
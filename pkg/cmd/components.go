@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+	"github.com/urfave/cli/v2"
+)
+
+var ComponentsCommand = cli.Command{
+	Name:   "components",
+	Usage:  "list the runners and builders of the daemon, and the configuration keys they accept",
+	Action: componentsCommand,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "name",
+			Usage: "only describe the runner or builder with `ID`",
+		},
+	},
+}
+
+func componentsCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.Components(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	res, err := client.ParseComponentsResponse(r, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	name := c.String("name")
+	found := false
+	for _, desc := range append(res.Runners, res.Builders...) {
+		if name != "" && desc.ID != name {
+			continue
+		}
+		found = true
+		printComponent(desc)
+	}
+	if name != "" && !found {
+		return fmt.Errorf("unknown runner or builder: %s", name)
+	}
+	return nil
+}
+
+func printComponent(desc api.ComponentDescription) {
+	compatible := "runners"
+	if desc.Type == api.RunnerType {
+		compatible = "builders"
+	}
+
+	fmt.Printf("%s (%s)\n", desc.ID, desc.Type)
+	fmt.Printf("compatible %s: %s\n\n", compatible, strings.Join(desc.Compatible, ", "))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  KEY\tTYPE\tDEFAULT\tOVERRIDABLE")
+	for _, f := range desc.Config {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%t\n", f.Key, f.Type, f.Default, f.Overridable)
+	}
+	w.Flush()
+	fmt.Println()
+}
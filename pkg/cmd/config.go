@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/engine"
+	"github.com/urfave/cli/v2"
+)
+
+var ConfigCommand = cli.Command{
+	Name:  "config",
+	Usage: "inspect the environment configuration (.env.toml)",
+	Subcommands: cli.Commands{
+		&cli.Command{
+			Name:   "validate",
+			Usage:  "validate the environment configuration, reporting all the problems found",
+			Action: configValidateCommand,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "print",
+					Usage: "print the effective configuration, with secrets redacted",
+				},
+			},
+		},
+	},
+}
+
+func configValidateCommand(c *cli.Context) error {
+	cfg := &config.EnvConfig{}
+	if err := cfg.Load(); err != nil {
+		return err
+	}
+
+	if c.Bool("print") {
+		if err := toml.NewEncoder(c.App.Writer).Encode(cfg.Redact()); err != nil {
+			return fmt.Errorf("failed to print the configuration: %w", err)
+		}
+		fmt.Fprintln(c.App.Writer)
+	}
+
+	errs := cfg.Validate()
+	errs = append(errs, engine.ValidateComponentConfigs(cfg)...)
+	if len(errs) == 0 {
+		fmt.Fprintf(c.App.Writer, "%s: ok\n", cfg.File())
+		return nil
+	}
+
+	for _, err := range errs {
+		fmt.Fprintf(c.App.Writer, "  - %s\n", err)
+	}
+	return fmt.Errorf("found %d problem(s) in the configuration", len(errs))
+}
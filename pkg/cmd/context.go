@@ -13,15 +13,31 @@ import (
 var (
 	processContext     context.Context
 	processContextOnce sync.Once
+	// reloadCh, when set, receives SIGHUP instead of it terminating the
+	// process.
+	reloadCh chan os.Signal
 )
 
+// ReloadSignal makes SIGHUP request a configuration reload rather than a
+// shutdown, delivering it on the returned channel. It must be called before
+// ProcessContext.
+func ReloadSignal() <-chan os.Signal {
+	reloadCh = make(chan os.Signal, 1)
+	return reloadCh
+}
+
 func ProcessContext() context.Context {
 	processContextOnce.Do(func() {
 		var cancel context.CancelFunc
 		processContext, cancel = context.WithCancel(context.Background())
 
 		notify := make(chan os.Signal, 2)
-		signal.Notify(notify, os.Interrupt, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+		if reloadCh != nil {
+			signal.Notify(reloadCh, syscall.SIGHUP)
+			signal.Notify(notify, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+		} else {
+			signal.Notify(notify, os.Interrupt, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+		}
 		go func() {
 			defer signal.Stop(notify)
 
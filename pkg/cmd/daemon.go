@@ -7,6 +7,7 @@ import (
 
 	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/daemon"
+	"github.com/testground/testground/pkg/engine"
 	"github.com/testground/testground/pkg/logging"
 
 	"github.com/urfave/cli/v2"
@@ -19,7 +20,33 @@ var DaemonCommand = cli.Command{
 	Action: daemonCommand,
 }
 
+// reloadConfig applies the .env.toml to the running daemon, unless it is
+// invalid, in which case the current configuration is kept.
+func reloadConfig(srv *daemon.Daemon) {
+	logging.S().Infow("reloading configuration")
+
+	cfg := &config.EnvConfig{}
+	if err := cfg.Load(); err != nil {
+		logging.S().Errorw("failed to reload configuration; keeping the current one", "err", err)
+		return
+	}
+
+	errs := cfg.Validate()
+	errs = append(errs, engine.ValidateComponentConfigs(cfg)...)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			logging.S().Errorw("invalid configuration", "err", err)
+		}
+		logging.S().Errorw("failed to reload configuration; keeping the current one")
+		return
+	}
+
+	srv.Reload(cfg)
+}
+
 func daemonCommand(c *cli.Context) error {
+	reload := ReloadSignal()
+
 	ctx, cancel := context.WithCancel(ProcessContext())
 	defer cancel()
 
@@ -55,6 +82,20 @@ func daemonCommand(c *cli.Context) error {
 		logging.S().Infow("rpc server stopped")
 	}()
 
+	// SIGHUP reloads the .env.toml.
+	go func() {
+		for {
+			select {
+			case <-reload:
+				reloadConfig(srv)
+			case <-ctx.Done():
+				return
+			case <-exiting:
+				return
+			}
+		}
+	}()
+
 	logging.S().Infow("listen and serve", "addr", srv.Addr())
 	err = srv.Serve()
 	if err == http.ErrServerClosed {
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/client"
@@ -9,15 +10,15 @@ import (
 )
 
 var LogsCommand = cli.Command{
-	Name:   "logs",
-	Usage:  "get the current status for a certain task",
-	Action: logsCommand,
+	Name:      "logs",
+	Usage:     "get the logs of a task, including completed ones",
+	ArgsUsage: "[TASK_ID]",
+	Action:    logsCommand,
 	Flags: []cli.Flag{
 		&cli.StringFlag{
-			Name:     "task",
-			Aliases:  []string{"t"},
-			Usage:    "the task id",
-			Required: true,
+			Name:    "task",
+			Aliases: []string{"t"},
+			Usage:   "the task id; may also be passed as an argument",
 		},
 		&cli.BoolFlag{
 			Name:    "follow",
@@ -31,13 +32,21 @@ func logsCommand(c *cli.Context) error {
 	ctx, cancel := context.WithCancel(ProcessContext())
 	defer cancel()
 
+	id := c.String("task")
+	if id == "" {
+		id = c.Args().First()
+	}
+	if id == "" {
+		return errors.New("no task id supplied")
+	}
+
 	cl, _, err := setupClient(c)
 	if err != nil {
 		return err
 	}
 
 	r, err := cl.Logs(ctx, &api.LogsRequest{
-		TaskID: c.String("task"),
+		TaskID: id,
 		Follow: c.Bool("follow"),
 	})
 	if err != nil {
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/client"
+)
+
+var PruneCommand = cli.Command{
+	Name:   "prune",
+	Usage:  "remove resources orphaned by previous runs, such as data networks, dangling images and completed plan containers",
+	Action: pruneCommand,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "runner",
+			Usage:    "runner to prune; values include: 'local:docker', 'cluster:k8s'",
+			Required: true,
+		},
+	},
+}
+
+func pruneCommand(c *cli.Context) error {
+	ctx, cancel := context.WithCancel(ProcessContext())
+	defer cancel()
+
+	runner := c.String("runner")
+
+	cl, _, err := setupClient(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := cl.Prune(ctx, &api.PruneRequest{
+		Runner: runner,
+	})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	resp, err := client.ParsePruneResponse(r, c.App.Writer)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("finished pruning runner %s\n", runner)
+	fmt.Println(resp.String())
+
+	return nil
+}
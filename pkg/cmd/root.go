@@ -15,10 +15,14 @@ var RootCommands = cli.CommandsByName{
 	&SidecarCommand,
 	&DaemonCommand,
 	&CollectCommand,
+	&AnalyzeCommand,
 	&TerminateCommand,
+	&PruneCommand,
 	&HealthcheckCommand,
 	&TasksCommand,
 	&StatusCommand,
+	&ComponentsCommand,
+	&ConfigCommand,
 	&LogsCommand,
 	&VersionCommand,
 }
@@ -40,4 +44,8 @@ var RootFlags = []cli.Flag{
 		Name:  "endpoint",
 		Usage: "set the daemon endpoint `URI` (overrides .env.toml)",
 	},
+	&cli.StringFlag{
+		Name:  "log-format",
+		Usage: "set the log `FORMAT`; values: console, json (default: console on terminals, json otherwise)",
+	},
 }
@@ -13,8 +13,10 @@ import (
 	"github.com/mitchellh/mapstructure"
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/client"
+	"github.com/testground/testground/pkg/conv"
 	"github.com/testground/testground/pkg/data"
 	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
 	"github.com/testground/testground/pkg/runner"
 	"github.com/testground/testground/pkg/task"
 
@@ -53,6 +55,15 @@ var RunCommand = cli.Command{
 					Name:  "run-ids",
 					Usage: "run a specific run id, or a comma-separated list of run ids",
 				},
+				&cli.StringSliceFlag{
+					Name:    "testcase",
+					Aliases: []string{"t"},
+					Usage:   "test case to run instead of the one of the composition; must be defined in the test plan manifest. Several test cases run as an experiment, sharing one build",
+				},
+				&cli.BoolFlag{
+					Name:  "all-testcases",
+					Usage: "run all the test cases of the test plan as an experiment, sharing one build",
+				},
 				&cli.StringFlag{
 					Name:    ResultFileOpt,
 					Aliases: []string{"O"},
@@ -70,6 +81,14 @@ var RunCommand = cli.Command{
 					Name:  "metadata-commit",
 					Usage: "commit that triggered this run",
 				},
+				&cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "label the run with a `KEY=VALUE` pair, to filter tasks by later on",
+				},
+				&cli.BoolFlag{
+					Name:  "allow-underresourced",
+					Usage: "run groups requesting dangerously little resources compared to what the test plan recommends, rather than failing",
+				},
 			),
 		},
 		&cli.Command{
@@ -105,11 +124,14 @@ var RunCommand = cli.Command{
 					Name:  "run-cfg",
 					Usage: "override runner configuration",
 				},
-				&cli.StringFlag{
-					Name:     "testcase",
-					Aliases:  []string{"t"},
-					Usage:    "test case to run; must be defined in the test plan manifest",
-					Required: true,
+				&cli.StringSliceFlag{
+					Name:    "testcase",
+					Aliases: []string{"t"},
+					Usage:   "test case to run; must be defined in the test plan manifest. Several test cases run as an experiment, sharing one build",
+				},
+				&cli.BoolFlag{
+					Name:  "all-testcases",
+					Usage: "run all the test cases of the test plan as an experiment, sharing one build",
 				},
 				&cli.StringSliceFlag{
 					Name:    "test-param",
@@ -133,6 +155,14 @@ var RunCommand = cli.Command{
 					Name:  "metadata-commit",
 					Usage: "commit that triggered this run",
 				},
+				&cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "label the run with a `KEY=VALUE` pair, to filter tasks by later on",
+				},
+				&cli.BoolFlag{
+					Name:  "allow-underresourced",
+					Usage: "run groups requesting dangerously little resources compared to what the test plan recommends, rather than failing",
+				},
 				&cli.BoolFlag{
 					Name:  "disable-metrics",
 					Usage: "disable metrics batching",
@@ -190,6 +220,28 @@ func run(c *cli.Context, comp *api.Composition) (err error) {
 		return fmt.Errorf("failed to resolve test plan: %w", err)
 	}
 
+	testCases, err := selectTestCases(c, comp, manifest)
+	if err != nil {
+		return err
+	}
+
+	// Check the test parameters against the manifest, for each test case.
+	checkCases := testCases
+	if len(checkCases) == 0 {
+		checkCases = []string{comp.Global.Case}
+	}
+	for _, tc := range checkCases {
+		tcomp := *comp
+		tcomp.Global.Case = tc
+		warnings, err := tcomp.CheckParams(manifest)
+		if err != nil {
+			return fmt.Errorf("invalid test parameters: %w", err)
+		}
+		for _, w := range warnings {
+			logging.S().Warn(w)
+		}
+	}
+
 	// Retrieve the run ids to use.
 	rawRunIds := c.String("run-ids")
 	var runIds []string
@@ -254,13 +306,13 @@ func run(c *cli.Context, comp *api.Composition) (err error) {
 
 	// Compute priority
 	isCollecting := c.Bool("collect")
+	if isCollecting && (len(comp.Global.Matrix) > 0 || len(testCases) > 0) {
+		return fmt.Errorf("cannot collect the outputs of an experiment; collect the runs of its cells instead")
+	}
 	isMultiple := len(runIds) > 1
 	isWaiting := c.Bool("wait") || isCollecting || isMultiple
 
-	priority := 0
-	if isWaiting {
-		priority = 1
-	}
+	priority := taskPriority(c, isWaiting)
 
 	// Compute compositionTarget
 	compositionTarget := ""
@@ -274,6 +326,11 @@ func run(c *cli.Context, comp *api.Composition) (err error) {
 	// Compute result target
 	resultTarget := c.String(ResultFileOpt)
 
+	labels, err := conv.ParseKeyValues(c.StringSlice("label"))
+	if err != nil {
+		return fmt.Errorf("failed while parsing labels: %w", err)
+	}
+
 	// Prepare the strategy
 	strategy := MultiRunStrategy{
 		CurrentRunIndex:      0,
@@ -281,11 +338,16 @@ func run(c *cli.Context, comp *api.Composition) (err error) {
 		Composition:          comp,
 		EffectiveComposition: comp,
 		BaseRequest: api.RunRequest{
-			BuildGroups: buildIdx,
-			Priority:    priority,
-			RunIds:      []string{},
-			Composition: *comp,
-			Manifest:    *manifest,
+			BuildGroups:         buildIdx,
+			Priority:            priority,
+			Callback:            c.String("callback"),
+			IdempotencyKey:      c.String("idempotency-key"),
+			Labels:              labels,
+			TestCases:           testCases,
+			AllowUnderResourced: c.Bool("allow-underresourced"),
+			RunIds:              []string{},
+			Composition:         *comp,
+			Manifest:            *manifest,
 			CreatedBy: api.CreatedBy{
 				User:   cfg.Client.User,
 				Repo:   c.String("metadata-repo"),
@@ -333,6 +395,45 @@ func run(c *cli.Context, comp *api.Composition) (err error) {
 	return strategy.ExitStatus()
 }
 
+// selectTestCases resolves the test cases to run with --testcase and
+// --all-testcases. A single test case replaces the one of the composition.
+// Several test cases are returned, to run as the cells of an experiment;
+// otherwise, it returns nil.
+func selectTestCases(c *cli.Context, comp *api.Composition, manifest *api.TestPlanManifest) ([]string, error) {
+	var cases []string
+	if c.Bool("all-testcases") {
+		for _, tc := range manifest.TestCases {
+			cases = append(cases, tc.Name)
+		}
+		if len(cases) == 0 {
+			return nil, fmt.Errorf("test plan %s has no test cases", manifest.Name)
+		}
+	} else {
+		cases = c.StringSlice("testcase")
+	}
+
+	switch len(cases) {
+	case 0:
+		if comp.Global.Case == "" {
+			return nil, fmt.Errorf("no test case supplied; use --testcase or --all-testcases")
+		}
+		return nil, nil
+	case 1:
+		comp.Global.Case = cases[0]
+		return nil, nil
+	}
+
+	for _, tc := range cases {
+		if _, _, ok := manifest.TestCaseByName(tc); !ok {
+			return nil, fmt.Errorf("test case %s not found in plan %s", tc, manifest.Name)
+		}
+	}
+	if comp.Global.Case == "" {
+		comp.Global.Case = cases[0]
+	}
+	return cases, nil
+}
+
 func (m *MultiRunStrategy) Next(ctx context.Context, cl *client.Client, c *cli.Context) (bool, error) {
 	// Done
 	if m.CurrentRunIndex >= len(m.RunIds) {
@@ -395,6 +496,11 @@ func (m *MultiRunStrategy) CurrentRequest() api.RunRequest {
 
 	request.Composition = *m.EffectiveComposition
 
+	// each run is a submission of its own.
+	if m.isMultiple && request.IdempotencyKey != "" {
+		request.IdempotencyKey += "/" + m.CurrentRunId()
+	}
+
 	return request
 }
 
@@ -405,6 +511,10 @@ func (m *MultiRunStrategy) CurrentRunId() string {
 
 func (m *MultiRunStrategy) ExitStatus() error {
 	for _, result := range m.Results {
+		if result.Error == "" && result.Result.Outcome == task.OutcomeFailure {
+			// the test plan failed, rather than the run.
+			return &rpc.Error{Msg: fmt.Sprintf("run \"%s\" failed", result.RunId), Code: rpc.ErrorCodeTestFailure}
+		}
 		if (result.Error != "" || !data.IsOutcomeSuccess(result.Result.Outcome)) {
 			return cli.Exit(fmt.Errorf("run \"%s\" failed", result.RunId), 1)
 		}
@@ -3,7 +3,12 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/client"
@@ -13,19 +18,19 @@ import (
 )
 
 var StatusCommand = cli.Command{
-	Name:   "status",
-	Usage:  "get the current status for a certain task",
-	Action: statusCommand,
+	Name:      "status",
+	Usage:     "get the current status for a certain task",
+	ArgsUsage: "[TASK_ID]",
+	Action:    statusCommand,
 	Flags: []cli.Flag{
 		&cli.BoolFlag{
 			Name:  "extended",
 			Usage: "print extended information such as input and results",
 		},
 		&cli.StringFlag{
-			Name:     "task",
-			Aliases:  []string{"t"},
-			Usage:    "the task id",
-			Required: true,
+			Name:    "task",
+			Aliases: []string{"t"},
+			Usage:   "the task id; may also be passed as an argument",
 		},
 	},
 }
@@ -35,6 +40,12 @@ func statusCommand(c *cli.Context) error {
 	defer cancel()
 
 	id := c.String("task")
+	if id == "" {
+		id = c.Args().First()
+	}
+	if id == "" {
+		return errors.New("no task id supplied")
+	}
 
 	cl, _, err := setupClient(c)
 	if err != nil {
@@ -52,7 +63,31 @@ func statusCommand(c *cli.Context) error {
 		return err
 	}
 
-	printTask(res)
+	printTask(res.Task)
+
+	if res.Parent != "" {
+		fmt.Printf("Parent:\t\t%s\n", res.Parent)
+	}
+	if len(res.Children) > 0 {
+		if res.Type == task.TypeExperiment {
+			fmt.Printf("Cells:\n")
+		} else {
+			fmt.Printf("Phases:\n")
+		}
+		for _, child := range res.Children {
+			printPhase(ctx, cl, child)
+		}
+		fmt.Printf("  %s:\t%s\t%s\n", res.Type, res.ID, res.State().State)
+	}
+
+	if q := res.Queue; q != nil {
+		fmt.Printf("Queue position:\t%d of %d\n", q.Position, q.Length)
+		if q.EstimatedWait != nil {
+			fmt.Printf("Estimated wait:\t~%s\n", q.EstimatedWait.Round(time.Second))
+		} else {
+			fmt.Printf("Estimated wait:\tunknown\n")
+		}
+	}
 
 	if c.Bool("extended") {
 		fmt.Printf("\nInput:\n")
@@ -73,6 +108,39 @@ func statusCommand(c *cli.Context) error {
 	return nil
 }
 
+// printPhase prints a one-line summary of a child task.
+func printPhase(ctx context.Context, cl *client.Client, id string) {
+	r, err := cl.Status(ctx, &api.StatusRequest{TaskID: id})
+	if err != nil {
+		fmt.Printf("  %s: failed to get status: %s\n", id, err)
+		return
+	}
+	defer r.Close()
+
+	tsk, err := client.ParseStatusResponse(r, ioutil.Discard)
+	if err != nil {
+		fmt.Printf("  %s: failed to get status: %s\n", id, err)
+		return
+	}
+
+	state := tsk.State().State
+	if state == task.StateScheduled || state == task.StateProcessing {
+		fmt.Printf("  %s:\t%s\t%s\n", tsk.Type, tsk.ID, state)
+		return
+	}
+	fmt.Printf("  %s:\t%s\t%s (%s)\n", tsk.Type, tsk.ID, state, processingTook(&tsk.Task))
+}
+
+// processingTook returns how long a completed task was processed for.
+func processingTook(tsk *task.Task) time.Duration {
+	for _, s := range tsk.States {
+		if s.State == task.StateProcessing {
+			return tsk.State().Created.Sub(s.Created).Round(time.Second)
+		}
+	}
+	return tsk.Took()
+}
+
 func printTask(tsk task.Task) {
 	outcome, err := data.DecodeTaskOutcome(&tsk)
 	outcomeStr := string(outcome)
@@ -88,4 +156,13 @@ func printTask(tsk task.Task) {
 	fmt.Printf("Status:\t\t%s\n", tsk.State().State)
 	fmt.Printf("Outcome:\t%s\n", outcomeStr)
 	fmt.Printf("Last update:\t%s\n", tsk.State().Created)
+
+	if len(tsk.Labels) > 0 {
+		labels := make([]string, 0, len(tsk.Labels))
+		for k, v := range tsk.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		fmt.Printf("Labels:\t\t%s\n", strings.Join(labels, ","))
+	}
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/client"
+	"github.com/testground/testground/pkg/conv"
 	"github.com/testground/testground/pkg/task"
 	"github.com/urfave/cli/v2"
 )
@@ -16,8 +17,12 @@ var TasksCommand = cli.Command{
 	Name:   "tasks",
 	Usage:  "get a list of the existing tasks",
 	Action: tasksCommand,
-	Flags:  []cli.Flag{
+	Flags: []cli.Flag{
 		// TODO(hac): add filters (type of task, date, state, etc)
+		&cli.StringSliceFlag{
+			Name:  "label",
+			Usage: "only list the tasks labeled with the `KEY=VALUE` pair; may be repeated",
+		},
 	},
 }
 
@@ -30,9 +35,15 @@ func tasksCommand(c *cli.Context) error {
 		return err
 	}
 
+	labels, err := conv.ParseKeyValues(c.StringSlice("label"))
+	if err != nil {
+		return fmt.Errorf("failed while parsing labels: %w", err)
+	}
+
 	req := &api.TasksRequest{
-		Types:  []task.Type{task.TypeBuild, task.TypeRun},
+		Types:  []task.Type{task.TypeBuild, task.TypeRun, task.TypeExperiment},
 		States: []task.State{task.StateScheduled, task.StateProcessing, task.StateComplete},
+		Labels: labels,
 	}
 
 	r, err := cl.Tasks(ctx, req)
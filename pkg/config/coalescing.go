@@ -4,12 +4,20 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
 
 type CoalescedConfig []map[string]interface{}
 
+// OverridableTag is the struct tag marking whether users may override a
+// runner or builder configuration field, e.g. through run-cfg and build-cfg.
+// Fields are overridable unless tagged `overridable:"no"`, in which case they
+// can only be set by the .env.toml and the plan manifest.
+const OverridableTag = "overridable"
+
 func (c CoalescedConfig) Append(in map[string]interface{}) CoalescedConfig {
 	return append(c, in)
 }
@@ -37,3 +45,73 @@ func (c CoalescedConfig) CoalesceIntoType(typ reflect.Type) (interface{}, error)
 	_, err := toml.DecodeReader(buf, v)
 	return v, err
 }
+
+// CheckOverrides fails if the user-supplied overrides in set fields of typ
+// that are not overridable; see OverridableTag.
+func CheckOverrides(in map[string]interface{}, typ reflect.Type) error {
+	var rejected []string
+	for _, key := range nonOverridableKeys(typ) {
+		for k := range in {
+			// keys match fields case-insensitively, as when decoding toml.
+			if strings.EqualFold(k, key) {
+				rejected = append(rejected, k)
+			}
+		}
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return fmt.Errorf("configuration keys can't be overridden: %s", strings.Join(rejected, ", "))
+	}
+	return nil
+}
+
+// nonOverridableKeys returns the toml keys of the fields of typ tagged as not
+// overridable, including the fields promoted from embedded structs.
+func nonOverridableKeys(typ reflect.Type) []string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var keys []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name := strings.Split(f.Tag.Get("toml"), ",")[0]
+		if f.Anonymous && name == "" {
+			keys = append(keys, nonOverridableKeys(f.Type)...)
+			continue
+		}
+		if f.Tag.Get(OverridableTag) != "no" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+// UnknownKeys returns the keys of in that don't match any field of typ, e.g.
+// because they're misspelt, and that CoalesceIntoType would silently ignore.
+// Keys are matched as when decoding toml.
+func UnknownKeys(in map[string]interface{}, typ reflect.Type) ([]string, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(in); err != nil {
+		return nil, fmt.Errorf("error while encoding into TOML: %w", err)
+	}
+
+	md, err := toml.DecodeReader(buf, reflect.New(typ).Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, k := range md.Undecoded() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+type embeddedTestConfig struct {
+	Verbose bool `toml:"verbose"`
+}
+
+type testConfig struct {
+	embeddedTestConfig
+
+	Enabled       bool
+	KeepService   bool              `toml:"keep_service"`
+	ExposedPorts  map[string]string `toml:"exposed_ports"`
+	RunTimeoutMin int               `toml:"run_timeout_min"`
+}
+
+func TestUnknownKeys(t *testing.T) {
+	in := map[string]interface{}{
+		"enabled":         true,
+		"verbose":         true,
+		"keep_service":    true,
+		"keep_services":   true,
+		"exposed_ports":   map[string]interface{}{"pprof": "6060"},
+		"run_timeout_mni": 10,
+	}
+
+	keys, err := UnknownKeys(in, reflect.TypeOf(testConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"keep_services", "run_timeout_mni"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("expected unknown keys %v, got %v", want, keys)
+	}
+
+	if _, err := UnknownKeys(map[string]interface{}{"run_timeout_min": "ten"}, reflect.TypeOf(testConfig{})); err == nil {
+		t.Error("expected an error decoding a mistyped value")
+	}
+}
+
+type overridableTestConfig struct {
+	embeddedLimits
+
+	LogLevel string `toml:"log_level" overridable:"yes"`
+	Image    string `toml:"image"`
+}
+
+type embeddedLimits struct {
+	MemoryMB int64 `toml:"memory_mb" overridable:"no"`
+}
+
+func TestCheckOverrides(t *testing.T) {
+	typ := reflect.TypeOf(overridableTestConfig{})
+
+	if err := CheckOverrides(map[string]interface{}{"log_level": "debug", "image": "busybox"}, typ); err != nil {
+		t.Errorf("expected overridable fields to be accepted, got %s", err)
+	}
+
+	err := CheckOverrides(map[string]interface{}{"log_level": "debug", "Memory_MB": 512}, typ)
+	if err == nil {
+		t.Fatal("expected overriding a non-overridable field to be rejected")
+	}
+	if want := "configuration keys can't be overridden: Memory_MB"; err.Error() != want {
+		t.Errorf("expected error %q, got %q", want, err)
+	}
+}
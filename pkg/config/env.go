@@ -43,6 +43,49 @@ type DaemonConfig struct {
 	GithubRepoStatusToken string          `toml:"github_repo_status_token"`
 	RootURL               string          `toml:"root_url"`
 	InfluxDBEndpoint      string          `toml:"influxdb_endpoint"`
+	Subnets               SubnetsConfig   `toml:"subnets"`
+	ControlSubnet         string          `toml:"control_subnet"`
+	ControlGateway        string          `toml:"control_gateway"`
+	// MountRoots are the host paths, or persistent volume claims
+	// (pvc://<claim>), under which compositions may mount volumes into plan
+	// instances. No volumes may be mounted when empty.
+	MountRoots []string `toml:"mount_roots"`
+	// MaxSourceSizeMB and MaxSourceFiles bound the total uncompressed size
+	// and the number of files of each source archive submitted to the
+	// daemon.
+	MaxSourceSizeMB int `toml:"max_source_size_mb"`
+	MaxSourceFiles  int `toml:"max_source_files"`
+	// SourceCacheMB is the disk budget of the cache of plan sources, which
+	// spares clients from uploading a plan source the daemon already holds.
+	// A negative value disables the cache.
+	SourceCacheMB int `toml:"source_cache_mb"`
+	// TaskLogMaxAgeDays and TaskLogBudgetMB bound the retention of the logs
+	// the daemon keeps for each task: logs older than the maximum age are
+	// removed, and then the oldest logs until the rest fit in the budget.
+	// A negative value disables the corresponding bound.
+	TaskLogMaxAgeDays int `toml:"task_log_max_age_days"`
+	TaskLogBudgetMB   int `toml:"task_log_budget_mb"`
+	// CallbackSecret, when set, is the key of the HMAC signature attached to
+	// the task completion callbacks the daemon sends.
+	CallbackSecret string `toml:"callback_secret"`
+	// ReadTimeout and WriteTimeout bound the time the daemon spends reading
+	// a request and writing its response, as duration strings (e.g. "20m").
+	// "0" disables the timeout, e.g. for very long streamed runs.
+	ReadTimeout  string `toml:"read_timeout"`
+	WriteTimeout string `toml:"write_timeout"`
+	// RequestTimeout bounds the handling of the quick, non-streaming
+	// endpoints, so that hung requests are caught early; the streaming
+	// endpoints are only bound by the read and write timeouts. "0" disables
+	// it.
+	RequestTimeout string `toml:"request_timeout"`
+}
+
+// SubnetsConfig selects how data network subnets are allocated to runs.
+type SubnetsConfig struct {
+	// Allocator is either "memory" (single daemon) or "redis" (coordinated
+	// across daemons sharing a Redis instance).
+	Allocator string `toml:"allocator"`
+	RedisAddr string `toml:"redis_addr"`
 }
 
 type SchedulerConfig struct {
@@ -50,6 +93,13 @@ type SchedulerConfig struct {
 	QueueSize      int    `toml:"queue_size"`
 	TaskRepoType   string `toml:"task_repo_type"`
 	TaskTimeoutMin int    `toml:"task_timeout_min"`
+	// MaxConcurrentTasks caps the number of tasks in flight, including runs
+	// that continue in the background after a worker detached from them.
+	// Zero means no limit beyond the number of workers.
+	MaxConcurrentTasks int `toml:"max_concurrent_tasks"`
+	// FairShare, when set, schedules tasks from the submitters with the
+	// fewest running tasks first, rather than strictly by priority and age.
+	FairShare bool `toml:"fair_share"`
 }
 
 type ClientConfig struct {
@@ -24,9 +24,25 @@ const (
 
 	DefaultTaskRepoType = "memory"
 
+	DefaultSubnetAllocator = "memory"
+
 	DefaultWorkers = 2
 
 	DefaultQueueSize = 100
+
+	DefaultMaxSourceSizeMB = 1024
+
+	DefaultMaxSourceFiles = 100000
+
+	DefaultSourceCacheMB = 1024
+
+	DefaultTaskLogMaxAgeDays = 30
+
+	DefaultDaemonTimeout = "7200s"
+
+	DefaultRequestTimeout = "60s"
+
+	DefaultTaskLogBudgetMB = 4096
 )
 
 func (e *EnvConfig) Load() error {
@@ -36,7 +52,7 @@ func (e *EnvConfig) Load() error {
 	}
 
 	// parse the .env.toml file, if it exists.
-	f := filepath.Join(e.dirs.Home(), ".env.toml")
+	f := e.File()
 	if _, err := os.Stat(f); err == nil {
 		// try to load the optional .env.toml file
 		_, err = toml.DecodeFile(f, e)
@@ -78,6 +94,15 @@ func (e *EnvConfig) EnsureMinimalConfig() error {
 	e.Daemon.Scheduler.Workers = defaultInt(e.Daemon.Scheduler.Workers, DefaultWorkers)
 	e.Daemon.Scheduler.QueueSize = defaultInt(e.Daemon.Scheduler.QueueSize, DefaultQueueSize)
 	e.Daemon.Scheduler.TaskRepoType = defaultString(e.Daemon.Scheduler.TaskRepoType, DefaultTaskRepoType)
+	e.Daemon.Subnets.Allocator = defaultString(e.Daemon.Subnets.Allocator, DefaultSubnetAllocator)
+	e.Daemon.MaxSourceSizeMB = defaultInt(e.Daemon.MaxSourceSizeMB, DefaultMaxSourceSizeMB)
+	e.Daemon.MaxSourceFiles = defaultInt(e.Daemon.MaxSourceFiles, DefaultMaxSourceFiles)
+	e.Daemon.SourceCacheMB = defaultInt(e.Daemon.SourceCacheMB, DefaultSourceCacheMB)
+	e.Daemon.TaskLogMaxAgeDays = defaultInt(e.Daemon.TaskLogMaxAgeDays, DefaultTaskLogMaxAgeDays)
+	e.Daemon.TaskLogBudgetMB = defaultInt(e.Daemon.TaskLogBudgetMB, DefaultTaskLogBudgetMB)
+	e.Daemon.ReadTimeout = defaultString(e.Daemon.ReadTimeout, DefaultDaemonTimeout)
+	e.Daemon.WriteTimeout = defaultString(e.Daemon.WriteTimeout, DefaultDaemonTimeout)
+	e.Daemon.RequestTimeout = defaultString(e.Daemon.RequestTimeout, DefaultRequestTimeout)
 
 	// 1. Use $TESTGROUND_HOME if set
         // 2. Otherwise use $HOME/testground if directory exists (legacy, to be deprecated)
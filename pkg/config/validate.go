@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Redacted replaces the secrets of a configuration printed out.
+const Redacted = "<redacted>"
+
+// File returns the path of the .env.toml file of the testground home.
+func (e EnvConfig) File() string {
+	return filepath.Join(e.dirs.Home(), ".env.toml")
+}
+
+// Validate checks a loaded environment configuration, and returns all the
+// problems found rather than stopping at the first one. The runner and builder
+// configuration blocks are not checked here, as their configuration types are
+// only known to the engine.
+func (e *EnvConfig) Validate() []error {
+	var errs []error
+
+	// keys that don't match any field are silently ignored by Load.
+	if _, err := os.Stat(e.File()); err == nil {
+		md, err := toml.DecodeFile(e.File(), &EnvConfig{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse %s: %w", e.File(), err))
+		}
+		for _, k := range md.Undecoded() {
+			errs = append(errs, fmt.Errorf("unknown key in %s: %s", e.File(), k))
+		}
+	}
+
+	for _, d := range []string{
+		e.dirs.Home(),
+		e.dirs.Outputs(),
+		e.dirs.Plans(),
+		e.dirs.SDKs(),
+		e.dirs.Work(),
+		e.dirs.Daemon(),
+	} {
+		if err := checkWritableDir(d); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	d := e.Daemon
+	if _, _, err := net.SplitHostPort(d.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("invalid daemon.listen address %q: %w", d.Listen, err))
+	}
+	for _, t := range []struct{ key, value string }{
+		{"daemon.read_timeout", d.ReadTimeout},
+		{"daemon.write_timeout", d.WriteTimeout},
+		{"daemon.request_timeout", d.RequestTimeout},
+	} {
+		if v, err := time.ParseDuration(t.value); err != nil || v < 0 {
+			errs = append(errs, fmt.Errorf("invalid %s duration: %q", t.key, t.value))
+		}
+	}
+
+	s := d.Scheduler
+	switch s.TaskRepoType {
+	case "memory", "disk":
+	default:
+		errs = append(errs, fmt.Errorf("unknown daemon.scheduler.task_repo_type: %q; values: memory, disk", s.TaskRepoType))
+	}
+	if s.Workers < 0 {
+		errs = append(errs, fmt.Errorf("daemon.scheduler.workers must be positive, got %d", s.Workers))
+	}
+	if s.QueueSize < 0 {
+		errs = append(errs, fmt.Errorf("daemon.scheduler.queue_size must be positive, got %d", s.QueueSize))
+	}
+	if s.MaxConcurrentTasks < 0 {
+		errs = append(errs, fmt.Errorf("daemon.scheduler.max_concurrent_tasks must not be negative, got %d", s.MaxConcurrentTasks))
+	}
+
+	switch d.Subnets.Allocator {
+	case "memory":
+	case "redis":
+		if d.Subnets.RedisAddr == "" {
+			errs = append(errs, fmt.Errorf("redis subnet allocator requires daemon.subnets.redis_addr"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown daemon.subnets.allocator: %q; values: memory, redis", d.Subnets.Allocator))
+	}
+
+	if u, err := url.Parse(e.Client.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("invalid client.endpoint URL: %q", e.Client.Endpoint))
+	}
+
+	return errs
+}
+
+// Redact returns a copy of the configuration with its secrets replaced, so
+// that it can be printed out.
+func (e EnvConfig) Redact() EnvConfig {
+	redact := func(s *string) {
+		if *s != "" {
+			*s = Redacted
+		}
+	}
+
+	redact(&e.AWS.SecretAccessKey)
+	redact(&e.DockerHub.AccessToken)
+	redact(&e.Daemon.SlackWebhookURL)
+	redact(&e.Daemon.GithubRepoStatusToken)
+	redact(&e.Daemon.CallbackSecret)
+	redact(&e.Client.Token)
+
+	if len(e.Daemon.Tokens) > 0 {
+		tokens := make([]string, len(e.Daemon.Tokens))
+		for i := range tokens {
+			tokens[i] = Redacted
+		}
+		e.Daemon.Tokens = tokens
+	}
+	return e
+}
+
+// checkWritableDir fails if path is not a directory the current user can
+// create files in.
+func checkWritableDir(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("directory %s is not accessible: %w", path, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("path %s exists, and it is not a directory", path)
+	}
+
+	f, err := ioutil.TempFile(path, ".validate-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", path, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
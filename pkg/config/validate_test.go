@@ -0,0 +1,82 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/testground/testground/pkg/testutil"
+)
+
+func TestValidate(t *testing.T) {
+	home := t.TempDir()
+	testutil.Setenv(t, EnvTestgroundHomeDir, home)
+
+	var cfg EnvConfig
+	if err := cfg.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if errs := cfg.Validate(); len(errs) != 0 {
+		t.Fatalf("expected the default configuration to be valid, got: %v", errs)
+	}
+
+	env := `
+[daemon]
+listen = "localhost"
+read_timeout = "2 hours"
+slack_webhok_url = "https://hooks.example.com"
+
+[daemon.scheduler]
+task_repo_type = "sql"
+`
+	if err := ioutil.WriteFile(filepath.Join(home, ".env.toml"), []byte(env), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = EnvConfig{}
+	if err := cfg.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []string
+	for _, err := range cfg.Validate() {
+		msgs = append(msgs, err.Error())
+	}
+	all := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		"unknown key in " + cfg.File() + ": daemon.slack_webhok_url",
+		"invalid daemon.listen address",
+		"invalid daemon.read_timeout duration",
+		"unknown daemon.scheduler.task_repo_type",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("expected problem %q, got:\n%s", want, all)
+		}
+	}
+	if len(msgs) != 4 {
+		t.Errorf("expected 4 problems, got %d:\n%s", len(msgs), all)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	var cfg EnvConfig
+	cfg.AWS.SecretAccessKey = "secret"
+	cfg.Client.Token = "token"
+	cfg.Daemon.Tokens = []string{"a", "b"}
+	cfg.Daemon.Listen = "localhost:8042"
+
+	r := cfg.Redact()
+	if r.AWS.SecretAccessKey != Redacted || r.Client.Token != Redacted {
+		t.Errorf("expected secrets to be redacted, got %+v", r)
+	}
+	if len(r.Daemon.Tokens) != 2 || r.Daemon.Tokens[0] != Redacted {
+		t.Errorf("expected tokens to be redacted, got %v", r.Daemon.Tokens)
+	}
+	if r.Daemon.Listen != "localhost:8042" || r.DockerHub.AccessToken != "" {
+		t.Errorf("expected other values to be preserved, got %+v", r)
+	}
+	if cfg.Daemon.Tokens[0] != "a" {
+		t.Errorf("expected the original configuration to be preserved")
+	}
+}
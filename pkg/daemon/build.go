@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime"
 	"mime/multipart"
 	"net/http"
@@ -12,8 +13,8 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/mholt/archiver"
 	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/logging"
 	"github.com/testground/testground/pkg/rpc"
 )
@@ -31,28 +32,32 @@ func (d *Daemon) buildHandler(engine api.Engine) func(w http.ResponseWriter, r *
 		// Create a packing directory under the workdir.
 		dir := filepath.Join(engine.EnvConfig().Dirs().Work(), "requests", ruid)
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			tgw.WriteError("failed to create temp directory to unpack request", "err", err)
+			tgw.WriteErrorCode(rpc.ErrorCodeInfrastructure, "failed to create temp directory to unpack request", "err", err)
 			return
 		}
 
 		var request *api.BuildRequest
-		sources, err := consumeRunBuildRequest(r, &request, dir)
+		sources, err := d.consumeRunBuildRequest(r, &request, dir, sourceLimits(engine.EnvConfig().Daemon))
 		if err != nil {
-			tgw.WriteError("failed to consume request", "err", err)
+			writeConsumeError(w, tgw, err)
 			return
 		}
 
 		if sources == nil || sources.PlanDir == "" {
-			tgw.WriteError("bad request", "err", errors.New("plan directory not present"))
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "bad request", "err", errors.New("plan directory not present"))
 			return
 		}
 
+		request.IdempotencyKey = r.Header.Get(api.IdempotencyKeyHeader)
+		request.RequestID = ruid
+
 		id, err := engine.QueueBuild(request, sources)
 		if err != nil {
 			tgw.WriteError(fmt.Sprintf("engine build error: %s", err))
 			return
 		}
 
+		tgw.WriteStage(rpc.StageQueued)
 		tgw.WriteResult(id)
 	}
 }
@@ -69,7 +74,7 @@ func (d *Daemon) buildPurgeHandler(engine api.Engine) func(w http.ResponseWriter
 		var req api.BuildPurgeRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			tgw.WriteError("build parge json decode", "err", err.Error())
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "build parge json decode", "err", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -84,7 +89,28 @@ func (d *Daemon) buildPurgeHandler(engine api.Engine) func(w http.ResponseWriter
 	}
 }
 
-func consumeRunBuildRequest(r *http.Request, body interface{}, dir string) (*api.UnpackedSources, error) {
+// sourceLimits returns the limits that source archives are unpacked with.
+func sourceLimits(cfg config.DaemonConfig) unzipLimits {
+	return unzipLimits{
+		maxSize:  int64(cfg.MaxSourceSizeMB) << 20,
+		maxFiles: cfg.MaxSourceFiles,
+	}
+}
+
+// writeConsumeError reports a request that couldn't be consumed. Requests
+// referencing a plan source that is no longer cached get a 412 Precondition
+// Failed status, so that the client uploads the source instead.
+func writeConsumeError(w http.ResponseWriter, tgw *rpc.OutputWriter, err error) {
+	if errors.Is(err, errSourceNotCached) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+	}
+	tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "failed to consume request", "err", err)
+}
+
+// consumeRunBuildRequest decodes the request body, and unpacks the attached
+// sources into dir. A plan.ref part, holding the hash of a plan source in the
+// source cache, stands in for a plan.zip part.
+func (d *Daemon) consumeRunBuildRequest(r *http.Request, body interface{}, dir string, limits unzipLimits) (*api.UnpackedSources, error) {
 	var (
 		p   *multipart.Part
 		err error
@@ -132,27 +158,42 @@ Outer:
 			}
 
 			var (
-				filename = p.FileName() // can be sdk.zip or extra.zip
+				filename = p.FileName() // can be plan.zip, plan.ref, sdk.zip or extra.zip
 				kind     = strings.TrimSuffix(filename, ".zip")
 			)
 
-			// Read the archive.
+			switch filename {
+			case "plan.zip", "sdk.zip", "extra.zip":
+			case "plan.ref":
+				if unpacked.PlanDir, err = d.restoreCachedPlan(p, dir); err != nil {
+					return nil, err
+				}
+				continue
+			default:
+				return nil, fmt.Errorf("unexpected source archive: %q", filename)
+			}
+
+			// Stream the archive to disk.
 			targetzip, err := os.Create(filepath.Join(dir, filename))
 			if err != nil {
 				return nil, fmt.Errorf("failed to create file for %s: %w", kind, err)
 			}
-			if _, err = io.Copy(targetzip, p); err != nil {
+			_, err = io.Copy(targetzip, p)
+			if cerr := targetzip.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
 				return nil, fmt.Errorf("unexpected error when copying %s: %w", kind, err)
 			}
 
 			// Inflate the archive.
 			destdir := filepath.Join(dir, kind)
 			if err := os.Mkdir(destdir, 0755); err != nil {
-				return nil, fmt.Errorf("failed to create directory for sdk: %w", err)
+				return nil, fmt.Errorf("failed to create directory for %s: %w", kind, err)
 			}
 			logging.S().Infof("extracting %s to %s", filename, destdir)
-			if err := archiver.NewZip().Unarchive(targetzip.Name(), destdir); err != nil {
-				return nil, fmt.Errorf("failed to decompress sdk: %w", err)
+			if err := unzip(targetzip.Name(), destdir, limits); err != nil {
+				return nil, fmt.Errorf("failed to decompress %s: %w", kind, err)
 			}
 
 			// Set the right directory.
@@ -163,6 +204,11 @@ Outer:
 				unpacked.ExtraDir = destdir
 			case "plan":
 				unpacked.PlanDir = destdir
+				if d.sources != nil {
+					if err := d.sources.store(destdir); err != nil {
+						logging.S().Warnw("failed to cache plan source", "err", err)
+					}
+				}
 			}
 		default:
 			// an error occurred.
@@ -172,3 +218,20 @@ Outer:
 
 	return unpacked, nil
 }
+
+// restoreCachedPlan copies the cached plan source referenced by the supplied
+// part into dir, returning the plan directory.
+func (d *Daemon) restoreCachedPlan(p io.Reader, dir string) (string, error) {
+	if d.sources == nil {
+		return "", fmt.Errorf("plan source cache is disabled")
+	}
+	hash, err := ioutil.ReadAll(io.LimitReader(p, 128))
+	if err != nil {
+		return "", fmt.Errorf("unexpected error when reading plan reference: %w", err)
+	}
+	destdir := filepath.Join(dir, "plan")
+	if err := d.sources.restore(strings.TrimSpace(string(hash)), destdir); err != nil {
+		return "", fmt.Errorf("failed to restore cached plan source: %w", err)
+	}
+	return destdir, nil
+}
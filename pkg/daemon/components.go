@@ -0,0 +1,19 @@
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func (d *Daemon) componentsHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tgw := rpc.NewOutputWriter(w, r)
+
+		tgw.WriteResult(api.ComponentsResponse{
+			Builders: engine.DescribeBuilders(),
+			Runners:  engine.DescribeRunners(),
+		})
+	}
+}
@@ -2,26 +2,44 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
-	"strings"
+	"path/filepath"
+	"regexp"
+	"sync"
 	"time"
 
+	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/engine"
 	"github.com/testground/testground/pkg/logging"
 	"github.com/testground/testground/pkg/metrics"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
 
 	"github.com/gorilla/mux"
 	"github.com/pborman/uuid"
 )
 
+// requestIDPattern matches the request IDs the daemon accepts from clients.
+// Request IDs name the directories requests are unpacked into, and label
+// containers and pods, so they must be valid Kubernetes label values.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+
 type Daemon struct {
-	server *http.Server
-	l      net.Listener
-	mv     *metrics.Viewer
-	doneCh chan struct{}
+	server  *http.Server
+	l       net.Listener
+	mv      *metrics.Viewer
+	sources *sourceCache
+	doneCh  chan struct{}
+	engine  *engine.Engine
+	// tokens are the accepted bearer tokens; any request is accepted when
+	// empty. They are replaced on Reload.
+	tokens   map[string]struct{}
+	tokensLk sync.RWMutex
 }
 
 // New creates a new Daemon and attaches the following handlers:
@@ -30,6 +48,8 @@ type Daemon struct {
 // * GET /describe: sends a `describe` request to the daemon. describes a test plan or test case.
 // * POST /build: sends a `build` request to the daemon. builds a test plan.
 // * POST /run: sends a `run` request to the daemon. (builds and) runs test case with name `<testplan>/<testcase>`.
+// * POST /sources: checks whether the daemon already holds a plan source, which then need not be uploaded.
+// * GET /livez, /readyz: liveness and readiness probes for orchestrators; they require no token.
 // A type-safe client for this server can be found in the `pkg/client` package.
 func New(cfg *config.EnvConfig) (srv *Daemon, err error) {
 	srv = new(Daemon)
@@ -44,50 +64,73 @@ func New(cfg *config.EnvConfig) (srv *Daemon, err error) {
 		return nil, err
 	}
 
-	r := mux.NewRouter().StrictSlash(true)
+	srv.sources, err = newSourceCache(filepath.Join(cfg.Dirs().Work(), "sources"), cfg.Daemon.SourceCacheMB)
+	if err != nil {
+		return nil, err
+	}
 
-	if len(cfg.Daemon.Tokens) > 0 {
-		tokens := map[string]struct{}{}
-		for _, t := range cfg.Daemon.Tokens {
-			tokens[strings.TrimSpace(t)] = struct{}{}
-		}
+	srv.engine = engine
+	srv.setTokens(cfg.Daemon.Tokens)
+
+	r := mux.NewRouter().StrictSlash(true)
 
-		r.Use(func(next http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				splitToken := strings.Split(r.Header.Get("Authorization"), "Bearer ")
-				if len(splitToken) == 2 {
-					requestToken := strings.TrimSpace(splitToken[1])
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := probePaths[r.URL.Path]; ok {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-					if _, ok := tokens[requestToken]; ok {
-						next.ServeHTTP(w, r)
-						return
-					}
-				}
+			if srv.authorized(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-				w.WriteHeader(403)
-			})
+			w.WriteHeader(403)
 		})
-	}
+	})
 
-	// Set a unique request ID.
+	// Set a unique request ID, unless the client supplied a valid one, and
+	// echo it back.
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			r.Header.Set("X-Request-ID", uuid.New()[:8])
+			if !requestIDPattern.MatchString(r.Header.Get(api.RequestIDHeader)) {
+				r.Header.Set(api.RequestIDHeader, uuid.New()[:8])
+			}
+			w.Header().Set(api.RequestIDHeader, r.Header.Get(api.RequestIDHeader))
 			next.ServeHTTP(w, r)
 		})
 	})
 
+	requestTimeout, err := time.ParseDuration(cfg.Daemon.RequestTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid daemon request_timeout: %w", err)
+	}
+
+	// quick wraps the handlers of endpoints that respond promptly, bounding
+	// them by the request timeout. Endpoints streaming the progress of tasks
+	// or large payloads are left to the server read and write timeouts.
+	quick := func(h http.HandlerFunc) http.Handler {
+		if requestTimeout == 0 {
+			return h
+		}
+		return http.TimeoutHandler(h, requestTimeout, "request timed out")
+	}
+
 	staticDir := "/static/"
 	r.PathPrefix(staticDir).Handler(http.StripPrefix(staticDir, http.FileServer(http.Dir("."+staticDir))))
 
-	r.HandleFunc("/data", srv.dataHandler(engine)).Methods("GET")
-	r.HandleFunc("/dashboard", srv.dashboardHandler(engine)).Methods("GET")
-	r.HandleFunc("/kill", srv.killTaskHandler(engine)).Methods("GET")
-	r.HandleFunc("/delete", srv.deleteHandler(engine)).Methods("GET") // temporary endpoint until we build a proper ACL/admin endpoints within the daemon
-	r.HandleFunc("/tasks", srv.listTasksHandler(engine)).Methods("GET")
+	r.Handle("/data", quick(srv.dataHandler(engine))).Methods("GET")
+	r.Handle("/dashboard", quick(srv.dashboardHandler(engine))).Methods("GET")
+	r.Handle("/kill", quick(srv.killTaskHandler(engine))).Methods("GET")
+	r.Handle("/delete", quick(srv.deleteHandler(engine))).Methods("GET") // temporary endpoint until we build a proper ACL/admin endpoints within the daemon
+	r.Handle("/tasks", quick(srv.listTasksHandler(engine))).Methods("GET")
 	r.HandleFunc("/logs", srv.getLogsHandler(engine)).Methods("GET")
 	r.HandleFunc("/outputs", srv.getOutputsHandler(engine)).Methods("GET")
-	r.HandleFunc("/journal", srv.getJournalHandler(engine)).Methods("GET")
+	r.Handle("/journal", quick(srv.getJournalHandler(engine))).Methods("GET")
+	r.HandleFunc("/livez", srv.livezHandler()).Methods("GET")
+	r.HandleFunc("/readyz", srv.readyzHandler(engine)).Methods("GET")
+	r.Handle("/metrics", runner.StageMetrics).Methods("GET")
 	r.HandleFunc("/", srv.redirect()).Methods("GET")
 
 	r.HandleFunc("/build", srv.buildHandler(engine)).Methods("POST")
@@ -95,16 +138,30 @@ func New(cfg *config.EnvConfig) (srv *Daemon, err error) {
 	r.HandleFunc("/run", srv.runHandler(engine)).Methods("POST")
 	r.HandleFunc("/outputs", srv.outputsHandler(engine)).Methods("POST")
 	r.HandleFunc("/terminate", srv.terminateHandler(engine)).Methods("POST")
+	r.HandleFunc("/prune", srv.pruneHandler(engine)).Methods("POST")
+	// healthchecks may apply fixes, such as pulling images, which can take
+	// long.
 	r.HandleFunc("/healthcheck", srv.healthcheckHandler(engine)).Methods("POST")
-	r.HandleFunc("/tasks", srv.tasksHandler(engine)).Methods("POST")
-	r.HandleFunc("/status", srv.statusHandler(engine)).Methods("POST")
+	r.Handle("/tasks", quick(srv.tasksHandler(engine))).Methods("POST")
+	r.Handle("/status", quick(srv.statusHandler(engine))).Methods("POST")
 	r.HandleFunc("/logs", srv.logsHandler(engine)).Methods("POST")
+	r.Handle("/sources", quick(srv.sourcesHandler())).Methods("POST")
+	r.Handle("/components", quick(srv.componentsHandler(engine))).Methods("POST")
+
+	readTimeout, err := time.ParseDuration(cfg.Daemon.ReadTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid daemon read_timeout: %w", err)
+	}
+	writeTimeout, err := time.ParseDuration(cfg.Daemon.WriteTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid daemon write_timeout: %w", err)
+	}
 
 	srv.doneCh = make(chan struct{})
 	srv.server = &http.Server{
 		Handler:      r,
-		WriteTimeout: 7200 * time.Second,
-		ReadTimeout:  7200 * time.Second,
+		WriteTimeout: writeTimeout,
+		ReadTimeout:  readTimeout,
 	}
 
 	srv.l, err = net.Listen("tcp", cfg.Daemon.Listen)
@@ -143,3 +200,13 @@ func (d *Daemon) Shutdown(ctx context.Context) error {
 	defer close(d.doneCh)
 	return d.server.Shutdown(ctx)
 }
+
+// errorCode returns the code of the error chunk reporting err to the client.
+func errorCode(err error) rpc.ErrorCode {
+	switch {
+	case errors.Is(err, api.ErrTestCaseNotFound), errors.Is(err, task.ErrNotFound):
+		return rpc.ErrorCodeNotFound
+	default:
+		return rpc.ErrorCodeGeneric
+	}
+}
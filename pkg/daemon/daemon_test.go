@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestRequestIDPattern(t *testing.T) {
+	for id, valid := range map[string]bool{
+		"1a2b3c4d":              true,
+		"ci-build.1234_5":       true,
+		"":                      false,
+		"..":                    false,
+		"../etc":                false,
+		"a/b":                   false,
+		"-leading":              false,
+		"trailing-":             false,
+		"with space":            false,
+		strings.Repeat("a", 63): true,
+		strings.Repeat("a", 64): false,
+	} {
+		if got := requestIDPattern.MatchString(id); got != valid {
+			t.Errorf("request ID %q: expected valid=%t, got %t", id, valid, got)
+		}
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	for err, code := range map[error]rpc.ErrorCode{
+		fmt.Errorf("%w: foo in plan bar", api.ErrTestCaseNotFound):    rpc.ErrorCodeNotFound,
+		fmt.Errorf("error while e.Status, err: %w", task.ErrNotFound): rpc.ErrorCodeNotFound,
+		errors.New("unknown runner: foo"):                             rpc.ErrorCodeGeneric,
+	} {
+		if got := errorCode(err); got != code {
+			t.Errorf("%s: expected code %s, got %s", err, code, got)
+		}
+	}
+}
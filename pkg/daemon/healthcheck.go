@@ -21,7 +21,7 @@ func (d *Daemon) healthcheckHandler(engine api.Engine) func(w http.ResponseWrite
 		var req api.HealthcheckRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			tgw.WriteError("healthcheck json decode", "err", err.Error())
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "healthcheck json decode", "err", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -21,14 +21,14 @@ func (d *Daemon) logsHandler(engine api.Engine) func(w http.ResponseWriter, r *h
 		var req api.LogsRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			tgw.WriteError("logs json decode", "err", err.Error())
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "logs json decode", "err", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
 		tsk, err := engine.Logs(r.Context(), req.TaskID, req.Follow, req.CancelWithContext, w)
 		if err != nil {
-			tgw.WriteError("error while getting task", "err", err)
+			tgw.WriteErrorCode(errorCode(err), "error while getting task", "err", err)
 			return
 		}
 
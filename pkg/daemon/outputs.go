@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"github.com/testground/testground/pkg/client"
 	"github.com/testground/testground/pkg/logging"
 	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
 )
 
 func (d *Daemon) outputsHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
@@ -31,11 +33,21 @@ func (d *Daemon) outputsHandler(engine api.Engine) func(w http.ResponseWriter, r
 		tgw := rpc.NewOutputWriter(w, r)
 
 		result := false
+		corrupt := false
 		defer func() {
-			tgw.WriteResult(result)
+			if !corrupt {
+				tgw.WriteResult(result)
+			}
 		}()
 
 		err = engine.DoCollectOutputs(r.Context(), req.RunID, tgw)
+		if errors.Is(err, runner.ErrCorruptOutputs) {
+			// the client must discard what it received, rather than take it
+			// for a missing run.
+			corrupt = true
+			tgw.WriteErrorCode(rpc.ErrorCodeInfrastructure, "collect outputs error", "err", err.Error())
+			return
+		}
 		if err != nil {
 			log.Warnw("collect outputs error", "err", err.Error())
 			return
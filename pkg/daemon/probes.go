@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+)
+
+// readyTimeout bounds the checks performed by the readiness probe.
+const readyTimeout = 5 * time.Second
+
+// probePaths are the paths of the liveness and readiness probes used by
+// orchestrators such as systemd or Kubernetes. They are served without
+// authentication.
+var probePaths = map[string]struct{}{
+	"/livez":  {},
+	"/readyz": {},
+}
+
+// livezHandler responds successfully as long as the daemon serves requests.
+func (d *Daemon) livezHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// readyzHandler responds successfully if the engine is ready to serve
+// requests. The optional `runner` url param additionally checks that the
+// infrastructure of that runner is reachable; runner healthchecks are never
+// run.
+func (d *Daemon) readyzHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+
+		if err := engine.Ready(ctx, r.URL.Query().Get("runner")); err != nil {
+			logging.S().Warnw("daemon not ready", "req_id", r.Header.Get("X-Request-ID"), "err", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "not ready: %s\n", err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
@@ -0,0 +1,53 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+// readyEngine is an api.Engine whose readiness is controlled by tests; any
+// other method panics.
+type readyEngine struct {
+	api.Engine
+	err    error
+	runner string
+}
+
+func (e *readyEngine) Ready(_ context.Context, runner string) error {
+	e.runner = runner
+	return e.err
+}
+
+func TestReadyz(t *testing.T) {
+	d := new(Daemon)
+
+	engine := &readyEngine{}
+	w := httptest.NewRecorder()
+	d.readyzHandler(engine)(w, httptest.NewRequest("GET", "/readyz?runner=local:docker", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("ready engine: got status %d, expected %d", w.Code, http.StatusOK)
+	}
+	if engine.runner != "local:docker" {
+		t.Errorf("runner not passed to the engine: got %q", engine.runner)
+	}
+
+	engine.err = errors.New("task storage unavailable")
+	w = httptest.NewRecorder()
+	d.readyzHandler(engine)(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unready engine: got status %d, expected %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestLivez(t *testing.T) {
+	w := httptest.NewRecorder()
+	new(Daemon).livezHandler()(w, httptest.NewRequest("GET", "/livez", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, expected %d", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func (d *Daemon) pruneHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.S().With("req_id", r.Header.Get("X-Request-ID"))
+
+		log.Debugw("handle request", "command", "prune")
+		defer log.Debugw("request handled", "command", "prune")
+
+		tgw := rpc.NewOutputWriter(w, r)
+
+		var req api.PruneRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "prune json decode", "err", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		out, err := engine.DoPrune(r.Context(), req.Runner, tgw)
+		if err != nil {
+			tgw.WriteError("prune error", "err", err.Error())
+			return
+		}
+
+		tgw.WriteResult(out)
+	}
+}
@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/logging"
+)
+
+// Reload applies a new environment configuration to the running daemon,
+// without disrupting in-flight requests and tasks. The tokens are replaced,
+// and the rest of the configuration is handed over to the engine. Changes to
+// the settings of the HTTP server, such as the listen address and the
+// timeouts, are ignored with a warning, and require a restart.
+func (d *Daemon) Reload(cfg *config.EnvConfig) {
+	cur, next := d.engine.EnvConfig().Daemon, cfg.Daemon
+	for _, s := range []struct {
+		key     string
+		changed bool
+	}{
+		{"daemon.listen", next.Listen != cur.Listen},
+		{"daemon.read_timeout", next.ReadTimeout != cur.ReadTimeout},
+		{"daemon.write_timeout", next.WriteTimeout != cur.WriteTimeout},
+		{"daemon.request_timeout", next.RequestTimeout != cur.RequestTimeout},
+		{"daemon.source_cache_mb", next.SourceCacheMB != cur.SourceCacheMB},
+	} {
+		if s.changed {
+			logging.S().Warnw("ignoring configuration change that requires a restart", "key", s.key)
+		}
+	}
+
+	reloaded := *cfg
+	reloaded.Daemon.Listen = cur.Listen
+	reloaded.Daemon.ReadTimeout = cur.ReadTimeout
+	reloaded.Daemon.WriteTimeout = cur.WriteTimeout
+	reloaded.Daemon.RequestTimeout = cur.RequestTimeout
+	reloaded.Daemon.SourceCacheMB = cur.SourceCacheMB
+
+	d.setTokens(reloaded.Daemon.Tokens)
+	d.engine.Reload(&reloaded)
+}
+
+func (d *Daemon) setTokens(tokens []string) {
+	m := make(map[string]struct{}, len(tokens))
+	for _, t := range tokens {
+		m[strings.TrimSpace(t)] = struct{}{}
+	}
+
+	d.tokensLk.Lock()
+	d.tokens = m
+	d.tokensLk.Unlock()
+}
+
+// authorized returns whether the request carries one of the accepted bearer
+// tokens, or whether the daemon accepts any request.
+func (d *Daemon) authorized(r *http.Request) bool {
+	d.tokensLk.RLock()
+	defer d.tokensLk.RUnlock()
+
+	if len(d.tokens) == 0 {
+		return true
+	}
+
+	splitToken := strings.Split(r.Header.Get("Authorization"), "Bearer ")
+	if len(splitToken) != 2 {
+		return false
+	}
+	_, ok := d.tokens[strings.TrimSpace(splitToken[1])]
+	return ok
+}
@@ -25,28 +25,32 @@ func (d *Daemon) runHandler(engine api.Engine) func(w http.ResponseWriter, r *ht
 		// Create a packing directory under the workdir.
 		dir := filepath.Join(engine.EnvConfig().Dirs().Work(), "requests", ruid)
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			tgw.WriteError("failed to create temp directory to unpack request", "err", err)
+			tgw.WriteErrorCode(rpc.ErrorCodeInfrastructure, "failed to create temp directory to unpack request", "err", err)
 			return
 		}
 
 		var request *api.RunRequest
-		sources, err := consumeRunBuildRequest(r, &request, dir)
+		sources, err := d.consumeRunBuildRequest(r, &request, dir, sourceLimits(engine.EnvConfig().Daemon))
 		if err != nil {
-			tgw.WriteError("failed to consume request", "err", err)
+			writeConsumeError(w, tgw, err)
 			return
 		}
 
 		if len(request.BuildGroups) > 0 && sources == nil {
-			tgw.WriteError("failed to consume request", "err", errors.New("plan dir required for build"))
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "failed to consume request", "err", errors.New("plan dir required for build"))
 			return
 		}
 
+		request.IdempotencyKey = r.Header.Get(api.IdempotencyKeyHeader)
+		request.RequestID = ruid
+
 		id, err := engine.QueueRun(request, sources)
 		if err != nil {
-			tgw.WriteError(fmt.Sprintf("engine run error: %s", err))
+			tgw.WriteErrorCode(errorCode(err), fmt.Sprintf("engine run error: %s", err))
 			return
 		}
 
+		tgw.WriteStage(rpc.StageQueued)
 		tgw.WriteResult(id)
 	}
 }
@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+
+	"github.com/otiai10/copy"
+)
+
+// errSourceNotCached is returned when restoring a source that isn't cached,
+// e.g. because it was evicted after the client checked for it.
+var errSourceNotCached = errors.New("source is not cached")
+
+// sourceCache is a content-addressed cache of unpacked plan sources, keyed by
+// their api.HashSourceDir hash. It is bounded by a disk budget, and evicts the
+// least recently used sources first.
+type sourceCache struct {
+	lk     sync.Mutex
+	dir    string
+	budget int64
+}
+
+// newSourceCache returns a cache rooted at dir, or nil if budgetMB is
+// negative, which disables caching.
+func newSourceCache(dir string, budgetMB int) (*sourceCache, error) {
+	if budgetMB < 0 {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create source cache directory: %w", err)
+	}
+	return &sourceCache{dir: dir, budget: int64(budgetMB) << 20}, nil
+}
+
+// path returns the directory of the source with the supplied hash, validating
+// that the hash is well-formed, as it is supplied by clients.
+func (c *sourceCache) path(hash string) (string, error) {
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != 32 {
+		return "", fmt.Errorf("invalid source hash: %q", hash)
+	}
+	return filepath.Join(c.dir, hash), nil
+}
+
+// has returns whether the source with the supplied hash is cached.
+func (c *sourceCache) has(hash string) bool {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	p, err := c.path(hash)
+	if err != nil {
+		return false
+	}
+	return c.touch(p) == nil
+}
+
+// restore copies the source with the supplied hash to dst.
+func (c *sourceCache) restore(hash string, dst string) error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	p, err := c.path(hash)
+	if err != nil {
+		return err
+	}
+	if err := c.touch(p); err != nil {
+		return fmt.Errorf("%w: %s", errSourceNotCached, hash)
+	}
+	return copy.Copy(p, dst)
+}
+
+// store adds the source in src to the cache, evicting other sources if the
+// budget is exceeded.
+func (c *sourceCache) store(src string) error {
+	hash, err := api.HashSourceDir(src)
+	if err != nil {
+		return err
+	}
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	p, _ := c.path(hash)
+	if c.touch(p) == nil {
+		return nil
+	}
+
+	// copy into a temporary directory first, so that a partial copy is never
+	// served.
+	tmp, err := ioutil.TempDir(c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if err := copy.Copy(src, tmp); err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+
+	c.evict()
+	return nil
+}
+
+// touch marks a cached source as used, failing if it doesn't exist.
+func (c *sourceCache) touch(p string) error {
+	now := time.Now()
+	return os.Chtimes(p, now, now)
+}
+
+// evict removes the least recently used sources until the cache fits in its
+// budget. It must be called with the lock held.
+func (c *sourceCache) evict() {
+	fis, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		logging.S().Warnw("failed to list source cache", "err", err)
+		return
+	}
+
+	type entry struct {
+		path string
+		used time.Time
+		size int64
+	}
+
+	var (
+		entries []entry
+		total   int64
+	)
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		e := entry{path: filepath.Join(c.dir, fi.Name()), used: fi.ModTime()}
+		_ = filepath.Walk(e.path, func(_ string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				e.size += info.Size()
+			}
+			return nil
+		})
+		entries = append(entries, e)
+		total += e.size
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].used.Before(entries[j].used) })
+	for _, e := range entries {
+		if total <= c.budget {
+			break
+		}
+		if err := os.RemoveAll(e.path); err != nil {
+			logging.S().Warnw("failed to evict cached source", "path", e.path, "err", err)
+			continue
+		}
+		total -= e.size
+	}
+}
+
+func (d *Daemon) sourcesHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tgw := rpc.NewOutputWriter(w, r)
+
+		var req api.SourcesRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "sources json decode", "err", err.Error())
+			return
+		}
+
+		var resp api.SourcesResponse
+		if d.sources != nil {
+			resp.Cached = d.sources.has(req.PlanHash)
+		}
+		tgw.WriteResult(resp)
+	}
+}
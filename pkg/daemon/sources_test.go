@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func TestSourceCache(t *testing.T) {
+	root := t.TempDir()
+	c, err := newSourceCache(filepath.Join(root, "cache"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// budget for a single 10-byte source.
+	c.budget = 15
+
+	mksrc := func(name, content string) (string, string) {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := api.HashSourceDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dir, hash
+	}
+
+	first, firstHash := mksrc("first", strings.Repeat("a", 10))
+	second, secondHash := mksrc("second", strings.Repeat("b", 10))
+
+	if c.has(firstHash) {
+		t.Fatal("empty cache reports a cached source")
+	}
+	if err := c.store(first); err != nil {
+		t.Fatal(err)
+	}
+	if !c.has(firstHash) {
+		t.Fatal("stored source is not cached")
+	}
+
+	dst := filepath.Join(root, "restored")
+	if err := c.restore(firstHash, dst); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := api.HashSourceDir(dst); err != nil || h != firstHash {
+		t.Errorf("restored source differs: %s, %v", h, err)
+	}
+
+	// storing the second source evicts the least recently used first one.
+	time.Sleep(10 * time.Millisecond)
+	if err := c.store(second); err != nil {
+		t.Fatal(err)
+	}
+	if c.has(firstHash) {
+		t.Error("expected the first source to be evicted")
+	}
+	if err := c.restore(firstHash, filepath.Join(root, "evicted")); !errors.Is(err, errSourceNotCached) {
+		t.Errorf("expected restoring an evicted source to fail with errSourceNotCached, got %v", err)
+	}
+	if !c.has(secondHash) {
+		t.Error("expected the second source to be cached")
+	}
+
+	// hashes are validated, as they come from clients.
+	if c.has("../" + secondHash) {
+		t.Error("malformed hash reported as cached")
+	}
+	if err := c.restore("..", dst); err == nil {
+		t.Error("expected an error restoring a malformed hash")
+	}
+}
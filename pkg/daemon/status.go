@@ -2,10 +2,12 @@ package daemon
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
 )
 
 func (d *Daemon) statusHandler(engine api.Engine) func(w http.ResponseWriter, r *http.Request) {
@@ -15,17 +17,29 @@ func (d *Daemon) statusHandler(engine api.Engine) func(w http.ResponseWriter, r
 		var req api.StatusRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			tgw.WriteError("status json decode", "err", err.Error())
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "status json decode", "err", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
 		tsk, err := engine.GetTask(req.TaskID)
+		if errors.Is(err, task.ErrNotFound) {
+			tgw.WriteErrorCode(rpc.ErrorCodeNotFound, "task not found", "task_id", req.TaskID)
+			return
+		}
 		if err != nil {
 			tgw.Warnw("could not fetch status", "task_id", req.TaskID, "err", err)
 			return
 		}
 
-		tgw.WriteResult(tsk)
+		res := &api.StatusResponse{Task: *tsk}
+		if tsk.State().State == task.StateScheduled {
+			res.Queue, err = engine.QueueStatus(tsk.ID)
+			if err != nil {
+				tgw.Warnw("could not fetch queue status", "task_id", req.TaskID, "err", err)
+			}
+		}
+
+		tgw.WriteResult(res)
 	}
 }
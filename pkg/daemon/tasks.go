@@ -32,14 +32,14 @@ func (d *Daemon) tasksHandler(engine api.Engine) func(w http.ResponseWriter, r *
 		var req api.TasksRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			tgw.WriteError("tasks json decode", "err", err.Error())
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "tasks json decode", "err", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
 		tasks, err := engine.Tasks(req)
 		if err != nil {
-			tgw.WriteError("tasks json decode", "err", err.Error())
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "tasks json decode", "err", err.Error())
 			return
 		}
 
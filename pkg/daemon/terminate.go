@@ -21,7 +21,7 @@ func (d *Daemon) terminateHandler(engine api.Engine) func(w http.ResponseWriter,
 		var req api.TerminateRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			tgw.WriteError("terminate json decode", "err", err.Error())
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "terminate json decode", "err", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -33,7 +33,7 @@ func (d *Daemon) terminateHandler(engine api.Engine) func(w http.ResponseWriter,
 
 		switch {
 		case req.Builder != "" && req.Runner != "":
-			tgw.WriteError("cannot terminate a runner and a builder at the same time")
+			tgw.WriteErrorCode(rpc.ErrorCodeBadRequest, "cannot terminate a runner and a builder at the same time")
 			return
 		case req.Builder != "":
 			ctype = api.BuilderType
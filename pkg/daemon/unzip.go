@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// unzipLimits bound the contents of a source archive.
+type unzipLimits struct {
+	// maxSize is the maximum total uncompressed size, in bytes.
+	maxSize int64
+	// maxFiles is the maximum number of entries.
+	maxFiles int
+}
+
+// unzip extracts the zip archive at src into dst, validating that every entry
+// stays within dst and that the archive is within limits. Sizes are enforced
+// on the bytes actually extracted, not on the sizes the archive declares.
+func unzip(src, dst string, limits unzipLimits) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	if limits.maxFiles > 0 && len(zr.File) > limits.maxFiles {
+		return fmt.Errorf("archive contains %d files, over the limit of %d", len(zr.File), limits.maxFiles)
+	}
+
+	remaining := limits.maxSize
+	for _, f := range zr.File {
+		target, err := entryPath(dst, f.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = os.MkdirAll(target, 0755)
+		case mode&os.ModeSymlink != 0:
+			err = extractSymlink(f, dst, target)
+		case mode.IsRegular():
+			var n int64
+			n, err = extractFile(f, target, remaining, limits.maxSize > 0)
+			remaining -= n
+		default:
+			err = fmt.Errorf("unsupported file type: %s", mode.Type())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// entryPath returns the path an archive entry extracts to, rejecting absolute
+// paths and paths that escape dst.
+func entryPath(dst, name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("archive entry has an absolute path: %s", name)
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("archive entry escapes the target directory: %s", name)
+		}
+	}
+	return filepath.Join(dst, filepath.FromSlash(name)), nil
+}
+
+func extractFile(f *zip.File, target string, remaining int64, limited bool) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, f.Mode().Perm()|0600)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	if limited {
+		// copy one byte past the limit, to tell an archive that is exactly at
+		// the limit from one that is over it.
+		n, err = io.CopyN(out, rc, remaining+1)
+		if err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = fmt.Errorf("archive exceeds the maximum uncompressed size")
+		}
+	} else {
+		n, err = io.Copy(out, rc)
+	}
+
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// extractSymlink creates a symlink, provided that its target is relative and
+// resolves within dst. The target is resolved against the directories already
+// on disk, and may only go up before going down, so that it can't escape dst
+// through other symlinks: all links to leave dst must go up through one, as
+// in a/b -> .. followed by x -> a/b/.. .
+func extractSymlink(f *zip.File, dst, target string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// symlink targets are short; anything longer is not a legitimate link.
+	b, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return err
+	}
+	link := string(b)
+
+	if filepath.IsAbs(link) {
+		return fmt.Errorf("symlink to an absolute path: %s", link)
+	}
+	down := false
+	for _, elem := range strings.Split(filepath.ToSlash(link), "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			if down {
+				return fmt.Errorf("symlink goes up after going down: %s", link)
+			}
+		default:
+			down = true
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	realDst, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return err
+	}
+	realDir, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	resolved := filepath.Join(realDir, link)
+	if rel, err := filepath.Rel(realDst, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("symlink escapes the target directory: %s", link)
+	}
+	return os.Symlink(link, target)
+}
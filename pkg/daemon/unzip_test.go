@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type zipEntry struct {
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+func writeZip(t *testing.T, entries []zipEntry) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "src.zip")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		hdr.SetMode(e.mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestUnzip(t *testing.T) {
+	src := writeZip(t, []zipEntry{
+		{name: "main.go", content: "package main", mode: 0644},
+		{name: "sub/", mode: os.ModeDir | 0755},
+		{name: "sub/data.txt", content: "data", mode: 0644},
+		{name: "link", content: "sub/data.txt", mode: os.ModeSymlink | 0777},
+	})
+
+	dst := t.TempDir()
+	if err := unzip(src, dst, unzipLimits{maxSize: 16, maxFiles: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dst, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "data" {
+		t.Errorf("unexpected content through symlink: %q", b)
+	}
+}
+
+func TestUnzipRejects(t *testing.T) {
+	cases := map[string]struct {
+		entries []zipEntry
+		limits  unzipLimits
+		errmsg  string
+	}{
+		"traversal": {
+			entries: []zipEntry{{name: "../../etc/passwd", content: "x", mode: 0644}},
+			errmsg:  "escapes",
+		},
+		"nested traversal": {
+			entries: []zipEntry{{name: `sub\..\..\evil`, content: "x", mode: 0644}},
+			errmsg:  "escapes",
+		},
+		"absolute": {
+			entries: []zipEntry{{name: "/etc/passwd", content: "x", mode: 0644}},
+			errmsg:  "absolute",
+		},
+		"escaping symlink": {
+			entries: []zipEntry{{name: "link", content: "../outside", mode: os.ModeSymlink | 0777}},
+			errmsg:  "symlink escapes",
+		},
+		"chained symlinks": {
+			entries: []zipEntry{
+				{name: "a/", mode: os.ModeDir | 0755},
+				{name: "a/b", content: "..", mode: os.ModeSymlink | 0777},
+				{name: "outside", content: "a/b/..", mode: os.ModeSymlink | 0777},
+			},
+			errmsg: "goes up after going down",
+		},
+		"symlink through a symlinked directory": {
+			entries: []zipEntry{
+				{name: "a/", mode: os.ModeDir | 0755},
+				{name: "a/b", content: "..", mode: os.ModeSymlink | 0777},
+				{name: "a/b/c/", mode: os.ModeDir | 0755},
+				{name: "a/b/c/link", content: "../../outside", mode: os.ModeSymlink | 0777},
+			},
+			errmsg: "symlink escapes",
+		},
+		"absolute symlink": {
+			entries: []zipEntry{{name: "link", content: "/etc", mode: os.ModeSymlink | 0777}},
+			errmsg:  "absolute",
+		},
+		"too large": {
+			entries: []zipEntry{{name: "a", content: "12345", mode: 0644}, {name: "b", content: "12345", mode: 0644}},
+			limits:  unzipLimits{maxSize: 9},
+			errmsg:  "maximum uncompressed size",
+		},
+		"too many files": {
+			entries: []zipEntry{{name: "a", mode: 0644}, {name: "b", mode: 0644}},
+			limits:  unzipLimits{maxFiles: 1},
+			errmsg:  "over the limit",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			src := writeZip(t, c.entries)
+			parent := t.TempDir()
+			dst := filepath.Join(parent, "dst")
+			if err := os.Mkdir(dst, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			err := unzip(src, dst, c.limits)
+			if err == nil || !strings.Contains(err.Error(), c.errmsg) {
+				t.Fatalf("expected error containing %q, got %v", c.errmsg, err)
+			}
+			if _, err := os.Stat(filepath.Join(parent, "outside")); !os.IsNotExist(err) {
+				t.Error("extraction wrote outside of the target directory")
+			}
+		})
+	}
+}
@@ -184,7 +184,7 @@ func DeleteContainers(cli *client.Client, ow *rpc.OutputWriter, ids []string) (e
 	for i := 0; i < len(ids); i++ {
 		if err := <-errs; err != nil {
 			ow.Errorw("failed while deleting container", "error", err)
-			merr = multierror.Append(merr, <-errs)
+			merr = multierror.Append(merr, err)
 		}
 	}
 	close(errs)
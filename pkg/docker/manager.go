@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/testground/testground/pkg/logging"
@@ -12,6 +14,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
+	"github.com/hashicorp/go-multierror"
 )
 
 type WorkerFn func(context.Context, *ContainerRef) error
@@ -19,6 +22,9 @@ type WorkerFn func(context.Context, *ContainerRef) error
 const (
 	workerShutdownTimeout = 1 * time.Minute
 	workerShutdownTick    = 5 * time.Second
+
+	// runIDLabel is the label that holds the run ID of plan containers.
+	runIDLabel = "testground.run_id"
 )
 
 // Manager is a convenient wrapper around the docker client.
@@ -26,6 +32,9 @@ type Manager struct {
 	logging.Logging
 
 	*client.Client
+
+	// managed counts the containers with a running worker.
+	managed int64
 }
 
 // NewManager connects to the local docker instance and provides a convenient
@@ -42,6 +51,11 @@ func NewManager() (*Manager, error) {
 	}, nil
 }
 
+// Managed returns the number of containers currently managed by Watch.
+func (m *Manager) Managed() int {
+	return int(atomic.LoadInt64(&m.managed))
+}
+
 // Close closes the docker client.
 func (m *Manager) Close() error {
 	return m.Client.Close()
@@ -102,7 +116,13 @@ func (c *ContainerRef) Exec(ctx context.Context, cmd ...string) error {
 //
 // If you pass labels, only containers labeled with at least one of the given
 // labels will be managed.
-func (m *Manager) Watch(ctx context.Context, worker WorkerFn, labels ...string) error {
+//
+// A worker failing doesn't affect the workers of other containers: the
+// failure is logged, along with the run of the container, and collected.
+// Watch only returns on a fatal error, such as losing the connection to
+// Docker, or when the context is done. The returned error then includes the
+// failures of all workers.
+func (m *Manager) Watch(ctx context.Context, worker WorkerFn, labels ...string) (err error) {
 	type workerHandle struct {
 		done   chan struct{}
 		cancel context.CancelFunc
@@ -111,6 +131,19 @@ func (m *Manager) Watch(ctx context.Context, worker WorkerFn, labels ...string)
 	// Manage workers.
 	managers := make(map[string]workerHandle)
 
+	var (
+		failuresLk sync.Mutex
+		failures   *multierror.Error
+	)
+
+	defer func() {
+		failuresLk.Lock()
+		defer failuresLk.Unlock()
+		if failures != nil {
+			err = multierror.Append(err, failures.Errors...).ErrorOrNil()
+		}
+	}()
+
 	defer func() {
 		// wait for the running managers to exit
 		// They'll get canceled when we close the main context (deferred
@@ -151,7 +184,7 @@ func (m *Manager) Watch(ctx context.Context, worker WorkerFn, labels ...string)
 		}
 	}
 
-	start := func(containerID string) {
+	start := func(containerID string, runID string) {
 		if _, ok := managers[containerID]; ok {
 			return
 		}
@@ -163,15 +196,22 @@ func (m *Manager) Watch(ctx context.Context, worker WorkerFn, labels ...string)
 			cancel: cancel,
 		}
 
+		atomic.AddInt64(&m.managed, 1)
 		go func() {
 			defer close(done)
+			defer atomic.AddInt64(&m.managed, -1)
+
 			handle := m.NewContainerRef(containerID)
 			err := worker(cctx, handle)
 			if err != nil {
 				if errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "context canceled") { // docker doesn't wrap errors
 					handle.S().Debugf("sidecar worker failed: %s", err)
 				} else {
-					handle.S().Errorf("sidecar worker failed: %s", err)
+					handle.S().Errorw("sidecar worker failed", "run_id", runID, "err", err)
+
+					failuresLk.Lock()
+					failures = multierror.Append(failures, fmt.Errorf("container %s (run %s): %w", containerID, runID, err))
+					failuresLk.Unlock()
 				}
 			}
 		}()
@@ -195,7 +235,7 @@ func (m *Manager) Watch(ctx context.Context, worker WorkerFn, labels ...string)
 	}
 
 	for _, n := range nodes {
-		start(n.ID)
+		start(n.ID, n.Labels[runIDLabel])
 	}
 
 	eventFilter := listFilter.Clone()
@@ -216,11 +256,11 @@ func (m *Manager) Watch(ctx context.Context, worker WorkerFn, labels ...string)
 		case event := <-eventCh:
 			switch event.Status {
 			case "start":
-				start(event.ID)
+				start(event.ID, event.Actor.Attributes[runIDLabel])
 			case "stop", "destroy", "die":
 				stop(event.ID)
 			default:
-				return fmt.Errorf("unexpected event: type=%s, status=%s", event.Type, event.Status)
+				m.S().Warnw("ignoring unexpected event", "type", event.Type, "status", event.Status)
 			}
 		case err := <-errs:
 			return err
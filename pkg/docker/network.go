@@ -51,3 +51,58 @@ func EnsureBridgeNetwork(ctx context.Context, ow *rpc.OutputWriter, cli *client.
 
 	return NewBridgeNetwork(ctx, cli, name, internal, nil, config...)
 }
+
+// BridgeNetworkMatches returns true if the network is configured with the
+// subnets of the supplied IPAM configs. A network always matches when no
+// configs are supplied.
+func BridgeNetworkMatches(nw types.NetworkResource, config ...network.IPAMConfig) bool {
+	for _, want := range config {
+		var found bool
+		for _, have := range nw.IPAM.Config {
+			if have.Subnet == want.Subnet {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// RecreateBridgeNetwork removes the network with the provided name and
+// creates it again with the supplied IPAM configs. Containers attached to the
+// old network are disconnected first, and reconnected to the new network.
+func RecreateBridgeNetwork(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, name string, internal bool, config ...network.IPAMConfig) (id string, err error) {
+	old, err := cli.NetworkInspect(ctx, name, types.NetworkInspectOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	containers := make([]string, 0, len(old.Containers))
+	for cid := range old.Containers {
+		ow.Debugw("disconnecting container from network", "network", name, "container", cid)
+		if err := cli.NetworkDisconnect(ctx, old.ID, cid, true); err != nil {
+			return "", err
+		}
+		containers = append(containers, cid)
+	}
+
+	if err := cli.NetworkRemove(ctx, old.ID); err != nil {
+		return "", err
+	}
+
+	id, err = NewBridgeNetwork(ctx, cli, name, internal, old.Labels, config...)
+	if err != nil {
+		return "", err
+	}
+
+	for _, cid := range containers {
+		ow.Debugw("reconnecting container to network", "network", name, "container", cid)
+		if err := cli.NetworkConnect(ctx, id, cid, nil); err != nil {
+			return id, err
+		}
+	}
+	return id, nil
+}
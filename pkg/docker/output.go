@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/pkg/jsonmessage"
 )
 
@@ -13,16 +14,40 @@ import (
 // if one occurs. In both cases, it will return the accumulated string
 // representation of the output.
 func PipeOutput(r io.ReadCloser, w io.Writer) (output string, err error) {
+	return pipeOutput(r, w, nil)
+}
+
+// PipePushOutput pipes the output of an image push like PipeOutput, and
+// returns the digest of the pushed image, as reported by the registry. The
+// digest is empty if the registry didn't report one.
+func PipePushOutput(r io.ReadCloser, w io.Writer) (digest string, err error) {
+	_, err = pipeOutput(r, w, func(aux *json.RawMessage) {
+		var res types.PushResult
+		if json.Unmarshal(*aux, &res) == nil && res.Digest != "" {
+			digest = res.Digest
+		}
+	})
+	return digest, err
+}
+
+// pipeOutput implements PipeOutput, calling onAux with the auxiliary
+// messages of the output, if not nil.
+func pipeOutput(r io.ReadCloser, w io.Writer, onAux func(*json.RawMessage)) (output string, err error) {
 	var (
-		msg   jsonmessage.JSONMessage
 		buf   = new(bytes.Buffer)
 		multi = io.MultiWriter(buf, w)
 	)
 
 Loop:
 	for dec := json.NewDecoder(r); ; {
+		// messages are decoded afresh, so that the fields of one don't
+		// carry over to the next.
+		var msg jsonmessage.JSONMessage
 		switch err := dec.Decode(&msg); err {
 		case nil:
+			if msg.Aux != nil && onAux != nil {
+				onAux(msg.Aux)
+			}
 			_ = msg.Display(multi, false)
 			if msg.Error != nil {
 				return buf.String(), msg.Error
@@ -0,0 +1,35 @@
+package docker_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/testground/testground/pkg/docker"
+)
+
+func TestPipePushOutput(t *testing.T) {
+	out := `{"status":"The push refers to repository [registry.example.com/testground]"}
+{"status":"Pushed","progressDetail":{},"id":"a1b2c3"}
+{"status":"abc123: digest: sha256:0123abcd size: 527"}
+{"progressDetail":{},"aux":{"Tag":"abc123","Digest":"sha256:0123abcd","Size":527}}
+{"status":"done"}
+`
+	var w bytes.Buffer
+	digest, err := docker.PipePushOutput(ioutil.NopCloser(strings.NewReader(out)), &w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != "sha256:0123abcd" {
+		t.Errorf("expected digest sha256:0123abcd, got %q", digest)
+	}
+	if !strings.Contains(w.String(), "Pushed") {
+		t.Errorf("expected the output to be piped, got %q", w.String())
+	}
+
+	digest, err = docker.PipePushOutput(ioutil.NopCloser(strings.NewReader(`{"errorDetail":{"message":"denied"},"error":"denied"}`)), &w)
+	if err == nil || digest != "" {
+		t.Errorf("expected an error and no digest, got %q and %v", digest, err)
+	}
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// callbackAttempts is the number of times the engine tries to deliver a task
+// callback; attempts are spaced by an exponential backoff starting at
+// callbackBackoff.
+const (
+	callbackAttempts = 5
+	callbackBackoff  = 2 * time.Second
+)
+
+// newTaskCallback summarises a completed task for its callback.
+func newTaskCallback(tsk *task.Task) *api.TaskCallback {
+	cb := &api.TaskCallback{
+		TaskID:   tsk.ID,
+		Type:     tsk.Type,
+		Plan:     tsk.Plan,
+		Case:     tsk.Case,
+		State:    tsk.State().State,
+		Error:    tsk.Error,
+		Duration: tsk.Took().Seconds(),
+		Result:   tsk.Result,
+	}
+
+	// leave the journal out of run results, it is only useful for debugging
+	// and can be large.
+	if r, ok := tsk.Result.(*runner.Result); ok {
+		cb.Outcome = r.Outcome
+		cb.Result = &runner.Result{Outcome: r.Outcome, Outcomes: r.Outcomes}
+	}
+	return cb
+}
+
+// postCallback delivers the callback of a completed task, if it has one,
+// retrying with backoff on failures. It blocks until the callback has been
+// delivered or all attempts failed.
+func (e *Engine) postCallback(tsk *task.Task) {
+	if tsk.Callback == "" {
+		return
+	}
+
+	body, err := json.Marshal(newTaskCallback(tsk))
+	if err != nil {
+		logging.S().Errorw("could not marshal task callback", "task_id", tsk.ID, "err", err)
+		return
+	}
+
+	backoff := callbackBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := e.sendCallback(tsk.Callback, body)
+		if err == nil {
+			logging.S().Infow("task callback delivered", "task_id", tsk.ID, "attempt", attempt)
+			return
+		}
+		if !retry || attempt == callbackAttempts {
+			logging.S().Errorw("could not deliver task callback", "task_id", tsk.ID, "attempt", attempt, "err", err)
+			return
+		}
+		logging.S().Warnw("task callback failed; retrying", "task_id", tsk.ID, "attempt", attempt, "backoff", backoff, "err", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-e.ctx.Done():
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// sendCallback POSTs a callback body to url, signing it if a callback secret
+// is configured. It returns whether a failed delivery is worth retrying.
+func (e *Engine) sendCallback(url string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(e.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	if secret := e.EnvConfig().Daemon.CallbackSecret; secret != "" {
+		req.Header.Set(api.CallbackSignatureHeader, api.SignCallback(secret, body))
+	}
+
+	cl := &http.Client{Timeout: time.Second * 10}
+	res, err := cl.Do(req)
+	if err != nil {
+		return true, err
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return true, fmt.Errorf("callback returned status %d", res.StatusCode)
+	default:
+		return false, fmt.Errorf("callback returned status %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/task"
+)
+
+// startChildTask records a task for a phase of a parent task, such as the
+// build phase of a run, and opens its log. Child tasks are processed inline by
+// the worker of their parent, so they never enter the queue.
+func (e *Engine) startChildTask(parent *task.Task, typ task.Type, input interface{}) (*task.Task, *os.File, error) {
+	now := time.Now().UTC()
+	child := &task.Task{
+		Version:  parent.Version,
+		Priority: parent.Priority,
+		ID:       xid.New().String(),
+		Plan:     parent.Plan,
+		Case:     parent.Case,
+		Type:     typ,
+		Input:    input,
+		States: []task.DatedState{
+			{State: task.StateScheduled, Created: now},
+			{State: task.StateProcessing, Created: now},
+		},
+		CreatedBy: parent.CreatedBy,
+		Parent:    parent.ID,
+		RequestID: parent.RequestID,
+	}
+
+	if err := e.store.PersistProcessing(child); err != nil {
+		return nil, nil, fmt.Errorf("could not persist child task: %w", err)
+	}
+
+	parent.Children = append(parent.Children, child.ID)
+	if err := e.store.PersistProcessing(parent); err != nil {
+		logging.S().Errorw("could not persist task", "task_id", parent.ID, "err", err)
+	}
+
+	f, err := os.OpenFile(e.taskLogPath(child.ID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		_ = e.finishTask(child, nil, err)
+		return nil, nil, fmt.Errorf("could not create child task log: %w", err)
+	}
+	return child, f, nil
+}
+
+// doChildBuild performs the build phase of a run as a child task of the run.
+// The build output goes to the logs of both tasks.
+func (e *Engine) doChildBuild(ctx context.Context, parent *task.Task, input *BuildInput, parentLog io.Writer) ([]*api.BuildOutput, error) {
+	child, f, err := e.startChildTask(parent, task.TypeBuild, input)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ow := taskOutputWriter(child, io.MultiWriter(parentLog, f))
+	ow.Infow("building as child task", "task_id", child.ID, "parent_id", parent.ID)
+
+	res, err := e.doBuild(ctx, child, input, ow)
+
+	var result interface{}
+	if res != nil {
+		var artifactPaths []string
+		for _, ap := range res {
+			artifactPaths = append(artifactPaths, ap.ArtifactPath)
+		}
+		result = artifactPaths
+	}
+
+	errTask := err
+	if errTask != nil {
+		errTask = &TaskExecutionError{TaskType: string(child.Type), WrappedErr: errTask}
+	}
+
+	// only the child log is done; the run carries on in the parent log.
+	rpc.NewFileOutputWriter(f).WriteStage(rpc.StageDone)
+	if ferr := e.finishTask(child, result, errTask); ferr != nil {
+		logging.S().Errorw("could not finish child task", "task_id", child.ID, "err", ferr)
+	}
+	return res, err
+}
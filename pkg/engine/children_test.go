@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/task"
+	"github.com/testground/testground/pkg/testutil"
+)
+
+func TestChildTaskLifecycle(t *testing.T) {
+	testutil.Setenv(t, config.EnvTestgroundHomeDir, t.TempDir())
+
+	cfg := &config.EnvConfig{}
+	if err := cfg.EnsureMinimalConfig(); err != nil {
+		t.Fatal(err)
+	}
+	store, err := task.NewMemoryTaskStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Engine{envcfg: cfg, store: store}
+
+	parent := &task.Task{
+		ID:     "c1bt9qrpc98qra498sg0",
+		Type:   task.TypeRun,
+		Plan:   "network",
+		Case:   "ping-pong",
+		States: []task.DatedState{{State: task.StateProcessing, Created: time.Now().UTC()}},
+	}
+	if err := store.PersistScheduled(parent); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ProcessTask(parent); err != nil {
+		t.Fatal(err)
+	}
+
+	child, f, err := e.startChildTask(parent, task.TypeBuild, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := os.Stat(e.taskLogPath(child.ID)); err != nil {
+		t.Errorf("child task log not created: %s", err)
+	}
+
+	stored, err := store.Get(parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Children) != 1 || stored.Children[0] != child.ID {
+		t.Errorf("parent children: got %v, expected [%s]", stored.Children, child.ID)
+	}
+
+	buildErr := &TaskExecutionError{TaskType: string(task.TypeBuild), WrappedErr: errors.New("boom")}
+	if err := e.finishTask(child, nil, buildErr); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err = store.Get(child.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Parent != parent.ID {
+		t.Errorf("child parent: got %q, expected %q", stored.Parent, parent.ID)
+	}
+	if s := stored.State().State; s != task.StateCanceled {
+		t.Errorf("child state: got %s, expected %s", s, task.StateCanceled)
+	}
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// This file holds the API to drive the engine in process, rather than through
+// the daemon: Build, Run and Healthcheck perform their task to completion,
+// write its output to an io.Writer as plain text, and return typed results.
+//
+// The daemon doesn't go through this API yet: its handlers queue tasks and
+// return their IDs, leaving clients to follow them.
+
+// BuildResult is the result of a build performed with Engine.Build.
+type BuildResult struct {
+	// TaskID is the ID of the build task.
+	TaskID string
+
+	// Artifacts are the artifacts built, one per group of the composition.
+	Artifacts []string
+}
+
+// RunResult is the result of a run performed with Engine.Run.
+type RunResult struct {
+	// TaskID is the ID of the run task, or of the experiment task if the run
+	// expanded into several cells.
+	TaskID string
+
+	// Result is the result of the run. For experiments, its outcomes are the
+	// outcomes of the cells, by task ID.
+	Result *runner.Result
+
+	// Task is the completed task.
+	Task *task.Task
+}
+
+// Build performs a build, and waits for it to complete, writing its output to
+// w. If ctx is done first, the build is canceled, whether it is still queued
+// or already processing.
+func (e *Engine) Build(ctx context.Context, request *api.BuildRequest, sources *api.UnpackedSources, w io.Writer) (*BuildResult, error) {
+	id, err := e.QueueBuild(request, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	tsk, err := e.await(ctx, id, w)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &BuildResult{TaskID: id}
+	if err := mapstructure.Decode(tsk.Result, &res.Artifacts); err != nil {
+		return nil, fmt.Errorf("could not decode the result of build %s: %w", id, err)
+	}
+	return res, nil
+}
+
+// Run performs a run, building the groups that need it first, and waits for
+// it to complete, writing its output to w. If ctx is done first, the run is
+// canceled, whether it is still queued or already processing. A run that
+// completes with a failure outcome is not an error; the outcome is in the
+// result.
+func (e *Engine) Run(ctx context.Context, request *api.RunRequest, sources *api.UnpackedSources, w io.Writer) (*RunResult, error) {
+	id, err := e.QueueRun(request, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	tsk, err := e.await(ctx, id, w)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RunResult{
+		TaskID: id,
+		Result: data.DecodeRunnerResult(tsk.Result),
+		Task:   tsk,
+	}, nil
+}
+
+// Healthcheck checks, and optionally fixes, the infrastructure of a runner,
+// writing its output to w.
+func (e *Engine) Healthcheck(ctx context.Context, runner string, fix bool, w io.Writer) (*api.HealthcheckReport, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- copyProgress(pr, w)
+		// drain the output left, should w fail.
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+
+	report, err := e.DoHealthcheck(ctx, runner, fix, rpc.NewFileOutputWriter(pw))
+	_ = pw.Close()
+	if werr := <-done; err == nil && werr != nil {
+		return report, werr
+	}
+	return report, err
+}
+
+// await follows the log of a task to w until it completes, and returns the
+// completed task. It fails if the task failed, or didn't complete.
+func (e *Engine) await(ctx context.Context, id string, w io.Writer) (*task.Task, error) {
+	tsk, err := e.followTaskLog(ctx, id, true, func(chunk rpc.Chunk) error {
+		return writeProgress(chunk, w)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			e.abandon(id)
+		}
+		return nil, err
+	}
+
+	switch s := tsk.State().State; s {
+	case task.StateComplete:
+	case task.StateCanceled:
+		return tsk, fmt.Errorf("task %s canceled: %s", id, tsk.Error)
+	default:
+		if ctx.Err() != nil {
+			e.abandon(id)
+			return tsk, ctx.Err()
+		}
+		return tsk, fmt.Errorf("task %s did not complete; state: %s", id, s)
+	}
+
+	if tsk.Error != "" {
+		return tsk, fmt.Errorf("task %s failed: %s", id, tsk.Error)
+	}
+	return tsk, nil
+}
+
+// abandon stops a task that nobody awaits anymore: it is canceled if it is
+// still scheduled, and killed if it is processing.
+func (e *Engine) abandon(id string) {
+	ok, err := e.queue.Cancel(id)
+	if err != nil {
+		logging.S().Warnw("could not cancel abandoned task", "task_id", id, "err", err)
+	}
+	if !ok {
+		_ = e.Kill(id)
+	}
+}
+
+// copyProgress writes the progress chunks read from r to w, as plain text.
+func copyProgress(r io.Reader, w io.Writer) error {
+	dec := json.NewDecoder(r)
+	for {
+		var chunk rpc.Chunk
+		if err := dec.Decode(&chunk); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error when decoding chunk, err: %w", err)
+		}
+
+		if err := writeProgress(chunk, w); err != nil {
+			return err
+		}
+	}
+}
+
+// writeProgress writes the payload of a progress chunk to w. Other chunks are
+// skipped.
+func writeProgress(chunk rpc.Chunk, w io.Writer) error {
+	if chunk.Type != rpc.ChunkTypeProgress {
+		return nil
+	}
+
+	p, ok := chunk.Payload.(string)
+	if !ok {
+		return fmt.Errorf("unexpected progress payload: %T", chunk.Payload)
+	}
+	m, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return fmt.Errorf("error when base64 decoding string, err: %w", err)
+	}
+
+	_, err = w.Write(m)
+	return err
+}
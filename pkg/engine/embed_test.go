@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+	"github.com/testground/testground/pkg/testutil"
+)
+
+func TestCopyProgress(t *testing.T) {
+	var chunks bytes.Buffer
+	ow := rpc.NewFileOutputWriter(&chunks)
+	_, _ = ow.WriteProgress([]byte("building\n"))
+	ow.WriteStage(rpc.StageDone)
+	_, _ = ow.WriteProgress([]byte("done\n"))
+
+	var out bytes.Buffer
+	if err := copyProgress(&chunks, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := out.String(), "building\ndone\n"; got != expected {
+		t.Errorf("got output %q, expected %q", got, expected)
+	}
+}
+
+// embedBuilder is a builder whose builds succeed with a fixed artifact, or
+// fail with err.
+type embedBuilder struct {
+	testBuilder
+	err error
+}
+
+func (b *embedBuilder) Build(_ context.Context, in *api.BuildInput, ow *rpc.OutputWriter) (*api.BuildOutput, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	ow.Infof("building %s", in.TestPlan)
+	return &api.BuildOutput{ArtifactPath: "artifact"}, nil
+}
+
+// embedRunner is a runner whose runs complete with a fixed outcome, and whose
+// infrastructure is always healthy.
+type embedRunner struct {
+	testRunner
+	outcome task.Outcome
+}
+
+func (r *embedRunner) Run(_ context.Context, in *api.RunInput, ow *rpc.OutputWriter) (*api.RunOutput, error) {
+	ow.Infof("running %s with %s", in.TestCase, in.Groups[0].ArtifactPath)
+	return &api.RunOutput{RunID: in.RunID, Result: &runner.Result{Outcome: r.outcome}}, nil
+}
+
+func (r *embedRunner) Healthcheck(context.Context, api.Engine, *rpc.OutputWriter, bool) (*api.HealthcheckReport, error) {
+	return &api.HealthcheckReport{Checks: []api.HealthcheckItem{{Name: "infra", Status: api.HealthcheckStatusOK}}}, nil
+}
+
+func newEmbedEngine(t *testing.T, b api.Builder, r api.Runner) *Engine {
+	t.Helper()
+	testutil.Setenv(t, config.EnvTestgroundHomeDir, t.TempDir())
+
+	cfg := &config.EnvConfig{}
+	if err := cfg.EnsureMinimalConfig(); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEngine(&EngineConfig{Builders: []api.Builder{b}, Runners: []api.Runner{r}, EnvConfig: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func embedComposition() (api.Composition, api.TestPlanManifest) {
+	comp := api.Composition{
+		Global: api.Global{Plan: "plan", Case: "case", Builder: "docker:go", Runner: "test:runner", TotalInstances: 1},
+		Groups: []*api.Group{{ID: "a", Instances: api.Instances{Count: 1}}},
+	}
+	manifest := api.TestPlanManifest{
+		Name:      "plan",
+		Builders:  map[string]config.ConfigMap{"docker:go": {}},
+		Runners:   map[string]config.ConfigMap{"test:runner": {}},
+		TestCases: []*api.TestCase{{Name: "case", Instances: api.InstanceConstraints{Minimum: 1, Maximum: 10}}},
+	}
+	return comp, manifest
+}
+
+func TestEmbedBuild(t *testing.T) {
+	e := newEmbedEngine(t, &embedBuilder{testBuilder: testBuilder{id: "docker:go"}}, &embedRunner{testRunner: testRunner{id: "test:runner"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	comp, manifest := embedComposition()
+	var out bytes.Buffer
+	res, err := e.Build(ctx, &api.BuildRequest{Composition: comp, Manifest: manifest}, nil, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Artifacts) != 1 || res.Artifacts[0] != "artifact" {
+		t.Errorf("got artifacts %v, expected [artifact]", res.Artifacts)
+	}
+	if !strings.Contains(out.String(), "building plan") {
+		t.Errorf("expected the build output, got %q", out.String())
+	}
+}
+
+func TestEmbedBuildFailure(t *testing.T) {
+	e := newEmbedEngine(t, &embedBuilder{testBuilder: testBuilder{id: "docker:go"}, err: errors.New("no compiler")}, &embedRunner{testRunner: testRunner{id: "test:runner"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	comp, manifest := embedComposition()
+	_, err := e.Build(ctx, &api.BuildRequest{Composition: comp, Manifest: manifest}, nil, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "no compiler") {
+		t.Errorf("expected the build to fail with the builder error, got %v", err)
+	}
+}
+
+func TestEmbedRun(t *testing.T) {
+	for _, outcome := range []task.Outcome{task.OutcomeSuccess, task.OutcomeFailure} {
+		e := newEmbedEngine(t, &embedBuilder{testBuilder: testBuilder{id: "docker:go"}}, &embedRunner{testRunner: testRunner{id: "test:runner"}, outcome: outcome})
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+
+		comp, manifest := embedComposition()
+		var out bytes.Buffer
+		res, err := e.Run(ctx, &api.RunRequest{Composition: comp, Manifest: manifest, BuildGroups: []int{0}, RunIds: []string{"default"}}, nil, &out)
+		cancel()
+		if err != nil {
+			t.Fatalf("%s: %s", outcome, err)
+		}
+		if res.Result.Outcome != outcome {
+			t.Errorf("got outcome %s, expected %s", res.Result.Outcome, outcome)
+		}
+		if res.Task.ID != res.TaskID {
+			t.Errorf("got task %s, expected %s", res.Task.ID, res.TaskID)
+		}
+		if !strings.Contains(out.String(), "running case with artifact") {
+			t.Errorf("expected the run output, got %q", out.String())
+		}
+	}
+}
+
+func TestEmbedHealthcheck(t *testing.T) {
+	e := newEmbedEngine(t, &embedBuilder{testBuilder: testBuilder{id: "docker:go"}}, &embedRunner{testRunner: testRunner{id: "test:runner"}})
+
+	var out bytes.Buffer
+	report, err := e.Healthcheck(context.Background(), "test:runner", false, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.ChecksSucceeded() {
+		t.Errorf("expected the checks to succeed, got %v", report)
+	}
+	if !strings.Contains(out.String(), "checking runner: test:runner") {
+		t.Errorf("expected the healthcheck output, got %q", out.String())
+	}
+
+	if _, err := e.Healthcheck(context.Background(), "other:runner", false, io.Discard); err == nil {
+		t.Error("expected a healthcheck of an unknown runner to fail")
+	}
+}
+
+func TestAwaitContextDone(t *testing.T) {
+	testutil.Setenv(t, config.EnvTestgroundHomeDir, t.TempDir())
+
+	cfg := &config.EnvConfig{}
+	if err := cfg.EnsureMinimalConfig(); err != nil {
+		t.Fatal(err)
+	}
+	store, err := task.NewMemoryTaskStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue, err := task.NewQueue(store, 10, UnmarshalTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no workers: the task stays scheduled.
+	e := &Engine{envcfg: cfg, store: store, queue: queue, signals: make(map[string]chan int), active: make(map[string]int)}
+
+	comp, manifest := embedComposition()
+	id, err := e.QueueBuild(&api.BuildRequest{Composition: comp, Manifest: manifest}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := e.await(ctx, id, io.Discard); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected await to stop with the context, got %v", err)
+	}
+
+	// the abandoned task is canceled, and no worker can pick it up.
+	tsk, err := store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := tsk.State().State; s != task.StateCanceled {
+		t.Errorf("expected the abandoned task to be canceled, got %s", s)
+	}
+	if n := queue.Len(); n != 0 {
+		t.Errorf("expected the abandoned task to leave the queue, got %d tasks", n)
+	}
+}
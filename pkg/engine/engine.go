@@ -8,9 +8,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-redis/redis/v7"
 	"github.com/rs/xid"
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/build"
@@ -27,6 +31,7 @@ var AllBuilders = []api.Builder{
 	&build.ExecGoBuilder{},
 	&build.DockerGenericBuilder{},
 	&build.DockerNodeBuilder{},
+	&build.DockerRustBuilder{},
 }
 
 // AllRunners enumerates all runners known to the system.
@@ -60,6 +65,17 @@ type Engine struct {
 	// by closing a channel, the task is canceled
 	signals   map[string]chan int
 	signalsLk sync.RWMutex
+	// active counts the tasks in flight per submitter, including detached
+	// runs; activeTotal is the sum across submitters.
+	active      map[string]int
+	activeTotal int
+	activeLk    sync.Mutex
+	// idempotencyLk serialises the submission of tasks with idempotency keys.
+	idempotencyLk sync.Mutex
+	// experimentLk serialises the completion of experiments by their cells.
+	experimentLk sync.Mutex
+	// envcfgLk guards envcfg, which Reload replaces.
+	envcfgLk sync.RWMutex
 }
 
 var _ api.Engine = (*Engine)(nil)
@@ -107,13 +123,25 @@ func NewEngine(cfg *EngineConfig) (*Engine, error) {
 		store:    store,
 		queue:    queue,
 		signals:  make(map[string]chan int),
+		active:   make(map[string]int),
 	}
 
 	for _, b := range cfg.Builders {
 		e.builders[b.ID()] = b
 	}
 
+	subnets, err := newSubnetAllocator(cfg.EnvConfig.Daemon.Subnets)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, r := range cfg.Runners {
+		if s, ok := r.(runner.SubnetAllocatorSetter); ok && subnets != nil {
+			s.SetSubnetAllocator(subnets)
+		}
+		if s, ok := r.(runner.EnvConfigSetter); ok {
+			s.SetEnvConfig(*cfg.EnvConfig)
+		}
 		e.runners[r.ID()] = r
 	}
 
@@ -121,9 +149,28 @@ func NewEngine(cfg *EngineConfig) (*Engine, error) {
 		go e.worker(i)
 	}
 
+	go e.pruneTaskLogsLoop()
+
 	return e, nil
 }
 
+// newSubnetAllocator returns the data network subnet allocator to inject into
+// runners, or nil if runners should use their own in-memory allocators.
+func newSubnetAllocator(cfg config.SubnetsConfig) (runner.SubnetAllocator, error) {
+	switch cfg.Allocator {
+	case "", "memory":
+		return nil, nil
+	case "redis":
+		if cfg.RedisAddr == "" {
+			return nil, fmt.Errorf("redis subnet allocator requires daemon.subnets.redis_addr")
+		}
+		client := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+		return runner.NewRedisSubnetAllocator(client, "testground:subnets"), nil
+	default:
+		return nil, fmt.Errorf("unknown subnet allocator: %s", cfg.Allocator)
+	}
+}
+
 func NewDefaultEngine(ecfg *config.EnvConfig) (*Engine, error) {
 	cfg := &EngineConfig{
 		Builders:  AllBuilders,
@@ -177,11 +224,158 @@ func (e *Engine) ListRunners() map[string]api.Runner {
 	return m
 }
 
+// DescribeRunners describes the runners known to the engine, sorted by ID.
+func (e *Engine) DescribeRunners() []api.ComponentDescription {
+	e.lk.RLock()
+	defer e.lk.RUnlock()
+
+	descs := make([]api.ComponentDescription, 0, len(e.runners))
+	for id, r := range e.runners {
+		compatible := compatibleBuilders(r)
+		sort.Strings(compatible)
+
+		descs = append(descs, api.ComponentDescription{
+			ID:         id,
+			Type:       api.RunnerType,
+			Config:     api.DescribeConfig(r.ConfigType()),
+			Compatible: compatible,
+		})
+	}
+	sort.Slice(descs, func(i, j int) bool { return descs[i].ID < descs[j].ID })
+	return descs
+}
+
+// DescribeBuilders describes the builders known to the engine, sorted by ID,
+// along with the runners that can work with their artifacts.
+func (e *Engine) DescribeBuilders() []api.ComponentDescription {
+	e.lk.RLock()
+	defer e.lk.RUnlock()
+
+	compatible := make(map[string][]string, len(e.builders))
+	for id, r := range e.runners {
+		for _, b := range compatibleBuilders(r) {
+			compatible[b] = append(compatible[b], id)
+		}
+	}
+
+	descs := make([]api.ComponentDescription, 0, len(e.builders))
+	for id, b := range e.builders {
+		runners := compatible[id]
+		sort.Strings(runners)
+
+		descs = append(descs, api.ComponentDescription{
+			ID:         id,
+			Type:       api.BuilderType,
+			Config:     api.DescribeConfig(b.ConfigType()),
+			Compatible: runners,
+		})
+	}
+	sort.Slice(descs, func(i, j int) bool { return descs[i].ID < descs[j].ID })
+	return descs
+}
+
+// reservedConfigKeys are keys found in runner and builder configurations that
+// are not part of their configuration types: the `enabled` flag of manifests,
+// and the `disabled` flag of the env config.
+var reservedConfigKeys = map[string]struct{}{
+	"enabled":                 {},
+	config.RunnerDisabledFlag: {},
+}
+
+// checkConfigKeys fails if the user-supplied cfg sets keys that the
+// configuration type of the runner or builder doesn't have, as those would be
+// silently ignored, or that are not overridable.
+func checkConfigKeys(ctype api.ComponentType, id string, cfg map[string]interface{}, typ reflect.Type) error {
+	if len(cfg) == 0 {
+		return nil
+	}
+
+	if err := config.CheckOverrides(cfg, typ); err != nil {
+		return fmt.Errorf("invalid %s configuration: %w", id, err)
+	}
+	return checkUnknownKeys(ctype, id, cfg, typ)
+}
+
+// checkUnknownKeys fails if cfg sets keys that the configuration type of the
+// runner or builder doesn't have.
+func checkUnknownKeys(ctype api.ComponentType, id string, cfg map[string]interface{}, typ reflect.Type) error {
+	keys, err := config.UnknownKeys(cfg, typ)
+	if err != nil {
+		return fmt.Errorf("invalid %s configuration: %w", id, err)
+	}
+
+	var unknown []string
+	for _, k := range keys {
+		if _, ok := reservedConfigKeys[k]; !ok {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown %s configuration keys for %s: %s; run `testground components --name %s` to list the valid ones",
+			ctype, id, strings.Join(unknown, ", "), id)
+	}
+	return nil
+}
+
+// checkBuildConfigKeys checks the build configurations of a composition
+// against the configuration types of their builders. The global build
+// configuration is shared by the builders of all groups, so keys unknown to
+// the global builder are allowed there.
+func (e *Engine) checkBuildConfigKeys(comp *api.Composition) error {
+	if b, ok := e.builders[comp.Global.Builder]; ok && len(comp.Global.BuildConfig) > 0 {
+		if err := config.CheckOverrides(comp.Global.BuildConfig, b.ConfigType()); err != nil {
+			return fmt.Errorf("invalid %s configuration: %w", b.ID(), err)
+		}
+	}
+
+	for _, g := range comp.Groups {
+		builder := g.Builder
+		if builder == "" {
+			builder = comp.Global.Builder
+		}
+		b, ok := e.builders[builder]
+		if !ok {
+			continue
+		}
+		if err := checkConfigKeys(api.BuilderType, b.ID(), g.BuildConfig, b.ConfigType()); err != nil {
+			return fmt.Errorf("group %s: %w", g.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkMounts checks the mounts of the groups of a composition against the
+// allowed mount roots, so that bad mounts fail the request rather than the run.
+func (e *Engine) checkMounts(comp *api.Composition) error {
+	for _, g := range comp.Groups {
+		if _, err := e.resolveMounts(g.Run.Mounts); err != nil {
+			return fmt.Errorf("group %s: %w", g.ID, err)
+		}
+	}
+	for _, r := range comp.Runs {
+		for _, g := range r.Groups {
+			if _, err := e.resolveMounts(g.Mounts); err != nil {
+				return fmt.Errorf("run %s, group %s: %w", r.ID, g.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
 func (e *Engine) QueueBuild(request *api.BuildRequest, sources *api.UnpackedSources) (string, error) {
+	if err := e.checkBuildConfigKeys(&request.Composition); err != nil {
+		return "", err
+	}
+	if request.Callback != "" {
+		if err := api.ValidateCallbackURL(request.Callback); err != nil {
+			return "", err
+		}
+	}
+
 	id := xid.New().String()
-	err := e.queue.Push(&task.Task{
+	return e.pushTask(e.queue.Push, &task.Task{
 		Version:  0,
-		Priority: request.Priority,
+		Priority: task.ClampPriority(request.Priority),
 		ID:       id,
 		Type:     task.TypeBuild,
 		Input: &BuildInput{
@@ -194,10 +388,11 @@ func (e *Engine) QueueBuild(request *api.BuildRequest, sources *api.UnpackedSour
 				Created: time.Now().UTC(),
 			},
 		},
-		CreatedBy: task.CreatedBy(request.CreatedBy),
+		CreatedBy:      task.CreatedBy(request.CreatedBy),
+		Callback:       request.Callback,
+		IdempotencyKey: request.IdempotencyKey,
+		RequestID:      request.RequestID,
 	})
-
-	return id, err
 }
 
 func (e *Engine) QueueRun(request *api.RunRequest, sources *api.UnpackedSources) (string, error) {
@@ -214,16 +409,71 @@ func (e *Engine) QueueRun(request *api.RunRequest, sources *api.UnpackedSources)
 
 	// Check if builders and runner are compatible
 	for _, builder := range builders {
-		if !stringInSlice(builder, run.CompatibleBuilders()) {
+		if !stringInSlice(builder, compatibleBuilders(run)) {
 			return "", fmt.Errorf("runner %s is incompatible with builder %s", runner, builder)
 		}
 	}
 
+	// Check the configurations now, rather than when the run starts.
+	defaults := request.Manifest.RunnerDefaults[runner]
+	if err := checkUnknownKeys(api.RunnerType, runner, defaults.Config, run.ConfigType()); err != nil {
+		return "", fmt.Errorf("runner defaults of plan %s: %w", request.Manifest.Name, err)
+	}
+	if err := api.ValidateInitContainers(defaults.InitContainers); err != nil {
+		return "", fmt.Errorf("runner defaults of plan %s: %w", request.Manifest.Name, err)
+	}
+	if err := checkConfigKeys(api.RunnerType, runner, request.Composition.Global.RunConfig, run.ConfigType()); err != nil {
+		return "", err
+	}
+	if err := e.checkBuildConfigKeys(&request.Composition); err != nil {
+		return "", err
+	}
+	if err := e.checkMounts(&request.Composition); err != nil {
+		return "", err
+	}
+
+	if request.Callback != "" {
+		if err := api.ValidateCallbackURL(request.Callback); err != nil {
+			return "", err
+		}
+	}
+
+	var cfg config.CoalescedConfig
+	cfg = cfg.Append(e.EnvConfig().Runners[runner])
+	cfg = cfg.Append(defaults.Config)
+	cfg = cfg.Append(request.Composition.Global.RunConfig)
+	obj, err := cfg.CoalesceIntoType(run.ConfigType())
+	if err != nil {
+		return "", fmt.Errorf("error while coalescing configuration values: %w", err)
+	}
+	if v, ok := obj.(api.ConfigValidator); ok {
+		if err := v.Validate(); err != nil {
+			return "", fmt.Errorf("invalid %s configuration: %w", runner, err)
+		}
+	}
+
+	for _, tc := range request.TestCases {
+		if _, _, ok := request.Manifest.TestCaseByName(tc); !ok {
+			return "", fmt.Errorf("%w: %s in plan %s", api.ErrTestCaseNotFound, tc, request.Composition.Global.Plan)
+		}
+	}
+
+	cells, err := request.Composition.ExpandCells(request.TestCases)
+	if err != nil {
+		return "", err
+	}
+	if len(cells) > 0 {
+		if err := checkCellParams(cells, &request.Manifest); err != nil {
+			return "", err
+		}
+		return e.queueExperiment(request, sources, cells)
+	}
+
 	id := xid.New().String()
 	cby := task.CreatedBy(request.CreatedBy)
 	newTask := &task.Task{
 		Version:     0,
-		Priority:    request.Priority,
+		Priority:    task.ClampPriority(request.Priority),
 		Plan:        request.Composition.Global.Plan,
 		Case:        request.Composition.Global.Case,
 		ID:          id,
@@ -240,12 +490,58 @@ func (e *Engine) QueueRun(request *api.RunRequest, sources *api.UnpackedSources)
 				Created: time.Now().UTC(),
 			},
 		},
-		CreatedBy: cby,
+		CreatedBy:      cby,
+		Callback:       request.Callback,
+		IdempotencyKey: request.IdempotencyKey,
+		RequestID:      request.RequestID,
+		Labels:         request.Labels,
+	}
+
+	return e.pushTask(e.queue.PushUniqueByBranch, newTask)
+}
+
+// pushTask queues a task through push, and returns its ID. If a task was
+// already submitted with the same idempotency key, nothing is queued and the
+// ID of that task is returned instead.
+func (e *Engine) pushTask(push func(*task.Task) error, tsk *task.Task) (string, error) {
+	push = e.pushLabeled(push)
+	if tsk.IdempotencyKey == "" {
+		return tsk.ID, push(tsk)
 	}
 
-	err := e.queue.PushUniqueByBranch(newTask)
+	e.idempotencyLk.Lock()
+	defer e.idempotencyLk.Unlock()
 
-	return id, err
+	existing, err := e.store.GetByIdempotencyKey(tsk.IdempotencyKey)
+	switch err {
+	case nil:
+		logging.S().Infow("task already submitted with idempotency key", "task_id", existing.ID, "key", tsk.IdempotencyKey)
+		return existing.ID, nil
+	case task.ErrNotFound:
+	default:
+		return "", err
+	}
+
+	if err := push(tsk); err != nil {
+		return "", err
+	}
+	if err := e.store.PersistIdempotencyKey(tsk); err != nil {
+		logging.S().Errorw("could not persist idempotency key", "task_id", tsk.ID, "err", err)
+	}
+	return tsk.ID, nil
+}
+
+// pushLabeled wraps push to index the tasks it pushes by their labels.
+func (e *Engine) pushLabeled(push func(*task.Task) error) func(*task.Task) error {
+	return func(tsk *task.Task) error {
+		if err := push(tsk); err != nil {
+			return err
+		}
+		if err := e.store.PersistLabels(tsk); err != nil {
+			logging.S().Errorw("could not persist labels", "task_id", tsk.ID, "err", err)
+		}
+		return nil
+	}
 }
 
 func (e *Engine) DoCollectOutputs(ctx context.Context, runID string, ow *rpc.OutputWriter) error {
@@ -263,7 +559,7 @@ func (e *Engine) DoCollectOutputs(ctx context.Context, runID string, ow *rpc.Out
 	var cfg config.CoalescedConfig
 
 	// Get the env config for the runner.
-	cfg = cfg.Append(e.envcfg.Runners[runner])
+	cfg = cfg.Append(e.EnvConfig().Runners[runner])
 
 	// Coalesce all configurations and deserialize into the config type
 	// mandated by the builder.
@@ -275,7 +571,7 @@ func (e *Engine) DoCollectOutputs(ctx context.Context, runID string, ow *rpc.Out
 	input := &api.CollectionInput{
 		RunnerID:     runner,
 		RunID:        runID,
-		EnvConfig:    *e.envcfg,
+		EnvConfig:    e.EnvConfig(),
 		RunnerConfig: obj,
 	}
 
@@ -312,6 +608,22 @@ func (e *Engine) DoTerminate(ctx context.Context, ctype api.ComponentType, ref s
 	return nil
 }
 
+func (e *Engine) DoPrune(ctx context.Context, runner string, ow *rpc.OutputWriter) (*api.PruneReport, error) {
+	run, ok := e.runners[runner]
+	if !ok {
+		return nil, fmt.Errorf("unknown runner: %s", runner)
+	}
+
+	prunable, ok := run.(api.Prunable)
+	if !ok {
+		return nil, fmt.Errorf("runner %s does not support pruning", runner)
+	}
+
+	ow.Infof("pruning orphaned resources of runner: %s", runner)
+
+	return prunable.Prune(ctx, ow)
+}
+
 func (e *Engine) DoHealthcheck(ctx context.Context, runner string, fix bool, ow *rpc.OutputWriter) (*api.HealthcheckReport, error) {
 	run, ok := e.runners[runner]
 	if !ok {
@@ -338,6 +650,9 @@ func (e *Engine) DoBuildPurge(ctx context.Context, builder, plan string, ow *rpc
 
 // EnvConfig returns the EnvConfig for this Engine.
 func (e *Engine) EnvConfig() config.EnvConfig {
+	e.envcfgLk.RLock()
+	defer e.envcfgLk.RUnlock()
+
 	return *e.envcfg
 }
 
@@ -345,6 +660,28 @@ func (e *Engine) Context() context.Context {
 	return e.ctx
 }
 
+func (e *Engine) Ready(ctx context.Context, runner string) error {
+	if err := e.store.Ping(); err != nil {
+		return fmt.Errorf("task storage unavailable: %w", err)
+	}
+	if runner == "" {
+		return nil
+	}
+
+	run, ok := e.runners[runner]
+	if !ok {
+		return fmt.Errorf("unknown runner: %s", runner)
+	}
+	p, ok := run.(api.Pinger)
+	if !ok {
+		return fmt.Errorf("runner %s cannot check its infrastructure", runner)
+	}
+	if err := p.Ping(ctx); err != nil {
+		return fmt.Errorf("runner %s unreachable: %w", runner, err)
+	}
+	return nil
+}
+
 func stringInSlice(a string, list []string) bool {
 	for _, b := range list {
 		if b == a {
@@ -372,14 +709,48 @@ func (e *Engine) Tasks(filters api.TasksFilters) ([]task.Task, error) {
 		after = time.Now().UTC()
 	}
 
+	// tasks with labels are looked up through the label index, rather than
+	// by going over all the tasks in the time range.
+	var labeled []*task.Task
+	if len(filters.Labels) > 0 {
+		ids, err := e.store.FindByLabels(filters.Labels)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			tsk, err := e.store.Get(id)
+			if err == task.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if created := tsk.Created().Unix(); created < before.Unix() || created >= after.Unix() {
+				continue
+			}
+			labeled = append(labeled, tsk)
+		}
+	}
+
 	e.signalsLk.RLock()
 
 	for _, state := range filters.States {
 		var ires []task.Task
 
-		tsks, err := e.store.Filter(state, before, after)
-		if err != nil {
-			return nil, err
+		var tsks []*task.Task
+		if len(filters.Labels) > 0 {
+			for _, tsk := range labeled {
+				if inState(tsk, state) {
+					tsks = append(tsks, tsk)
+				}
+			}
+		} else {
+			var err error
+			tsks, err = e.store.Filter(state, before, after)
+			if err != nil {
+				e.signalsLk.RUnlock()
+				return nil, err
+			}
 		}
 
 		for _, tsk := range tsks {
@@ -406,16 +777,30 @@ func (e *Engine) Tasks(filters api.TasksFilters) ([]task.Task, error) {
 	return res, nil
 }
 
-// DeleteTask removes a task from the Testground daemon database
+// inState reports whether a task is stored as being in state. Canceled tasks
+// are stored along with the completed ones.
+func inState(tsk *task.Task, state task.State) bool {
+	s := tsk.State().State
+	if s == task.StateCanceled {
+		s = task.StateComplete
+	}
+	return s == state
+}
+
+// DeleteTask removes a task and its logs from the Testground daemon database
 func (e *Engine) DeleteTask(id string) error {
-	return e.store.Delete(id)
+	if err := e.store.Delete(id); err != nil {
+		return err
+	}
+	return e.removeTaskLog(id)
 }
 
 func (e *Engine) GetTask(id string) (*task.Task, error) {
 	return e.store.Get(id)
 }
 
-// Kill closes the signal channel for a given task, which signals to the runner to stop it
+// Kill closes the signal channel for a given task, which signals to the runner to stop it.
+// Killing an experiment kills the runs of all its cells.
 func (e *Engine) Kill(id string) error {
 	e.signalsLk.RLock()
 	if ch, ok := e.signals[id]; ok {
@@ -423,6 +808,12 @@ func (e *Engine) Kill(id string) error {
 	}
 	e.signalsLk.RUnlock()
 
+	if tsk, err := e.store.Get(id); err == nil && tsk.Type == task.TypeExperiment {
+		for _, child := range tsk.Children {
+			_ = e.Kill(child)
+		}
+	}
+
 	return nil
 }
 
@@ -446,6 +837,9 @@ func UnmarshalTask(taskData []byte) (*task.Task, error) {
 	case task.TypeBuild:
 		finalTask.Input = &BuildInput{}
 		err = json.Unmarshal(taskData, finalTask)
+	case task.TypeExperiment:
+		finalTask.Input = &ExperimentInput{}
+		err = json.Unmarshal(taskData, finalTask)
 	default:
 		err = fmt.Errorf("invalid task type: %s", unmarshaledValue.Type)
 	}
@@ -461,10 +855,13 @@ func UnmarshalTask(taskData []byte) (*task.Task, error) {
 func (e *Engine) Logs(ctx context.Context, id string, follow bool, cancel bool, w io.Writer) (*task.Task, error) {
 	ow := rpc.NewFileOutputWriter(w)
 
-	path := filepath.Join(e.EnvConfig().Dirs().Daemon(), id+".out")
+	path := e.taskLogPath(id)
 
 	if !follow {
 		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no logs retained for task %s", id)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("error while os.Open, err: %w", err)
 		}
@@ -479,6 +876,26 @@ func (e *Engine) Logs(ctx context.Context, id string, follow bool, cancel bool,
 		return e.GetTask(id)
 	}
 
+	return e.followTaskLog(ctx, id, cancel, func(chunk rpc.Chunk) error {
+		m, err := base64.StdEncoding.DecodeString(chunk.Payload.(string))
+		if err != nil {
+			return fmt.Errorf("error when base64 decoding string, err: %w", err)
+		}
+
+		_, err = ow.WriteProgress([]byte(m))
+		if err != nil {
+			return fmt.Errorf("error on ow.WriteProgress, err: %w", err)
+		}
+		return nil
+	})
+}
+
+// followTaskLog waits for a task to start, and then passes each chunk of its
+// log to write, until the task completes. If ctx is done first and cancel is
+// set, the task is canceled.
+func (e *Engine) followTaskLog(ctx context.Context, id string, cancel bool, write func(rpc.Chunk) error) (*task.Task, error) {
+	path := e.taskLogPath(id)
+
 	// wait for the task to start
 	for {
 		tsk, err := e.GetTask(id)
@@ -486,11 +903,15 @@ func (e *Engine) Logs(ctx context.Context, id string, follow bool, cancel bool,
 			return nil, fmt.Errorf("error while e.Status, err: %w", err)
 		}
 
-		if tsk.State().State == task.StateScheduled {
-			time.Sleep(time.Millisecond * 500)
-		} else {
+		if tsk.State().State != task.StateScheduled {
 			break
 		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
 	}
 
 	stop := make(chan struct{})
@@ -542,14 +963,8 @@ Outer:
 				return nil, fmt.Errorf("error when decoding chunk, err: %w", err)
 			}
 
-			m, err := base64.StdEncoding.DecodeString(chunk.Payload.(string))
-			if err != nil {
-				return nil, fmt.Errorf("error when base64 decoding string, err: %w", err)
-			}
-
-			_, err = ow.WriteProgress([]byte(m))
-			if err != nil {
-				return nil, fmt.Errorf("error on ow.WriteProgress, err: %w", err)
+			if err := write(chunk); err != nil {
+				return nil, err
 			}
 		}
 	}
@@ -96,3 +96,34 @@ func TestUnmarshalTaskBuild(t *testing.T) {
 		t.Errorf("Unmarshal Build task returned incorrect data")
 	}
 }
+
+// configBuilder is a builder with a configuration type, unlike testBuilder.
+type configBuilder struct{ testBuilder }
+
+func (*configBuilder) ConfigType() reflect.Type {
+	return reflect.TypeOf(struct {
+		ModulePath string `toml:"module_path"`
+	}{})
+}
+
+func TestCheckBuildConfigKeys(t *testing.T) {
+	e := &Engine{builders: map[string]api.Builder{"test:builder": &configBuilder{testBuilder{id: "test:builder"}}}}
+
+	// keys of other builders are allowed in the global build configuration.
+	comp := &api.Composition{
+		Global: api.Global{
+			Builder:     "test:builder",
+			BuildConfig: map[string]interface{}{"go_proxy_mode": "direct"},
+		},
+		Groups: []*api.Group{{ID: "a", BuildConfig: map[string]interface{}{"module_path": "example.com/plan"}}},
+	}
+	if err := e.checkBuildConfigKeys(comp); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// but not in the build configuration of groups.
+	comp.Groups[0].BuildConfig["go_proxy_mode"] = "direct"
+	if err := e.checkBuildConfigKeys(comp); err == nil {
+		t.Error("expected an unknown group build configuration key to be rejected")
+	}
+}
@@ -0,0 +1,185 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/task"
+)
+
+// etaHistory is how far back completed tasks are considered when estimating
+// task durations.
+const etaHistory = 7 * 24 * time.Hour
+
+// QueueStatus returns the queue position of a scheduled task, and a rough
+// estimate of the time until it starts. It returns nil if the task is not
+// queued.
+func (e *Engine) QueueStatus(id string) (*api.QueueStatus, error) {
+	pos, ahead, ok := e.queue.Position(id)
+	if !ok {
+		return nil, nil
+	}
+
+	qs := &api.QueueStatus{Position: pos, Length: e.queue.Len()}
+
+	wait, ok, err := e.estimateWait(ahead)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		qs.EstimatedWait = &wait
+	}
+	return qs, nil
+}
+
+// estimateWait estimates the time until the tasks ahead of a queued task and
+// the tasks in progress leave a slot free for it. Tasks are assumed to take as
+// long as recently completed tasks of the same kind, and to be spread over as
+// many slots as the scheduler processes concurrently.
+func (e *Engine) estimateWait(ahead []*task.Task) (time.Duration, bool, error) {
+	now := time.Now().UTC()
+
+	completed, err := e.store.Filter(task.StateComplete, now.Add(-etaHistory), now)
+	if err != nil {
+		return 0, false, err
+	}
+	durations := newDurationEstimator(completed)
+	if durations.empty() {
+		return 0, false, nil
+	}
+
+	// the storage keys tasks by creation second, so look slightly ahead to
+	// include tasks created just now.
+	running, err := e.store.Filter(task.StateProcessing, time.Unix(0, 0), now.Add(time.Minute))
+	if err != nil {
+		return 0, false, err
+	}
+
+	sched := e.EnvConfig().Daemon.Scheduler
+	n := sched.Workers
+	if sched.MaxConcurrentTasks > 0 && sched.MaxConcurrentTasks < n {
+		n = sched.MaxConcurrentTasks
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	// list-schedule the work onto n slots, each task going to the slot that
+	// frees up first; the queued task starts when a slot frees up next.
+	slots := make([]time.Duration, n)
+	assign := func(d time.Duration) {
+		min := 0
+		for i := range slots {
+			if slots[i] < slots[min] {
+				min = i
+			}
+		}
+		slots[min] += d
+	}
+
+	for _, tsk := range running {
+		// the phases of a run are accounted for by the run, and experiments
+		// by the runs of their cells, which take up the slots.
+		if (tsk.Parent != "" && tsk.Type != task.TypeRun) || tsk.Type == task.TypeExperiment {
+			continue
+		}
+		remaining := durations.estimate(tsk)
+		if started, ok := processingSince(tsk); ok {
+			remaining -= now.Sub(started)
+		}
+		if remaining > 0 {
+			assign(remaining)
+		}
+	}
+	for _, tsk := range ahead {
+		assign(durations.estimate(tsk))
+	}
+
+	wait := slots[0]
+	for _, s := range slots[1:] {
+		if s < wait {
+			wait = s
+		}
+	}
+	return wait, true, nil
+}
+
+// durationEstimator estimates how long tasks take from the processing time of
+// completed tasks, averaged per plan and case for runs, and across builds.
+type durationEstimator struct {
+	byKind  map[string]time.Duration
+	overall time.Duration
+}
+
+func newDurationEstimator(completed []*task.Task) *durationEstimator {
+	var (
+		sums   = make(map[string]time.Duration)
+		counts = make(map[string]int)
+		total  time.Duration
+		count  int
+	)
+	for _, tsk := range completed {
+		d, ok := processingTime(tsk)
+		if !ok {
+			continue
+		}
+		k := taskKind(tsk)
+		sums[k] += d
+		counts[k]++
+		total += d
+		count++
+	}
+
+	de := &durationEstimator{byKind: make(map[string]time.Duration, len(sums))}
+	for k, sum := range sums {
+		de.byKind[k] = sum / time.Duration(counts[k])
+	}
+	if count > 0 {
+		de.overall = total / time.Duration(count)
+	}
+	return de
+}
+
+func (de *durationEstimator) empty() bool {
+	return len(de.byKind) == 0
+}
+
+// estimate returns the average duration of tasks of the same kind, falling
+// back to the average across all tasks.
+func (de *durationEstimator) estimate(tsk *task.Task) time.Duration {
+	if d, ok := de.byKind[taskKind(tsk)]; ok {
+		return d
+	}
+	return de.overall
+}
+
+// taskKind groups tasks whose durations are expected to be alike.
+func taskKind(tsk *task.Task) string {
+	if tsk.Type == task.TypeBuild {
+		return string(tsk.Type)
+	}
+	return string(tsk.Type) + ":" + tsk.Plan + ":" + tsk.Case
+}
+
+// processingSince returns when a task started processing.
+func processingSince(tsk *task.Task) (time.Time, bool) {
+	for _, s := range tsk.States {
+		if s.State == task.StateProcessing {
+			return s.Created, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// processingTime returns how long a completed task was processed for. Canceled
+// tasks are left out, as they say little about how long tasks take.
+func processingTime(tsk *task.Task) (time.Duration, bool) {
+	if tsk.State().State != task.StateComplete {
+		return 0, false
+	}
+	started, ok := processingSince(tsk)
+	if !ok {
+		return 0, false
+	}
+	return tsk.State().Created.Sub(started), true
+}
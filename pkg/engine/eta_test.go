@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/testground/testground/pkg/task"
+)
+
+func completedTask(typ task.Type, plan, tcase string, took time.Duration, final task.State) *task.Task {
+	start := time.Now().Add(-time.Hour)
+	return &task.Task{
+		Type: typ,
+		Plan: plan,
+		Case: tcase,
+		States: []task.DatedState{
+			{State: task.StateScheduled, Created: start.Add(-time.Minute)},
+			{State: task.StateProcessing, Created: start},
+			{State: final, Created: start.Add(took)},
+		},
+	}
+}
+
+func TestDurationEstimator(t *testing.T) {
+	de := newDurationEstimator([]*task.Task{
+		completedTask(task.TypeRun, "network", "ping", 2*time.Minute, task.StateComplete),
+		completedTask(task.TypeRun, "network", "ping", 4*time.Minute, task.StateComplete),
+		completedTask(task.TypeRun, "network", "ping", time.Hour, task.StateCanceled),
+		completedTask(task.TypeBuild, "", "", 6*time.Minute, task.StateComplete),
+	})
+
+	for _, tc := range []struct {
+		tsk      *task.Task
+		expected time.Duration
+	}{
+		{&task.Task{Type: task.TypeRun, Plan: "network", Case: "ping"}, 3 * time.Minute},
+		{&task.Task{Type: task.TypeBuild}, 6 * time.Minute},
+		// unknown kinds use the average across all tasks.
+		{&task.Task{Type: task.TypeRun, Plan: "dht", Case: "find-peers"}, 4 * time.Minute},
+	} {
+		if got := de.estimate(tc.tsk); got != tc.expected {
+			t.Errorf("estimate for %s: got %s, expected %s", taskKind(tc.tsk), got, tc.expected)
+		}
+	}
+
+	if !newDurationEstimator(nil).empty() {
+		t.Errorf("estimator without history should be empty")
+	}
+}
@@ -0,0 +1,243 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+)
+
+// ExperimentInput is the input of an experiment task.
+type ExperimentInput struct {
+	*api.RunRequest
+	Sources *api.UnpackedSources
+	Cells   []*api.MatrixCell
+}
+
+// queueExperiment queues an experiment task for a run request expanding into
+// several cells, for the cells of a parameter matrix, the test cases to run,
+// or both. The experiment builds the composition once, and then queues a run
+// for each cell as a child task. It completes once all its cells have.
+func (e *Engine) queueExperiment(request *api.RunRequest, sources *api.UnpackedSources, cells []*api.MatrixCell) (string, error) {
+	return e.pushTask(e.queue.PushUniqueByBranch, &task.Task{
+		Version:     0,
+		Priority:    task.ClampPriority(request.Priority),
+		Plan:        request.Composition.Global.Plan,
+		Case:        request.Composition.Global.Case,
+		ID:          xid.New().String(),
+		Runner:      request.Composition.Global.Runner,
+		Type:        task.TypeExperiment,
+		Composition: request.Composition,
+		Input: &ExperimentInput{
+			RunRequest: request,
+			Sources:    sources,
+			Cells:      cells,
+		},
+		States: []task.DatedState{
+			{
+				State:   task.StateScheduled,
+				Created: time.Now().UTC(),
+			},
+		},
+		CreatedBy:      task.CreatedBy(request.CreatedBy),
+		Callback:       request.Callback,
+		IdempotencyKey: request.IdempotencyKey,
+		RequestID:      request.RequestID,
+		Labels:         request.Labels,
+	})
+}
+
+// checkCellParams checks the test parameters of every cell against the
+// manifest, so that an invalid matrix, e.g. with a misspelt parameter, is
+// rejected when it's queued, rather than by each of its cells once they run.
+func checkCellParams(cells []*api.MatrixCell, manifest *api.TestPlanManifest) error {
+	for _, cell := range cells {
+		if _, err := cell.Composition.CheckParams(manifest); err != nil {
+			return fmt.Errorf("invalid test parameters in cell %s: %w", formatParams(cell.Params), err)
+		}
+	}
+	return nil
+}
+
+// doExperiment performs an experiment task: it builds the groups that need it
+// as a child task, and queues the run of each cell with the artifacts of that
+// build. log is the log file of the experiment task.
+func (e *Engine) doExperiment(ctx context.Context, tsk *task.Task, input *ExperimentInput, ow *rpc.OutputWriter, log io.Writer) error {
+	if len(input.BuildGroups) > 0 {
+		bcomp, err := input.Composition.PickGroups(input.BuildGroups...)
+		if err != nil {
+			return err
+		}
+
+		bout, err := e.doChildBuild(ctx, tsk, &BuildInput{
+			BuildRequest: &api.BuildRequest{
+				Composition: bcomp,
+				Manifest:    input.Manifest,
+				CreatedBy:   api.CreatedBy(tsk.CreatedBy),
+			},
+			Sources: input.Sources,
+		}, log)
+		if err != nil {
+			return err
+		}
+
+		// all the cells run the artifacts of this build.
+		for _, cell := range input.Cells {
+			for i, groupIdx := range input.BuildGroups {
+				cell.Composition.Groups[groupIdx].Run.Artifact = bout[i].ArtifactPath
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	children := make([]*task.Task, 0, len(input.Cells))
+	for _, cell := range input.Cells {
+		req := *input.RunRequest
+		req.Composition = cell.Composition
+		req.BuildGroups = nil
+		req.TestCases = nil
+		req.Callback = ""
+		req.IdempotencyKey = ""
+
+		child := &task.Task{
+			Version:     tsk.Version,
+			Priority:    tsk.Priority,
+			Plan:        tsk.Plan,
+			Case:        cell.Composition.Global.Case,
+			ID:          xid.New().String(),
+			Runner:      tsk.Runner,
+			Type:        task.TypeRun,
+			Composition: req.Composition,
+			Input: &RunInput{
+				RunRequest: &req,
+				Sources:    input.Sources,
+			},
+			States:    []task.DatedState{{State: task.StateScheduled, Created: now}},
+			CreatedBy: tsk.CreatedBy,
+			Parent:    tsk.ID,
+			RequestID: tsk.RequestID,
+			Labels:    tsk.Labels,
+		}
+		children = append(children, child)
+		tsk.Children = append(tsk.Children, child.ID)
+	}
+
+	// the cells are recorded on the experiment before they are queued, so
+	// that it doesn't complete along with the first cells to.
+	if err := e.store.PersistProcessing(tsk); err != nil {
+		return fmt.Errorf("could not persist experiment task: %w", err)
+	}
+
+	push := e.pushLabeled(e.queue.Push)
+	for i, child := range children {
+		// the cells of an experiment don't replace one another, so they are
+		// not pushed unique by branch.
+		if err := push(child); err != nil {
+			return fmt.Errorf("could not queue cell %d of experiment: %w", i, err)
+		}
+		ow.Infow("queued experiment cell", "task_id", child.ID, "case", child.Case, "params", formatParams(input.Cells[i].Params))
+	}
+	return nil
+}
+
+// finishExperimentCell completes the experiment a run task is a cell of, if
+// all the cells of the experiment have completed. The result of the
+// experiment holds the outcome of each cell, by task ID.
+func (e *Engine) finishExperimentCell(cell *task.Task) {
+	e.experimentLk.Lock()
+	defer e.experimentLk.Unlock()
+
+	parent, err := e.store.Get(cell.Parent)
+	if err != nil || parent.Type != task.TypeExperiment || parent.State().State != task.StateProcessing {
+		return
+	}
+
+	var (
+		result = &runner.Result{
+			Outcome:  task.OutcomeSuccess,
+			Outcomes: make(map[string]*runner.GroupOutcome),
+		}
+		cells  []*task.Task
+		failed int
+	)
+	for _, id := range parent.Children {
+		child, err := e.store.Get(id)
+		if err == task.ErrNotFound {
+			// not queued yet.
+			return
+		}
+
+		o := &runner.GroupOutcome{Total: 1}
+		if err != nil {
+			logging.S().Errorw("could not get experiment cell", "task_id", parent.ID, "cell_id", id, "err", err)
+			result.Outcomes[id] = o
+			failed++
+			continue
+		}
+		if child.Type != task.TypeRun {
+			// the build of the experiment.
+			continue
+		}
+
+		switch child.State().State {
+		case task.StateScheduled, task.StateProcessing:
+			return
+		case task.StateComplete:
+			if child.Error == "" && data.DecodeRunnerResult(child.Result).Outcome == task.OutcomeSuccess {
+				o.Ok = 1
+			}
+		}
+		if o.Ok == 0 {
+			failed++
+		}
+		result.Outcomes[id] = o
+		cells = append(cells, child)
+	}
+
+	if failed > 0 {
+		result.Outcome = task.OutcomeFailure
+	}
+
+	f, err := os.OpenFile(e.taskLogPath(parent.ID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		ow := taskOutputWriter(parent, f)
+		for _, c := range cells {
+			outcome := task.OutcomeSuccess
+			if result.Outcomes[c.ID].Ok == 0 {
+				outcome = task.OutcomeFailure
+			}
+			ow.Infow("experiment cell completed", "task_id", c.ID, "case", c.Case, "outcome", outcome)
+		}
+		ow.Infow("experiment completed", "task_id", parent.ID, "cells", len(result.Outcomes), "failed", failed)
+		ow.WriteStage(rpc.StageDone)
+		f.Close()
+	}
+	e.deleteSignal(parent.ID)
+
+	if err := e.finishTask(parent, result, nil); err != nil {
+		logging.S().Errorw("could not finish experiment", "task_id", parent.ID, "err", err)
+		return
+	}
+	go e.postCallback(parent)
+}
+
+// formatParams renders test parameters as sorted key=value pairs.
+func formatParams(params map[string]string) string {
+	kvs := make([]string, 0, len(params))
+	for k, v := range params {
+		kvs = append(kvs, k+"="+v)
+	}
+	sort.Strings(kvs)
+	return strings.Join(kvs, ",")
+}
@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/data"
+	"github.com/testground/testground/pkg/runner"
+	"github.com/testground/testground/pkg/task"
+	"github.com/testground/testground/pkg/testutil"
+)
+
+func TestExperimentLifecycle(t *testing.T) {
+	testutil.Setenv(t, config.EnvTestgroundHomeDir, t.TempDir())
+
+	cfg := &config.EnvConfig{}
+	if err := cfg.EnsureMinimalConfig(); err != nil {
+		t.Fatal(err)
+	}
+	store, err := task.NewMemoryTaskStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue, err := task.NewQueue(store, 10, UnmarshalTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Engine{envcfg: cfg, store: store, queue: queue, signals: make(map[string]chan int)}
+
+	comp := api.Composition{
+		Global: api.Global{
+			Plan:   "network",
+			Case:   "ping-pong",
+			Runner: "local:docker",
+			Matrix: map[string][]interface{}{"payload_size": {int64(1024), int64(4096)}},
+		},
+		Groups: []*api.Group{{ID: "a"}},
+	}
+	cells, err := comp.ExpandCells([]string{"ping-pong", "traffic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := e.queueExperiment(&api.RunRequest{Composition: comp}, nil, cells)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent, err := queue.Pop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.ID != id || parent.Type != task.TypeExperiment {
+		t.Fatalf("experiment: got task %s of type %s", parent.ID, parent.Type)
+	}
+	parent.States = append(parent.States, task.DatedState{State: task.StateProcessing, Created: time.Now().UTC()})
+	if err := store.PersistProcessing(parent); err != nil {
+		t.Fatal(err)
+	}
+
+	input := parent.Input.(*ExperimentInput)
+	if err := e.doExperiment(context.Background(), parent, input, taskOutputWriter(parent, io.Discard), io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if len(parent.Children) != 4 {
+		t.Fatalf("experiment: got %d children, expected 4", len(parent.Children))
+	}
+
+	var children []*task.Task
+	cases := make(map[string]int)
+	for range parent.Children {
+		child, err := queue.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if child.Parent != id {
+			t.Errorf("cell parent: got %q, expected %q", child.Parent, id)
+		}
+		children = append(children, child)
+		cases[child.Case]++
+	}
+	if cases["ping-pong"] != 2 || cases["traffic"] != 2 {
+		t.Errorf("cell cases: got %v", cases)
+	}
+
+	for _, child := range children[:3] {
+		if err := e.finishTask(child, &runner.Result{Outcome: task.OutcomeSuccess}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if parent, _ = store.Get(id); parent.State().State != task.StateProcessing {
+		t.Fatalf("experiment completed with a cell still running")
+	}
+
+	if err := e.finishTask(children[3], &runner.Result{Outcome: task.OutcomeFailure}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if parent, _ = store.Get(id); parent.State().State != task.StateComplete {
+		t.Fatalf("experiment state: got %s, expected %s", parent.State().State, task.StateComplete)
+	}
+	res := data.DecodeRunnerResult(parent.Result)
+	if res.Outcome != task.OutcomeFailure {
+		t.Errorf("experiment result: got %v, expected a failure", parent.Result)
+	}
+	if o := res.Outcomes[children[3].ID]; o == nil || o.Ok != 0 || o.Total != 1 {
+		t.Errorf("outcome of the failed cell: got %v", o)
+	}
+	if o := res.Outcomes[children[0].ID]; o == nil || o.Ok != 1 {
+		t.Errorf("outcome of a successful cell: got %v", o)
+	}
+}
+
+func TestCheckCellParams(t *testing.T) {
+	manifest := &api.TestPlanManifest{
+		Name: "network",
+		TestCases: []*api.TestCase{{
+			Name:      "ping-pong",
+			Instances: api.InstanceConstraints{Minimum: 1, Maximum: 10},
+			Parameters: map[string]api.Parameter{
+				"payload_size": {Type: "int"},
+			},
+		}},
+	}
+	expand := func(matrix map[string][]interface{}) []*api.MatrixCell {
+		t.Helper()
+		comp := api.Composition{
+			Global: api.Global{Plan: "network", Case: "ping-pong", Runner: "local:docker", Matrix: matrix},
+			Groups: []*api.Group{{ID: "a"}},
+		}
+		cells, err := comp.ExpandCells(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cells
+	}
+
+	if err := checkCellParams(expand(map[string][]interface{}{"payload_size": {int64(1024), int64(4096)}}), manifest); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := checkCellParams(expand(map[string][]interface{}{"payload_sise": {int64(1024)}}), manifest); err == nil {
+		t.Error("expected an error for an undeclared matrix parameter")
+	}
+	if err := checkCellParams(expand(map[string][]interface{}{"payload_size": {"large"}}), manifest); err == nil {
+		t.Error("expected an error for a matrix value of the wrong type")
+	}
+}
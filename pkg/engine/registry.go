@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+var (
+	// registryLk guards AllRunners, AllBuilders and registeredCompatibility
+	// against concurrent registrations.
+	registryLk sync.RWMutex
+
+	// registeredCompatibility holds the IDs of the registered builders whose
+	// artifacts a runner can work with, besides its CompatibleBuilders, by
+	// runner ID.
+	registeredCompatibility = make(map[string][]string)
+)
+
+// RegisterRunner adds a runner to AllRunners, the runners of the engines
+// created by NewDefaultEngine. It lets a build of testground include
+// out-of-tree runners, by registering them in its main package before the
+// engine is constructed. Runners registered afterwards are not known to
+// existing engines.
+func RegisterRunner(r api.Runner) error {
+	registryLk.Lock()
+	defer registryLk.Unlock()
+
+	for _, existing := range AllRunners {
+		if existing.ID() == r.ID() {
+			return fmt.Errorf("runner %s already registered", r.ID())
+		}
+	}
+	AllRunners = append(AllRunners, r)
+	return nil
+}
+
+// RegisterBuilder adds a builder to AllBuilders, the builders of the engines
+// created by NewDefaultEngine, like RegisterRunner does for runners. The
+// runners with the supplied IDs are deemed compatible with the builder, in
+// addition to the runners listing it in their CompatibleBuilders.
+func RegisterBuilder(b api.Builder, compatibleRunners ...string) error {
+	registryLk.Lock()
+	defer registryLk.Unlock()
+
+	for _, existing := range AllBuilders {
+		if existing.ID() == b.ID() {
+			return fmt.Errorf("builder %s already registered", b.ID())
+		}
+	}
+	AllBuilders = append(AllBuilders, b)
+
+	for _, r := range compatibleRunners {
+		registeredCompatibility[r] = append(registeredCompatibility[r], b.ID())
+	}
+	return nil
+}
+
+// compatibleBuilders returns the IDs of the builders whose artifacts a runner
+// can work with: its CompatibleBuilders, and the registered builders declared
+// compatible with it.
+func compatibleBuilders(r api.Runner) []string {
+	registryLk.RLock()
+	defer registryLk.RUnlock()
+
+	res := append([]string(nil), r.CompatibleBuilders()...)
+	for _, b := range registeredCompatibility[r.ID()] {
+		if !stringInSlice(b, res) {
+			res = append(res, b)
+		}
+	}
+	return res
+}
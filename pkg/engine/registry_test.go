@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+)
+
+type testRunner struct{ id string }
+
+func (r *testRunner) ID() string                 { return r.id }
+func (*testRunner) ConfigType() reflect.Type     { return reflect.TypeOf(struct{}{}) }
+func (*testRunner) CompatibleBuilders() []string { return []string{"docker:go"} }
+func (*testRunner) Run(context.Context, *api.RunInput, *rpc.OutputWriter) (*api.RunOutput, error) {
+	return &api.RunOutput{}, nil
+}
+func (*testRunner) CollectOutputs(context.Context, *api.CollectionInput, *rpc.OutputWriter) error {
+	return nil
+}
+
+type testBuilder struct{ id string }
+
+func (b *testBuilder) ID() string             { return b.id }
+func (*testBuilder) ConfigType() reflect.Type { return reflect.TypeOf(struct{}{}) }
+func (*testBuilder) Build(context.Context, *api.BuildInput, *rpc.OutputWriter) (*api.BuildOutput, error) {
+	return &api.BuildOutput{}, nil
+}
+func (*testBuilder) Purge(context.Context, string, *rpc.OutputWriter) error { return nil }
+
+func TestRegisterRunner(t *testing.T) {
+	defer func(runners []api.Runner) { AllRunners = runners }(AllRunners)
+
+	if err := RegisterRunner(&testRunner{id: "custom:infra"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := AllRunners[len(AllRunners)-1]; r.ID() != "custom:infra" {
+		t.Errorf("last runner: got %s, expected custom:infra", r.ID())
+	}
+
+	if err := RegisterRunner(&testRunner{id: "local:docker"}); err == nil {
+		t.Error("expected registering a known runner ID to fail")
+	}
+}
+
+func TestRegisterBuilder(t *testing.T) {
+	defer func(builders []api.Builder) { AllBuilders = builders }(AllBuilders)
+	defer func() { registeredCompatibility = make(map[string][]string) }()
+
+	if err := RegisterBuilder(&testBuilder{id: "docker:go-custom"}, "custom:infra"); err != nil {
+		t.Fatal(err)
+	}
+	if b := AllBuilders[len(AllBuilders)-1]; b.ID() != "docker:go-custom" {
+		t.Errorf("last builder: got %s, expected docker:go-custom", b.ID())
+	}
+
+	compatible := compatibleBuilders(&testRunner{id: "custom:infra"})
+	if !stringInSlice("docker:go", compatible) || !stringInSlice("docker:go-custom", compatible) {
+		t.Errorf("compatible builders: got %v", compatible)
+	}
+	if compatible := compatibleBuilders(&testRunner{id: "other"}); stringInSlice("docker:go-custom", compatible) {
+		t.Errorf("compatible builders of another runner: got %v", compatible)
+	}
+
+	if err := RegisterBuilder(&testBuilder{id: "docker:go-custom"}); err == nil {
+		t.Error("expected registering a known builder ID to fail")
+	}
+}
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/logging"
+	"github.com/testground/testground/pkg/runner"
+)
+
+// Reload replaces the environment configuration of the engine. Running tasks
+// are not disrupted, as their builders and runners keep the configuration
+// they were given; the next tasks pick the new one up, e.g. new registry
+// credentials, runner and builder settings, task timeouts and concurrency
+// limits.
+//
+// Changes to the settings that the engine only reads when it is created,
+// such as the number of workers or the task storage, are ignored with a
+// warning, and require a restart.
+func (e *Engine) Reload(cfg *config.EnvConfig) {
+	e.envcfgLk.Lock()
+	defer e.envcfgLk.Unlock()
+
+	cur, next := e.envcfg.Daemon, cfg.Daemon
+	for _, s := range []struct {
+		key     string
+		changed bool
+	}{
+		{"daemon.scheduler.workers", next.Scheduler.Workers != cur.Scheduler.Workers},
+		{"daemon.scheduler.queue_size", next.Scheduler.QueueSize != cur.Scheduler.QueueSize},
+		{"daemon.scheduler.task_repo_type", next.Scheduler.TaskRepoType != cur.Scheduler.TaskRepoType},
+		{"daemon.subnets", next.Subnets != cur.Subnets},
+	} {
+		if s.changed {
+			logging.S().Warnw("ignoring configuration change that requires a restart", "key", s.key)
+		}
+	}
+
+	reloaded := *cfg
+	reloaded.Daemon.Scheduler.Workers = cur.Scheduler.Workers
+	reloaded.Daemon.Scheduler.QueueSize = cur.Scheduler.QueueSize
+	reloaded.Daemon.Scheduler.TaskRepoType = cur.Scheduler.TaskRepoType
+	reloaded.Daemon.Subnets = cur.Subnets
+
+	e.envcfg = &reloaded
+	for _, r := range e.runners {
+		if s, ok := r.(runner.EnvConfigSetter); ok {
+			s.SetEnvConfig(reloaded)
+		}
+	}
+	logging.S().Infow("configuration reloaded")
+}
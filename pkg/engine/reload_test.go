@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/testground/testground/pkg/config"
+)
+
+func TestReload(t *testing.T) {
+	cfg := &config.EnvConfig{}
+	cfg.Daemon.Scheduler.Workers = 2
+	cfg.Daemon.Scheduler.MaxConcurrentTasks = 4
+	cfg.DockerHub.AccessToken = "old"
+	e := &Engine{envcfg: cfg}
+
+	next := &config.EnvConfig{}
+	next.Daemon.Scheduler.Workers = 8
+	next.Daemon.Scheduler.MaxConcurrentTasks = 16
+	next.DockerHub.AccessToken = "new"
+	e.Reload(next)
+
+	got := e.EnvConfig()
+	if got.Daemon.Scheduler.MaxConcurrentTasks != 16 || got.DockerHub.AccessToken != "new" {
+		t.Errorf("expected the reloadable settings to be applied, got %+v", got)
+	}
+	if got.Daemon.Scheduler.Workers != 2 {
+		t.Errorf("expected the number of workers to be kept, got %d", got.Daemon.Scheduler.Workers)
+	}
+	if cfg.DockerHub.AccessToken != "old" || next.Daemon.Scheduler.Workers != 8 {
+		t.Errorf("expected the given configurations to be left untouched")
+	}
+}
+
+func TestReloadTaskTimeout(t *testing.T) {
+	e := &Engine{envcfg: &config.EnvConfig{}}
+	if got := e.taskTimeout(); got != 10*time.Minute {
+		t.Errorf("expected the default task timeout, got %s", got)
+	}
+
+	next := &config.EnvConfig{}
+	next.Daemon.Scheduler.TaskTimeoutMin = 30
+	e.Reload(next)
+	if got := e.taskTimeout(); got != 30*time.Minute {
+		t.Errorf("expected the reloaded task timeout, got %s", got)
+	}
+}
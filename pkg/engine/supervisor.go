@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -32,6 +33,16 @@ type BuildInput struct {
 	Sources *api.UnpackedSources
 }
 
+// taskOutputWriter returns an output writer to w, tagging the log lines with
+// the ID of the request that created the task, if known.
+func taskOutputWriter(tsk *task.Task, w io.Writer) *rpc.OutputWriter {
+	ow := rpc.NewFileOutputWriter(w)
+	if tsk.RequestID != "" {
+		ow.SugaredLogger = ow.SugaredLogger.With("req_id", tsk.RequestID)
+	}
+	return ow
+}
+
 func (e *Engine) addSignal(id string, ch chan int) {
 	e.signalsLk.Lock()
 	e.signals[id] = ch
@@ -44,16 +55,70 @@ func (e *Engine) deleteSignal(id string) {
 	e.signalsLk.Unlock()
 }
 
+// errConcurrencyLimit is returned by nextTask when the scheduler's maximum
+// number of concurrent tasks is already in flight.
+var errConcurrencyLimit = errors.New("concurrency limit reached")
+
+// nextTask pops the next task to process, honouring the scheduler's
+// concurrency limit and fair-share policy. The task counts as active until
+// releaseTask is called for it.
+func (e *Engine) nextTask() (*task.Task, error) {
+	sched := e.EnvConfig().Daemon.Scheduler
+
+	e.activeLk.Lock()
+	defer e.activeLk.Unlock()
+
+	if sched.MaxConcurrentTasks > 0 && e.activeTotal >= sched.MaxConcurrentTasks {
+		return nil, errConcurrencyLimit
+	}
+
+	var (
+		tsk *task.Task
+		err error
+	)
+	if sched.FairShare {
+		tsk, err = e.queue.PopFair(func(submitter string) int {
+			return e.active[submitter]
+		})
+	} else {
+		tsk, err = e.queue.Pop()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e.active[tsk.Submitter()]++
+	e.activeTotal++
+	return tsk, nil
+}
+
+// releaseTask stops counting a task popped by nextTask as active.
+func (e *Engine) releaseTask(tsk *task.Task) {
+	e.activeLk.Lock()
+	defer e.activeLk.Unlock()
+
+	s := tsk.Submitter()
+	if e.active[s]--; e.active[s] <= 0 {
+		delete(e.active, s)
+	}
+	e.activeTotal--
+}
+
+// taskTimeout returns the timeout of the next task, read from the current
+// configuration, as it may be reloaded.
+func (e *Engine) taskTimeout() time.Duration {
+	if m := e.EnvConfig().Daemon.Scheduler.TaskTimeoutMin; m != 0 {
+		return time.Duration(m) * time.Minute
+	}
+	return 10 * time.Minute
+}
+
 func (e *Engine) worker(n int) {
 	logging.S().Infow("supervisor worker started", "worker_id", n)
-	taskTimeout := 10 * time.Minute
-	if e.EnvConfig().Daemon.Scheduler.TaskTimeoutMin != 0 {
-		taskTimeout = time.Duration(e.EnvConfig().Daemon.Scheduler.TaskTimeoutMin) * time.Minute
-	}
 
 	for {
-		tsk, err := e.queue.Pop()
-		if err == task.ErrQueueEmpty {
+		tsk, err := e.nextTask()
+		if err == task.ErrQueueEmpty || err == errConcurrencyLimit {
 			time.Sleep(time.Second)
 			continue
 		}
@@ -64,8 +129,21 @@ func (e *Engine) worker(n int) {
 		}
 
 		func() {
-			ctx, cancel := context.WithTimeout(context.Background(), taskTimeout)
-			defer cancel()
+			// detached is set when a run continues in the background after
+			// the runner returned; the task is then completed asynchronously.
+			var detached bool
+			defer func() {
+				if !detached {
+					e.releaseTask(tsk)
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), e.taskTimeout())
+			defer func() {
+				if !detached {
+					cancel()
+				}
+			}()
 
 			ch := make(chan int)
 			e.addSignal(tsk.ID, ch)
@@ -95,15 +173,18 @@ func (e *Engine) worker(n int) {
 			}
 
 			// Create a packing directory under the work dir.
-			file := filepath.Join(e.EnvConfig().Dirs().Daemon(), tsk.ID+".out")
-			f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			f, err := os.OpenFile(e.taskLogPath(tsk.ID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 			if err != nil {
 				logging.S().Errorw("could not create stop log", "err", err)
 				return
 			}
-			defer f.Close()
+			defer func() {
+				if !detached {
+					f.Close()
+				}
+			}()
 
-			ow := rpc.NewFileOutputWriter(f)
+			ow := taskOutputWriter(tsk, f)
 
 			var result interface{}
 			var errTask error
@@ -111,7 +192,7 @@ func (e *Engine) worker(n int) {
 			switch tsk.Type {
 			case task.TypeRun:
 				var res *api.RunOutput
-				res, errTask = e.doRun(ctx, tsk.ID, tsk.Input.(*RunInput), ow)
+				res, errTask = e.doRun(ctx, tsk, tsk.Input.(*RunInput), ow, f)
 
 				if errTask != nil {
 					errTask = &TaskExecutionError{TaskType: string(tsk.Type), WrappedErr: errTask}
@@ -122,9 +203,37 @@ func (e *Engine) worker(n int) {
 					result = res.Result
 					tsk.Composition = res.Composition
 				}
+
+				if errTask == nil && res != nil && res.Done != nil {
+					detached = true
+					tsk.Result = result
+					if err := e.store.PersistProcessing(tsk); err != nil {
+						logging.S().Errorw("could not persist task", "err", err)
+					}
+					logging.S().Infow("worker detached from task", "worker_id", n, "task_id", tsk.ID)
+
+					go func() {
+						defer e.releaseTask(tsk)
+						defer f.Close()
+						defer cancel()
+
+						c := <-res.Done
+						if c.Output != nil {
+							result = c.Output.Result
+						}
+						errTask := c.Err
+						if errTask != nil {
+							errTask = &TaskExecutionError{TaskType: string(tsk.Type), WrappedErr: errTask}
+							logging.S().Errorw("detached run returned err", "err", errTask)
+						}
+						e.completeTask(tsk, ow, result, errTask)
+						logging.S().Infow("detached task completed", "task_id", tsk.ID)
+					}()
+					return
+				}
 			case task.TypeBuild:
 				var res []*api.BuildOutput
-				res, errTask = e.doBuild(ctx, tsk.Input.(*BuildInput), ow)
+				res, errTask = e.doBuild(ctx, tsk, tsk.Input.(*BuildInput), ow)
 				if errTask != nil {
 					errTask = &TaskExecutionError{TaskType: string(tsk.Type), WrappedErr: errTask}
 					logging.S().Errorw("doBuild returned err", "err", errTask)
@@ -138,59 +247,91 @@ func (e *Engine) worker(n int) {
 					result = artifactPaths
 				}
 
+			case task.TypeExperiment:
+				errTask = e.doExperiment(ctx, tsk, tsk.Input.(*ExperimentInput), ow, f)
+				if errTask == nil {
+					// the experiment completes along with its last cell, in
+					// finishExperimentCell; its log is followed until then.
+					logging.S().Infow("worker queued experiment cells", "worker_id", n, "task_id", tsk.ID)
+					return
+				}
+				errTask = &TaskExecutionError{TaskType: string(tsk.Type), WrappedErr: errTask}
+				logging.S().Errorw("doExperiment returned err", "err", errTask)
+
 			default:
 				logging.S().Errorw("unknown task type", "type", tsk.Type)
 				return
 			}
 
-			newState := task.DatedState{
-				Created: time.Now().UTC(),
-				State:   task.StateComplete,
-			}
-			if errTask != nil {
-				tsk.Error = errTask.Error()
-
-				var e *TaskExecutionError
-				if errors.As(errTask, &e) || errors.Is(errTask, context.Canceled) {
-					newState.State = task.StateCanceled
-					logging.S().Errorw("task cancelled due to error", "err", errTask)
-				} else {
-					logging.S().Infow("Task encountered error, but was not canceled.")
-				}
-			}
+			e.completeTask(tsk, ow, result, errTask)
+			logging.S().Infow("worker completed task", "worker_id", n, "task_id", tsk.ID)
+		}()
+	}
+}
 
-			tsk.States = append(tsk.States, newState)
-			tsk.Result = result
+// completeTask records the final state and result of a task, archives it, and
+// sends out notifications.
+func (e *Engine) completeTask(tsk *task.Task, ow *rpc.OutputWriter, result interface{}, errTask error) {
+	defer e.deleteSignal(tsk.ID)
 
-			err = e.store.PersistProcessing(tsk)
-			if err != nil {
-				logging.S().Errorw("could not persist task", "err", err)
-				return
-			}
+	ow.WriteStage(rpc.StageDone)
 
-			err = e.store.ArchiveTask(tsk)
-			if err != nil {
-				logging.S().Errorw("could not archive task", "err", err)
-				return
-			}
+	if err := e.finishTask(tsk, result, errTask); err != nil {
+		logging.S().Errorw("could not finish task", "err", err)
+		return
+	}
 
-			err = e.postStatusToSlack(tsk)
-			if err != nil {
-				logging.S().Errorw("could not send status to slack", "err", err)
-			}
-			err = e.postStatusToGithub(tsk)
-			if err != nil {
-				logging.S().Errorw("could not post status to github", "err", err)
-			}
+	err := e.postStatusToSlack(tsk)
+	if err != nil {
+		logging.S().Errorw("could not send status to slack", "err", err)
+	}
+	err = e.postStatusToGithub(tsk)
+	if err != nil {
+		logging.S().Errorw("could not post status to github", "err", err)
+	}
 
-			e.deleteSignal(tsk.ID)
-			logging.S().Infow("worker completed task", "worker_id", n, "task_id", tsk.ID)
-		}()
+	go e.postCallback(tsk)
+}
+
+// finishTask records the final state and result of a task, and archives it.
+func (e *Engine) finishTask(tsk *task.Task, result interface{}, errTask error) error {
+	newState := task.DatedState{
+		Created: time.Now().UTC(),
+		State:   task.StateComplete,
 	}
+	if errTask != nil {
+		tsk.Error = errTask.Error()
+
+		var execErr *TaskExecutionError
+		if errors.As(errTask, &execErr) || errors.Is(errTask, context.Canceled) {
+			newState.State = task.StateCanceled
+			logging.S().Errorw("task cancelled due to error", "err", errTask)
+		} else {
+			logging.S().Infow("Task encountered error, but was not canceled.")
+		}
+	}
+
+	tsk.States = append(tsk.States, newState)
+	tsk.Result = result
+
+	err := e.store.PersistProcessing(tsk)
+	if err != nil {
+		return fmt.Errorf("could not persist task: %w", err)
+	}
+
+	err = e.store.ArchiveTask(tsk)
+	if err != nil {
+		return fmt.Errorf("could not archive task: %w", err)
+	}
+
+	if tsk.Parent != "" && tsk.Type == task.TypeRun {
+		e.finishExperimentCell(tsk)
+	}
+	return nil
 }
 
 func (e *Engine) postStatusToGithub(tsk *task.Task) error {
-	if e.envcfg.Daemon.GithubRepoStatusToken == "" {
+	if e.EnvConfig().Daemon.GithubRepoStatusToken == "" {
 		return nil
 	}
 
@@ -245,7 +386,7 @@ func (e *Engine) postStatusToGithub(tsk *task.Task) error {
 	if err != nil {
 		return err
 	}
-	req.Header.Add("Authorization", "Basic "+e.envcfg.Daemon.GithubRepoStatusToken)
+	req.Header.Add("Authorization", "Basic "+e.EnvConfig().Daemon.GithubRepoStatusToken)
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
 	res, err := cl.Do(req)
@@ -259,7 +400,7 @@ func (e *Engine) postStatusToGithub(tsk *task.Task) error {
 }
 
 func (e *Engine) postStatusToSlack(tsk *task.Task) error {
-	if e.envcfg.Daemon.SlackWebhookURL == "" {
+	if e.EnvConfig().Daemon.SlackWebhookURL == "" {
 		return nil
 	}
 
@@ -282,7 +423,7 @@ func (e *Engine) postStatusToSlack(tsk *task.Task) error {
 	cl := &http.Client{Timeout: time.Second * 10}
 	body := strings.NewReader(payload)
 	res, err := cl.Post(
-		e.envcfg.Daemon.SlackWebhookURL,
+		e.EnvConfig().Daemon.SlackWebhookURL,
 		"application/json; charset=UTF-8",
 		body,
 	)
@@ -295,7 +436,7 @@ func (e *Engine) postStatusToSlack(tsk *task.Task) error {
 	return nil
 }
 
-func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputWriter) ([]*api.BuildOutput, error) {
+func (e *Engine) doBuild(ctx context.Context, tsk *task.Task, input *BuildInput, ow *rpc.OutputWriter) ([]*api.BuildOutput, error) {
 	sources := input.Sources
 	comp, err := input.Composition.PrepareForBuild(&input.Manifest)
 
@@ -311,6 +452,8 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 		plan = clean(comp.Global.Plan)
 	)
 
+	ow.WriteStage(rpc.StageBuilding)
+
 	// Validate builders we use
 	usedBuilders := comp.ListBuilders()
 
@@ -425,15 +568,6 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 
 			ow.Infow("performing build for groups", "plan", plan, "groups", grpids, "builder", builder)
 
-			deps := make(map[string]api.DependencyTarget, len(grp.Build.Dependencies))
-
-			for _, dep := range grp.Build.Dependencies {
-				deps[dep.Module] = api.DependencyTarget{
-					Target:  dep.Target,
-					Version: dep.Version,
-				}
-			}
-
 			// This var compiles all configurations to coalesce.
 			//
 			// Precedence (highest to lowest):
@@ -443,7 +577,7 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 			//  3. Builder defaults (applied by the builder itself, nothing to do here).
 			//
 			var cfg config.CoalescedConfig
-			cfg = cfg.Append(e.envcfg.Builders[builder]) // env config for the builder
+			cfg = cfg.Append(e.EnvConfig().Builders[builder]) // env config for the builder
 			groupCfg := cfg.Append(grp.BuildConfig)      // add the group config
 
 			// Coalesce all configurations and deserialize into the config type
@@ -456,10 +590,11 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 
 			in := &api.BuildInput{
 				BuildID:         uuid.New().String()[24:],
-				EnvConfig:       *e.envcfg,
+				RequestID:       tsk.RequestID,
+				EnvConfig:       e.EnvConfig(),
 				TestPlan:        plan,
 				Selectors:       grp.Build.Selectors,
-				Dependencies:    deps,
+				Dependencies:    grp.Build.DependencyTargets(),
 				BuildConfig:     obj,
 				UnpackedSources: src,
 			}
@@ -491,20 +626,25 @@ func (e *Engine) doBuild(ctx context.Context, input *BuildInput, ow *rpc.OutputW
 	return ress, nil
 }
 
-func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.OutputWriter) (*api.RunOutput, error) {
+// doRun performs a run task, building the groups that need it first, as a
+// child task of the run. log is the log file of the run task.
+func (e *Engine) doRun(ctx context.Context, tsk *task.Task, input *RunInput, ow *rpc.OutputWriter, log io.Writer) (*api.RunOutput, error) {
+	id := tsk.ID
+
 	if len(input.BuildGroups) > 0 {
 		bcomp, err := input.Composition.PickGroups(input.BuildGroups...)
 		if err != nil {
 			return nil, err
 		}
 
-		bout, err := e.doBuild(ctx, &BuildInput{
+		bout, err := e.doChildBuild(ctx, tsk, &BuildInput{
 			BuildRequest: &api.BuildRequest{
 				Composition: bcomp,
 				Manifest:    input.Manifest,
+				CreatedBy:   api.CreatedBy(tsk.CreatedBy),
 			},
 			Sources: input.Sources,
-		}, ow)
+		}, log)
 		if err != nil {
 			return nil, err
 		}
@@ -517,6 +657,14 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 		}
 	}
 
+	warnings, err := input.Composition.CheckParams(&input.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid test parameters: %w", err)
+	}
+	for _, w := range warnings {
+		ow.Warn(w)
+	}
+
 	comp, err := input.Composition.PrepareForRun(&input.Manifest)
 	if err != nil {
 		return nil, err
@@ -556,20 +704,26 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 	//
 	// Precedence (highest to lowest):
 	//
-	//  1. CLI --run-param, --build-param flags.
-	//  2. .env.toml.
-	//  3. Builder defaults (applied by the builder itself, nothing to do here).
+	//  1. CLI --run-param, --build-param flags, and the runner configuration
+	//     of the test plan manifest (merged into the composition).
+	//  2. Runner defaults of the test plan manifest.
+	//  3. .env.toml.
+	//  4. Builder defaults (applied by the builder itself, nothing to do here).
 	//
 	var cfg config.CoalescedConfig
 
-	// 2. Get the env config for the runner.
-	cfg = cfg.Append(e.envcfg.Runners[trunner])
+	// 3. Get the env config for the runner.
+	cfg = cfg.Append(e.EnvConfig().Runners[trunner])
 
-	var flag = e.envcfg.Runners[trunner][config.RunnerDisabledFlag]
+	var flag = e.EnvConfig().Runners[trunner][config.RunnerDisabledFlag]
 	if flag == true {
 		return nil, runner.ErrRunnerDisabled
 	}
 
+	// 2. Get the defaults of the test plan for the runner.
+	defaults := input.Manifest.RunnerDefaults[trunner]
+	cfg = cfg.Append(defaults.Config)
+
 	// 1. Get overrides from the composition.
 	cfg = cfg.Append(comp.Global.RunConfig)
 
@@ -596,13 +750,17 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 
 	in := api.RunInput{
 		RunID:          id,
-		EnvConfig:      *e.envcfg,
+		RequestID:      tsk.RequestID,
+		EnvConfig:      e.EnvConfig(),
 		RunnerConfig:   obj,
 		TestPlan:       clean(plan),
 		TestCase:       clean(tcase),
 		TotalInstances: int(compRun.TotalInstances),
 		Groups:         make([]*api.RunGroup, 0, len(compRun.Groups)),
 		DisableMetrics: comp.Global.DisableMetrics,
+		Composition:    compositionUsedForRun,
+
+		AllowUnderResourced: input.AllowUnderResourced,
 	}
 
 	for _, grp := range compRun.Groups {
@@ -611,22 +769,41 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 			return nil, err
 		}
 
+		mounts, err := e.resolveMounts(grp.Mounts)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", grp.ID, err)
+		}
+
+		initContainers := grp.InitContainers
+		if len(initContainers) == 0 {
+			initContainers = defaults.InitContainers
+		}
+
 		g := &api.RunGroup{
-			ID:           grp.ID,
-			Instances:    int(grp.CalculatedInstanceCount()),
-			ArtifactPath: buildgroup.Run.Artifact,
-			Parameters:   grp.TestParams,
-			Resources:    grp.Resources,
-			Profiles:     grp.Profiles,
+			ID:                   grp.ID,
+			Instances:            int(grp.CalculatedInstanceCount()),
+			ArtifactPath:         buildgroup.Run.Artifact,
+			Parameters:           grp.TestParams,
+			Resources:            grp.Resources,
+			RecommendedResources: defaults.Resources,
+			Profiles:             grp.Profiles,
+			Mounts:               mounts,
+			Role:                 grp.Role,
+			Dials:                grp.Dials,
+			InitContainers:       initContainers,
 		}
 
 		in.Groups = append(in.Groups, g)
 	}
 
 	ow.Infow("starting run", "run_id", id, "plan", in.TestPlan, "case", in.TestCase, "runner", trunner, "instances", in.TotalInstances)
+	ow.WriteStage(rpc.StageScheduling)
+	in.StartTime = time.Now()
 	out, err := run.Run(ctx, &in, ow)
 
-	if err == nil {
+	if err == nil && out.Done != nil {
+		ow.Infow("run continues in the background", "run_id", id, "plan", plan, "case", tcase, "runner", trunner, "instances", in.TotalInstances)
+	} else if err == nil {
 		message := "run finished with outcome unknown"
 		if out.Result != nil {
 			message = fmt.Sprintf("run finished with %v", out.Result)
@@ -646,6 +823,30 @@ func (e *Engine) doRun(ctx context.Context, id string, input *RunInput, ow *rpc.
 	return out, err
 }
 
+// resolveMounts parses the mounts declared in a composition, resolves their
+// symbolic links, and checks that they lie within the mount roots allowed by
+// the daemon configuration.
+func (e *Engine) resolveMounts(mounts []string) ([]api.Mount, error) {
+	var (
+		res   []api.Mount
+		roots = api.ResolveMountRoots(e.EnvConfig().Daemon.MountRoots)
+	)
+	for _, s := range mounts {
+		m, err := api.ParseMount(s)
+		if err != nil {
+			return nil, err
+		}
+		if m, err = m.Resolve(); err != nil {
+			return nil, err
+		}
+		if !m.Within(roots) {
+			return nil, fmt.Errorf("mount source %s is not within the allowed mount roots", m.Source)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
 func clean(name string) string {
 	forbiddenChar := "/"
 
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/testground/testground/pkg/logging"
+)
+
+// taskLogsPruneInterval is how often the engine enforces the retention policy
+// of task logs.
+const taskLogsPruneInterval = 10 * time.Minute
+
+// taskLogPath returns the path of the file holding the output of a task.
+func (e *Engine) taskLogPath(id string) string {
+	return filepath.Join(e.EnvConfig().Dirs().Daemon(), id+".out")
+}
+
+// removeTaskLog removes the log file of a task, if any.
+func (e *Engine) removeTaskLog(id string) error {
+	err := os.Remove(e.taskLogPath(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// pruneTaskLogsLoop periodically prunes task logs until the engine context is
+// done.
+func (e *Engine) pruneTaskLogsLoop() {
+	ticker := time.NewTicker(taskLogsPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		e.pruneTaskLogs()
+
+		select {
+		case <-ticker.C:
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+// pruneTaskLogs removes the logs of tasks that completed longer ago than the
+// configured maximum age, and then the oldest logs until the remaining ones
+// fit in the configured budget. Logs of tasks in progress are never removed.
+func (e *Engine) pruneTaskLogs() {
+	var (
+		cfg    = e.EnvConfig().Daemon
+		dir    = e.EnvConfig().Dirs().Daemon()
+		maxAge = time.Duration(cfg.TaskLogMaxAgeDays) * 24 * time.Hour
+		budget = int64(cfg.TaskLogBudgetMB) << 20
+	)
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		logging.S().Warnw("failed to list task logs", "err", err)
+		return
+	}
+
+	type entry struct {
+		id       string
+		modified time.Time
+		size     int64
+	}
+
+	var (
+		entries []entry
+		total   int64
+	)
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() || !strings.HasSuffix(fi.Name(), ".out") {
+			continue
+		}
+		entries = append(entries, entry{
+			id:       strings.TrimSuffix(fi.Name(), ".out"),
+			modified: fi.ModTime(),
+			size:     fi.Size(),
+		})
+		total += fi.Size()
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modified.Before(entries[j].modified) })
+
+	e.signalsLk.RLock()
+	defer e.signalsLk.RUnlock()
+
+	for _, ent := range entries {
+		expired := maxAge > 0 && time.Since(ent.modified) > maxAge
+		overBudget := budget > 0 && total > budget
+		if !expired && !overBudget {
+			break
+		}
+		if _, running := e.signals[ent.id]; running {
+			continue
+		}
+		if err := e.removeTaskLog(ent.id); err != nil {
+			logging.S().Warnw("failed to prune task log", "task_id", ent.id, "err", err)
+			continue
+		}
+		logging.S().Debugw("pruned task log", "task_id", ent.id, "expired", expired)
+		total -= ent.size
+	}
+}
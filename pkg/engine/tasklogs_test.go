@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/testutil"
+)
+
+func TestPruneTaskLogs(t *testing.T) {
+	testutil.Setenv(t, config.EnvTestgroundHomeDir, t.TempDir())
+
+	cfg := &config.EnvConfig{}
+	if err := cfg.EnsureMinimalConfig(); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Daemon.TaskLogMaxAgeDays = 1
+	cfg.Daemon.TaskLogBudgetMB = 1
+
+	e := &Engine{envcfg: cfg, signals: make(map[string]chan int)}
+
+	write := func(id string, size int, age time.Duration) {
+		t.Helper()
+		path := e.taskLogPath(id)
+		if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mod := time.Now().Add(-age)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const mb = 1 << 20
+	write("expired", 1, 48*time.Hour)
+	write("running", 1, 72*time.Hour)
+	write("oldest", mb/2, 3*time.Hour)
+	write("older", mb/3, 2*time.Hour)
+	write("newest", mb/3, time.Hour)
+	e.signals["running"] = make(chan int)
+
+	e.pruneTaskLogs()
+
+	for id, kept := range map[string]bool{
+		"expired": false,
+		"running": true,
+		"oldest":  false,
+		"older":   true,
+		"newest":  true,
+	} {
+		_, err := os.Stat(e.taskLogPath(id))
+		if exists := err == nil; exists != kept {
+			t.Errorf("log of task %s: kept=%t, expected %t", id, exists, kept)
+		}
+	}
+}
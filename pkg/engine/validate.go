@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+)
+
+// ValidateComponentConfigs checks the runner and builder configuration blocks
+// of the environment configuration against the configuration types of the
+// known runners and builders, and returns all the problems found.
+func ValidateComponentConfigs(cfg *config.EnvConfig) []error {
+	runners := make(map[string]reflect.Type, len(AllRunners))
+	for _, r := range AllRunners {
+		runners[r.ID()] = r.ConfigType()
+	}
+	builders := make(map[string]reflect.Type, len(AllBuilders))
+	for _, b := range AllBuilders {
+		builders[b.ID()] = b.ConfigType()
+	}
+
+	var errs []error
+	errs = append(errs, validateComponentConfigs(api.RunnerType, cfg.Runners, runners)...)
+	errs = append(errs, validateComponentConfigs(api.BuilderType, cfg.Builders, builders)...)
+	return errs
+}
+
+func validateComponentConfigs(ctype api.ComponentType, cfgs map[string]config.ConfigMap, types map[string]reflect.Type) []error {
+	ids := make([]string, 0, len(cfgs))
+	for id := range cfgs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var errs []error
+	for _, id := range ids {
+		typ, ok := types[id]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown %s: %s", ctype, id))
+			continue
+		}
+
+		if err := checkUnknownKeys(ctype, id, cfgs[id], typ); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// disabled runners are never configured, so their values don't matter.
+		if cfgs[id][config.RunnerDisabledFlag] == true {
+			continue
+		}
+
+		obj, err := config.CoalescedConfig{cfgs[id]}.CoalesceIntoType(typ)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s configuration: %w", id, err))
+			continue
+		}
+		if v, ok := obj.(api.ConfigValidator); ok {
+			if err := v.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s configuration: %w", id, err))
+			}
+		}
+	}
+	return errs
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/testground/testground/pkg/docker"
 	"github.com/testground/testground/pkg/rpc"
 
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -27,18 +28,21 @@ func CheckContainerStarted(ctx context.Context, ow *rpc.OutputWriter, cli *clien
 	}
 }
 
-// CheckNetwork returns a Checker that succeeds if the specified network exists,
-// and fails otherwise.
-func CheckNetwork(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, networkID string) Checker {
+// CheckNetwork returns a Checker that succeeds if the specified network exists
+// with the supplied IPAM configs, and fails otherwise.
+func CheckNetwork(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, networkID string, netcfg ...network.IPAMConfig) Checker {
 	return func() (bool, string, error) {
 		networks, err := docker.CheckBridgeNetwork(ctx, ow, cli, networkID)
 		if err != nil {
 			return false, "error when checking for network", err
 		}
-		if len(networks) > 0 {
-			return true, "network exists.", nil
+		if len(networks) == 0 {
+			return false, "network does not exist.", nil
 		}
-		return false, "network does not exist.", nil
+		if !docker.BridgeNetworkMatches(networks[0], netcfg...) {
+			return false, fmt.Sprintf("network exists with mismatching subnet: %v.", networks[0].IPAM.Config), nil
+		}
+		return true, "network exists.", nil
 	}
 }
 
@@ -45,10 +45,23 @@ func BuildImage(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, o
 }
 
 // CreateNetwork returns a Fixer that creates a Docker bridge network with
-// supplied ID and characteristics.
+// supplied ID and characteristics. If the network exists with a different
+// subnet, it is recreated.
 func CreateNetwork(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, networkID string, netcfg network.IPAMConfig) Fixer {
 	return func() (string, error) {
-		_, err := docker.EnsureBridgeNetwork(ctx, ow, cli, networkID, false, netcfg)
+		networks, err := docker.CheckBridgeNetwork(ctx, ow, cli, networkID)
+		if err != nil {
+			return "could not check network.", err
+		}
+		if len(networks) > 0 && !docker.BridgeNetworkMatches(networks[0], netcfg) {
+			_, err := docker.RecreateBridgeNetwork(ctx, ow, cli, networkID, false, netcfg)
+			if err != nil {
+				return "could not recreate network.", err
+			}
+			return "network recreated.", nil
+		}
+
+		_, err = docker.EnsureBridgeNetwork(ctx, ow, cli, networkID, false, netcfg)
 		if err != nil {
 			return "could not create network.", err
 		}
@@ -2,18 +2,28 @@ package logging
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-var ()
+// Log formats, selecting the encoder of the loggers.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
 
 var (
 	encConfig zapcore.EncoderConfig
 	encoder   zapcore.Encoder
 
+	// streamEncoder encodes the entries written to the extra WriteSyncers of
+	// loggers, e.g. the progress streamed to clients, which always get the
+	// console format, whatever the format of the local output.
+	streamEncoder zapcore.Encoder
+
 	stdout zapcore.WriteSyncer
 	stderr zapcore.WriteSyncer
 
@@ -25,15 +35,6 @@ var (
 )
 
 func init() {
-	encConfig = zap.NewDevelopmentEncoderConfig()
-	encConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-	encConfig.EncodeCaller = nil
-	encConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-		enc.AppendString(t.UTC().Format(time.StampMicro))
-	}
-
-	encoder = zapcore.NewConsoleEncoder(encConfig)
-
 	sout, closer, err := zap.Open("stdout")
 	if err != nil {
 		closer()
@@ -49,7 +50,48 @@ func init() {
 	stdout = sout
 	stderr = serr
 
+	_, streamEncoder = consoleEncoder()
+	if err := SetFormat(FormatConsole); err != nil {
+		panic(err)
+	}
+}
+
+// SetFormat selects the format of the loggers created from then on, and of
+// the global logger: FormatConsole or FormatJSON. An empty format selects
+// FormatConsole when stdout is a terminal, and FormatJSON otherwise, e.g.
+// for ingestion into a log pipeline.
+func SetFormat(format string) error {
+	if format == "" {
+		format = FormatJSON
+		if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			format = FormatConsole
+		}
+	}
+
+	switch format {
+	case FormatConsole:
+		encConfig, encoder = consoleEncoder()
+	case FormatJSON:
+		encConfig = zap.NewProductionEncoderConfig()
+		encConfig.EncodeCaller = nil
+		encConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoder = zapcore.NewJSONEncoder(encConfig)
+	default:
+		return fmt.Errorf("unknown log format: %s; values: %s, %s", format, FormatConsole, FormatJSON)
+	}
+
 	global = NewLogging(NewLogger())
+	return nil
+}
+
+func consoleEncoder() (zapcore.EncoderConfig, zapcore.Encoder) {
+	cfg := zap.NewDevelopmentEncoderConfig()
+	cfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	cfg.EncodeCaller = nil
+	cfg.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(t.UTC().Format(time.StampMicro))
+	}
+	return cfg, zapcore.NewConsoleEncoder(cfg)
 }
 
 // IsTerminal returns whether we're running in terminal mode.
@@ -63,12 +105,14 @@ func SetLevel(l zapcore.Level) {
 }
 
 // NewLogger returns a logger that outputs to stdout AND any extra WriteSyncers
-// that have been passed in.
+// that have been passed in. Only stdout follows the format set by SetFormat;
+// the extra WriteSyncers always get the console format.
 func NewLogger(extraWs ...zapcore.WriteSyncer) *zap.Logger {
-	wss := append([]zapcore.WriteSyncer{stdout}, extraWs...)
-	ws := zapcore.NewMultiWriteSyncer(wss...)
-
-	core := zapcore.NewCore(encoder, ws, level)
+	core := zapcore.NewCore(encoder, stdout, level)
+	if len(extraWs) > 0 {
+		ws := zapcore.NewMultiWriteSyncer(extraWs...)
+		core = zapcore.NewTee(core, zapcore.NewCore(streamEncoder, ws, level))
+	}
 	return zap.New(core, zap.ErrorOutput(stderr))
 }
 
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSetFormat(t *testing.T) {
+	defer SetFormat(FormatConsole) //nolint
+
+	if err := SetFormat(FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := Encoder().EncodeEntry(zapcore.Entry{Message: "hello"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.HasPrefix(out, "{") || !strings.Contains(out, `"msg":"hello"`) {
+		t.Errorf("expected a JSON log line, got %q", out)
+	}
+
+	if err := SetFormat(FormatConsole); err != nil {
+		t.Fatal(err)
+	}
+	buf, err = Encoder().EncodeEntry(zapcore.Entry{Message: "hello"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.HasPrefix(out, "{") {
+		t.Errorf("expected a console log line, got %q", out)
+	}
+
+	if err := SetFormat("xml"); err == nil {
+		t.Errorf("expected an unknown format to fail")
+	}
+}
+
+func TestNewLoggerStreamFormat(t *testing.T) {
+	defer SetFormat(FormatConsole) //nolint
+
+	if err := SetFormat(FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	logger := NewLogger(zapcore.AddSync(&buf))
+	logger.Info("hello")
+	_ = logger.Sync()
+
+	if out := buf.String(); strings.HasPrefix(out, "{") || !strings.Contains(out, "hello") {
+		t.Errorf("expected a console log line in the stream, got %q", out)
+	}
+}
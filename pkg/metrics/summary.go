@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// Files, within the outputs of each instance, that the SDK writes metrics
+// to, one JSON object per line: the results recorded by the plan through
+// runenv.R(), and the diagnostics recorded through runenv.D().
+const (
+	ResultsFile     = "results.out"
+	DiagnosticsFile = "diagnostics.out"
+)
+
+// diagnosticsPrefix prefixes the names of diagnostics in summaries, so that
+// they don't collide with results.
+const diagnosticsPrefix = "diagnostics."
+
+// Stats are the summary statistics of the values of a metric.
+type Stats struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// Summary aggregates the metrics of all instances of a run, by group and by
+// metric name.
+type Summary struct {
+	Groups map[string]map[string]*Stats `json:"groups"`
+	// Skipped counts the lines that could not be parsed, e.g. the last line
+	// of an instance that was killed while writing it.
+	Skipped int `json:"skipped_lines,omitempty"`
+}
+
+// metricLine is a line of a metrics file. Points carry a single "value"
+// measure; the SDK's aggregate metrics (counters, histograms, timers) carry
+// several measures, each of which is summarised as "<name>.<measure>".
+type metricLine struct {
+	Name     string                 `json:"name"`
+	Measures map[string]interface{} `json:"measures"`
+}
+
+// summarizer accumulates the values of each metric, per group.
+type summarizer struct {
+	values  map[string]map[string][]float64
+	skipped int
+}
+
+func newSummarizer() *summarizer {
+	return &summarizer{values: make(map[string]map[string][]float64)}
+}
+
+func (s *summarizer) add(group, name string, v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	g, ok := s.values[group]
+	if !ok {
+		g = make(map[string][]float64)
+		s.values[group] = g
+	}
+	g[name] = append(g[name], v)
+}
+
+// read parses the file at p, if it's a metrics file, given the
+// <group>/<instance>/<file> layout of run outputs.
+func (s *summarizer) read(p string, r io.Reader) error {
+	group := path.Base(path.Dir(path.Dir(p)))
+	switch path.Base(p) {
+	case ResultsFile:
+		return s.readMetrics(group, "", r)
+	case DiagnosticsFile:
+		return s.readMetrics(group, diagnosticsPrefix, r)
+	}
+	return nil
+}
+
+// readMetrics parses the metrics file of an instance of the supplied group,
+// prefixing the names of metrics.
+func (s *summarizer) readMetrics(group, prefix string, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var l metricLine
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil || l.Name == "" {
+			s.skipped++
+			continue
+		}
+		for k, m := range l.Measures {
+			v, ok := m.(float64)
+			if !ok {
+				continue
+			}
+			if k == "value" {
+				s.add(group, prefix+l.Name, v)
+			} else {
+				s.add(group, prefix+l.Name+"."+k, v)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *summarizer) summary() *Summary {
+	sum := &Summary{Groups: make(map[string]map[string]*Stats, len(s.values)), Skipped: s.skipped}
+	for group, metrics := range s.values {
+		g := make(map[string]*Stats, len(metrics))
+		for name, values := range metrics {
+			g[name] = computeStats(values)
+		}
+		sum.Groups[group] = g
+	}
+	return sum
+}
+
+// computeStats calculates the statistics of values, sorting it in place.
+// Percentiles use the nearest-rank method.
+func computeStats(values []float64) *Stats {
+	sort.Float64s(values)
+
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p/100*float64(len(values)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return values[i]
+	}
+
+	return &Stats{
+		Count: len(values),
+		Min:   values[0],
+		Max:   values[len(values)-1],
+		Mean:  total / float64(len(values)),
+		P50:   rank(50),
+		P95:   rank(95),
+		P99:   rank(99),
+	}
+}
+
+// SummarizeDir summarises the metrics files found under dir, which holds the
+// outputs of a run, such as an extracted `testground collect` archive.
+func SummarizeDir(dir string) (*Summary, error) {
+	s := newSummarizer()
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || (info.Name() != ResultsFile && info.Name() != DiagnosticsFile) {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return s.read(filepath.ToSlash(p), f)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.summary(), nil
+}
+
+// SummarizeArchive summarises the metrics files within a .tgz archive of the
+// outputs of a run, as produced by `testground collect`.
+func SummarizeArchive(r io.Reader) (*Summary, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	s := newSummarizer()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := s.read(hdr.Name, tr); err != nil {
+			return nil, err
+		}
+	}
+	return s.summary(), nil
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testMetrics = map[string]string{
+	"run/dialer/0/results.out": `{"ts":1,"type":"point","name":"transfer_time","measures":{"value":10}}
+{"ts":2,"type":"point","name":"transfer_time","measures":{"value":30}}
+{"ts":3,"type":"counter","name":"conns","measures":{"count":2}}
+{"ts":4,"type":"point","name":"transfer_ti`,
+	"run/dialer/0/diagnostics.out": `{"ts":1,"type":"gauge","name":"goroutines","measures":{"value":12}}`,
+	"run/dialer/1/results.out":     `{"ts":1,"type":"point","name":"transfer_time","measures":{"value":20}}`,
+	"run/listener/0/results.out":   `{"ts":1,"type":"point","name":"transfer_time","measures":{"value":5}}`,
+	"run/listener/0/run.out":       `{"ts":1,"type":"point","name":"ignored","measures":{"value":1}}`,
+	"run/listener/0/metrics.jsonl": `{"ts":1,"type":"point","name":"ignored","measures":{"value":1}}`,
+}
+
+func checkSummary(t *testing.T, s *Summary) {
+	t.Helper()
+
+	if len(s.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(s.Groups))
+	}
+	tt := s.Groups["dialer"]["transfer_time"]
+	if tt == nil {
+		t.Fatal("missing transfer_time for dialer")
+	}
+	if tt.Count != 3 || tt.Min != 10 || tt.Max != 30 || tt.Mean != 20 || tt.P50 != 20 || tt.P99 != 30 {
+		t.Errorf("unexpected stats: %+v", *tt)
+	}
+	if c := s.Groups["dialer"]["conns.count"]; c == nil || c.Count != 1 || c.Max != 2 {
+		t.Errorf("unexpected counter stats: %+v", c)
+	}
+	if d := s.Groups["dialer"]["diagnostics.goroutines"]; d == nil || d.Max != 12 {
+		t.Errorf("unexpected diagnostics stats: %+v", d)
+	}
+	if l := s.Groups["listener"]["transfer_time"]; l == nil || l.Count != 1 || l.P95 != 5 {
+		t.Errorf("unexpected listener stats: %+v", l)
+	}
+	if _, ok := s.Groups["listener"]["ignored"]; ok {
+		t.Error("only results and diagnostics files should be summarised")
+	}
+	if s.Skipped != 1 {
+		t.Errorf("expected 1 skipped line, got %d", s.Skipped)
+	}
+}
+
+func TestSummarizeDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range testMetrics {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := SummarizeDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSummary(t, s)
+}
+
+func TestSummarizeArchive(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range testMetrics {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := SummarizeArchive(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSummary(t, s)
+}
@@ -8,17 +8,58 @@ const (
 	ChunkTypeBinary   ChunkType = 'b'
 	ChunkTypeResult   ChunkType = 'r'
 	ChunkTypeError    ChunkType = 'e'
+	ChunkTypeStage    ChunkType = 's'
+)
+
+// Stage is a coarse-grained stage in the lifecycle of a task. Stages are sent
+// to clients in `stage` chunks, whose payload is the stage name.
+type Stage string
+
+const (
+	StageQueued        Stage = "QUEUED"
+	StageBuilding      Stage = "BUILDING"
+	StageScheduling    Stage = "SCHEDULING"
+	StageNetworksReady Stage = "NETWORKS_READY"
+	StageRunning       Stage = "RUNNING"
+	StageCollecting    Stage = "COLLECTING"
+	StageDone          Stage = "DONE"
 )
 
 // Chunk is a response chunk sent from the Testground daemon to the Testground
 // client. For a given request, clients should expect between 0 to `n`
-// `progress` chunks, and exactly 1 `result` or `error` chunk before EOF.
+// `progress` and `stage` chunks, and exactly 1 `result` or `error` chunk
+// before EOF.
 type Chunk struct {
-	Type    ChunkType   `json:"t"` // progress or result or error
+	Type    ChunkType   `json:"t"` // progress or stage or result or error
 	Payload interface{} `json:"p,omitempty"`
 	Error   *Error      `json:"e,omitempty"`
 }
 
+// ErrorCode is a stable, machine-readable category of an Error.
+type ErrorCode string
+
+const (
+	// ErrorCodeGeneric is the code of errors that haven't been categorised.
+	ErrorCodeGeneric ErrorCode = "generic"
+	// ErrorCodeBadRequest signals that the request was malformed or invalid.
+	ErrorCodeBadRequest ErrorCode = "bad_request"
+	// ErrorCodeNotFound signals that the requested entity does not exist.
+	ErrorCodeNotFound ErrorCode = "not_found"
+	// ErrorCodeInfrastructure signals a failure of the daemon or of the
+	// infrastructure it relies on.
+	ErrorCodeInfrastructure ErrorCode = "infrastructure"
+	// ErrorCodeTestFailure signals that the test plan itself failed.
+	ErrorCodeTestFailure ErrorCode = "test_failure"
+)
+
 type Error struct {
-	Msg string `json:"m"`
+	Msg     string                 `json:"m"`
+	Code    ErrorCode              `json:"c,omitempty"`
+	Details map[string]interface{} `json:"d,omitempty"`
+}
+
+var _ error = (*Error)(nil)
+
+func (e *Error) Error() string {
+	return e.Msg
 }
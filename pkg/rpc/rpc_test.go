@@ -2,6 +2,7 @@ package rpc_test
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"reflect"
 	"strings"
@@ -124,3 +125,48 @@ func TestWriters(t *testing.T) {
 		testBody(t, &test, res.Body)
 	}
 }
+
+func TestWriteStage(t *testing.T) {
+	rec, ow := rpctest.NewRecordedOutputWriter(t.Name())
+	ow.WriteStage(rpc.StageRunning)
+	ow.Flush()
+
+	var ch rpc.Chunk
+	if err := json.NewDecoder(rec.Result().Body).Decode(&ch); err != nil {
+		t.Fatal(err)
+	}
+	if ch.Type != rpc.ChunkTypeStage {
+		t.Fatalf("expected stage chunk, got %q", string(ch.Type))
+	}
+	if ch.Payload != string(rpc.StageRunning) {
+		t.Fatalf("expected stage %s, got %v", rpc.StageRunning, ch.Payload)
+	}
+}
+
+func TestWriteErrorCode(t *testing.T) {
+	rec, ow := rpctest.NewRecordedOutputWriter(t.Name())
+	ow.WriteErrorCode(rpc.ErrorCodeBadRequest, "invalid request", "err", errors.New("boom"))
+	ow.Flush()
+
+	dec := json.NewDecoder(rec.Result().Body)
+	for dec.More() {
+		var ch rpc.Chunk
+		if err := dec.Decode(&ch); err != nil {
+			t.Fatal(err)
+		}
+		if ch.Type != rpc.ChunkTypeError {
+			continue
+		}
+		if ch.Error.Code != rpc.ErrorCodeBadRequest {
+			t.Errorf("expected code %s, got %s", rpc.ErrorCodeBadRequest, ch.Error.Code)
+		}
+		if ch.Error.Msg != "invalid request; err: boom" {
+			t.Errorf("unexpected message: %s", ch.Error.Msg)
+		}
+		if ch.Error.Details["err"] != "boom" {
+			t.Errorf("unexpected details: %v", ch.Error.Details)
+		}
+		return
+	}
+	t.Fatal("no error chunk received")
+}
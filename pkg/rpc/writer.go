@@ -229,6 +229,27 @@ func (ow *OutputWriter) WriteBinary(b []byte) (n int, err error) {
 	return n, err
 }
 
+// WriteStage notifies the client that the task has advanced to the supplied
+// stage.
+func (ow *OutputWriter) WriteStage(stage Stage) {
+	ow.Debugw("task advanced to stage", "stage", stage)
+
+	msg := Chunk{Type: ChunkTypeStage, Payload: stage}
+	json, err := json.Marshal(msg)
+	if err != nil {
+		logging.S().Errorw("could not write stage", "err", err)
+		return
+	}
+
+	ow.Lock()
+	defer ow.Unlock()
+
+	_, err = ow.out.Write(json)
+	if err != nil {
+		logging.S().Errorw("could not write stage", "err", err)
+	}
+}
+
 func (ow *OutputWriter) WriteResult(res interface{}) {
 	msg := Chunk{Type: ChunkTypeResult, Payload: res}
 	json, err := json.Marshal(msg)
@@ -246,19 +267,29 @@ func (ow *OutputWriter) WriteResult(res interface{}) {
 	}
 }
 
+// WriteError sends an error to the client, with the generic error code.
 func (ow *OutputWriter) WriteError(message string, keysAndValues ...interface{}) {
+	ow.WriteErrorCode(ErrorCodeGeneric, message, keysAndValues...)
+}
+
+// WriteErrorCode sends an error with the supplied code to the client. The
+// key-value pairs are appended to the message, and sent as the error details.
+func (ow *OutputWriter) WriteErrorCode(code ErrorCode, message string, keysAndValues ...interface{}) {
 	ow.Warnw(message, keysAndValues...)
 
+	var details map[string]interface{}
 	if len(keysAndValues) > 0 {
+		details = make(map[string]interface{}, len(keysAndValues)/2)
 		b := &strings.Builder{}
 		for i := 0; i < len(keysAndValues); i = i + 2 {
 			fmt.Fprintf(b, "%s: %s;", keysAndValues[i], keysAndValues[i+1])
+			details[fmt.Sprint(keysAndValues[i])] = detailValue(keysAndValues[i+1])
 		}
 		kvs := b.String()
 		message = message + "; " + kvs[:len(kvs)-1]
 	}
 
-	pld := Chunk{Type: ChunkTypeError, Error: &Error{message}}
+	pld := Chunk{Type: ChunkTypeError, Error: &Error{Msg: message, Code: code, Details: details}}
 	json, err := json.Marshal(pld)
 	if err != nil {
 		logging.S().Errorw("could not write error response", "err", err)
@@ -274,6 +305,19 @@ func (ow *OutputWriter) WriteError(message string, keysAndValues ...interface{})
 	}
 }
 
+// detailValue makes v serializable as an error detail; errors would otherwise
+// serialize as empty objects.
+func detailValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
+
 func (ow *OutputWriter) Flush() {
 	if f, ok := ow.out.(http.Flusher); ok {
 		f.Flush()
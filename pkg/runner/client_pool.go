@@ -1,26 +1,55 @@
 package runner
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
+
+	"github.com/testground/testground/pkg/logging"
 
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+const (
+	// poolAcquireTimeout bounds the wait for a client when all are in use,
+	// regardless of the context of the caller.
+	poolAcquireTimeout = 2 * time.Minute
+	// poolHealthTimeout bounds the health check of a client.
+	poolHealthTimeout = 5 * time.Second
+	// poolHealthInterval is how long a client is trusted after passing a
+	// health check, sparing busy callers a round-trip on every acquire.
+	poolHealthInterval = 30 * time.Second
+)
+
 type pool struct {
 	availableC chan *kubernetes.Clientset
+	config     KubernetesConfig
+
+	// restCfg is the REST config the latest clients were created with.
+	restCfg   *rest.Config
+	restCfgLk sync.RWMutex
+
+	// checked holds the time each client last passed a health check.
+	checked   map[*kubernetes.Clientset]time.Time
+	checkedLk sync.Mutex
 }
 
 // newPool returns a pool of Kubernetes clientset connections
 func newPool(workers int, config KubernetesConfig) (*pool, error) {
-	k8scfg, err := clientcmd.BuildConfigFromFlags("", config.KubeConfigPath)
+	k8scfg, err := buildRESTConfig(config)
 	if err != nil {
-		return nil, fmt.Errorf("could not start k8s client from config: %v", err)
+		return nil, err
 	}
 
 	pool := &pool{
 		availableC: make(chan *kubernetes.Clientset, workers),
+		config:     config,
+		restCfg:    k8scfg,
+		checked:    make(map[*kubernetes.Clientset]time.Time, workers),
 	}
 
 	for i := 0; i < workers; i++ {
@@ -35,10 +64,119 @@ func newPool(workers int, config KubernetesConfig) (*pool, error) {
 	return pool, nil
 }
 
-func (p *pool) Acquire() *kubernetes.Clientset {
-	return <-p.availableC
+// buildRESTConfig builds the REST config of the Kubernetes API clients, from
+// the service account of the pod when running in the cluster, or from the
+// kubeconfig otherwise.
+func buildRESTConfig(config KubernetesConfig) (*rest.Config, error) {
+	if config.InCluster {
+		k8scfg, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("could not start in-cluster k8s client: %v", err)
+		}
+		return k8scfg, nil
+	}
+
+	k8scfg, err := clientcmd.BuildConfigFromFlags("", config.KubeConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not start k8s client from config: %v", err)
+	}
+	return k8scfg, nil
+}
+
+// RESTConfig returns the REST config the clients of the pool are created
+// with, for the API calls that need it rather than a clientset, e.g. to exec
+// into pods.
+func (p *pool) RESTConfig() *rest.Config {
+	p.restCfgLk.RLock()
+	defer p.restCfgLk.RUnlock()
+	return p.restCfg
+}
+
+// newClient creates a clientset, building the REST config afresh so that
+// rotated credentials are picked up; the pool uses the new REST config from
+// then on.
+func (p *pool) newClient() (*kubernetes.Clientset, error) {
+	k8scfg, err := buildRESTConfig(p.config)
+	if err != nil {
+		return nil, err
+	}
+
+	k8sClientset, err := kubernetes.NewForConfig(k8scfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not create k8s clientset: %v", err)
+	}
+
+	p.restCfgLk.Lock()
+	p.restCfg = k8scfg
+	p.restCfgLk.Unlock()
+	return k8sClientset, nil
+}
+
+// Acquire returns a healthy client from the pool, which must be released
+// once done with. It waits for a client to be released if all are in use,
+// until ctx is done or poolAcquireTimeout elapses. A client failing its
+// health check, e.g. because its credentials expired, is replaced with a new
+// one.
+func (p *pool) Acquire(ctx context.Context) (*kubernetes.Clientset, error) {
+	actx, cancel := context.WithTimeout(ctx, poolAcquireTimeout)
+	defer cancel()
+
+	var cs *kubernetes.Clientset
+	select {
+	case cs = <-p.availableC:
+	case <-actx.Done():
+		return nil, fmt.Errorf("no k8s client available: %w", actx.Err())
+	}
+
+	err := p.check(ctx, cs)
+	if err == nil {
+		return cs, nil
+	}
+
+	logging.S().Warnw("k8s client failed its health check; recreating it", "err", err)
+
+	p.forget(cs)
+	fresh, err := p.newClient()
+	if err != nil {
+		// keep the pool at capacity; the next acquire tries again.
+		p.availableC <- cs
+		return nil, err
+	}
+	if err := p.check(ctx, fresh); err != nil {
+		p.availableC <- fresh
+		return nil, fmt.Errorf("k8s API server unhealthy: %w", err)
+	}
+	return fresh, nil
 }
 
 func (p *pool) Release(cs *kubernetes.Clientset) {
 	p.availableC <- cs
 }
+
+// check verifies that the client can reach the API server, unless it did so
+// recently.
+func (p *pool) check(ctx context.Context, cs *kubernetes.Clientset) error {
+	p.checkedLk.Lock()
+	last, ok := p.checked[cs]
+	p.checkedLk.Unlock()
+	if ok && time.Since(last) < poolHealthInterval {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, poolHealthTimeout)
+	defer cancel()
+	if err := cs.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+		return err
+	}
+
+	p.checkedLk.Lock()
+	p.checked[cs] = time.Now()
+	p.checkedLk.Unlock()
+	return nil
+}
+
+func (p *pool) forget(cs *kubernetes.Clientset) {
+	p.checkedLk.Lock()
+	delete(p.checked, cs)
+	p.checkedLk.Unlock()
+}
@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/testground/testground/pkg/testutil"
+)
+
+// testKubeConfig writes a kubeconfig pointing at the given API server.
+func testKubeConfig(t *testing.T, server string) KubernetesConfig {
+	path := filepath.Join(t.TempDir(), "config")
+	cfg := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+current-context: test
+`, server)
+	if err := os.WriteFile(path, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return KubernetesConfig{KubeConfigPath: path, Namespace: "default"}
+}
+
+func TestPoolAcquire(t *testing.T) {
+	var healthy int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"major":"1","minor":"22"}`)
+	}))
+	defer srv.Close()
+
+	p, err := newPool(1, testKubeConfig(t, srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// all clients are checked out: acquiring is bounded by the context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.Acquire(ctx); err == nil {
+		t.Fatal("expected acquiring from an exhausted pool to time out")
+	}
+	p.Release(cs)
+
+	// a client that fails its health check is replaced.
+	atomic.StoreInt32(&healthy, 0)
+	p.forget(cs)
+	if _, err := p.Acquire(context.Background()); err == nil {
+		t.Fatal("expected acquiring to fail while the API server is unhealthy")
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	fresh, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh == cs {
+		t.Error("expected the unhealthy client to have been replaced")
+	}
+	p.Release(fresh)
+}
+
+func TestDefaultKubernetesConfigInCluster(t *testing.T) {
+	token := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(token, []byte("token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(path string) { k8sServiceAccountTokenPath = path }(k8sServiceAccountTokenPath)
+	k8sServiceAccountTokenPath = token
+
+	testutil.Setenv(t, "KUBERNETES_SERVICE_HOST", "")
+	if cfg := defaultKubernetesConfig(); cfg.InCluster {
+		t.Error("expected kubeconfig outside of the cluster")
+	}
+
+	testutil.Setenv(t, "KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	if cfg := defaultKubernetesConfig(); !cfg.InCluster || cfg.KubeConfigPath != "" {
+		t.Errorf("expected in-cluster config; got %+v", cfg)
+	}
+
+	k8sServiceAccountTokenPath = filepath.Join(t.TempDir(), "missing")
+	if cfg := defaultKubernetesConfig(); cfg.InCluster {
+		t.Error("expected kubeconfig without a service account token")
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,12 +14,14 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/testground/sdk-go/ptypes"
@@ -28,10 +32,12 @@ import (
 	ss "github.com/testground/sdk-go/sync"
 	"github.com/testground/testground/pkg/api"
 	"github.com/testground/testground/pkg/aws"
+	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/conv"
 	"github.com/testground/testground/pkg/healthcheck"
 	"github.com/testground/testground/pkg/logging"
 	"github.com/testground/testground/pkg/rpc"
+	"github.com/testground/testground/pkg/sidecar"
 	"github.com/testground/testground/pkg/task"
 	"golang.org/x/sync/errgroup"
 
@@ -40,10 +46,10 @@ import (
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/msoap/byline"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/remotecommand"
 )
 
@@ -51,15 +57,27 @@ var (
 	_             api.Runner        = (*ClusterK8sRunner)(nil)
 	_             api.Terminatable  = (*ClusterK8sRunner)(nil)
 	_             api.Healthchecker = (*ClusterK8sRunner)(nil)
+	_             api.Prunable      = (*ClusterK8sRunner)(nil)
+	_             api.Pinger        = (*ClusterK8sRunner)(nil)
 	mu                              = sync.Mutex{}
 	errSyncClient                   = errors.New("failed to start sync client")
 )
 
 const (
 	defaultK8sNetworkAnnotation = "aws-cni"
+	defaultK8sDataNetwork       = "weave"
+	defaultK8sGPUResource       = "nvidia.com/gpu"
+	// defaults of the shared volume the outputs of runs are written to.
+	defaultOutputsClaimName  = "efs"
+	defaultOutputsVolumeName = "efs-shared"
+	defaultOutputsMountPath  = "/outputs"
 	// collect-outputs pod is used to compress outputs at the end of a testplan run
 	// as well as to copy archives from it, since it has EFS attached to it
 	collectOutputsPodName = "collect-outputs"
+	// defaults of the collect-outputs pod, unless set in the runner config.
+	defaultCollectOutputsPodImage  = "busybox"
+	defaultCollectOutputsPodCPU    = "2000m"
+	defaultCollectOutputsPodMemory = "1024Mi"
 
 	// number of CPUs allocated to each Sidecar. should be same as what is set in sidecar.yaml
 	sidecarCPUs = 0.2
@@ -69,27 +87,24 @@ const (
 	// api proxy, node_exporter, dummy, etc.
 	utilisation = 0.85
 
-	// magic values that we monitor on the Testground runner side to detect when Testground
-	// testplan instances are initialised and at the stage of actually running a test
-	// check sdk/sync for more information
-	NetworkInitialisationSuccessful = "network initialisation successful"
-	NetworkInitialisationFailed     = "network initialisation failed"
+	// k8sRetryAttempts and k8sRetrySleep bound the retries of Kubernetes API
+	// calls failing with transient errors: 500ms, 1s, 2s, 4s between them.
+	k8sRetryAttempts = 5
+	k8sRetrySleep    = 500 * time.Millisecond
+
+	// defaultPodCreationConcurrency is the initial number of testplan pods
+	// created concurrently, unless configured.
+	defaultPodCreationConcurrency = 30
 )
 
-var k8sSubnetIdx uint64 = 0
+// defaultK8sSubnets is the allocator used by cluster:k8s runners that haven't
+// been injected one.
+var defaultK8sSubnets *MemorySubnetAllocator
 
 func init() {
 	// Avoid collisions in picking up subnets
 	rand.Seed(time.Now().UnixNano())
-	k8sSubnetIdx = rand.Uint64() % 4096
-}
-
-func nextK8sSubnet() (*net.IPNet, error) {
-	subnet, _, err := nextDataNetwork(int(atomic.AddUint64(&k8sSubnetIdx, 1) % 4096))
-	if err != nil {
-		return nil, err
-	}
-	return subnet, err
+	defaultK8sSubnets = NewMemorySubnetAllocator(rand.Intn(maxDataNetworks))
 }
 
 func homeDir() string {
@@ -116,14 +131,261 @@ type ClusterK8sRunnerConfig struct {
 	TestplanPodCPU    string `toml:"testplan_pod_cpu"`
 
 	// Resources requested for the `collect-outputs` pod from the Kubernetes cluster
-	CollectOutputsPodMemory string `toml:"collect_outputs_pod_memory"`
-	CollectOutputsPodCPU    string `toml:"collect_outputs_pod_cpu"`
+	// (default: 2000m CPU, 1024Mi memory).
+	CollectOutputsPodMemory string `toml:"collect_outputs_pod_memory" default:"1024Mi"`
+	CollectOutputsPodCPU    string `toml:"collect_outputs_pod_cpu" default:"2000m"`
+	// CollectOutputsPodImage is the image of the `collect-outputs` pod. It must
+	// provide sh and tar (default: "busybox"). The pod is long-lived, so it has
+	// to be deleted for a change to take effect.
+	CollectOutputsPodImage string `toml:"collect_outputs_pod_image" default:"busybox"`
+	// CollectOutputsPodIdleTimeoutSec deletes the `collect-outputs` pod once
+	// it has been idle for that long, releasing its resources; it's created
+	// again by the next collection (default: 0, keep it running).
+	CollectOutputsPodIdleTimeoutSec int `toml:"collect_outputs_pod_idle_timeout_sec"`
+	// CollectCompression is the compressor of the outputs archive: "gzip", or
+	// "pigz" to compress with as many threads as the CPUs requested for the
+	// `collect-outputs` pod, whose image must then provide pigz. Both produce
+	// gzip archives (default: "gzip").
+	CollectCompression string `toml:"collect_compression" default:"gzip"`
 
 	ExposedPorts ExposedPorts `toml:"exposed_ports"`
 
 	RunTimeoutMin int `toml:"run_timeout_min"`
 
+	// FailFastThreshold aborts the run once that many instances have failed.
+	// Values below 1 are a fraction of the total instances, values of 1 and
+	// above are an absolute count (default: 0, wait for all instances).
+	FailFastThreshold float64 `toml:"fail_fast_threshold"`
+
+	// NetworkInitTimeoutSec is how long the instances may take to initialise
+	// their networks, once all pods have been scheduled for creation. When
+	// set, an instance failing to initialise its network aborts the run
+	// immediately (default: 0, not monitored).
+	NetworkInitTimeoutSec int `toml:"network_init_timeout_sec"`
+
 	Sysctls []string `toml:"sysctls"`
+
+	// RestartPolicy is the restart policy of testplan pods: "Never" or
+	// "OnFailure" (default: "Never").
+	RestartPolicy string `toml:"restart_policy"`
+
+	// ExtraHosts are additional /etc/hosts entries for testplan pods, in the
+	// "host:ip" format.
+	ExtraHosts []string `toml:"extra_hosts"`
+	// DNS are nameservers for testplan pods, in addition to the cluster DNS.
+	DNS []string `toml:"dns"`
+
+	// ControlNetworkCNI is the `cni` annotation of pods, selecting the CNI
+	// plugin of the control network (default: "aws-cni").
+	ControlNetworkCNI string `toml:"control_network_cni"`
+	// DataNetwork is the multus network attachment of the data network, set
+	// as the `k8s.v1.cni.cncf.io/networks` annotation of pods. It must match
+	// the CNI plugin configured in the sidecar (default: "weave").
+	DataNetwork string `toml:"data_network"`
+
+	// GPUResource is the extended resource that the GPUs requested by groups
+	// are mapped to (default: "nvidia.com/gpu").
+	GPUResource string `toml:"gpu_resource"`
+	// GPUNodeSelector are node labels that pods requesting GPUs are
+	// additionally scheduled by, to target GPU nodes.
+	GPUNodeSelector map[string]string `toml:"gpu_node_selector"`
+
+	// PodCreationConcurrency is the number of testplan pods created
+	// concurrently at the start of a run (default: 30). It's halved whenever
+	// the API server throttles the creations, which are retried.
+	PodCreationConcurrency int `toml:"pod_creation_concurrency"`
+	// PodCreationMaxConcurrency is how far the concurrency of pod creations
+	// may grow while the API server keeps up (default: 0, the initial
+	// concurrency). Creations also wait for a client of the pool of the
+	// runner, which holds 20.
+	PodCreationMaxConcurrency int `toml:"pod_creation_max_concurrency"`
+
+	// OutputsClaimName is the PersistentVolumeClaim of the shared volume the
+	// outputs of runs are written to, which must support ReadWriteMany
+	// (default: "efs"). Like the other outputs volume settings, it applies to
+	// the whole cluster, so it's only taken from .env.toml.
+	OutputsClaimName string `toml:"outputs_claim_name" overridable:"no"`
+	// OutputsVolumeName is the name of the outputs volume in pods (default:
+	// "efs-shared").
+	OutputsVolumeName string `toml:"outputs_volume_name" overridable:"no"`
+	// OutputsMountPath is where pods mount the outputs volume (default:
+	// "/outputs").
+	OutputsMountPath string `toml:"outputs_mount_path" overridable:"no"`
+
+	// ImagePullPolicy is when nodes pull the images of testplan pods:
+	// "Always", "IfNotPresent" or "Never" (default: "Always" when images are
+	// pushed to a registry, so that an image pushed under the same tag is
+	// never stale; "Never" when they're loaded onto the nodes;
+	// "IfNotPresent" otherwise).
+	ImagePullPolicy string `toml:"image_pull_policy"`
+
+	// LoadImages loads the images of runs from the local docker daemon onto
+	// the nodes of a local cluster, so that no registry is needed: "kind",
+	// "minikube", or "auto" to detect either from the current context of the
+	// kubeconfig (default: not set). It excludes provider.
+	LoadImages string `toml:"load_images"`
+	// LoadImagesCluster is the name of the kind cluster, or the minikube
+	// profile, to load images onto (default: the cluster of the current
+	// kubeconfig context, or else the default cluster of the tool).
+	LoadImagesCluster string `toml:"load_images_cluster"`
+
+	// ImagePullSecrets are the names of the secrets that testplan pods pull
+	// their images with, e.g. from the private registries that images are
+	// pushed to. They're docker-registry secrets, which must exist in the
+	// namespace of the runner:
+	//
+	//   kubectl create secret docker-registry <name> --docker-server=... \
+	//     --docker-username=... --docker-password=...
+	ImagePullSecrets []string `toml:"image_pull_secrets"`
+
+	// DisableSidecar runs the instances without the sidecar, for plans that
+	// don't shape traffic: pods only get the control network, instances are
+	// told that no sidecar is available, and the run doesn't wait for their
+	// networks to be initialised. Plans can set it in the runner defaults of
+	// their manifest (default: false).
+	DisableSidecar bool `toml:"disable_sidecar"`
+
+	// Namespace is the Kubernetes namespace of the testground infrastructure,
+	// where the pods of all runs are created (default: "default"). It applies
+	// to the whole cluster, so it's only taken from .env.toml.
+	Namespace string `toml:"namespace" overridable:"no"`
+}
+
+var _ api.ConfigValidator = (*ClusterK8sRunnerConfig)(nil)
+
+func (c *ClusterK8sRunnerConfig) Validate() error {
+	switch v1.RestartPolicy(c.RestartPolicy) {
+	case "", v1.RestartPolicyNever, v1.RestartPolicyOnFailure:
+	default:
+		return fmt.Errorf("unsupported restart policy for testplan pods: %q", c.RestartPolicy)
+	}
+	switch c.CollectCompression {
+	case "", "gzip", "pigz":
+	default:
+		return fmt.Errorf("unsupported collect compression: %q; values: gzip, pigz", c.CollectCompression)
+	}
+	if _, _, mountPath := c.outputsVolume(); !path.IsAbs(mountPath) || !outputsMountPathPattern.MatchString(mountPath) {
+		return fmt.Errorf("invalid outputs mount path: %q; it must be an absolute path of letters, digits, '.', '_' and '-'", mountPath)
+	}
+	if err := validatePullPolicy(c.ImagePullPolicy); err != nil {
+		return err
+	}
+	switch c.LoadImages {
+	case "", LoadImagesAuto, LoadImagesKind, LoadImagesMinikube:
+	default:
+		return fmt.Errorf("unsupported load_images mode: %q; values: %s, %s, %s", c.LoadImages, LoadImagesAuto, LoadImagesKind, LoadImagesMinikube)
+	}
+	if c.LoadImages != "" && c.Provider != "" {
+		return fmt.Errorf("load_images and provider are mutually exclusive: images are either loaded onto the nodes or pushed to a registry")
+	}
+	for _, name := range c.ImagePullSecrets {
+		if name == "" {
+			return fmt.Errorf("image pull secret names must not be empty")
+		}
+	}
+	if c.PodCreationConcurrency < 0 || c.PodCreationMaxConcurrency < 0 {
+		return fmt.Errorf("pod creation concurrency must not be negative")
+	}
+	if initial, max := c.podCreationConcurrency(); c.PodCreationMaxConcurrency > 0 && max < initial {
+		return fmt.Errorf("pod_creation_max_concurrency (%d) is lower than pod_creation_concurrency (%d)", max, initial)
+	}
+	return validateHostsAndDNS(c.ExtraHosts, c.DNS)
+}
+
+// outputsMountPathPattern matches the mount paths of the outputs volume, which
+// are interpolated into the shell commands run in the collect-outputs pod.
+var outputsMountPathPattern = regexp.MustCompile(`^[A-Za-z0-9/._-]+$`)
+
+// outputsVolume returns the claim, the name and the mount path of the shared
+// volume the outputs of runs are written to.
+func (c *ClusterK8sRunnerConfig) outputsVolume() (claim, volume, mountPath string) {
+	claim, volume, mountPath = c.OutputsClaimName, c.OutputsVolumeName, c.OutputsMountPath
+	if claim == "" {
+		claim = defaultOutputsClaimName
+	}
+	if volume == "" {
+		volume = defaultOutputsVolumeName
+	}
+	if mountPath == "" {
+		mountPath = defaultOutputsMountPath
+	}
+	return claim, volume, mountPath
+}
+
+// imagePullPolicy returns the pull policy of the images of testplan pods.
+func (c *ClusterK8sRunnerConfig) imagePullPolicy() v1.PullPolicy {
+	switch {
+	case c.ImagePullPolicy != "":
+		return v1.PullPolicy(c.ImagePullPolicy)
+	case c.Provider != "":
+		return v1.PullAlways
+	case c.LoadImages != "":
+		return v1.PullNever
+	default:
+		return v1.PullIfNotPresent
+	}
+}
+
+// gpuResource returns the extended resource GPUs are requested as.
+func (c *ClusterK8sRunnerConfig) gpuResource() v1.ResourceName {
+	if c.GPUResource == "" {
+		return defaultK8sGPUResource
+	}
+	return v1.ResourceName(c.GPUResource)
+}
+
+// podCreationConcurrency returns the initial and maximum concurrency of pod
+// creations.
+func (c *ClusterK8sRunnerConfig) podCreationConcurrency() (initial, max int) {
+	initial = c.PodCreationConcurrency
+	if initial == 0 {
+		initial = defaultPodCreationConcurrency
+	}
+	max = c.PodCreationMaxConcurrency
+	if max == 0 {
+		max = initial
+	}
+	return initial, max
+}
+
+// podNetworkAnnotations returns the annotations attaching pods to the control
+// and data networks.
+func podNetworkAnnotations(cfg ClusterK8sRunnerConfig) map[string]string {
+	cni, data := cfg.ControlNetworkCNI, cfg.DataNetwork
+	if cni == "" {
+		cni = defaultK8sNetworkAnnotation
+	}
+	if data == "" {
+		data = defaultK8sDataNetwork
+	}
+	if cfg.DisableSidecar {
+		// without a sidecar to configure it, the data network is not
+		// attached.
+		return map[string]string{"cni": cni}
+	}
+	return map[string]string{"cni": cni, "k8s.v1.cni.cncf.io/networks": data}
+}
+
+// hostAliases converts extra hosts in the "host:ip" format to the host
+// aliases of a pod.
+func hostAliases(extraHosts []string) ([]v1.HostAlias, error) {
+	var (
+		aliases []v1.HostAlias
+		byIP    = make(map[string]int)
+	)
+	for _, h := range extraHosts {
+		host, ip, err := splitExtraHost(h)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := byIP[ip]; ok {
+			aliases[i].Hostnames = append(aliases[i].Hostnames, host)
+			continue
+		}
+		byIP[ip] = len(aliases)
+		aliases = append(aliases, v1.HostAlias{IP: ip, Hostnames: []string{host}})
+	}
+	return aliases, nil
 }
 
 // ClusterK8sRunner is a runner that creates a Docker service to launch as
@@ -134,6 +396,47 @@ type ClusterK8sRunner struct {
 	pool        *pool
 	imagesLRU   *lru.Cache
 	syncClient  *ss.DefaultClient
+	subnets     SubnetAllocator
+	// namespace is the namespace set in the env configuration, if any.
+	namespace string
+
+	// collectors counts the collections and metadata writes using the
+	// collect-outputs pod, which is only reaped when it's idle. collectGen
+	// invalidates the reaping scheduled before the pod was last used.
+	collectors   int
+	collectGen   int
+	collectTimer *time.Timer
+	collectLk    sync.Mutex
+}
+
+var _ EnvConfigSetter = (*ClusterK8sRunner)(nil)
+
+// SetEnvConfig picks up the namespace from the env configuration. Changing
+// it once connected to the cluster requires a restart.
+func (c *ClusterK8sRunner) SetEnvConfig(cfg config.EnvConfig) {
+	ns, _ := cfg.Runners[c.ID()]["namespace"].(string)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if c.initialized {
+		if ns != c.namespace {
+			logging.S().Warnw("ignoring cluster:k8s namespace change that requires a restart", "namespace", c.config.Namespace)
+		}
+		return
+	}
+	c.namespace = ns
+}
+
+func (c *ClusterK8sRunner) SetSubnetAllocator(a SubnetAllocator) {
+	c.subnets = a
+}
+
+func (c *ClusterK8sRunner) subnetAllocator() SubnetAllocator {
+	if c.subnets == nil {
+		return defaultK8sSubnets
+	}
+	return c.subnets
 }
 
 type Journal struct {
@@ -164,11 +467,26 @@ type KubernetesConfig struct {
 	KubeConfigPath string `json:"kubeConfigPath"`
 	// Namespace is the kubernetes namespaces where the pods should be running
 	Namespace string `json:"namespace"`
+	// InCluster uses the service account of the pod the daemon runs in,
+	// rather than a kubeconfig.
+	InCluster bool `json:"inCluster"`
 }
 
-// defaultKubernetesConfig uses the default ~/.kube/config
-// to discover the kubernetes clusters. It also uses the "default" namespace.
+// k8sServiceAccountTokenPath is where Kubernetes mounts the service account
+// token into pods.
+var k8sServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// defaultKubernetesConfig uses the service account of the pod when running
+// inside the cluster, and the default ~/.kube/config otherwise, to discover
+// the kubernetes clusters. It also uses the "default" namespace.
 func defaultKubernetesConfig() KubernetesConfig {
+	if inKubernetesCluster() {
+		return KubernetesConfig{
+			InCluster: true,
+			Namespace: "default",
+		}
+	}
+
 	kubeconfig := filepath.Join(homeDir(), ".kube", "config")
 	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
 		kubeconfig = ""
@@ -179,6 +497,16 @@ func defaultKubernetesConfig() KubernetesConfig {
 	}
 }
 
+// inKubernetesCluster returns whether we're running inside a pod, with a
+// service account token mounted.
+func inKubernetesCluster() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return false
+	}
+	_, err := os.Stat(k8sServiceAccountTokenPath)
+	return err == nil
+}
+
 func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc.OutputWriter) (runoutput *api.RunOutput, runerr error) {
 	if err := c.initPool(); err != nil {
 		return nil, fmt.Errorf("could not init pool: %w", err)
@@ -200,6 +528,16 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 
 	cfg := *input.RunnerConfig.(*ClusterK8sRunnerConfig)
 
+	if cfg.Namespace != "" && cfg.Namespace != c.config.Namespace {
+		runerr = fmt.Errorf("namespace %q differs from the namespace of the runner %q; set it in .env.toml", cfg.Namespace, c.config.Namespace)
+		return
+	}
+
+	if err := c.checkImagePullSecrets(ctx, cfg.ImagePullSecrets); err != nil {
+		runerr = err
+		return
+	}
+
 	// if `provider` is set, we have to push to a docker registry
 	if cfg.Provider != "" {
 		err := c.pushImagesToDockerRegistry(ctx, ow, input)
@@ -209,6 +547,14 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 		}
 	}
 
+	// if `load_images` is set, we have to load the images onto the nodes
+	if cfg.LoadImages != "" {
+		if err := c.loadImagesOntoNodes(ctx, ow, input, cfg); err != nil {
+			runerr = fmt.Errorf("failed to load images onto the nodes; err: %w", err)
+			return
+		}
+	}
+
 	defaultCPU, err := resource.ParseQuantity(cfg.TestplanPodCPU)
 	if err != nil {
 		runerr = fmt.Errorf("couldn't parse default test plan pod CPU request; make sure you have specified `testplan_pod_cpu` in .env.toml; err: %w", err)
@@ -221,15 +567,17 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 		return
 	}
 
+	_, _, outputsPath := cfg.outputsVolume()
+
 	template := runtime.RunParams{
 		TestPlan:           input.TestPlan,
 		TestCase:           input.TestCase,
 		TestRun:            input.RunID,
 		TestInstanceCount:  input.TotalInstances,
 		TestDisableMetrics: input.DisableMetrics,
-		TestSidecar:        true,
-		TestOutputsPath:    "/outputs",
-		TestStartTime:      time.Now(),
+		TestSidecar:        !cfg.DisableSidecar,
+		TestOutputsPath:    outputsPath,
+		TestStartTime:      input.StartTime,
 	}
 
 	// currently weave is not releaasing IP addresses upon container deletion - we get errors back when trying to
@@ -237,15 +585,22 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 	// this functionality should be refactored asap, when we understand how weave releases IPs (or why it doesn't release
 	// them when a container is removed/ and as soon as we decide how to manage `networks in-use` so that there are no
 	// collisions in concurrent testplan runs
-	subnet, err := nextK8sSubnet()
+	subnet, _, releaseSubnet, err := c.subnetAllocator().Allocate()
 	if err != nil {
 		runerr = err
 		return
 	}
+	defer releaseSubnet()
 
 	template.TestSubnet = &ptypes.IPNet{IPNet: *subnet}
 
-	enoughResources, err := c.checkClusterResources(ow, input.Groups, defaultMemory, defaultCPU)
+	fallback := api.Resources{CPU: defaultCPU.String(), Memory: defaultMemory.String()}
+	if err := reviewRunResources(input, fallback, true, result, ow); err != nil {
+		runerr = err
+		return
+	}
+
+	enoughResources, err := c.checkClusterResources(ow, cfg, input.Groups, defaultMemory, defaultCPU)
 	if err != nil {
 		runerr = fmt.Errorf("couldn't check cluster resources: %v", err)
 		return
@@ -264,7 +619,8 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 
 	ow.Infow("deploying testground testplan run on k8s", "job-name", jobName)
 
-	var eg errgroup.Group
+	// a failing pod aborts the run.
+	eg, egCtx := errgroup.WithContext(ctx)
 
 	eg.Go(func() error {
 		ctxContainers, cancel := context.WithCancel(ctx)
@@ -275,17 +631,25 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 			ow.Errorw("could not start collecting outcomes", "err", err)
 		}
 
-		err = c.watchRunPods(ctx, ow, input, result, &template)
+		err = c.watchRunPods(egCtx, ow, input, result, &template)
 		if err != nil {
 			return err
 		}
 
+		ow.WriteStage(rpc.StageCollecting)
 		cancel()
 		<-outcomesDoneCh
 		return nil
 	})
 
-	sem := make(chan struct{}, 30) // limit the number of concurrent k8s api calls
+	limiter := newAdaptiveLimiter(cfg.podCreationConcurrency())
+
+	// instance is the index of the instance within the run, across groups.
+	instance := 0
+	scheduled := time.Now()
+
+	// pods are the names of the pods of the run.
+	var pods []string
 
 	for _, g := range input.Groups {
 		runenv := template
@@ -293,6 +657,7 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 		runenv.TestGroupInstanceCount = g.Instances
 		runenv.TestInstanceParams = g.Parameters
 		runenv.TestCaptureProfiles = g.Profiles
+		runenv.TestInstanceRole = g.Role
 
 		result.Outcomes[g.ID] = &GroupOutcome{
 			Total: g.Instances,
@@ -302,6 +667,9 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 		env = append(env, v1.EnvVar{Name: "REDIS_HOST", Value: "testground-infra-redis"})
 		env = append(env, v1.EnvVar{Name: "SYNC_SERVICE_HOST", Value: "testground-sync-service"})
 		env = append(env, v1.EnvVar{Name: "INFLUXDB_URL", Value: "http://influxdb:8086"})
+		env = append(env, v1.EnvVar{Name: EnvTestRunSeed, Value: strconv.FormatInt(runSeed(input.RunID), 10)})
+		env = append(env, v1.EnvVar{Name: EnvTestRequestID, Value: input.RequestID})
+		env = append(env, conv.ToEnvVar(topologyEnvVars(input, g))...)
 		// This subnet should correspond to the secondary CNI's IP range (usually Weave)
 		env = append(env, v1.EnvVar{Name: "TEST_SUBNET", Value: "10.32.0.0/12"})
 
@@ -338,18 +706,30 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 			}
 		}
 
+		podGPU, err := parseGPUs(g.Resources)
+		if err != nil {
+			runerr = err
+			return
+		}
+
 		for i := 0; i < g.Instances; i++ {
 			i := i
 			g := g
-			sem <- struct{}{}
+			index := instance
+			instance++
 
 			podName := fmt.Sprintf("%s-%s-%s-%d", jobName, input.RunID, g.ID, i)
+			pods = append(pods, podName)
 
 			defer func() {
 				if cfg.KeepService {
 					return
 				}
-				client := c.pool.Acquire()
+				client, err := c.pool.Acquire(ctx)
+				if err != nil {
+					ow.Errorw("couldn't remove pod", "pod", podName, "err", err)
+					return
+				}
 				defer c.pool.Release(client)
 				ow.Debugw("deleting pod", "pod", podName)
 				err = client.CoreV1().Pods(c.config.Namespace).Delete(ctx, podName, metav1.DeleteOptions{})
@@ -359,25 +739,36 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 			}()
 
 			eg.Go(func() error {
-				defer func() { <-sem }()
+				return createWithLimiter(egCtx, limiter, func() error {
+					currentEnv := make([]v1.EnvVar, len(env))
+					copy(currentEnv, env)
 
-				currentEnv := make([]v1.EnvVar, len(env))
-				copy(currentEnv, env)
+					currentEnv = append(currentEnv, v1.EnvVar{
+						Name:  "TEST_OUTPUTS_PATH",
+						Value: fmt.Sprintf("%s/%s/%s/%d", outputsPath, input.RunID, g.ID, i),
+					})
+					currentEnv = append(currentEnv, conv.ToEnvVar(instanceEnvVars(i, index))...)
+					currentEnv = append(currentEnv, conv.ToEnvVar(lifecycleEnvVars(input.StartTime, scheduled, time.Now()))...)
 
-				currentEnv = append(currentEnv, v1.EnvVar{
-					Name:  "TEST_OUTPUTS_PATH",
-					Value: fmt.Sprintf("/outputs/%s/%s/%d", input.RunID, g.ID, i),
+					return c.createTestplanPod(ctx, podName, input, runenv, currentEnv, g, i, podMemory, podCPU, podGPU)
 				})
-
-				return c.createTestplanPod(ctx, podName, input, runenv, currentEnv, g, i, podMemory, podCPU)
 			})
 		}
 	}
 
+	// without sidecars, there are no networks to initialise.
+	if cfg.NetworkInitTimeoutSec > 0 && !cfg.DisableSidecar {
+		timeout := time.Duration(cfg.NetworkInitTimeoutSec) * time.Second
+		eg.Go(func() error {
+			return c.waitNetworksInitialised(egCtx, ow, &template, pods, scheduled, timeout)
+		})
+	}
+
 	// we want to fetch logs even in an event of error
 	defer func() {
 		if input.TotalInstances <= 200 {
 			var gg errgroup.Group
+			sem := make(chan struct{}, 30) // limit the number of concurrent k8s api calls
 
 			for _, g := range input.Groups {
 				for i := 0; i < g.Instances; i++ {
@@ -413,6 +804,13 @@ func (c *ClusterK8sRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 	}()
 
 	err = eg.Wait()
+	ow.Debugw("pod creation concurrency at the end of the run", "concurrency", limiter.current())
+
+	// Record how the run was produced alongside its outputs.
+	if err := c.writeRunMetadata(ctx, input); err != nil {
+		ow.Warnw("failed to write run metadata", "error", err)
+	}
+
 	if err != nil {
 		runerr = err
 		return
@@ -436,7 +834,10 @@ func (c *ClusterK8sRunner) Healthcheck(ctx context.Context, engine api.Engine, o
 		return nil, err
 	}
 
-	client := c.pool.Acquire()
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
 	defer c.pool.Release(client)
 
 	// How many plan worker nodes are there?
@@ -489,7 +890,7 @@ func (*ClusterK8sRunner) ConfigType() reflect.Type {
 }
 
 func (*ClusterK8sRunner) CompatibleBuilders() []string {
-	return []string{"docker:go", "docker:generic"}
+	return []string{"docker:go", "docker:node", "docker:rust", "docker:generic"}
 }
 
 func (c *ClusterK8sRunner) Enabled() bool {
@@ -497,6 +898,21 @@ func (c *ClusterK8sRunner) Enabled() bool {
 	return c.pool != nil
 }
 
+// Ping checks that the Kubernetes API server is reachable.
+func (c *ClusterK8sRunner) Ping(ctx context.Context) error {
+	if err := c.initPool(); err != nil {
+		return fmt.Errorf("could not init pool: %w", err)
+	}
+
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.pool.Release(client)
+
+	return client.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+}
+
 func (c *ClusterK8sRunner) initPool() error {
 	mu.Lock()
 	defer mu.Unlock()
@@ -506,6 +922,9 @@ func (c *ClusterK8sRunner) initPool() error {
 	}
 
 	c.config = defaultKubernetesConfig()
+	if c.namespace != "" {
+		c.config.Namespace = c.namespace
+	}
 	c.imagesLRU, _ = lru.New(256)
 
 	var err error
@@ -525,27 +944,30 @@ func (c *ClusterK8sRunner) initPool() error {
 	return nil
 }
 
+// CollectOutputs archives the outputs of a run in the collect-outputs pod,
+// which has the shared outputs volume mounted, and streams the archive to the
+// client through a single exec session, as it's compressed. The archive is
+// never stored, so there's nothing to download in parallel ranges; the
+// compression is what can be parallelised, see CollectCompression.
 func (c *ClusterK8sRunner) CollectOutputs(ctx context.Context, input *api.CollectionInput, ow *rpc.OutputWriter) error {
 	if err := c.initPool(); err != nil {
 		return fmt.Errorf("could not init pool: %w", err)
 	}
 
 	log := ow.With("runner", "cluster:k8s", "run_id", input.RunID)
-	err := c.ensureCollectOutputsPod(ctx, input)
+	release, err := c.acquireCollectOutputsPod(ctx, input)
 	if err != nil {
 		return err
 	}
+	defer release()
 
-	client := c.pool.Acquire()
-	defer c.pool.Release(client)
+	command := collectOutputsCommand(*input.RunnerConfig.(*ClusterK8sRunnerConfig), input.RunID)
 
-	// This is the same line found in client_pool.go...
-	// I need the restCfg, for remotecommand.
-	// TODO: Reorganize not to repeat ourselves.
-	k8sCfg, err := clientcmd.BuildConfigFromFlags("", c.config.KubeConfigPath)
+	client, err := c.pool.Acquire(ctx)
 	if err != nil {
 		return err
 	}
+	defer c.pool.Release(client)
 
 	// This request is sent to the collect-outputs pod
 	// tar, compress, and write to stdout.
@@ -559,26 +981,19 @@ func (c *ClusterK8sRunner) CollectOutputs(ctx context.Context, input *api.Collec
 		Post().
 		Resource("pods").
 		Name("collect-outputs").
-		Namespace("default").
+		Namespace(c.config.Namespace).
 		SubResource("exec").
 		Param("container", "collect-outputs").
 		VersionedParams(&v1.PodExecOptions{
 			Container: "collect-outputs",
-			Command: []string{
-				"tar",
-				"-C",
-				"/outputs",
-				"-czf",
-				"-",
-				input.RunID,
-			},
-			Stdin:  false,
-			Stderr: false,
-			Stdout: true,
+			Command:   command,
+			Stdin:     false,
+			Stderr:    true,
+			Stdout:    true,
 		}, scheme.ParameterCodec)
 
 	log.Debug("sending command to remote server: ", req.URL())
-	exec, err := remotecommand.NewSPDYExecutor(k8sCfg, "POST", req.URL())
+	exec, err := remotecommand.NewSPDYExecutor(c.pool.RESTConfig(), "POST", req.URL())
 	if err != nil {
 		log.Warnf("failed to send remote collection command: %v", err)
 		return err
@@ -588,52 +1003,187 @@ func (c *ClusterK8sRunner) CollectOutputs(ctx context.Context, input *api.Collec
 	// Connect stderr to a buffer which we can read from to display any errors to the user.
 	outbuf := bufio.NewWriter(ow.BinaryWriter())
 	defer outbuf.Flush()
+	var (
+		hash   = sha256.New()
+		stderr bytes.Buffer
+	)
 	err = exec.Stream(remotecommand.StreamOptions{
-		Stdout: outbuf,
+		Stdout: io.MultiWriter(outbuf, hash),
+		Stderr: &stderr,
 	})
 	if err != nil {
 		log.Warnf("failed to collect results from remote collection command: %v", err)
 		return err
 	}
+	// The pod writes the archive to stdout, and its checksum to stderr.
+	if err := verifyOutputsChecksum(stderr.String(), hash.Sum(nil)); err != nil {
+		log.Warnw("outputs archive failed its integrity check", "err", err)
+		return err
+	}
 	return nil
 }
 
-// waitForPod waits until a given pod reaches the desired `phase` or the context is canceled
-func (c *ClusterK8sRunner) waitForPod(ctx context.Context, podName string, phase string) error {
-	client := c.pool.Acquire()
+// verifyOutputsChecksum checks the SHA-256 of the archive received against
+// the one computed by the collect-outputs pod, i.e. the last sha256sum line
+// on its stderr.
+func verifyOutputsChecksum(stderr string, sum []byte) error {
+	var expected string
+	for _, line := range strings.Split(stderr, "\n") {
+		f := strings.Fields(line)
+		if len(f) == 2 && f[1] == "-" && len(f[0]) == sha256.Size*2 {
+			expected = f[0]
+		}
+	}
+	if expected == "" {
+		return fmt.Errorf("%w: no checksum reported by the collect-outputs pod; stderr: %s", ErrCorruptOutputs, stderr)
+	}
+	if got := hex.EncodeToString(sum); got != expected {
+		return fmt.Errorf("%w: sha256 %s, expected %s", ErrCorruptOutputs, got, expected)
+	}
+	return nil
+}
+
+// writeRunMetadata writes the metadata of a run to its directory on the shared
+// outputs volume, through the collect-outputs pod, so that it's collected
+// along with the outputs.
+func (c *ClusterK8sRunner) writeRunMetadata(ctx context.Context, input *api.RunInput) error {
+	b, err := marshalRunMetadata(input)
+	if err != nil {
+		return err
+	}
+
+	release, err := c.acquireCollectOutputsPod(ctx, &api.CollectionInput{
+		EnvConfig:    input.EnvConfig,
+		RunID:        input.RunID,
+		RunnerID:     c.ID(),
+		RunnerConfig: input.RunnerConfig,
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
 	defer c.pool.Release(client)
 
-	var p string
+	_, _, outputsPath := input.RunnerConfig.(*ClusterK8sRunnerConfig).outputsVolume()
+	dir := path.Join(outputsPath, input.RunID)
+	req := client.
+		CoreV1().
+		RESTClient().
+		Post().
+		Resource("pods").
+		Name(collectOutputsPodName).
+		Namespace(c.config.Namespace).
+		SubResource("exec").
+		Param("container", "collect-outputs").
+		VersionedParams(&v1.PodExecOptions{
+			Container: "collect-outputs",
+			Command: []string{
+				"sh",
+				"-c",
+				fmt.Sprintf("mkdir -p %s && cat > %s", dir, path.Join(dir, runMetadataFile)),
+			},
+			Stdin:  true,
+			Stderr: false,
+			Stdout: false,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(c.pool.RESTConfig(), "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	return exec.Stream(remotecommand.StreamOptions{
+		Stdin: bytes.NewReader(b),
+	})
+}
+
+// waitForPod waits until a given pod reaches the desired `phase` or the context is canceled.
+// Transient API errors are retried; it fails if the pod doesn't exist.
+func (c *ClusterK8sRunner) waitForPod(ctx context.Context, podName string, phase string) error {
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.pool.Release(client)
 
 	for {
+		var pod *v1.Pod
+		err := retryBackoff(ctx, k8sRetryAttempts, k8sRetrySleep, isTransientK8sError, func() (err error) {
+			pod, err = client.CoreV1().Pods(c.config.Namespace).Get(ctx, podName, metav1.GetOptions{})
+			return err
+		})
+		switch {
+		case k8serrors.IsNotFound(err):
+			return fmt.Errorf("pod %s not found", podName)
+		case err != nil:
+			return err
+		}
+
+		if string(pod.Status.Phase) == phase {
+			return nil
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
-			if p == phase {
-				return nil
-			}
-			res, err := client.CoreV1().Pods(c.config.Namespace).List(ctx, metav1.ListOptions{
-				FieldSelector: "metadata.name=" + podName,
-			})
-			if err != nil {
-				return err
-			}
-			if len(res.Items) != 1 {
-				continue
-			}
+		case <-time.After(1 * time.Second):
+		}
+	}
+}
+
+// isTransientK8sError returns whether err is a transient failure of the
+// Kubernetes API, such as throttling, a timeout, or an API server that is
+// briefly unreachable, which is worth retrying.
+func isTransientK8sError(err error) bool {
+	if k8serrors.IsTooManyRequests(err) ||
+		k8serrors.IsServerTimeout(err) ||
+		k8serrors.IsTimeout(err) ||
+		k8serrors.IsServiceUnavailable(err) ||
+		k8serrors.IsInternalError(err) ||
+		k8serrors.IsUnexpectedServerError(err) {
+		return true
+	}
+
+	var nerr net.Error
+	return errors.As(err, &nerr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
 
-			pod := res.Items[0]
-			p = string(pod.Status.Phase)
+// isK8sThrottlingError returns whether err means that the Kubernetes API
+// server isn't keeping up with the calls made to it.
+func isK8sThrottlingError(err error) bool {
+	return k8serrors.IsTooManyRequests(err) || k8serrors.IsServerTimeout(err) || k8serrors.IsTimeout(err)
+}
 
-			time.Sleep(1 * time.Second)
+// createWithLimiter calls create within the concurrency of limiter, retrying
+// with backoff while the API server throttles it. A pod that already exists
+// on a retry was created by an attempt that timed out.
+func createWithLimiter(ctx context.Context, limiter *adaptiveLimiter, create func() error) error {
+	attempt := 0
+	return retryBackoff(ctx, k8sRetryAttempts, k8sRetrySleep, isK8sThrottlingError, func() error {
+		tok, err := limiter.acquire(ctx)
+		if err != nil {
+			return err
 		}
-	}
+		err = create()
+		limiter.release(tok, isK8sThrottlingError(err))
+
+		if attempt++; attempt > 1 && k8serrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	})
 }
 
 // ensureCollectOutputsPod ensures that we have a collect-outputs pod running
 func (c *ClusterK8sRunner) ensureCollectOutputsPod(ctx context.Context, input *api.CollectionInput) error {
-	client := c.pool.Acquire()
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
 	defer c.pool.Release(client)
 
 	res, err := client.CoreV1().Pods(c.config.Namespace).List(ctx, metav1.ListOptions{
@@ -658,8 +1208,100 @@ func (c *ClusterK8sRunner) ensureCollectOutputsPod(ctx context.Context, input *a
 	return nil
 }
 
+// acquireCollectOutputsPod ensures that the collect-outputs pod is running,
+// and keeps it from being reaped until the returned function is called.
+func (c *ClusterK8sRunner) acquireCollectOutputsPod(ctx context.Context, input *api.CollectionInput) (release func(), err error) {
+	c.collectLk.Lock()
+	c.collectors++
+	c.collectGen++
+	if c.collectTimer != nil {
+		c.collectTimer.Stop()
+		c.collectTimer = nil
+	}
+	c.collectLk.Unlock()
+
+	if err := c.ensureCollectOutputsPod(ctx, input); err != nil {
+		c.releaseCollectOutputsPod(0)
+		return nil, err
+	}
+
+	cfg := *input.RunnerConfig.(*ClusterK8sRunnerConfig)
+	idle := time.Duration(cfg.CollectOutputsPodIdleTimeoutSec) * time.Second
+	return func() { c.releaseCollectOutputsPod(idle) }, nil
+}
+
+// releaseCollectOutputsPod schedules the reaping of the collect-outputs pod
+// once its last user is done with it, if an idle timeout is set.
+func (c *ClusterK8sRunner) releaseCollectOutputsPod(idle time.Duration) {
+	c.collectLk.Lock()
+	defer c.collectLk.Unlock()
+
+	c.collectors--
+	if c.collectors > 0 || idle <= 0 {
+		return
+	}
+
+	gen := c.collectGen
+	c.collectTimer = time.AfterFunc(idle, func() {
+		c.collectLk.Lock()
+		defer c.collectLk.Unlock()
+
+		if c.collectors > 0 || c.collectGen != gen {
+			return
+		}
+		c.collectTimer = nil
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if _, err := c.deleteCollectOutputsPod(ctx); err != nil {
+			logging.S().Warnw("failed to delete idle collect-outputs pod", "err", err)
+			return
+		}
+		logging.S().Infow("deleted idle collect-outputs pod", "idle", idle)
+	})
+}
+
+// deleteCollectOutputsPod deletes the collect-outputs pod, if any, and waits
+// for it to be gone, so that it's not mistaken for a running one. It must be
+// called with collectLk held.
+func (c *ClusterK8sRunner) deleteCollectOutputsPod(ctx context.Context) (deleted bool, err error) {
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer c.pool.Release(client)
+
+	// the pod only sleeps, so there is nothing to shut down gracefully.
+	var grace int64
+	err = client.CoreV1().Pods(c.config.Namespace).Delete(ctx, collectOutputsPodName, metav1.DeleteOptions{GracePeriodSeconds: &grace})
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for {
+		_, err := client.CoreV1().Pods(c.config.Namespace).Get(ctx, collectOutputsPodName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil && !isTransientK8sError(err) {
+			return true, err
+		}
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
 func (c *ClusterK8sRunner) getPodLogs(ow *rpc.OutputWriter, podName string) (string, error) {
-	client := c.pool.Acquire()
+	client, err := c.pool.Acquire(context.TODO())
+	if err != nil {
+		return "", err
+	}
 	defer c.pool.Release(client)
 
 	podLogOpts := v1.PodLogOptions{
@@ -667,7 +1309,6 @@ func (c *ClusterK8sRunner) getPodLogs(ow *rpc.OutputWriter, podName string) (str
 	}
 
 	var podLogs io.ReadCloser
-	var err error
 	err = retry(5, 5*time.Second, func() error {
 		req := client.CoreV1().Pods(c.config.Namespace).GetLogs(podName, &podLogOpts)
 		podLogs, err = req.Stream(context.TODO())
@@ -693,8 +1334,81 @@ func (c *ClusterK8sRunner) getPodLogs(ow *rpc.OutputWriter, podName string) (str
 	return buf.String(), nil
 }
 
+// waitNetworksInitialised waits for the sidecars of the supplied pods to
+// report that their networks have been initialised. It fails as soon as any
+// sidecar reports a failure, or if the networks are not initialised within
+// timeout, naming the pods at fault. The RunStageNetworkInit stage is timed
+// from scheduled, when the runner began creating the pods.
+func (c *ClusterK8sRunner) waitNetworksInitialised(ctx context.Context, ow *rpc.OutputWriter, tpl *runtime.RunParams, pods []string, scheduled time.Time, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ss.WithRunParams(ctx, tpl), timeout)
+	defer cancel()
+
+	outcomes := make(chan *sidecar.NetworkInitialisation)
+	sub, err := c.syncClient.Subscribe(ctx, sidecar.NetworkInitialisationTopic, outcomes)
+	if err != nil {
+		return fmt.Errorf("failed to watch network initialisation: %w", err)
+	}
+
+	// sidecars know instances by their hostnames.
+	pending := make(map[string]string, len(pods))
+	for _, pod := range pods {
+		pending[podHostname(pod)] = pod
+	}
+
+	// done returns the error ending the wait before all pods reported.
+	done := func() error {
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ctx.Err()
+		}
+		late := make([]string, 0, len(pending))
+		for _, pod := range pending {
+			late = append(late, pod)
+		}
+		sort.Strings(late)
+		return fmt.Errorf("pods did not initialise their networks within %s: %s", timeout, strings.Join(late, ", "))
+	}
+
+	for len(pending) > 0 {
+		select {
+		case o := <-outcomes:
+			pod, ok := pending[o.Hostname]
+			if !ok {
+				continue
+			}
+			if o.Error != "" {
+				return fmt.Errorf("aborted: pod %s failed to initialise its network: %s", pod, o.Error)
+			}
+			delete(pending, o.Hostname)
+		case <-ctx.Done():
+			return done()
+		case err := <-sub.Done():
+			if err == nil && ctx.Err() != nil {
+				return done()
+			}
+			return fmt.Errorf("stopped watching network initialisation: %v", err)
+		}
+	}
+
+	ow.Infow("all testplan instances initialised their networks")
+	observeStage(ow, c.ID(), RunStageNetworkInit, time.Since(scheduled))
+	ow.WriteStage(rpc.StageNetworksReady)
+	return nil
+}
+
+// podHostname returns the hostname the kubelet gives the pod with the supplied
+// name: the name, truncated to 63 characters without trailing dashes or dots.
+func podHostname(name string) string {
+	if len(name) <= 63 {
+		return name
+	}
+	return strings.TrimRight(name[:63], "-.")
+}
+
 func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWriter, input *api.RunInput, result *Result, rp *runtime.RunParams) error {
-	client := c.pool.Acquire()
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
 	defer c.pool.Release(client)
 
 	cfg := *input.RunnerConfig.(*ClusterK8sRunnerConfig)
@@ -704,6 +1418,8 @@ func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWrite
 		runTimeout = time.Duration(cfg.RunTimeoutMin) * time.Minute
 	}
 
+	maxFailed := failFastCount(cfg.FailFastThreshold, input.TotalInstances)
+
 	fieldSelector := "type!=Normal"
 	opts := metav1.ListOptions{
 		FieldSelector: fieldSelector,
@@ -732,94 +1448,85 @@ func (c *ClusterK8sRunner) watchRunPods(ctx context.Context, ow *rpc.OutputWrite
 		}
 	}()
 
-	podsByState := make(map[string]*v1.PodList)
-	var countersMu sync.Mutex
+	// the informer on the pods of the run is torn down along with the watch.
+	informerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	start := time.Now()
-	allRunningStage := false
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		if time.Since(start) > runTimeout {
-			return fmt.Errorf("run timeout reached. make sure your plan execution completes within %s.", runTimeout)
-		}
-		time.Sleep(2000 * time.Millisecond)
-
-		countPodsByState := func(state string) int {
-			fieldSelector := fmt.Sprintf("status.phase=%s", state)
-			opts := metav1.ListOptions{
-				LabelSelector: fmt.Sprintf("testground.run_id=%s", input.RunID),
-				FieldSelector: fieldSelector,
-			}
-			res, err := client.CoreV1().Pods(c.config.Namespace).List(ctx, opts)
-			if err != nil {
-				ow.Warnw("k8s client pods list error", "err", err.Error())
-				return -1
-			}
-			countersMu.Lock()
-			podsByState[state] = res
-			countersMu.Unlock()
-			return len(res.Items)
-		}
-
-		counters := map[string]int{}
-		states := []string{"Pending", "Running", "Succeeded", "Failed", "Unknown"}
+	tracker, err := watchPodPhases(informerCtx, client, c.config.Namespace, input.RunID)
+	if err != nil {
+		return err
+	}
 
-		var wg sync.WaitGroup
-		wg.Add(len(states))
-		for _, state := range states {
-			state := state
-			go func() {
-				defer wg.Done()
+	timeout := time.NewTimer(runTimeout - time.Since(start))
+	defer timeout.Stop()
 
-				n := countPodsByState(state)
+	allRunningStage := false
+	// running is when all instances were running; execution is measured from
+	// then, or from the start if they finished before all running at once.
+	running := start
+	var logged time.Time
+	for {
+		counters, failed := tracker.snapshot()
 
-				countersMu.Lock()
-				counters[state] = n
-				countersMu.Unlock()
-			}()
+		// the state is logged at most every couple of seconds, while pods
+		// change phase.
+		if time.Since(logged) >= 2*time.Second {
+			logged = time.Now()
+			ow.Debugw("testplan pods state", "running_for", time.Since(start).Truncate(time.Second), "succeeded", counters[v1.PodSucceeded], "running", counters[v1.PodRunning], "pending", counters[v1.PodPending], "failed", counters[v1.PodFailed], "unknown", counters[v1.PodUnknown])
 		}
-		wg.Wait()
 
-		ow.Debugw("testplan pods state", "running_for", time.Since(start).Truncate(time.Second), "succeeded", counters["Succeeded"], "running", counters["Running"], "pending", counters["Pending"], "failed", counters["Failed"], "unknown", counters["Unknown"])
-
-		if counters["Failed"] > 0 {
-			for _, p := range podsByState["Failed"].Items {
-				if !strings.Contains(p.ObjectMeta.Name, input.RunID) {
+		for _, p := range failed {
+			for _, st := range p.Status.ContainerStatuses {
+				if st.State.Terminated == nil {
 					continue
 				}
-
-				for _, st := range p.Status.ContainerStatuses {
-					event := fmt.Sprintf("pod status <failed> obj<%s> reason<%s> started_at<%s> finished_at<%s> exitcode<%d>", st.Name, st.State.Terminated.Reason, st.State.Terminated.StartedAt, st.State.Terminated.FinishedAt, st.State.Terminated.ExitCode)
-					ow.Warnw("testplan received status", "status", event)
-					result.Journal.PodsStatuses[event] = struct{}{}
-				}
+				event := fmt.Sprintf("pod status <failed> obj<%s> reason<%s> started_at<%s> finished_at<%s> exitcode<%d>", st.Name, st.State.Terminated.Reason, st.State.Terminated.StartedAt, st.State.Terminated.FinishedAt, st.State.Terminated.ExitCode)
+				ow.Warnw("testplan received status", "status", event)
+				result.Journal.PodsStatuses[event] = struct{}{}
 			}
 		}
 
-		if counters["Running"] == input.TotalInstances && !allRunningStage {
+		if maxFailed > 0 && counters[v1.PodFailed] >= maxFailed {
+			result.Outcome = task.OutcomeFailure
+			return fmt.Errorf("aborted: failure threshold exceeded (%d of %d instances failed, threshold: %d)", counters[v1.PodFailed], input.TotalInstances, maxFailed)
+		}
+
+		if counters[v1.PodRunning] == input.TotalInstances && !allRunningStage {
 			allRunningStage = true
+			running = time.Now()
 			ow.Infow("all testplan instances in `Running` state", "took", time.Since(start).Truncate(time.Second))
+			observeStage(ow, c.ID(), RunStageScheduling, running.Sub(start))
+			ow.WriteStage(rpc.StageRunning)
 		}
 
-		if counters["Succeeded"] == input.TotalInstances {
+		if counters[v1.PodSucceeded] == input.TotalInstances {
 			ow.Infow("all testplan instances in `Succeeded` state", "took", time.Since(start).Truncate(time.Second))
+			observeStage(ow, c.ID(), RunStageExecution, time.Since(running))
 			return nil
 		}
 
-		if (counters["Succeeded"] + counters["Failed"]) == input.TotalInstances {
+		if (counters[v1.PodSucceeded] + counters[v1.PodFailed]) == input.TotalInstances {
 			ow.Warnw("all testplan instances in `Succeeded` or `Failed` state", "took", time.Since(start).Truncate(time.Second))
+			observeStage(ow, c.ID(), RunStageExecution, time.Since(running))
 			return nil
 		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout.C:
+			return fmt.Errorf("run timeout reached. make sure your plan execution completes within %s.", runTimeout)
+		case <-tracker.changed:
+		}
 	}
 }
 
-func (c *ClusterK8sRunner) createTestplanPod(ctx context.Context, podName string, input *api.RunInput, runenv runtime.RunParams, env []v1.EnvVar, g *api.RunGroup, i int, podResourceMemory resource.Quantity, podResourceCPU resource.Quantity) error {
-	client := c.pool.Acquire()
+func (c *ClusterK8sRunner) createTestplanPod(ctx context.Context, podName string, input *api.RunInput, runenv runtime.RunParams, env []v1.EnvVar, g *api.RunGroup, i int, podResourceMemory resource.Quantity, podResourceCPU resource.Quantity, podResourceGPU resource.Quantity) error {
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
 	defer c.pool.Release(client)
 
 	cfg := *input.RunnerConfig.(*ClusterK8sRunnerConfig)
@@ -842,51 +1549,83 @@ func (c *ClusterK8sRunner) createTestplanPod(ctx context.Context, podName string
 		cnt++
 	}
 
+	// the restart policy has been validated on submission.
+	restartPolicy := v1.RestartPolicyNever
+	if cfg.RestartPolicy != "" {
+		restartPolicy = v1.RestartPolicy(cfg.RestartPolicy)
+	}
+
+	aliases, err := hostAliases(cfg.ExtraHosts)
+	if err != nil {
+		return err
+	}
+
+	var dnsConfig *v1.PodDNSConfig
+	if len(cfg.DNS) > 0 {
+		dnsConfig = &v1.PodDNSConfig{Nameservers: cfg.DNS}
+	}
+
 	mountPropagationMode := v1.MountPropagationHostToContainer
-	sharedVolumeName := "efs-shared"
+	claimName, sharedVolumeName, outputsPath := cfg.outputsVolume()
+
+	volumes := []v1.Volume{
+		{
+			Name: sharedVolumeName,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: claimName,
+				},
+			},
+		},
+	}
+	volumeMounts := []v1.VolumeMount{
+		{
+			Name:             sharedVolumeName,
+			MountPath:        outputsPath,
+			MountPropagation: &mountPropagationMode,
+		},
+	}
+
+	// Additional read-only mounts declared by the composition.
+	for n, m := range g.Mounts {
+		name := fmt.Sprintf("mount-%d", n)
+		vm := v1.VolumeMount{Name: name, MountPath: m.Target, ReadOnly: true}
+
+		var src v1.VolumeSource
+		if m.IsPVC() {
+			claim, subPath := m.PVC()
+			src.PersistentVolumeClaim = &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim, ReadOnly: true}
+			vm.SubPath = subPath
+		} else {
+			src.HostPath = &v1.HostPathVolumeSource{Path: m.Source}
+		}
+
+		volumes = append(volumes, v1.Volume{Name: name, VolumeSource: src})
+		volumeMounts = append(volumeMounts, vm)
+	}
 
 	podRequest := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: podName,
 			Labels: map[string]string{
-				"testground.plan":     input.TestPlan,
-				"testground.testcase": runenv.TestCase,
-				"testground.run_id":   input.RunID,
-				"testground.groupid":  g.ID,
-				"testground.purpose":  "plan",
+				"testground.plan":       input.TestPlan,
+				"testground.testcase":   runenv.TestCase,
+				"testground.run_id":     input.RunID,
+				"testground.groupid":    g.ID,
+				"testground.purpose":    "plan",
+				"testground.request_id": input.RequestID,
 			},
-			Annotations: map[string]string{"cni": defaultK8sNetworkAnnotation, "k8s.v1.cni.cncf.io/networks": "weave"},
+			Annotations: podNetworkAnnotations(cfg),
 		},
 		Spec: v1.PodSpec{
-			Volumes: []v1.Volume{
-				{
-					Name: sharedVolumeName,
-					VolumeSource: v1.VolumeSource{
-						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
-							ClaimName: "efs",
-						},
-					},
-				},
-			},
+			Volumes: volumes,
 			SecurityContext: &v1.PodSecurityContext{
 				Sysctls: sysctls,
 			},
-			RestartPolicy: v1.RestartPolicyNever,
+			RestartPolicy: restartPolicy,
+			HostAliases:   aliases,
+			DNSConfig:     dnsConfig,
 			InitContainers: []v1.Container{
-				{
-					Name:            "wait-for-sidecar",
-					Image:           "busybox",
-					ImagePullPolicy: v1.PullIfNotPresent,
-					Args:            []string{"-c", "until nc -vz $HOST_IP 6060; do echo \"Waiting for local sidecar to listen to $HOST_IP:6060\"; sleep 2; done;"},
-					Command:         []string{"sh"},
-					Env:             env,
-					Resources: v1.ResourceRequirements{
-						Limits: v1.ResourceList{
-							v1.ResourceMemory: resource.MustParse("10Mi"),
-							v1.ResourceCPU:    resource.MustParse("10m"),
-						},
-					},
-				},
 				{
 					Name:            "mkdir-outputs",
 					Image:           "busybox",
@@ -897,7 +1636,7 @@ func (c *ClusterK8sRunner) createTestplanPod(ctx context.Context, podName string
 					VolumeMounts: []v1.VolumeMount{
 						{
 							Name:             sharedVolumeName,
-							MountPath:        "/outputs",
+							MountPath:        outputsPath,
 							MountPropagation: &mountPropagationMode,
 						},
 					},
@@ -913,17 +1652,11 @@ func (c *ClusterK8sRunner) createTestplanPod(ctx context.Context, podName string
 				{
 					Name:            podName,
 					Image:           g.ArtifactPath,
-					ImagePullPolicy: v1.PullIfNotPresent,
+					ImagePullPolicy: cfg.imagePullPolicy(),
 					Args:            []string{},
 					Env:             env,
 					Ports:           ports,
-					VolumeMounts: []v1.VolumeMount{
-						{
-							Name:             sharedVolumeName,
-							MountPath:        "/outputs",
-							MountPropagation: &mountPropagationMode,
-						},
-					},
+					VolumeMounts:    volumeMounts,
 					Resources: v1.ResourceRequirements{
 						Requests: v1.ResourceList{
 							v1.ResourceMemory: podResourceMemory,
@@ -935,27 +1668,150 @@ func (c *ClusterK8sRunner) createTestplanPod(ctx context.Context, podName string
 					},
 				},
 			},
-			NodeSelector: map[string]string{"testground.node.role.plan": "true"},
+			NodeSelector:     map[string]string{"testground.node.role.plan": "true"},
+			ImagePullSecrets: imagePullSecrets(cfg.ImagePullSecrets),
 		},
 	}
 
-	_, err := client.CoreV1().Pods(c.config.Namespace).Create(ctx, podRequest, metav1.CreateOptions{})
+	if !cfg.DisableSidecar {
+		// wait until the sidecar on the node reports ready on its /readyz
+		// endpoint, i.e. until it can reach Docker and the sync service.
+		waitForSidecar := v1.Container{
+			Name:            "wait-for-sidecar",
+			Image:           "busybox",
+			ImagePullPolicy: v1.PullIfNotPresent,
+			Args:            []string{"-c", "until wget -q -T 5 -O /dev/null http://$HOST_IP:6060/readyz; do echo \"Waiting for local sidecar to be ready at $HOST_IP:6060\"; sleep 2; done;"},
+			Command:         []string{"sh"},
+			Env:             env,
+			Resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{
+					v1.ResourceMemory: resource.MustParse("10Mi"),
+					v1.ResourceCPU:    resource.MustParse("10m"),
+				},
+			},
+		}
+		podRequest.Spec.InitContainers = append([]v1.Container{waitForSidecar}, podRequest.Spec.InitContainers...)
+	}
+	podRequest.Spec.InitContainers = append(podRequest.Spec.InitContainers, planInitContainers(g.InitContainers, env, volumeMounts)...)
+
+	if !podResourceGPU.IsZero() {
+		requestGPUs(podRequest, cfg, podResourceGPU)
+	}
+
+	_, err = client.CoreV1().Pods(c.config.Namespace).Create(ctx, podRequest, metav1.CreateOptions{})
 	return err
 }
 
-func int64Ptr(i int64) *int64 { return &i }
+// imagePullSecrets returns the references to the image pull secrets with the
+// supplied names.
+func imagePullSecrets(names []string) []v1.LocalObjectReference {
+	if len(names) == 0 {
+		return nil
+	}
+	refs := make([]v1.LocalObjectReference, 0, len(names))
+	for _, name := range names {
+		refs = append(refs, v1.LocalObjectReference{Name: name})
+	}
+	return refs
+}
+
+// checkImagePullSecrets checks that the image pull secrets with the supplied
+// names exist in the namespace of the runner, so that a missing one fails the
+// run before any pod fails to pull its image.
+func (c *ClusterK8sRunner) checkImagePullSecrets(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
 
-type FakeWriterAt struct {
-	w io.Writer
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.pool.Release(client)
+
+	for _, name := range names {
+		err := retryBackoff(ctx, k8sRetryAttempts, k8sRetrySleep, isTransientK8sError, func() error {
+			_, err := client.CoreV1().Secrets(c.config.Namespace).Get(ctx, name, metav1.GetOptions{})
+			return err
+		})
+		switch {
+		case k8serrors.IsNotFound(err):
+			return fmt.Errorf("image pull secret %s not found in namespace %s", name, c.config.Namespace)
+		case err != nil:
+			return fmt.Errorf("failed to check image pull secret %s: %w", name, err)
+		}
+	}
+	return nil
 }
 
-func (fw FakeWriterAt) WriteAt(p []byte, offset int64) (n int, err error) {
-	// ignore 'offset' because we forced sequential downloads
-	return fw.w.Write(p)
+// planInitContainers returns the init containers declared for a group, which
+// share the environment and the volumes of the testplan container.
+func planInitContainers(ics []api.InitContainer, env []v1.EnvVar, mounts []v1.VolumeMount) []v1.Container {
+	ctrs := make([]v1.Container, 0, len(ics))
+	for _, ic := range ics {
+		ctrs = append(ctrs, v1.Container{
+			Name:            ic.Name,
+			Image:           ic.Image,
+			ImagePullPolicy: v1.PullIfNotPresent,
+			Command:         ic.Command,
+			Env:             env,
+			VolumeMounts:    mounts,
+		})
+	}
+	return ctrs
+}
+
+// parseGPUs parses the number of GPUs requested by a group, which must be a
+// whole number.
+func parseGPUs(res api.Resources) (resource.Quantity, error) {
+	if res.GPU == "" {
+		return resource.Quantity{}, nil
+	}
+	q, err := resource.ParseQuantity(res.GPU)
+	if err != nil {
+		return q, fmt.Errorf("invalid gpu resources %q: %w", res.GPU, err)
+	}
+	if q.Sign() < 0 || q.MilliValue()%1000 != 0 {
+		return q, fmt.Errorf("gpu resources must be a whole number, got %q", res.GPU)
+	}
+	return q, nil
+}
+
+// requestGPUs requests gpus for the testplan container of pod, as the GPU
+// resource of the configuration, and schedules the pod on GPU nodes.
+func requestGPUs(pod *v1.Pod, cfg ClusterK8sRunnerConfig, gpus resource.Quantity) {
+	name := cfg.gpuResource()
+	ctr := &pod.Spec.Containers[0]
+	// extended resources can't be overcommitted; their requests equal
+	// their limits.
+	ctr.Resources.Requests[name] = gpus
+	ctr.Resources.Limits[name] = gpus
+
+	for k, v := range cfg.GPUNodeSelector {
+		pod.Spec.NodeSelector[k] = v
+	}
+}
+
+// clusterGPUs returns the GPUs allocatable on the nodes matching selector.
+func clusterGPUs(nodes []v1.Node, name v1.ResourceName, selector map[string]string) int64 {
+	var total int64
+nodes:
+	for _, n := range nodes {
+		for k, v := range selector {
+			if n.Labels[k] != v {
+				continue nodes
+			}
+		}
+		q := n.Status.Allocatable[name]
+		total += q.Value()
+	}
+	return total
 }
 
+func int64Ptr(i int64) *int64 { return &i }
+
 // checkClusterResources returns whether we can fit the input groups in the current cluster
-func (c *ClusterK8sRunner) checkClusterResources(ow *rpc.OutputWriter, groups []*api.RunGroup, fallbackMemory resource.Quantity, fallbackCPU resource.Quantity) (bool, error) {
+func (c *ClusterK8sRunner) checkClusterResources(ow *rpc.OutputWriter, cfg ClusterK8sRunnerConfig, groups []*api.RunGroup, fallbackMemory resource.Quantity, fallbackCPU resource.Quantity) (bool, error) {
 	neededCPUs := 0.0
 
 	defaultPodCPU, err := strconv.ParseFloat(fallbackCPU.AsDec().String(), 64)
@@ -963,7 +1819,10 @@ func (c *ClusterK8sRunner) checkClusterResources(ow *rpc.OutputWriter, groups []
 		return false, err
 	}
 
-	client := c.pool.Acquire()
+	client, err := c.pool.Acquire(context.TODO())
+	if err != nil {
+		return false, err
+	}
 	defer c.pool.Release(client)
 
 	res, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
@@ -983,6 +1842,21 @@ func (c *ClusterK8sRunner) checkClusterResources(ow *rpc.OutputWriter, groups []
 
 	availableCPUs := float64(totalCPUs) - float64(nodes)*sidecarCPUs
 
+	var neededGPUs int64
+	for _, g := range groups {
+		gpus, err := parseGPUs(g.Resources)
+		if err != nil {
+			return false, err
+		}
+		neededGPUs += gpus.Value() * int64(g.Instances)
+	}
+	if neededGPUs > 0 {
+		if availableGPUs := clusterGPUs(res.Items, cfg.gpuResource(), cfg.GPUNodeSelector); availableGPUs < neededGPUs {
+			ow.Warnw("not enough GPUs on cluster", "resource", cfg.gpuResource(), "available_gpus", availableGPUs, "needed_gpus", neededGPUs)
+			return false, nil
+		}
+	}
+
 	for _, g := range groups {
 		var podCPU float64
 		if g.Resources.CPU != "" {
@@ -1016,13 +1890,16 @@ func (c *ClusterK8sRunner) TerminateAll(ctx context.Context, ow *rpc.OutputWrite
 		return fmt.Errorf("could not init pool: %w", err)
 	}
 
-	client := c.pool.Acquire()
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
 	defer c.pool.Release(client)
 
 	planPods := metav1.ListOptions{
 		LabelSelector: "testground.purpose=plan",
 	}
-	err := client.CoreV1().Pods("default").DeleteCollection(ctx, metav1.DeleteOptions{}, planPods)
+	err = client.CoreV1().Pods(c.config.Namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, planPods)
 	if err != nil {
 		ow.Errorw("could not terminate all pods", "err", err)
 		return err
@@ -1030,6 +1907,61 @@ func (c *ClusterK8sRunner) TerminateAll(ctx context.Context, ow *rpc.OutputWrite
 	return nil
 }
 
+// Prune removes all plan pods that have completed, either successfully or
+// not, and that were left behind because `keep_service` was set or because
+// the run was interrupted before cleanup.
+func (c *ClusterK8sRunner) Prune(ctx context.Context, ow *rpc.OutputWriter) (*api.PruneReport, error) {
+	if err := c.initPool(); err != nil {
+		return nil, fmt.Errorf("could not init pool: %w", err)
+	}
+
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.pool.Release(client)
+
+	report := new(api.PruneReport)
+
+	for _, phase := range []v1.PodPhase{v1.PodSucceeded, v1.PodFailed} {
+		res, err := client.CoreV1().Pods(c.config.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: "testground.purpose=plan",
+			FieldSelector: fmt.Sprintf("status.phase=%s", phase),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not list %s pods: %w", phase, err)
+		}
+
+		for _, p := range res.Items {
+			ow.Debugw("deleting pod", "pod", p.Name, "phase", phase)
+			err := client.CoreV1().Pods(c.config.Namespace).Delete(ctx, p.Name, metav1.DeleteOptions{})
+			if err != nil {
+				ow.Errorw("couldn't remove pod", "pod", p.Name, "err", err)
+				continue
+			}
+			report.Containers = append(report.Containers, p.Name)
+		}
+	}
+
+	ow.Infow("pruned completed plan pods", "count", len(report.Containers))
+
+	// the collect-outputs pod is created again by the next collection.
+	c.collectLk.Lock()
+	if c.collectors == 0 {
+		c.collectGen++
+		deleted, err := c.deleteCollectOutputsPod(ctx)
+		if err != nil {
+			ow.Errorw("couldn't remove collect-outputs pod", "err", err)
+		}
+		if deleted {
+			report.Containers = append(report.Containers, collectOutputsPodName)
+		}
+	}
+	c.collectLk.Unlock()
+
+	return report, nil
+}
+
 func (c *ClusterK8sRunner) pushImagesToDockerRegistry(ctx context.Context, ow *rpc.OutputWriter, in *api.RunInput) error {
 	cfg := *in.RunnerConfig.(*ClusterK8sRunnerConfig)
 
@@ -1094,23 +2026,28 @@ func (c *ClusterK8sRunner) pushImagesToDockerRegistry(ctx context.Context, ow *r
 }
 
 func (c *ClusterK8sRunner) createCollectOutputsPod(ctx context.Context, input *api.CollectionInput) error {
-	client := c.pool.Acquire()
+	client, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
 	defer c.pool.Release(client)
 
 	cfg := *input.RunnerConfig.(*ClusterK8sRunnerConfig)
 
-	collectOutputsCPU, err := resource.ParseQuantity(cfg.CollectOutputsPodCPU)
+	image, cpu, memory := collectOutputsPodSettings(cfg)
+
+	collectOutputsCPU, err := resource.ParseQuantity(cpu)
 	if err != nil {
-		return fmt.Errorf("couldn't parse default `collect` pod CPU request; make sure you have specified `collect_outputs_pod_cpu` in .env.toml; err: %w", err)
+		return fmt.Errorf("couldn't parse `collect` pod CPU request; check `collect_outputs_pod_cpu` in .env.toml; err: %w", err)
 	}
 
-	collectOutputsMemory, err := resource.ParseQuantity(cfg.CollectOutputsPodMemory)
+	collectOutputsMemory, err := resource.ParseQuantity(memory)
 	if err != nil {
-		return fmt.Errorf("couldn't parse default `collect` pod Memory request; make sure you have specified `collect_outputs_pod_memory` in .env.toml; err: %w", err)
+		return fmt.Errorf("couldn't parse `collect` pod Memory request; check `collect_outputs_pod_memory` in .env.toml; err: %w", err)
 	}
 
 	mountPropagationMode := v1.MountPropagationHostToContainer
-	sharedVolumeName := "efs-shared"
+	claimName, sharedVolumeName, outputsPath := cfg.outputsVolume()
 
 	podRequest := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1118,7 +2055,7 @@ func (c *ClusterK8sRunner) createCollectOutputsPod(ctx context.Context, input *a
 			Labels: map[string]string{
 				"testground.purpose": "outputs",
 			},
-			Annotations: map[string]string{"cni": defaultK8sNetworkAnnotation, "k8s.v1.cni.cncf.io/networks": "weave"},
+			Annotations: podNetworkAnnotations(cfg),
 		},
 		Spec: v1.PodSpec{
 			Volumes: []v1.Volume{
@@ -1126,7 +2063,7 @@ func (c *ClusterK8sRunner) createCollectOutputsPod(ctx context.Context, input *a
 					Name: sharedVolumeName,
 					VolumeSource: v1.VolumeSource{
 						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
-							ClaimName: "efs",
+							ClaimName: claimName,
 						},
 					},
 				},
@@ -1138,13 +2075,13 @@ func (c *ClusterK8sRunner) createCollectOutputsPod(ctx context.Context, input *a
 			Containers: []v1.Container{
 				{
 					Name:    "collect-outputs",
-					Image:   "busybox",
+					Image:   image,
 					Args:    []string{"-c", "sleep 999999999"},
 					Command: []string{"sh"},
 					VolumeMounts: []v1.VolumeMount{
 						{
 							Name:             sharedVolumeName,
-							MountPath:        "/outputs",
+							MountPath:        outputsPath,
 							MountPropagation: &mountPropagationMode,
 						},
 					},
@@ -1166,12 +2103,61 @@ func (c *ClusterK8sRunner) createCollectOutputsPod(ctx context.Context, input *a
 	return err
 }
 
+// collectOutputsCommand returns the command archiving the outputs of a run in
+// the collect-outputs pod, writing the archive to stdout and its SHA-256 to
+// stderr. The configuration must have been validated.
+func collectOutputsCommand(cfg ClusterK8sRunnerConfig, runID string) []string {
+	_, _, outputsPath := cfg.outputsVolume()
+	archive := `tar -C ` + outputsPath + ` -czf - "$1"`
+	if cfg.CollectCompression == "pigz" {
+		_, cpu, _ := collectOutputsPodSettings(cfg)
+		threads := int64(1)
+		if q, err := resource.ParseQuantity(cpu); err == nil {
+			threads = q.Value() // rounds up to whole CPUs.
+		}
+		archive = `tar -C ` + outputsPath + ` -cf - "$1" | pigz -c -p ` + strconv.FormatInt(threads, 10)
+	}
+
+	// the checksum is computed alongside the archive, through a fifo. The
+	// run id is passed as a positional parameter, rather than interpolated
+	// into the script.
+	script := strings.Join([]string{
+		`set -eo pipefail`,
+		`fifo=$(mktemp -u)`,
+		`mkfifo "$fifo"`,
+		`trap 'rm -f "$fifo"' EXIT`,
+		`sha256sum < "$fifo" >&2 &`,
+		archive + ` | tee "$fifo"`,
+		`wait $!`,
+	}, "\n")
+	return []string{"sh", "-c", script, "sh", runID}
+}
+
+// collectOutputsPodSettings returns the image, and the CPU and memory
+// requests of the collect-outputs pod, falling back to the defaults.
+func collectOutputsPodSettings(cfg ClusterK8sRunnerConfig) (image, cpu, memory string) {
+	image, cpu, memory = cfg.CollectOutputsPodImage, cfg.CollectOutputsPodCPU, cfg.CollectOutputsPodMemory
+	if image == "" {
+		image = defaultCollectOutputsPodImage
+	}
+	if cpu == "" {
+		cpu = defaultCollectOutputsPodCPU
+	}
+	if memory == "" {
+		memory = defaultCollectOutputsPodMemory
+	}
+	return image, cpu, memory
+}
+
 func (c *ClusterK8sRunner) GetClusterCapacity() (int64, int64, error) {
 	if err := c.initPool(); err != nil {
 		return -1, -1, fmt.Errorf("could not init pool: %w", err)
 	}
 
-	client := c.pool.Acquire()
+	client, err := c.pool.Acquire(context.TODO())
+	if err != nil {
+		return -1, -1, err
+	}
 	defer c.pool.Release(client)
 
 	res, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/rpc"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Modes of loading images onto the nodes of local clusters.
+const (
+	// LoadImagesAuto detects a kind or minikube cluster from the current
+	// context of the kubeconfig.
+	LoadImagesAuto = "auto"
+	// LoadImagesKind loads images with `kind load docker-image`.
+	LoadImagesKind = "kind"
+	// LoadImagesMinikube loads images with `minikube image load`.
+	LoadImagesMinikube = "minikube"
+)
+
+// kindContextPrefix prefixes the names of the kubeconfig contexts of kind
+// clusters.
+const kindContextPrefix = "kind-"
+
+// imageLoader loads images onto the nodes of a local cluster, with the CLI of
+// the tool that manages it.
+type imageLoader struct {
+	tool    string // kind or minikube
+	cluster string // the name of the kind cluster, or the minikube profile
+}
+
+// newImageLoader returns the loader for the mode, of the cluster with the
+// supplied name, or else the cluster of the kubeconfig context currentContext.
+func newImageLoader(mode, cluster, currentContext string) (imageLoader, error) {
+	if mode == LoadImagesAuto {
+		switch {
+		case strings.HasPrefix(currentContext, kindContextPrefix):
+			mode = LoadImagesKind
+		case currentContext == "minikube":
+			mode = LoadImagesMinikube
+		default:
+			return imageLoader{}, fmt.Errorf("cannot detect a kind or minikube cluster from the kubeconfig context %q; set load_images to %s or %s", currentContext, LoadImagesKind, LoadImagesMinikube)
+		}
+	}
+
+	switch {
+	case cluster != "":
+	case mode == LoadImagesKind && strings.HasPrefix(currentContext, kindContextPrefix):
+		cluster = strings.TrimPrefix(currentContext, kindContextPrefix)
+	case mode == LoadImagesKind:
+		cluster = "kind"
+	case mode == LoadImagesMinikube:
+		cluster = "minikube"
+	}
+
+	switch mode {
+	case LoadImagesKind, LoadImagesMinikube:
+		return imageLoader{tool: mode, cluster: cluster}, nil
+	default:
+		return imageLoader{}, fmt.Errorf("unsupported load_images mode: %q; values: %s, %s, %s", mode, LoadImagesAuto, LoadImagesKind, LoadImagesMinikube)
+	}
+}
+
+// command returns the command that loads image onto the nodes.
+func (l imageLoader) command(image string) []string {
+	if l.tool == LoadImagesMinikube {
+		return []string{"minikube", "image", "load", image, "--profile", l.cluster}
+	}
+	return []string{"kind", "load", "docker-image", image, "--name", l.cluster}
+}
+
+// loadImagesOntoNodes loads the images of the groups of a run, from the local
+// docker daemon onto the nodes of a local cluster, and replaces their artifact
+// paths by the loaded images.
+func (c *ClusterK8sRunner) loadImagesOntoNodes(ctx context.Context, ow *rpc.OutputWriter, in *api.RunInput, cfg ClusterK8sRunnerConfig) error {
+	if c.config.InCluster {
+		return fmt.Errorf("cannot load images onto the nodes from inside the cluster; push them to a registry instead")
+	}
+
+	var currentContext string
+	if cfg.LoadImages == LoadImagesAuto || cfg.LoadImagesCluster == "" {
+		kubeconfig, err := clientcmd.LoadFromFile(c.config.KubeConfigPath)
+		if err != nil {
+			return fmt.Errorf("failed to read kubeconfig: %w", err)
+		}
+		currentContext = kubeconfig.CurrentContext
+	}
+
+	loader, err := newImageLoader(cfg.LoadImages, cfg.LoadImagesCluster, currentContext)
+	if err != nil {
+		return err
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create docker client: %w", err)
+	}
+	defer cli.Close()
+
+	loaded := make(map[string]string, len(in.Groups))
+	for _, g := range in.Groups {
+		if image, ok := loaded[g.ArtifactPath]; ok {
+			g.ArtifactPath = image
+			continue
+		}
+
+		// images are referred to by tag, as nodes don't know them by the ID
+		// the builders return.
+		image := loadedImageTag(in.TestPlan, g.ArtifactPath)
+		if err := cli.ImageTag(ctx, g.ArtifactPath, image); err != nil {
+			return fmt.Errorf("failed to tag image %s: %w", g.ArtifactPath, err)
+		}
+
+		ow.Infow("loading image onto the nodes", "group_id", g.ID, "image", image, "tool", loader.tool, "cluster", loader.cluster)
+		args := loader.command(image)
+		if out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to load image %s onto %s cluster %s: %w; output: %s", image, loader.tool, loader.cluster, err, strings.TrimSpace(string(out)))
+		}
+
+		loaded[g.ArtifactPath] = image
+		g.ArtifactPath = image
+	}
+	return nil
+}
+
+// loadedImageTag returns the tag an image is loaded onto the nodes with. It
+// matches the tag the docker builders give images.
+func loadedImageTag(plan, artifact string) string {
+	return fmt.Sprintf("tg-plan-%s:%s", plan, artifact)
+}
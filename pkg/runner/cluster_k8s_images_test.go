@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewImageLoader(t *testing.T) {
+	cases := []struct {
+		mode, cluster, context string
+		want                   imageLoader
+	}{
+		{LoadImagesAuto, "", "kind-dev", imageLoader{LoadImagesKind, "dev"}},
+		{LoadImagesAuto, "", "minikube", imageLoader{LoadImagesMinikube, "minikube"}},
+		{LoadImagesAuto, "other", "kind-dev", imageLoader{LoadImagesKind, "other"}},
+		{LoadImagesKind, "", "docker-desktop", imageLoader{LoadImagesKind, "kind"}},
+		{LoadImagesMinikube, "profile", "", imageLoader{LoadImagesMinikube, "profile"}},
+	}
+	for _, c := range cases {
+		got, err := newImageLoader(c.mode, c.cluster, c.context)
+		if err != nil {
+			t.Errorf("%s/%q/%q: unexpected error: %v", c.mode, c.cluster, c.context, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s/%q/%q: got %+v; want %+v", c.mode, c.cluster, c.context, got, c.want)
+		}
+	}
+
+	if _, err := newImageLoader(LoadImagesAuto, "", "arn:aws:eks:us-west-2:1:cluster/tg"); err == nil {
+		t.Error("expected no local cluster to be detected from an EKS context")
+	}
+	if _, err := newImageLoader("docker-desktop", "", ""); err == nil {
+		t.Error("expected an unsupported mode to be rejected")
+	}
+}
+
+func TestImageLoaderCommand(t *testing.T) {
+	kind := imageLoader{LoadImagesKind, "dev"}
+	if got, want := kind.command("tg-plan-p:abc"), []string{"kind", "load", "docker-image", "tg-plan-p:abc", "--name", "dev"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	minikube := imageLoader{LoadImagesMinikube, "minikube"}
+	if got, want := minikube.command("tg-plan-p:abc"), []string{"minikube", "image", "load", "tg-plan-p:abc", "--profile", "minikube"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestValidateLoadImages(t *testing.T) {
+	cfg := ClusterK8sRunnerConfig{LoadImages: "docker-desktop"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an unsupported mode to be rejected")
+	}
+
+	cfg = ClusterK8sRunnerConfig{LoadImages: LoadImagesAuto, Provider: "aws"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected loading images and a provider to be rejected")
+	}
+
+	cfg = ClusterK8sRunnerConfig{LoadImages: LoadImagesAuto}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podPhaseTracker counts the pods of a run by phase, incrementally from the
+// events of an informer on them.
+type podPhaseTracker struct {
+	mu     sync.Mutex
+	phases map[string]v1.PodPhase // by pod name
+	counts map[v1.PodPhase]int
+
+	// failed holds the pods that failed since the last snapshot; reported
+	// the names of all the pods that failed, so that they're reported once.
+	failed   []*v1.Pod
+	reported map[string]struct{}
+
+	// changed is signalled whenever the counts change.
+	changed chan struct{}
+}
+
+func newPodPhaseTracker() *podPhaseTracker {
+	return &podPhaseTracker{
+		phases:   make(map[string]v1.PodPhase),
+		counts:   make(map[v1.PodPhase]int),
+		reported: make(map[string]struct{}),
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// watchPodPhases starts an informer on the pods of the run runID, feeding a
+// tracker, and returns the tracker once the informer has synced. The informer
+// stops when ctx is done.
+func watchPodPhases(ctx context.Context, client kubernetes.Interface, namespace string, runID string) (*podPhaseTracker, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = fmt.Sprintf("testground.run_id=%s", runID)
+		}),
+	)
+	informer := factory.Core().V1().Pods().Informer()
+
+	tracker := newPodPhaseTracker()
+	informer.AddEventHandler(tracker.handlers())
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return nil, fmt.Errorf("pods informer did not sync: %w", ctx.Err())
+	}
+	return tracker, nil
+}
+
+// handlers returns the informer event handlers feeding the tracker.
+func (t *podPhaseTracker) handlers() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*v1.Pod); ok {
+				t.update(pod)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if pod, ok := obj.(*v1.Pod); ok {
+				t.update(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			switch o := obj.(type) {
+			case *v1.Pod:
+				t.remove(o.Name)
+			case cache.DeletedFinalStateUnknown:
+				if pod, ok := o.Obj.(*v1.Pod); ok {
+					t.remove(pod.Name)
+				}
+			}
+		},
+	}
+}
+
+// update records the current phase of pod.
+func (t *podPhaseTracker) update(pod *v1.Pod) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	phase := pod.Status.Phase
+	old, seen := t.phases[pod.Name]
+	if seen && old == phase {
+		return
+	}
+	if seen {
+		t.counts[old]--
+	}
+	t.phases[pod.Name] = phase
+	t.counts[phase]++
+
+	if _, ok := t.reported[pod.Name]; phase == v1.PodFailed && !ok {
+		t.reported[pod.Name] = struct{}{}
+		t.failed = append(t.failed, pod)
+	}
+	t.signal()
+}
+
+// remove forgets the pod with the supplied name.
+func (t *podPhaseTracker) remove(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	phase, ok := t.phases[name]
+	if !ok {
+		return
+	}
+	delete(t.phases, name)
+	t.counts[phase]--
+	t.signal()
+}
+
+// snapshot returns the current counts by phase, and the pods that failed
+// since the last snapshot.
+func (t *podPhaseTracker) snapshot() (counts map[v1.PodPhase]int, failed []*v1.Pod) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counts = make(map[v1.PodPhase]int, len(t.counts))
+	for phase, n := range t.counts {
+		counts[phase] = n
+	}
+	failed, t.failed = t.failed, nil
+	return counts, failed
+}
+
+// signal notifies a change, without blocking; changes are coalesced until
+// they're received.
+func (t *podPhaseTracker) signal() {
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodPhaseTracker(t *testing.T) {
+	pod := func(name string, phase v1.PodPhase) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}, Status: v1.PodStatus{Phase: phase}}
+	}
+
+	tracker := newPodPhaseTracker()
+	h := tracker.handlers()
+
+	h.OnAdd(pod("a", v1.PodPending))
+	h.OnAdd(pod("b", v1.PodPending))
+	h.OnUpdate(nil, pod("a", v1.PodRunning))
+	h.OnUpdate(nil, pod("b", v1.PodFailed))
+	h.OnUpdate(nil, pod("b", v1.PodFailed))
+
+	select {
+	case <-tracker.changed:
+	default:
+		t.Error("expected a change to be signalled")
+	}
+
+	counts, failed := tracker.snapshot()
+	if counts[v1.PodRunning] != 1 || counts[v1.PodFailed] != 1 || counts[v1.PodPending] != 0 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+	if len(failed) != 1 || failed[0].Name != "b" {
+		t.Errorf("expected pod b to be reported as failed, got %v", failed)
+	}
+	if _, failed = tracker.snapshot(); len(failed) != 0 {
+		t.Errorf("expected failed pods to be reported once, got %v", failed)
+	}
+
+	h.OnDelete(pod("a", v1.PodRunning))
+	h.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/b", Obj: pod("b", v1.PodFailed)})
+	if counts, _ = tracker.snapshot(); counts[v1.PodRunning] != 0 || counts[v1.PodFailed] != 0 {
+		t.Errorf("expected deleted pods to be forgotten, got %v", counts)
+	}
+}
@@ -0,0 +1,312 @@
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/testground/testground/pkg/api"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// TestClusterK8sNamespace guards against API calls that ignore the configured
+// namespace.
+func TestClusterK8sNamespace(t *testing.T) {
+	src, err := ioutil.ReadFile("cluster_k8s.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`(Namespace|Pods|Events)\(\s*"[^"]*"\s*\)`)
+	for _, m := range re.FindAll(src, -1) {
+		t.Errorf("literal namespace in k8s API call: %s", m)
+	}
+}
+
+func TestCollectOutputsPodSettings(t *testing.T) {
+	image, cpu, memory := collectOutputsPodSettings(ClusterK8sRunnerConfig{})
+	if image != defaultCollectOutputsPodImage || cpu != defaultCollectOutputsPodCPU || memory != defaultCollectOutputsPodMemory {
+		t.Errorf("expected defaults; got %s, %s, %s", image, cpu, memory)
+	}
+
+	image, cpu, memory = collectOutputsPodSettings(ClusterK8sRunnerConfig{
+		CollectOutputsPodImage:  "pigz",
+		CollectOutputsPodCPU:    "4",
+		CollectOutputsPodMemory: "256Mi",
+	})
+	if image != "pigz" || cpu != "4" || memory != "256Mi" {
+		t.Errorf("expected the configured settings; got %s, %s, %s", image, cpu, memory)
+	}
+}
+
+func TestCollectOutputsCommand(t *testing.T) {
+	cmd := collectOutputsCommand(ClusterK8sRunnerConfig{}, "run1")
+	if len(cmd) != 5 || cmd[0] != "sh" || cmd[4] != "run1" {
+		t.Fatalf("unexpected command: %q", cmd)
+	}
+	if !strings.Contains(cmd[2], `tar -C /outputs -czf - "$1" | tee "$fifo"`) {
+		t.Errorf("unexpected gzip script: %s", cmd[2])
+	}
+
+	cmd = collectOutputsCommand(ClusterK8sRunnerConfig{CollectCompression: "pigz", CollectOutputsPodCPU: "1500m"}, "run1")
+	if !strings.Contains(cmd[2], "pigz -c -p 2 | tee") {
+		t.Errorf("unexpected pigz script: %s", cmd[2])
+	}
+
+	cmd = collectOutputsCommand(ClusterK8sRunnerConfig{OutputsMountPath: "/mnt/cephfs"}, "run1")
+	if !strings.Contains(cmd[2], `tar -C /mnt/cephfs -czf - "$1"`) {
+		t.Errorf("unexpected script for a custom outputs mount path: %s", cmd[2])
+	}
+}
+
+func TestOutputsVolume(t *testing.T) {
+	cfg := ClusterK8sRunnerConfig{}
+	if claim, volume, mountPath := cfg.outputsVolume(); claim != "efs" || volume != "efs-shared" || mountPath != "/outputs" {
+		t.Errorf("unexpected default outputs volume: %s, %s, %s", claim, volume, mountPath)
+	}
+
+	cfg = ClusterK8sRunnerConfig{OutputsClaimName: "cephfs", OutputsMountPath: "/mnt/outputs"}
+	if claim, volume, mountPath := cfg.outputsVolume(); claim != "cephfs" || volume != "efs-shared" || mountPath != "/mnt/outputs" {
+		t.Errorf("unexpected outputs volume: %s, %s, %s", claim, volume, mountPath)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	for _, p := range []string{"outputs", "/mnt/out puts", "/mnt/$(reboot)"} {
+		cfg.OutputsMountPath = p
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected outputs mount path %q to be invalid", p)
+		}
+	}
+}
+
+func TestVerifyOutputsChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("archive"))
+	line := hex.EncodeToString(sum[:]) + "  -\n"
+
+	if err := verifyOutputsChecksum("tar: removing leading '/'\n"+line, sum[:]); err != nil {
+		t.Errorf("expected a match; got %v", err)
+	}
+
+	truncated := sha256.Sum256([]byte("arch"))
+	if err := verifyOutputsChecksum(line, truncated[:]); !errors.Is(err, ErrCorruptOutputs) {
+		t.Errorf("expected a mismatch; got %v", err)
+	}
+
+	if err := verifyOutputsChecksum("", sum[:]); !errors.Is(err, ErrCorruptOutputs) {
+		t.Errorf("expected an error without a checksum; got %v", err)
+	}
+}
+
+func TestCreateWithLimiter(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	l := newAdaptiveLimiter(8, 8)
+
+	// throttled creations are retried, and lower the concurrency.
+	var calls int
+	err := createWithLimiter(context.Background(), l, func() error {
+		if calls++; calls == 1 {
+			return k8serrors.NewTooManyRequests("slow down", 0)
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("expected success after a throttled creation, got %d calls and err %v", calls, err)
+	}
+	if got := l.current(); got != 4 {
+		t.Errorf("expected the concurrency to be halved to 4, got %d", got)
+	}
+
+	// a pod created by an attempt that timed out already exists on retry.
+	calls = 0
+	err = createWithLimiter(context.Background(), l, func() error {
+		if calls++; calls == 1 {
+			return k8serrors.NewServerTimeout(pods, "create", 0)
+		}
+		return k8serrors.NewAlreadyExists(pods, "tg-plan-0")
+	})
+	if err != nil {
+		t.Errorf("expected an existing pod on retry to be a success, got %v", err)
+	}
+
+	// other errors are not retried.
+	calls = 0
+	err = createWithLimiter(context.Background(), l, func() error {
+		calls++
+		return k8serrors.NewAlreadyExists(pods, "tg-plan-0")
+	})
+	if !k8serrors.IsAlreadyExists(err) || calls != 1 {
+		t.Errorf("expected an existing pod not to be retried, got %d calls and err %v", calls, err)
+	}
+}
+
+func TestPodCreationConcurrency(t *testing.T) {
+	cfg := ClusterK8sRunnerConfig{}
+	if initial, max := cfg.podCreationConcurrency(); initial != defaultPodCreationConcurrency || max != initial {
+		t.Errorf("expected the default concurrency without ramp-up, got %d, %d", initial, max)
+	}
+
+	cfg = ClusterK8sRunnerConfig{PodCreationConcurrency: 50, PodCreationMaxConcurrency: 20}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected a maximum below the initial concurrency to be invalid")
+	}
+	cfg.PodCreationMaxConcurrency = 200
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestParseGPUs(t *testing.T) {
+	for gpu, valid := range map[string]bool{"": true, "2": true, "0": true, "1.5": false, "500m": false, "-1": false, "many": false} {
+		_, err := parseGPUs(api.Resources{GPU: gpu})
+		if valid && err != nil {
+			t.Errorf("expected gpu %q to be valid, got %s", gpu, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected gpu %q to be invalid", gpu)
+		}
+	}
+}
+
+func TestRequestGPUs(t *testing.T) {
+	pod := &v1.Pod{Spec: v1.PodSpec{
+		Containers: []v1.Container{{Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+			Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+		}}},
+		NodeSelector: map[string]string{"testground.node.role.plan": "true"},
+	}}
+	cfg := ClusterK8sRunnerConfig{GPUNodeSelector: map[string]string{"accelerator": "nvidia"}}
+
+	requestGPUs(pod, cfg, resource.MustParse("2"))
+
+	res := pod.Spec.Containers[0].Resources
+	if q := res.Requests["nvidia.com/gpu"]; q.Value() != 2 {
+		t.Errorf("expected 2 GPUs requested, got %s", q.String())
+	}
+	if q := res.Limits["nvidia.com/gpu"]; q.Value() != 2 {
+		t.Errorf("expected a limit of 2 GPUs, got %s", q.String())
+	}
+	if pod.Spec.NodeSelector["accelerator"] != "nvidia" || pod.Spec.NodeSelector["testground.node.role.plan"] != "true" {
+		t.Errorf("expected the GPU node selector to be added, got %v", pod.Spec.NodeSelector)
+	}
+}
+
+func TestClusterGPUs(t *testing.T) {
+	node := func(gpus string, labels map[string]string) v1.Node {
+		return v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels},
+			Status:     v1.NodeStatus{Allocatable: v1.ResourceList{"nvidia.com/gpu": resource.MustParse(gpus)}},
+		}
+	}
+	nodes := []v1.Node{
+		node("4", map[string]string{"accelerator": "nvidia"}),
+		node("2", map[string]string{"accelerator": "nvidia"}),
+		node("8", nil),
+	}
+
+	if n := clusterGPUs(nodes, "nvidia.com/gpu", nil); n != 14 {
+		t.Errorf("expected 14 GPUs, got %d", n)
+	}
+	if n := clusterGPUs(nodes, "nvidia.com/gpu", map[string]string{"accelerator": "nvidia"}); n != 6 {
+		t.Errorf("expected 6 GPUs on the selected nodes, got %d", n)
+	}
+	if n := clusterGPUs(nodes, "amd.com/gpu", nil); n != 0 {
+		t.Errorf("expected no GPUs of another resource, got %d", n)
+	}
+}
+
+func TestPlanInitContainers(t *testing.T) {
+	env := []v1.EnvVar{{Name: "TEST_OUTPUTS_PATH", Value: "/outputs/run1/single/0"}}
+	mounts := []v1.VolumeMount{{Name: "efs-shared", MountPath: "/outputs"}}
+	ics := []api.InitContainer{
+		{Name: "fetch-fixtures", Image: "busybox", Command: []string{"wget", "http://fixtures/data"}},
+		{Name: "warm-cache", Image: "redis", Command: []string{"true"}},
+	}
+
+	ctrs := planInitContainers(ics, env, mounts)
+	if len(ctrs) != 2 || ctrs[0].Name != "fetch-fixtures" || ctrs[1].Name != "warm-cache" {
+		t.Fatalf("expected the init containers in order, got %v", ctrs)
+	}
+	for _, c := range ctrs {
+		if len(c.Env) != 1 || len(c.VolumeMounts) != 1 {
+			t.Errorf("expected init container %s to share the env and volumes of the instance", c.Name)
+		}
+	}
+	if ctrs[0].Image != "busybox" || len(ctrs[0].Command) != 2 {
+		t.Errorf("unexpected init container: %v", ctrs[0])
+	}
+}
+
+func TestPodNetworkAnnotations(t *testing.T) {
+	ann := podNetworkAnnotations(ClusterK8sRunnerConfig{})
+	if ann["cni"] != "aws-cni" || ann["k8s.v1.cni.cncf.io/networks"] != "weave" {
+		t.Errorf("unexpected default annotations: %v", ann)
+	}
+
+	ann = podNetworkAnnotations(ClusterK8sRunnerConfig{DisableSidecar: true})
+	if _, ok := ann["k8s.v1.cni.cncf.io/networks"]; ok || ann["cni"] != "aws-cni" {
+		t.Errorf("expected no data network without the sidecar, got %v", ann)
+	}
+}
+
+func TestImagePullSecrets(t *testing.T) {
+	if refs := imagePullSecrets(nil); refs != nil {
+		t.Errorf("expected no references, got %v", refs)
+	}
+
+	refs := imagePullSecrets([]string{"regcred", "ecr"})
+	if len(refs) != 2 || refs[0].Name != "regcred" || refs[1].Name != "ecr" {
+		t.Errorf("unexpected references: %v", refs)
+	}
+
+	cfg := ClusterK8sRunnerConfig{ImagePullSecrets: []string{"regcred", ""}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an empty secret name to be rejected")
+	}
+}
+
+func TestImagePullPolicy(t *testing.T) {
+	cases := []struct {
+		cfg  ClusterK8sRunnerConfig
+		want v1.PullPolicy
+	}{
+		{ClusterK8sRunnerConfig{}, v1.PullIfNotPresent},
+		{ClusterK8sRunnerConfig{Provider: "aws"}, v1.PullAlways},
+		{ClusterK8sRunnerConfig{Provider: "aws", ImagePullPolicy: PullPolicyIfNotPresent}, v1.PullIfNotPresent},
+		{ClusterK8sRunnerConfig{ImagePullPolicy: PullPolicyNever}, v1.PullNever},
+		{ClusterK8sRunnerConfig{LoadImages: LoadImagesKind}, v1.PullNever},
+	}
+	for _, c := range cases {
+		if got := c.cfg.imagePullPolicy(); got != c.want {
+			t.Errorf("imagePullPolicy() of %+v = %s; want %s", c.cfg, got, c.want)
+		}
+	}
+
+	cfg := ClusterK8sRunnerConfig{ImagePullPolicy: "Sometimes"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an unsupported pull policy to be rejected")
+	}
+}
+
+func TestPodHostname(t *testing.T) {
+	if got := podHostname("tg-ping-c1a2b3-single-0"); got != "tg-ping-c1a2b3-single-0" {
+		t.Errorf("expected short pod names to be kept, got %s", got)
+	}
+
+	// the 63rd character is a dash, which the kubelet trims.
+	name := "tg-" + strings.Repeat("a", 59) + "-single-0"
+	if got, want := podHostname(name), "tg-"+strings.Repeat("a", 59); got != want {
+		t.Errorf("podHostname(%s) = %s; want %s", name, got, want)
+	}
+}
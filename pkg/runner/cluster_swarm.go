@@ -90,6 +90,7 @@ func (*ClusterSwarmRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 		TestInstanceCount:  input.TotalInstances,
 		TestDisableMetrics: input.DisableMetrics,
 		TestSidecar:        true,
+		TestStartTime:      input.StartTime,
 	}
 
 	// Create a docker client.
@@ -192,15 +193,21 @@ func (*ClusterSwarmRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 	ow.Infof("fetched an authorization token from AWS ECR")
 
 	services := make(map[string]int, len(input.Groups))
+	scheduled := time.Now()
 	for _, g := range input.Groups {
 		runenv := template
 		runenv.TestGroupID = g.ID
 		runenv.TestGroupInstanceCount = g.Instances
 		runenv.TestInstanceParams = g.Parameters
 		runenv.TestCaptureProfiles = g.Profiles
+		runenv.TestInstanceRole = g.Role
 
 		// Serialize the runenv into env variables to pass to docker.
 		env := conv.ToOptionsSlice(runenv.ToEnvVars())
+		env = append(env, fmt.Sprintf("%s=%s", EnvTestRequestID, input.RequestID))
+		env = append(env, conv.ToOptionsSlice(topologyEnvVars(input, g))...)
+		// the replicas of a service are all created at once.
+		env = append(env, conv.ToOptionsSlice(lifecycleEnvVars(input.StartTime, scheduled, time.Now()))...)
 
 		// Set the log level if provided in cfg.
 		if cfg.LogLevel != "" {
@@ -227,10 +234,11 @@ func (*ClusterSwarmRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc
 					Image: g.ArtifactPath,
 					Env:   env,
 					Labels: map[string]string{
-						"testground.plan":     input.TestPlan,
-						"testground.testcase": input.TestCase,
-						"testground.run_id":   input.RunID,
-						"testground.groupid":  g.ID,
+						"testground.plan":       input.TestPlan,
+						"testground.testcase":   input.TestCase,
+						"testground.run_id":     input.RunID,
+						"testground.groupid":    g.ID,
+						"testground.request_id": input.RequestID,
 					},
 				},
 				RestartPolicy: &swarm.RestartPolicy{
@@ -387,19 +395,3 @@ func (*ClusterSwarmRunner) ConfigType() reflect.Type {
 func (*ClusterSwarmRunner) CompatibleBuilders() []string {
 	return []string{"docker:go"}
 }
-
-func retry(attempts int, sleep time.Duration, f func() error) (err error) {
-	for i := 0; ; i++ {
-		err = f()
-		if err == nil {
-			return
-		}
-
-		if i >= (attempts - 1) {
-			break
-		}
-
-		time.Sleep(sleep)
-	}
-	return fmt.Errorf("after %d attempts, last error: %s", attempts, err)
-}
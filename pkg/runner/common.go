@@ -4,27 +4,224 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
 	"github.com/testground/testground/pkg/rpc"
+
+	"github.com/docker/docker/api/types/network"
+	"github.com/logrusorgru/aurora"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 // Use consistent IP address ranges for both the data and the control subnet.
 // This range was selected as it's specifically set aside for testing and
 // shouldn't conflict with any real networks.
+// The control subnet can be overridden through the daemon configuration, for
+// hosts where it collides with an existing network (e.g. a VPN).
 var (
 	controlSubnet  = "192.18.0.0/16"
 	controlGateway = "192.18.0.1"
 )
 
+// EnvConfigSetter is implemented by runners with settings that apply to all
+// runs, so that the engine can hand them the env configuration on start and
+// on reload.
+type EnvConfigSetter interface {
+	SetEnvConfig(config.EnvConfig)
+}
+
+// controlNetworkIPAM returns the IPAM config of the control network, as
+// configured in the daemon configuration. When only the subnet is set, the
+// gateway is the first address in the subnet.
+func controlNetworkIPAM(cfg config.DaemonConfig) (network.IPAMConfig, error) {
+	if cfg.ControlSubnet == "" {
+		return network.IPAMConfig{Subnet: controlSubnet, Gateway: controlGateway}, nil
+	}
+
+	ip, subnet, err := net.ParseCIDR(cfg.ControlSubnet)
+	if err != nil {
+		return network.IPAMConfig{}, fmt.Errorf("invalid control subnet %q: %w", cfg.ControlSubnet, err)
+	}
+	if ip.To4() == nil {
+		return network.IPAMConfig{}, fmt.Errorf("invalid control subnet %q: only IPv4 is supported", cfg.ControlSubnet)
+	}
+
+	gateway := cfg.ControlGateway
+	if gateway == "" {
+		gw := make(net.IP, len(subnet.IP.To4()))
+		copy(gw, subnet.IP.To4())
+		gw[3]++
+		gateway = gw.String()
+	} else if gw := net.ParseIP(gateway); gw == nil || !subnet.Contains(gw) {
+		return network.IPAMConfig{}, fmt.Errorf("control gateway %q is not within control subnet %s", gateway, subnet)
+	}
+	return network.IPAMConfig{Subnet: subnet.String(), Gateway: gateway}, nil
+}
+
+// Environment variables that identify an instance within its run. They are
+// exported to plans alongside the runtime.RunParams.
+const (
+	// EnvTestGroupInstanceSeq is the ordinal of the instance within its
+	// group, starting at 0.
+	EnvTestGroupInstanceSeq = "TEST_GROUP_INSTANCE_SEQ"
+	// EnvTestInstanceIndex is the ordinal of the instance within the run,
+	// across all groups in composition order, starting at 0.
+	EnvTestInstanceIndex = "TEST_INSTANCE_INDEX"
+	// EnvTestRunSeed is a seed shared by all instances of a run, from which
+	// they can derive reproducible randomness, e.g. by combining it with
+	// their EnvTestInstanceIndex. The SDK does not provide a helper for
+	// this yet, so plans read it themselves.
+	EnvTestRunSeed = "TEST_RUN_SEED"
+	// EnvTestRequestID is the ID of the daemon request that created the run,
+	// which instances and sidecars can log to correlate with the daemon.
+	EnvTestRequestID = "TEST_REQUEST_ID"
+)
+
+// Environment variables describing the topology of a run. The SDK does not
+// resolve the peers of an instance by group from them yet, so plans parse
+// them and exchange addresses over the sync service themselves.
+const (
+	// EnvTestRunGroups lists the groups of the run, with their number of
+	// instances and their role, as a JSON array of topologyGroup.
+	EnvTestRunGroups = "TEST_RUN_GROUPS"
+	// EnvTestGroupDials is the comma-separated IDs of the groups that the
+	// instances of the group connect to.
+	EnvTestGroupDials = "TEST_GROUP_DIALS"
+)
+
+// topologyGroup is the description of a group in EnvTestRunGroups.
+type topologyGroup struct {
+	ID        string `json:"id"`
+	Instances int    `json:"instances"`
+	Role      string `json:"role,omitempty"`
+}
+
+// topologyEnvVars returns the environment variables describing the topology
+// of the run to the instances of group g.
+func topologyEnvVars(input *api.RunInput, g *api.RunGroup) map[string]string {
+	groups := make([]topologyGroup, 0, len(input.Groups))
+	for _, grp := range input.Groups {
+		groups = append(groups, topologyGroup{ID: grp.ID, Instances: grp.Instances, Role: grp.Role})
+	}
+	b, _ := json.Marshal(groups)
+	return map[string]string{
+		EnvTestRunGroups:  string(b),
+		EnvTestGroupDials: strings.Join(g.Dials, ","),
+	}
+}
+
+// Environment variables carrying the lifecycle timestamps of an instance, in
+// the RFC 3339 format with nanoseconds. Every runner sets them at the same
+// points, so that time-to-start measurements are comparable across runners.
+// There is no container_started timestamp: the time an instance started is
+// for the instance to take when its process begins. The SDK does not expose
+// these yet, so plans read them from the environment.
+const (
+	// EnvTestAdmittedTime is the time the engine admitted the run, which is
+	// also the TestStartTime of the runtime.RunParams.
+	EnvTestAdmittedTime = "TEST_ADMITTED_TIME"
+	// EnvTestScheduledTime is the time the runner began creating the
+	// instances of the run, once done preparing, e.g. networks or images.
+	EnvTestScheduledTime = "TEST_SCHEDULED_TIME"
+	// EnvTestContainerCreatedTime is the time the runner requested the
+	// creation of the container, pod or process of the instance.
+	EnvTestContainerCreatedTime = "TEST_CONTAINER_CREATED_TIME"
+)
+
+// runSeed derives the seed of a run from its ID, so that re-running the same
+// task reproduces the same randomness.
+func runSeed(runID string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(runID))
+	return int64(h.Sum64())
+}
+
+// instanceEnvVars returns the environment variables identifying the instance
+// with the supplied ordinals.
+func instanceEnvVars(groupSeq int, index int) map[string]string {
+	return map[string]string{
+		EnvTestGroupInstanceSeq: strconv.Itoa(groupSeq),
+		EnvTestInstanceIndex:    strconv.Itoa(index),
+	}
+}
+
+// lifecycleEnvVars returns the environment variables carrying the lifecycle
+// timestamps of an instance.
+func lifecycleEnvVars(admitted, scheduled, created time.Time) map[string]string {
+	return map[string]string{
+		EnvTestAdmittedTime:         admitted.UTC().Format(time.RFC3339Nano),
+		EnvTestScheduledTime:        scheduled.UTC().Format(time.RFC3339Nano),
+		EnvTestContainerCreatedTime: created.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// splitExtraHost splits an extra host entry in the "host:ip" format.
+func splitExtraHost(entry string) (host string, ip string, err error) {
+	parts := strings.SplitN(entry, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || net.ParseIP(parts[1]) == nil {
+		return "", "", fmt.Errorf("invalid extra host %q: expected host:ip", entry)
+	}
+	return parts[0], parts[1], nil
+}
+
+// Image pull policies of plan instances, named after the Kubernetes ones.
+const (
+	// PullPolicyAlways pulls the image of instances before every run.
+	PullPolicyAlways = "Always"
+	// PullPolicyIfNotPresent pulls the image of instances when it's missing.
+	PullPolicyIfNotPresent = "IfNotPresent"
+	// PullPolicyNever never pulls the image of instances, which must be
+	// present already.
+	PullPolicyNever = "Never"
+)
+
+// validatePullPolicy checks that policy is unset, or a supported image pull
+// policy.
+func validatePullPolicy(policy string) error {
+	switch policy {
+	case "", PullPolicyAlways, PullPolicyIfNotPresent, PullPolicyNever:
+		return nil
+	default:
+		return fmt.Errorf("unsupported image pull policy: %q; values: %s, %s, %s", policy, PullPolicyAlways, PullPolicyIfNotPresent, PullPolicyNever)
+	}
+}
+
+// validateHostsAndDNS checks the extra hosts and DNS servers configured for
+// plan instances.
+func validateHostsAndDNS(extraHosts []string, dns []string) error {
+	for _, h := range extraHosts {
+		if _, _, err := splitExtraHost(h); err != nil {
+			return err
+		}
+	}
+	for _, s := range dns {
+		if net.ParseIP(s) == nil {
+			return fmt.Errorf("invalid DNS server %q: expected an IP address", s)
+		}
+	}
+	return nil
+}
+
 var ErrRunnerDisabled = fmt.Errorf("runner is disabled by config")
 
+// ErrCorruptOutputs is returned by CollectOutputs when the archive of the
+// outputs failed its integrity check, e.g. because the transfer was cut
+// short; collecting again may succeed.
+var ErrCorruptOutputs = errors.New("outputs archive is corrupt")
+
 func nextDataNetwork(lenNetworks int) (*net.IPNet, string, error) {
 	if lenNetworks > 4095 {
 		return nil, "", errors.New("space exhausted")
@@ -39,6 +236,24 @@ func nextDataNetwork(lenNetworks int) (*net.IPNet, string, error) {
 	return subnet, gw, err
 }
 
+// failFastCount returns the number of failed instances at which a run should
+// be aborted, given a threshold expressed as a fraction of total (below 1) or
+// an absolute count (1 and above). It returns 0 when fail-fast is disabled.
+func failFastCount(threshold float64, total int) int {
+	switch {
+	case threshold <= 0:
+		return 0
+	case threshold < 1:
+		n := int(math.Ceil(threshold * float64(total)))
+		if n < 1 {
+			n = 1
+		}
+		return n
+	default:
+		return int(threshold)
+	}
+}
+
 func gzipRunOutputs(ctx context.Context, basedir string, input *api.CollectionInput, ow *rpc.OutputWriter) error {
 	pattern := filepath.Join(basedir, "*", input.RunID)
 
@@ -117,7 +332,91 @@ func gzipRunOutputs(ctx context.Context, basedir string, input *api.CollectionIn
 
 func reviewResources(group *api.RunGroup, ow *rpc.OutputWriter) {
 	log := ow.With("group_id", group.ID)
-	if group.Resources.CPU != "" || group.Resources.Memory != "" {
+	if group.Resources.CPU != "" || group.Resources.Memory != "" || group.Resources.GPU != "" {
 		log.Warnw("group has resources set. Note that resources requirement and limits are ignored by this runner.")
 	}
 }
+
+func reviewInitContainers(group *api.RunGroup, ow *rpc.OutputWriter) {
+	log := ow.With("group_id", group.ID)
+	if len(group.InitContainers) > 0 {
+		log.Warnw("group has init containers set. Note that init containers are ignored by this runner.", "count", len(group.InitContainers))
+	}
+}
+
+// dangerousResourceRatio is the fraction of the resources recommended by a
+// test plan below which a group requests dangerously little.
+const dangerousResourceRatio = 0.5
+
+// reviewRunResources compares the resources of the instances of each group of
+// a run with the ones the test plan recommends, before any instance starts.
+// Groups that don't request resources get fallback. Groups requesting less
+// than recommended are warned about, and the warnings recorded in result, if
+// any. When the runner applies resources (enforce), a group requesting
+// dangerously little fails the run, unless the run allows it; runners that
+// ignore resources only warn about it.
+func reviewRunResources(input *api.RunInput, fallback api.Resources, enforce bool, result *Result, ow *rpc.OutputWriter) error {
+	for _, g := range input.Groups {
+		requested := g.Resources
+		if requested.CPU == "" {
+			requested.CPU = fallback.CPU
+		}
+		if requested.Memory == "" {
+			requested.Memory = fallback.Memory
+		}
+
+		for _, s := range resourceShortfalls(requested, g.RecommendedResources) {
+			if s.dangerous && enforce && !input.AllowUnderResourced {
+				return fmt.Errorf("group %s requests dangerously little %s: %s, while the test plan recommends %s; use --allow-underresourced to run it anyway",
+					g.ID, s.resource, s.requested, s.recommended)
+			}
+
+			warning := fmt.Sprintf("group %s requests less %s than the test plan recommends: %s < %s", g.ID, s.resource, s.requested, s.recommended)
+			ow.Warn(aurora.Bold(aurora.Yellow(warning)).String())
+			if result != nil {
+				result.Warnings = append(result.Warnings, warning)
+			}
+		}
+	}
+	return nil
+}
+
+// resourceShortfall is a resource requested below the recommended amount.
+type resourceShortfall struct {
+	resource    string
+	requested   string
+	recommended string
+	// dangerous is set when less than dangerousResourceRatio of the
+	// recommended amount is requested.
+	dangerous bool
+}
+
+// resourceShortfalls lists the resources requested below the recommended
+// ones. Resources not set on either side, or not parseable, are not compared.
+func resourceShortfalls(requested api.Resources, recommended api.Resources) []resourceShortfall {
+	var res []resourceShortfall
+	check := func(name, req, rec string) {
+		if req == "" || rec == "" {
+			return
+		}
+		q, err := resource.ParseQuantity(req)
+		if err != nil {
+			return
+		}
+		r, err := resource.ParseQuantity(rec)
+		if err != nil {
+			return
+		}
+		if q.Cmp(r) < 0 {
+			res = append(res, resourceShortfall{
+				resource:    name,
+				requested:   req,
+				recommended: rec,
+				dangerous:   float64(q.MilliValue()) < dangerousResourceRatio*float64(r.MilliValue()),
+			})
+		}
+	}
+	check("cpu", requested.CPU, recommended.CPU)
+	check("memory", requested.Memory, recommended.Memory)
+	return res
+}
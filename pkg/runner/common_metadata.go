@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/version"
+)
+
+// runMetadataFile is the file at the root of the outputs of a run that
+// records how the run was produced.
+const runMetadataFile = "run.json"
+
+// runMetadata is the content of runMetadataFile. It makes collected outputs
+// self-describing, so that a run can be reproduced from its archive.
+type runMetadata struct {
+	RunID             string             `json:"run_id"`
+	TestgroundVersion string             `json:"testground_version"`
+	TestPlan          string             `json:"test_plan"`
+	TestCase          string             `json:"test_case"`
+	StartTime         time.Time          `json:"start_time"`
+	TotalInstances    int                `json:"total_instances"`
+	Composition       *api.Composition   `json:"composition"`
+	RunnerConfig      interface{}        `json:"runner_config"`
+	Groups            []runMetadataGroup `json:"groups"`
+}
+
+type runMetadataGroup struct {
+	ID         string            `json:"id"`
+	Instances  int               `json:"instances"`
+	Artifact   string            `json:"artifact"`
+	Parameters map[string]string `json:"parameters"`
+	Resources  api.Resources     `json:"resources"`
+	Profiles   map[string]string `json:"profiles,omitempty"`
+}
+
+// marshalRunMetadata produces the metadata of a run.
+func marshalRunMetadata(input *api.RunInput) ([]byte, error) {
+	v := version.GitCommit
+	if v == "" {
+		v = "dirty"
+	}
+
+	md := runMetadata{
+		RunID:             input.RunID,
+		TestgroundVersion: v,
+		TestPlan:          input.TestPlan,
+		TestCase:          input.TestCase,
+		StartTime:         input.StartTime,
+		TotalInstances:    input.TotalInstances,
+		Composition:       input.Composition,
+		RunnerConfig:      input.RunnerConfig,
+	}
+	for _, g := range input.Groups {
+		md.Groups = append(md.Groups, runMetadataGroup{
+			ID:         g.ID,
+			Instances:  g.Instances,
+			Artifact:   g.ArtifactPath,
+			Parameters: g.Parameters,
+			Resources:  g.Resources,
+			Profiles:   g.Profiles,
+		})
+	}
+	return json.MarshalIndent(md, "", "  ")
+}
+
+// writeRunMetadata writes the metadata of a run to dir, which is the root of
+// the outputs of the run.
+func writeRunMetadata(dir string, input *api.RunInput) error {
+	b, err := marshalRunMetadata(input)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, runMetadataFile), b, 0644)
+}
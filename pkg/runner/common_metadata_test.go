@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/testground/testground/pkg/api"
+)
+
+func TestWriteRunMetadata(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "plan", "c0ffee")
+	input := &api.RunInput{
+		RunID:          "c0ffee",
+		TestPlan:       "plan",
+		TestCase:       "case",
+		TotalInstances: 2,
+		Composition:    &api.Composition{Global: api.Global{Plan: "plan", Case: "case"}},
+		RunnerConfig:   &LocalDockerRunnerConfig{LogLevel: "debug"},
+		Groups: []*api.RunGroup{
+			{ID: "miners", Instances: 2, ArtifactPath: "sha256:abc", Parameters: map[string]string{"size": "1MiB"}},
+		},
+	}
+
+	if err := writeRunMetadata(dir, input); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, runMetadataFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var md runMetadata
+	if err := json.Unmarshal(b, &md); err != nil {
+		t.Fatal(err)
+	}
+	if md.RunID != "c0ffee" || md.Composition.Global.Plan != "plan" {
+		t.Errorf("unexpected metadata: %+v", md)
+	}
+	if len(md.Groups) != 1 || md.Groups[0].Artifact != "sha256:abc" || md.Groups[0].Parameters["size"] != "1MiB" {
+		t.Errorf("unexpected groups: %+v", md.Groups)
+	}
+	if md.TestgroundVersion == "" {
+		t.Error("expected the testground version to be recorded")
+	}
+}
@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/testground/testground/pkg/rpc"
+)
+
+// maxProfileFailures is how many captures of a profile may fail in a row,
+// e.g. because the instance doesn't serve pprof, before it's given up on.
+const maxProfileFailures = 5
+
+// captureProfiles periodically captures the requested pprof profiles from the
+// pprof endpoint at baseURL (e.g. http://localhost:6060), and writes them to
+// the profiles subdirectory of dir. profiles maps a profile kind to the
+// frequency of capture, as in api.RunGroup.Profiles.
+//
+// It complements the capture the SDK performs within instances, by taking a
+// last snapshot of heap-like profiles when ctx is done, right before the
+// instance is torn down. The "cpu" profile is left to the SDK, which profiles
+// the whole test in-process; the pprof endpoint can't serve it meanwhile.
+// captureProfiles blocks until ctx is done.
+func captureProfiles(ctx context.Context, ow *rpc.OutputWriter, baseURL string, profiles map[string]string, dir string) {
+	if len(profiles) == 0 {
+		return
+	}
+
+	dir = filepath.Join(dir, "profiles")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		ow.Warnw("failed to create profiles dir", "dir", dir, "error", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for kind, freq := range profiles {
+		if kind == "cpu" {
+			continue
+		}
+
+		var d time.Duration
+		if freq != "" {
+			var err error
+			if d, err = time.ParseDuration(freq); err != nil || d <= 0 {
+				ow.Warnw("ignoring profile with invalid frequency", "kind", kind, "frequency", freq)
+				continue
+			}
+		}
+
+		wg.Add(1)
+		go func(kind string, d time.Duration) {
+			defer wg.Done()
+			captureSnapshotProfiles(ctx, ow, baseURL, kind, d, dir)
+		}(kind, d)
+	}
+	wg.Wait()
+}
+
+func captureSnapshotProfiles(ctx context.Context, ow *rpc.OutputWriter, baseURL string, kind string, d time.Duration, dir string) {
+	url := fmt.Sprintf("%s/debug/pprof/%s", baseURL, kind)
+	if d > 0 {
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+
+		failures := 0
+	Loop:
+		for {
+			select {
+			case <-ticker.C:
+				err := fetchProfile(ctx, url, dir, kind)
+				if err == nil {
+					failures = 0
+					continue
+				}
+				ow.Debugw("failed to capture profile", "kind", kind, "error", err)
+				if failures++; failures >= maxProfileFailures {
+					ow.Warnw("giving up capturing profile", "kind", kind, "error", err)
+					return
+				}
+			case <-ctx.Done():
+				break Loop
+			}
+		}
+	} else {
+		<-ctx.Done()
+	}
+
+	// take a last snapshot before teardown; the instance may already be gone.
+	fctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := fetchProfile(fctx, url, dir, kind); err != nil {
+		ow.Debugw("failed to capture final profile", "kind", kind, "error", err)
+	}
+}
+
+// fetchProfile downloads the profile at url to a timestamped file in dir.
+func fetchProfile(ctx context.Context, url string, dir string, kind string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status fetching %s: %s", url, resp.Status)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s.%d.pb.gz", kind, time.Now().UnixNano()))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/testground/testground/pkg/rpc"
+)
+
+func TestCaptureProfiles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/debug/pprof/heap" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("profile"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	captureProfiles(ctx, rpc.Discard(), srv.URL, map[string]string{"heap": "20ms", "goroutine": "invalid"}, dir)
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "profiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// periodic snapshots, plus the final one.
+	if len(files) < 2 {
+		t.Fatalf("expected at least 2 profiles, got %d", len(files))
+	}
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name(), "heap.") {
+			t.Errorf("unexpected profile file: %s", f.Name())
+		}
+	}
+}
+
+func TestCaptureProfilesGivesUp(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// cpu profiles are left to the SDK, and failing captures stop before ctx
+	// is done.
+	start := time.Now()
+	captureProfiles(ctx, rpc.Discard(), srv.URL, map[string]string{"heap": "5ms", "cpu": ""}, t.TempDir())
+	if time.Since(start) >= 5*time.Second {
+		t.Error("expected failing captures to be given up on")
+	}
+	if n := atomic.LoadInt32(&requests); n != maxProfileFailures {
+		t.Errorf("expected %d requests, got %d", maxProfileFailures, n)
+	}
+}
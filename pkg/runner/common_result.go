@@ -9,6 +9,10 @@ type Result struct {
 	Outcome  task.Outcome             `json:"outcome"`
 	Outcomes map[string]*GroupOutcome `json:"outcomes"`
 	Journal  *Journal                 `json:"journal"`
+	// Warnings are the problems found with the run that didn't prevent it
+	// from running, such as groups requesting less resources than the test
+	// plan recommends.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 func newResult(input *api.RunInput) *Result {
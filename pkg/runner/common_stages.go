@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/testground/testground/pkg/rpc"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Stages of a run, as reported by the testground_run_stage_seconds metric.
+const (
+	// RunStageScheduling lasts from the creation of the instances until they
+	// are all running.
+	RunStageScheduling = "scheduling"
+	// RunStageNetworkInit lasts from the creation of the instances until
+	// they have all initialised their networks. It is only measured when
+	// the network initialisation is monitored, see NetworkInitTimeoutSec.
+	RunStageNetworkInit = "network_init"
+	// RunStageExecution lasts from all instances running until they have all
+	// finished.
+	RunStageExecution = "execution"
+)
+
+// stageBuckets are the upper bounds, in seconds, of the run stage duration
+// histograms.
+var stageBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// StageMetrics holds the durations of the stages of the runs of this process,
+// and serves them to Prometheus.
+var StageMetrics = newStageMetrics()
+
+type stageMetrics struct {
+	http.Handler
+
+	durations *prometheus.HistogramVec
+}
+
+func newStageMetrics() *stageMetrics {
+	durations := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "testground_run_stage_seconds",
+		Help:    "Duration of the stages of runs.",
+		Buckets: stageBuckets,
+	}, []string{"runner", "stage"})
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(durations)
+
+	return &stageMetrics{
+		Handler:   promhttp.HandlerFor(reg, promhttp.HandlerOpts{}),
+		durations: durations,
+	}
+}
+
+// observe records that a run of runner completed stage in d.
+func (m *stageMetrics) observe(runner, stage string, d time.Duration) {
+	m.durations.WithLabelValues(runner, stage).Observe(d.Seconds())
+}
+
+// observeStage records that a run of runner completed stage in d, in
+// StageMetrics, and as an event on the output of the run.
+func observeStage(ow *rpc.OutputWriter, runner, stage string, d time.Duration) {
+	StageMetrics.observe(runner, stage, d)
+	ow.Infow("run stage completed", "runner", runner, "stage", stage, "seconds", d.Seconds())
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStageMetrics(t *testing.T) {
+	m := newStageMetrics()
+	m.observe("cluster:k8s", RunStageScheduling, 3*time.Second)
+	m.observe("cluster:k8s", RunStageScheduling, 45*time.Second)
+	m.observe("cluster:k8s", RunStageExecution, 2*time.Hour)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	b, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+
+	for _, line := range []string{
+		`testground_run_stage_seconds_bucket{runner="cluster:k8s",stage="scheduling",le="1"} 0`,
+		`testground_run_stage_seconds_bucket{runner="cluster:k8s",stage="scheduling",le="5"} 1`,
+		`testground_run_stage_seconds_bucket{runner="cluster:k8s",stage="scheduling",le="60"} 2`,
+		`testground_run_stage_seconds_count{runner="cluster:k8s",stage="scheduling"} 2`,
+		`testground_run_stage_seconds_sum{runner="cluster:k8s",stage="scheduling"} 48`,
+		`testground_run_stage_seconds_bucket{runner="cluster:k8s",stage="execution",le="3600"} 0`,
+		`testground_run_stage_seconds_bucket{runner="cluster:k8s",stage="execution",le="+Inf"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, out)
+		}
+	}
+
+	// series are sorted, so that the output is stable.
+	if strings.Index(out, `stage="execution"`) > strings.Index(out, `stage="scheduling"`) {
+		t.Errorf("expected series to be sorted by stage:\n%s", out)
+	}
+}
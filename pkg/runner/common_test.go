@@ -2,6 +2,13 @@ package runner
 
 import (
 	"testing"
+	"time"
+
+	"github.com/testground/testground/pkg/api"
+	"github.com/testground/testground/pkg/config"
+	"github.com/testground/testground/pkg/rpc"
+
+	"github.com/docker/docker/api/types/network"
 )
 
 func TestNextDataNetwork(t *testing.T) {
@@ -33,3 +40,191 @@ func TestNextDataNetwork(t *testing.T) {
 		}
 	}
 }
+
+func TestControlNetworkIPAM(t *testing.T) {
+	var tests = []struct {
+		subnet   string
+		gateway  string
+		want     network.IPAMConfig
+		hasError bool
+	}{
+		{"", "", network.IPAMConfig{Subnet: controlSubnet, Gateway: controlGateway}, false},
+		{"10.200.0.0/16", "", network.IPAMConfig{Subnet: "10.200.0.0/16", Gateway: "10.200.0.1"}, false},
+		{"10.200.3.4/16", "", network.IPAMConfig{Subnet: "10.200.0.0/16", Gateway: "10.200.0.1"}, false},
+		{"10.200.0.0/16", "10.200.0.254", network.IPAMConfig{Subnet: "10.200.0.0/16", Gateway: "10.200.0.254"}, false},
+		{"10.200.0.0/16", "10.201.0.1", network.IPAMConfig{}, true},
+		{"not-a-subnet", "", network.IPAMConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := controlNetworkIPAM(config.DaemonConfig{ControlSubnet: tt.subnet, ControlGateway: tt.gateway})
+		if err != nil {
+			if !tt.hasError {
+				t.Errorf("got error but didn't expect one: %s", err)
+			}
+			continue
+		}
+		if tt.hasError {
+			t.Errorf("expected error for subnet %q gateway %q", tt.subnet, tt.gateway)
+		}
+		if got.Subnet != tt.want.Subnet || got.Gateway != tt.want.Gateway {
+			t.Errorf("got %+v, want %+v", got, tt.want)
+		}
+	}
+}
+
+func TestFailFastCount(t *testing.T) {
+	var tests = []struct {
+		threshold float64
+		total     int
+		want      int
+	}{
+		{0, 100, 0},
+		{-1, 100, 0},
+		{0.5, 100, 50},
+		{0.25, 10, 3},
+		{0.01, 10, 1},
+		{1, 100, 1},
+		{20, 100, 20},
+	}
+
+	for _, tt := range tests {
+		if got := failFastCount(tt.threshold, tt.total); got != tt.want {
+			t.Errorf("failFastCount(%v, %d) = %d, want %d", tt.threshold, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestValidateHostsAndDNS(t *testing.T) {
+	var tests = []struct {
+		extraHosts []string
+		dns        []string
+		hasError   bool
+	}{
+		{nil, nil, false},
+		{[]string{"gateway.local:10.0.0.1", "v6.local:::1"}, []string{"8.8.8.8", "2001:4860:4860::8888"}, false},
+		{[]string{"gateway.local"}, nil, true},
+		{[]string{":10.0.0.1"}, nil, true},
+		{[]string{"gateway.local:not-an-ip"}, nil, true},
+		{nil, []string{"dns.google"}, true},
+	}
+
+	for _, tt := range tests {
+		err := validateHostsAndDNS(tt.extraHosts, tt.dns)
+		if (err != nil) != tt.hasError {
+			t.Errorf("hosts %v, dns %v: expected error %v, got %v", tt.extraHosts, tt.dns, tt.hasError, err)
+		}
+	}
+}
+
+func TestValidatePullPolicy(t *testing.T) {
+	for _, p := range []string{"", PullPolicyAlways, PullPolicyIfNotPresent, PullPolicyNever} {
+		if err := validatePullPolicy(p); err != nil {
+			t.Errorf("policy %q: unexpected error: %v", p, err)
+		}
+	}
+	for _, p := range []string{"always", "Sometimes"} {
+		if err := validatePullPolicy(p); err == nil {
+			t.Errorf("policy %q: expected an error", p)
+		}
+	}
+}
+
+func TestRunSeed(t *testing.T) {
+	if runSeed("c0ffee") != runSeed("c0ffee") {
+		t.Error("expected the seed of a run to be stable")
+	}
+	if runSeed("c0ffee") == runSeed("decaf") {
+		t.Error("expected distinct runs to have distinct seeds")
+	}
+}
+
+func TestLifecycleEnvVars(t *testing.T) {
+	admitted := time.Date(2021, 5, 1, 10, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	scheduled := admitted.Add(1500 * time.Millisecond)
+	created := scheduled.Add(time.Second)
+
+	env := lifecycleEnvVars(admitted, scheduled, created)
+	for k, want := range map[string]time.Time{
+		EnvTestAdmittedTime:         admitted,
+		EnvTestScheduledTime:        scheduled,
+		EnvTestContainerCreatedTime: created,
+	} {
+		got, err := time.Parse(time.RFC3339Nano, env[k])
+		if err != nil {
+			t.Fatalf("%s: %v", k, err)
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("%s: expected %s in UTC; got %s", k, want, got)
+		}
+	}
+}
+
+func TestTopologyEnvVars(t *testing.T) {
+	clients := &api.RunGroup{ID: "clients", Instances: 3, Role: "client", Dials: []string{"servers", "relays"}}
+	servers := &api.RunGroup{ID: "servers", Instances: 1, Role: "server"}
+	input := &api.RunInput{Groups: []*api.RunGroup{clients, servers}}
+
+	env := topologyEnvVars(input, clients)
+	if got, want := env[EnvTestRunGroups], `[{"id":"clients","instances":3,"role":"client"},{"id":"servers","instances":1,"role":"server"}]`; got != want {
+		t.Errorf("expected groups %s; got %s", want, got)
+	}
+	if got := env[EnvTestGroupDials]; got != "servers,relays" {
+		t.Errorf("unexpected dials: %s", got)
+	}
+	if got := topologyEnvVars(input, servers)[EnvTestGroupDials]; got != "" {
+		t.Errorf("expected no dials; got %s", got)
+	}
+}
+
+func TestResourceShortfalls(t *testing.T) {
+	rec := api.Resources{CPU: "1", Memory: "1Gi"}
+
+	if s := resourceShortfalls(api.Resources{CPU: "2000m", Memory: "1024Mi"}, rec); len(s) != 0 {
+		t.Errorf("got shortfalls %v, expected none", s)
+	}
+	if s := resourceShortfalls(api.Resources{}, rec); len(s) != 0 {
+		t.Errorf("got shortfalls %v for unset resources, expected none", s)
+	}
+
+	s := resourceShortfalls(api.Resources{CPU: "600m", Memory: "256Mi"}, rec)
+	expected := []resourceShortfall{
+		{resource: "cpu", requested: "600m", recommended: "1"},
+		{resource: "memory", requested: "256Mi", recommended: "1Gi", dangerous: true},
+	}
+	if len(s) != len(expected) || s[0] != expected[0] || s[1] != expected[1] {
+		t.Errorf("got shortfalls %v, expected %v", s, expected)
+	}
+}
+
+func TestReviewRunResources(t *testing.T) {
+	ow := rpc.Discard()
+	input := &api.RunInput{
+		Groups: []*api.RunGroup{
+			{ID: "a", Resources: api.Resources{CPU: "600m"}, RecommendedResources: api.Resources{CPU: "1", Memory: "1Gi"}},
+		},
+	}
+
+	// the fallback memory is dangerously little.
+	if err := reviewRunResources(input, api.Resources{Memory: "256Mi"}, true, nil, ow); err == nil {
+		t.Fatal("expected an under-resourced run to fail")
+	}
+
+	// but runners that ignore resources only warn about it.
+	result := newResult(input)
+	if err := reviewRunResources(input, api.Resources{Memory: "256Mi"}, false, result, ow); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Warnings) != 2 {
+		t.Errorf("got warnings %v, expected 2", result.Warnings)
+	}
+
+	input.AllowUnderResourced = true
+	result = newResult(input)
+	if err := reviewRunResources(input, api.Resources{Memory: "256Mi"}, true, result, ow); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Warnings) != 2 {
+		t.Errorf("got warnings %v, expected 2", result.Warnings)
+	}
+}
@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"context"
+	"sync"
+)
+
+// adaptiveLimiter limits the number of concurrent calls to an API, adapting
+// the limit to how the API keeps up: the limit is halved when a call is
+// throttled, and grows by one after as many successful calls as the current
+// limit, up to a maximum.
+type adaptiveLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	max      int
+	inflight int
+
+	// successes counts the successful calls since the limit last changed.
+	successes int
+	// gen is incremented whenever the limit is lowered, so that the calls
+	// that were in flight at the time don't lower it again.
+	gen int
+	// changed is closed, and replaced, whenever a slot may have freed up.
+	changed chan struct{}
+}
+
+// limiterToken is held by a call between acquire and release.
+type limiterToken struct {
+	gen int
+}
+
+// newAdaptiveLimiter returns a limiter starting at initial concurrent calls,
+// that grows up to max. If max is lower than initial, the limit never grows
+// above initial.
+func newAdaptiveLimiter(initial, max int) *adaptiveLimiter {
+	if initial < 1 {
+		initial = 1
+	}
+	if max < initial {
+		max = initial
+	}
+	return &adaptiveLimiter{
+		limit:   initial,
+		max:     max,
+		changed: make(chan struct{}),
+	}
+}
+
+// acquire waits for a slot, or for ctx to be done.
+func (l *adaptiveLimiter) acquire(ctx context.Context) (limiterToken, error) {
+	for {
+		l.mu.Lock()
+		if l.inflight < l.limit {
+			l.inflight++
+			tok := limiterToken{gen: l.gen}
+			l.mu.Unlock()
+			return tok, nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return limiterToken{}, ctx.Err()
+		}
+	}
+}
+
+// release frees the slot of tok, adapting the limit to whether the call was
+// throttled.
+func (l *adaptiveLimiter) release(tok limiterToken, throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight--
+	switch {
+	case throttled && tok.gen == l.gen:
+		l.limit = (l.limit + 1) / 2
+		l.successes = 0
+		l.gen++
+	case !throttled && l.limit < l.max:
+		if l.successes++; l.successes >= l.limit {
+			l.limit++
+			l.successes = 0
+		}
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// current returns the current limit.
+func (l *adaptiveLimiter) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
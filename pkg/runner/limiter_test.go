@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := newAdaptiveLimiter(4, 6)
+
+	// four slots, then the fifth call waits.
+	toks := make([]limiterToken, 4)
+	for i := range toks {
+		tok, err := l.acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		toks[i] = tok
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx); err == nil {
+		t.Fatal("expected acquire to wait for a slot")
+	}
+
+	// calls in flight when throttled lower the limit only once.
+	l.release(toks[0], true)
+	l.release(toks[1], true)
+	if got := l.current(); got != 2 {
+		t.Errorf("expected the limit to be halved once, to 2, got %d", got)
+	}
+	l.release(toks[2], false)
+	l.release(toks[3], false)
+
+	// the limit grows by one after as many successes, up to the maximum.
+	for i := 0; i < 20; i++ {
+		tok, err := l.acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.release(tok, false)
+	}
+	if got := l.current(); got != 6 {
+		t.Errorf("expected the limit to grow to the maximum of 6, got %d", got)
+	}
+
+	// a throttled call acquired after the limit was lowered lowers it again.
+	tok, _ := l.acquire(context.Background())
+	l.release(tok, true)
+	if got := l.current(); got != 3 {
+		t.Errorf("expected the limit to be halved to 3, got %d", got)
+	}
+}
+
+func TestAdaptiveLimiterWakesWaiters(t *testing.T) {
+	l := newAdaptiveLimiter(1, 1)
+	tok, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		_, err := l.acquire(context.Background())
+		acquired <- err
+	}()
+
+	l.release(tok, false)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiter not woken up by release")
+	}
+}
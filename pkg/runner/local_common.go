@@ -15,7 +15,7 @@ import (
 	"github.com/docker/go-connections/nat"
 )
 
-func localCommonHealthcheck(ctx context.Context, hh *healthcheck.Helper, cli *client.Client, ow *rpc.OutputWriter, controlNetworkID string, workdir string) {
+func localCommonHealthcheck(ctx context.Context, hh *healthcheck.Helper, cli *client.Client, ow *rpc.OutputWriter, controlNetworkID string, controlIPAM network.IPAMConfig, workdir string) {
 	hh.Enlist("local-outputs-dir",
 		healthcheck.CheckDirectoryExists(workdir),
 		healthcheck.CreateDirectory(workdir),
@@ -23,8 +23,8 @@ func localCommonHealthcheck(ctx context.Context, hh *healthcheck.Helper, cli *cl
 
 	// testground-control network
 	hh.Enlist("control-network",
-		healthcheck.CheckNetwork(ctx, ow, cli, controlNetworkID),
-		healthcheck.CreateNetwork(ctx, ow, cli, controlNetworkID, network.IPAMConfig{Subnet: controlSubnet, Gateway: controlGateway}),
+		healthcheck.CheckNetwork(ctx, ow, cli, controlNetworkID, controlIPAM),
+		healthcheck.CreateNetwork(ctx, ow, cli, controlNetworkID, controlIPAM),
 	)
 
 	// grafana from downloaded image, with no additional configuration.
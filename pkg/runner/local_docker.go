@@ -9,9 +9,11 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/testground/testground/pkg/logging"
@@ -49,6 +51,8 @@ var (
 	_ api.Runner        = (*LocalDockerRunner)(nil)
 	_ api.Healthchecker = (*LocalDockerRunner)(nil)
 	_ api.Terminatable  = (*LocalDockerRunner)(nil)
+	_ api.Prunable      = (*LocalDockerRunner)(nil)
+	_ api.Pinger        = (*LocalDockerRunner)(nil)
 )
 
 // LocalDockerRunnerConfig is the configuration object of this runner. Boolean
@@ -62,7 +66,8 @@ type LocalDockerRunnerConfig struct {
 	// Unstarted creates the containers without starting them (default: false).
 	Unstarted bool `toml:"no_start"`
 	// Background avoids tailing the output of containers, and displaying it as
-	// log messages (default: false).
+	// log messages. Run then returns as soon as all containers have started,
+	// and the task completes in the background (default: false).
 	Background bool `toml:"background"`
 	// Ulimits that should be applied on this run, in Docker format.
 	// See
@@ -76,12 +81,73 @@ type LocalDockerRunnerConfig struct {
 	OutcomesCollectionTimeout time.Duration `toml:"outcomes_collection_timeout"`
 
 	AdditionalHosts []string `toml:"additional_hosts"`
+
+	// PprofBasePort, when set, binds the pprof port of each instance to the
+	// host port PprofBasePort + instance index, where the index runs across all
+	// groups in composition order. Otherwise pprof is bound to a random host
+	// port (default: 0).
+	PprofBasePort int `toml:"pprof_base_port"`
+
+	PrettyPrinterOpts
+	// StderrToFile additionally writes the stderr of each instance to a
+	// stderr.log file in its outputs directory (default: false).
+	StderrToFile bool `toml:"stderr_to_file"`
+	// TailPerGroup limits live output to the first TailPerGroup instances of
+	// each group. The output of the remaining instances is written to
+	// stdout.log and stderr.log files in their outputs directories instead
+	// (default: 0, tail all instances).
+	TailPerGroup int `toml:"tail_per_group"`
+
+	// PlanRestartPolicy is the Docker restart policy of plan containers: "no",
+	// "on-failure" or "on-failure:<max retries>" (default: "no"). Policies
+	// restarting containers that succeeded, such as "always", are not
+	// supported, as the run would never complete.
+	PlanRestartPolicy string `toml:"plan_restart_policy"`
+
+	// ExtraHosts are additional /etc/hosts entries for plan containers, in
+	// the "host:ip" format.
+	ExtraHosts []string `toml:"extra_hosts"`
+	// DNS are custom DNS servers for plan containers.
+	DNS []string `toml:"dns"`
+
+	// AbortOnFailure ends the run, tearing down all containers, as soon as
+	// any instance reports a failure or a crash (default: false). It can't
+	// be combined with a plan_restart_policy restarting failed containers.
+	AbortOnFailure bool `toml:"abort_on_failure"`
+
+	// ImagePullPolicy is when to pull the images of plan containers that
+	// are referenced by name, e.g. artifacts set in the composition:
+	// "Always", before every run, so that a tag moved in the registry is
+	// never stale; "IfNotPresent", when it's missing; or "Never" (default).
+	// Image IDs, as produced by the docker builders, are never pulled.
+	ImagePullPolicy string `toml:"image_pull_policy"`
+}
+
+var _ api.ConfigValidator = (*LocalDockerRunnerConfig)(nil)
+
+func (c *LocalDockerRunnerConfig) Validate() error {
+	policy, err := parseRestartPolicy(c.PlanRestartPolicy)
+	if err != nil {
+		return err
+	}
+	if c.AbortOnFailure && !policy.IsNone() {
+		return fmt.Errorf("abort_on_failure can't be combined with the restart policy %q", c.PlanRestartPolicy)
+	}
+	if err := validatePullPolicy(c.ImagePullPolicy); err != nil {
+		return err
+	}
+	return validateHostsAndDNS(c.ExtraHosts, c.DNS)
 }
 
+// pprofPort is the port plan instances serve pprof on.
+const pprofPort = nat.Port("6060/tcp")
+
 type testContainerInstance struct {
 	containerID string
 	groupID     string
 	groupIdx    int
+	outputsDir  string
+	profiles    map[string]string
 }
 
 // defaultConfig is the default configuration. Incoming configurations will be
@@ -111,6 +177,22 @@ type LocalDockerRunner struct {
 	outputsDir       string
 
 	syncClient *ss.DefaultClient
+	subnets    SubnetAllocator
+}
+
+// defaultLocalSubnets is the allocator used by local:docker runners that
+// haven't been injected one.
+var defaultLocalSubnets = NewMemorySubnetAllocator(0)
+
+func (r *LocalDockerRunner) SetSubnetAllocator(a SubnetAllocator) {
+	r.subnets = a
+}
+
+func (r *LocalDockerRunner) subnetAllocator() SubnetAllocator {
+	if r.subnets == nil {
+		return defaultLocalSubnets
+	}
+	return r.subnets
 }
 
 func (r *LocalDockerRunner) Healthcheck(ctx context.Context, engine api.Engine, ow *rpc.OutputWriter, fix bool) (*api.HealthcheckReport, error) {
@@ -126,10 +208,15 @@ func (r *LocalDockerRunner) Healthcheck(ctx context.Context, engine api.Engine,
 	r.outputsDir = filepath.Join(engine.EnvConfig().Dirs().Outputs(), "local_docker")
 	r.controlNetworkID = "testground-control"
 
+	controlIPAM, err := controlNetworkIPAM(engine.EnvConfig().Daemon)
+	if err != nil {
+		return nil, err
+	}
+
 	hh := &healthcheck.Helper{}
 
 	// enlist healthchecks which are common between local:docker and local:exec
-	localCommonHealthcheck(ctx, hh, cli, ow, r.controlNetworkID, r.outputsDir)
+	localCommonHealthcheck(ctx, hh, cli, ow, r.controlNetworkID, controlIPAM, r.outputsDir)
 
 	dockerSock := "/var/run/docker.sock"
 	if host := cli.DaemonHost(); strings.HasPrefix(host, "unix://") {
@@ -214,40 +301,18 @@ func (r *LocalDockerRunner) setupSyncClient() error {
 
 // collectOutcomes listens to the sync service and collects the outcome for every test instance.
 // It stops when all instances have submitted a result or the context was canceled.
-func (r *LocalDockerRunner) collectOutcomes(ctx context.Context, result *Result, tpl *runtime.RunParams) (chan bool, error) {
+// If onFailure is not nil, it is called for every failed or crashed instance.
+// See tallyOutcomes for exited.
+func (r *LocalDockerRunner) collectOutcomes(ctx context.Context, result *Result, tpl *runtime.RunParams, exited <-chan string, onFailure func()) (chan bool, error) {
 	eventsCh, err := r.syncClient.SubscribeEvents(ctx, tpl)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: eventually we'll keep a trace of each test instance status.
-	// Right now, if a container sends multiple events, it will mess up the outcomes.
-	// We have to pass its group id to the container, so that it can send us back messages
-	// with its own id.
-	expectingOutcomes := result.countTotalInstances()
 	done := make(chan bool)
 
 	go func() {
-		running := true
-		for running && expectingOutcomes > 0 {
-			select {
-			case <-ctx.Done():
-				running = false
-			case e := <-eventsCh:
-				if e.SuccessEvent != nil {
-					result.addOutcome(e.SuccessEvent.TestGroupID, task.OutcomeSuccess)
-					expectingOutcomes -= 1
-				} else if e.FailureEvent != nil {
-					result.addOutcome(e.FailureEvent.TestGroupID, task.OutcomeFailure)
-					expectingOutcomes -= 1
-				} else if e.CrashEvent != nil {
-					result.addOutcome(e.CrashEvent.TestGroupID, task.OutcomeFailure)
-					expectingOutcomes -= 1
-				}
-				// else: skip
-			}
-		}
-
+		tallyOutcomes(ctx, result, eventsCh, exited, onFailure)
 		result.updateOutcome()
 		done <- true
 	}()
@@ -255,6 +320,51 @@ func (r *LocalDockerRunner) collectOutcomes(ctx context.Context, result *Result,
 	return done, nil
 }
 
+// tallyOutcomes counts the outcome events of the instances until all of
+// them are known or the context is canceled.
+//
+// Under a restart policy, a failed or crashed instance may be restarted and
+// send more events, so exited is not nil, and a failure is only counted when
+// the group ID of a container that exited with an error, and won't be
+// restarted, is received on it.
+func tallyOutcomes(ctx context.Context, result *Result, eventsCh <-chan *runtime.Event, exited <-chan string, onFailure func()) {
+	// TODO: eventually we'll keep a trace of each test instance status.
+	// Right now, if a container sends multiple events, it will mess up the outcomes.
+	// We have to pass its group id to the container, so that it can send us back messages
+	// with its own id.
+	expectingOutcomes := result.countTotalInstances()
+
+	for expectingOutcomes > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case groupID := <-exited:
+			result.addOutcome(groupID, task.OutcomeFailure)
+			expectingOutcomes -= 1
+		case e := <-eventsCh:
+			var failedGroupID string
+			if e.SuccessEvent != nil {
+				result.addOutcome(e.SuccessEvent.TestGroupID, task.OutcomeSuccess)
+				expectingOutcomes -= 1
+			} else if e.FailureEvent != nil {
+				failedGroupID = e.FailureEvent.TestGroupID
+			} else if e.CrashEvent != nil {
+				failedGroupID = e.CrashEvent.TestGroupID
+			}
+			// else: skip
+
+			if failedGroupID == "" || exited != nil {
+				continue
+			}
+			result.addOutcome(failedGroupID, task.OutcomeFailure)
+			expectingOutcomes -= 1
+			if onFailure != nil {
+				onFailure()
+			}
+		}
+	}
+}
+
 func (r *LocalDockerRunner) prepareOutputDirectory(instance_id int, runenv *runtime.RunParams) (string, error) {
 	// <outputs_dir>/<plan>/<run_id>/<group_id>/<instance_number>
 	odir := filepath.Join(r.outputsDir, runenv.TestPlan, runenv.TestRun, runenv.TestGroupID, strconv.Itoa(instance_id))
@@ -277,7 +387,40 @@ func (r *LocalDockerRunner) prepareTemporaryDirectory(instance_id int, runenv *r
 	return tmpdir, nil
 }
 
-func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc.OutputWriter) (runoutput *api.RunOutput, err error) {
+func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rpc.OutputWriter) (*api.RunOutput, error) {
+	cfg, ok := input.RunnerConfig.(*LocalDockerRunnerConfig)
+	if !ok || cfg == nil || !cfg.Background || cfg.Unstarted {
+		return r.run(ctx, input, ow, nil)
+	}
+
+	// In background mode, we return as soon as the containers have started,
+	// and report the final output through the Done channel.
+	var (
+		detach = make(chan struct{})
+		done   = make(chan api.RunCompletion, 1)
+	)
+	go func() {
+		out, err := r.run(ctx, input, ow, detach)
+		done <- api.RunCompletion{Output: out, Err: err}
+		close(done)
+	}()
+
+	select {
+	case <-detach:
+		ow.Infow("containers started; run continues in the background", "run_id", input.RunID)
+		return &api.RunOutput{
+			RunID:  input.RunID,
+			Result: newResult(input),
+			Done:   done,
+		}, nil
+	case c := <-done:
+		return c.Output, c.Err
+	}
+}
+
+// run executes a run. If detach is not nil, it is closed once all containers
+// have started.
+func (r *LocalDockerRunner) run(ctx context.Context, input *api.RunInput, ow *rpc.OutputWriter, detach chan<- struct{}) (runoutput *api.RunOutput, err error) {
 	log := ow.With("runner", "local:docker", "run_id", input.RunID)
 
 	result := newResult(input)
@@ -324,7 +467,7 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 	}
 
 	// Create a data network.
-	dataNetworkID, subnet, err := newDataNetwork(ctx, cli, ow, input, "default")
+	dataNetworkID, subnet, releaseSubnet, err := newDataNetwork(ctx, cli, ow, r.subnetAllocator(), input, "default")
 	if err != nil {
 		return
 	}
@@ -339,7 +482,7 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 		TestSidecar:        true,
 		TestOutputsPath:    "/outputs",
 		TestTempPath:       "/temp", // not using /tmp to avoid overriding linux standard paths.
-		TestStartTime:      time.Now(),
+		TestStartTime:      input.StartTime,
 		TestSubnet:         &ptypes.IPNet{IPNet: *subnet},
 	}
 
@@ -350,8 +493,13 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 		return
 	}
 
+	restartPolicy, err := parseRestartPolicy(cfg.PlanRestartPolicy)
+	if err != nil {
+		return
+	}
+
 	// Prepare the ports mapping.
-	ports := make(nat.PortSet)
+	ports := nat.PortSet{pprofPort: struct{}{}}
 	for _, p := range cfg.ExposedPorts {
 		ports[nat.Port(p)] = struct{}{}
 	}
@@ -360,6 +508,8 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 	sharedEnv := make([]string, 0, 3)
 	sharedEnv = append(sharedEnv, "INFLUXDB_URL=http://testground-influxdb:8086")
 	sharedEnv = append(sharedEnv, "REDIS_HOST=testground-redis")
+	sharedEnv = append(sharedEnv, fmt.Sprintf("%s=%d", EnvTestRunSeed, runSeed(input.RunID)))
+	sharedEnv = append(sharedEnv, fmt.Sprintf("%s=%s", EnvTestRequestID, input.RequestID))
 	// Inject exposed ports.
 	sharedEnv = append(sharedEnv, conv.ToOptionsSlice(cfg.ExposedPorts.ToEnvVars())...)
 	// Set the log level if provided in cfg.
@@ -371,6 +521,7 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 	var (
 		containers []testContainerInstance
 		tmpdirs    []string
+		instance   int
 	)
 
 	defer func() {
@@ -380,21 +531,58 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 		}
 	}()
 
+	if err = reviewRunResources(input, api.Resources{}, false, result, ow); err != nil {
+		return
+	}
+
+	if err := writeRunMetadata(filepath.Join(r.outputsDir, input.TestPlan, input.RunID), input); err != nil {
+		log.Warnw("failed to write run metadata", "error", err)
+	}
+
+	pulled := make(map[string]struct{}, len(input.Groups))
+	for _, g := range input.Groups {
+		if _, ok := pulled[g.ArtifactPath]; ok {
+			continue
+		}
+		if err := pullPlanImage(ctx, ow, cli, g.ArtifactPath, cfg.ImagePullPolicy); err != nil {
+			return nil, fmt.Errorf("failed to pull image %s: %w", g.ArtifactPath, err)
+		}
+		pulled[g.ArtifactPath] = struct{}{}
+	}
+
+	scheduled := time.Now()
 	for _, g := range input.Groups {
 		reviewResources(g, ow)
+		reviewInitContainers(g, ow)
 
 		runenv := template
 		runenv.TestGroupInstanceCount = g.Instances
 		runenv.TestGroupID = g.ID
 		runenv.TestInstanceParams = g.Parameters
 		runenv.TestCaptureProfiles = g.Profiles
+		runenv.TestInstanceRole = g.Role
 		// Prepare the group's environment variables.
 		env := make([]string, 0, len(sharedEnv)+len(runenv.ToEnvVars()))
 		env = append(env, sharedEnv...)
 		env = append(env, conv.ToOptionsSlice(runenv.ToEnvVars())...)
+		env = append(env, conv.ToOptionsSlice(topologyEnvVars(input, g))...)
 		logging.S().Infow("additional hosts", "hosts", strings.Join(cfg.AdditionalHosts, ","))
 		env = append(env, fmt.Sprintf("ADDITIONAL_HOSTS=%s", strings.Join(cfg.AdditionalHosts, ",")))
 
+		// Prepare the group's additional read-only mounts.
+		var mounts []mount.Mount
+		for _, m := range g.Mounts {
+			if m.IsPVC() {
+				return nil, fmt.Errorf("cannot mount %s: persistent volume claims are not supported by local:docker", m.Source)
+			}
+			mounts = append(mounts, mount.Mount{
+				Type:     mount.TypeBind,
+				Source:   m.Source,
+				Target:   m.Target,
+				ReadOnly: true,
+			})
+		}
+
 		// Start as many containers as group instances.
 		for i := 0; i < g.Instances; i++ {
 			// TODO: We should set the instance id in runenv and make this whole operation self contained around a local runenv.
@@ -413,23 +601,41 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 			name := fmt.Sprintf("tg-%s-%s-%s-%s-%d", runenv.TestPlan, runenv.TestCase, runenv.TestRun, runenv.TestGroupID, i)
 			log.Infow("creating container", "name", name)
 
+			ienv := make([]string, 0, len(env)+5)
+			ienv = append(ienv, env...)
+			ienv = append(ienv, conv.ToOptionsSlice(instanceEnvVars(i, instance))...)
+			ienv = append(ienv, conv.ToOptionsSlice(lifecycleEnvVars(input.StartTime, scheduled, time.Now()))...)
+
 			ccfg := &container.Config{
 				Image:        g.ArtifactPath,
 				ExposedPorts: ports,
-				Env:          env,
+				Env:          ienv,
 				Labels: map[string]string{
-					"testground.purpose":  "plan",
-					"testground.plan":     runenv.TestPlan,
-					"testground.testcase": runenv.TestCase,
-					"testground.run_id":   runenv.TestRun,
-					"testground.group_id": runenv.TestGroupID,
+					"testground.purpose":    "plan",
+					"testground.plan":       runenv.TestPlan,
+					"testground.testcase":   runenv.TestCase,
+					"testground.run_id":     runenv.TestRun,
+					"testground.group_id":   runenv.TestGroupID,
+					"testground.request_id": input.RequestID,
 				},
 			}
 
+			pprofHostPort := "0"
+			if cfg.PprofBasePort > 0 {
+				pprofHostPort = strconv.Itoa(cfg.PprofBasePort + instance)
+			}
+			instance++
+
 			hcfg := &container.HostConfig{
 				NetworkMode:     container.NetworkMode("testground-control"),
 				PublishAllPorts: true,
-				Mounts: []mount.Mount{{
+				RestartPolicy:   restartPolicy,
+				ExtraHosts:      cfg.ExtraHosts,
+				DNS:             cfg.DNS,
+				PortBindings: nat.PortMap{
+					pprofPort: []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: pprofHostPort}},
+				},
+				Mounts: append([]mount.Mount{{
 					Type:   mount.TypeBind,
 					Source: odir,
 					Target: runenv.TestOutputsPath,
@@ -437,7 +643,7 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 					Type:   mount.TypeBind,
 					Source: tmpdir,
 					Target: runenv.TestTempPath,
-				}},
+				}}, mounts...),
 			}
 
 			if len(cfg.Ulimits) > 0 {
@@ -460,6 +666,8 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 				containerID: res.ID,
 				groupID:     g.ID,
 				groupIdx:    i,
+				outputsDir:  odir,
+				profiles:    g.Profiles,
 			}
 			containers = append(containers, container)
 
@@ -480,11 +688,11 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 			if err := docker.DeleteContainers(cli, log, ids); err != nil {
 				log.Errorw("failed to delete containers", "err", err)
 			}
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			defer cancel()
-			if err := cli.NetworkRemove(ctx, dataNetworkID); err != nil {
+			if err := removeDataNetwork(context.Background(), cli, log, dataNetworkID); err != nil {
 				log.Errorw("removing network", "network", dataNetworkID, "error", err)
+				return
 			}
+			releaseSubnet()
 		}()
 	}
 
@@ -506,7 +714,25 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 	}()
 
 	// First we collect every container outcomes.
-	outcomesCollectIsCompleteCh, err := r.collectOutcomes(runCtx, result, &template)
+	var (
+		aborted   int32
+		onFailure func()
+	)
+	if cfg.AbortOnFailure {
+		onFailure = func() {
+			if atomic.CompareAndSwapInt32(&aborted, 0, 1) {
+				log.Warnw("an instance failed; aborting the run")
+				cancelRun()
+			}
+		}
+	}
+	// With a restart policy, the containers that exit with an error for good
+	// report their failure, each exactly once.
+	var exited chan string
+	if !restartPolicy.IsNone() {
+		exited = make(chan string, len(containers))
+	}
+	outcomesCollectIsCompleteCh, err := r.collectOutcomes(runCtx, result, &template, exited, onFailure)
 	if err != nil {
 		log.Error(err)
 		return
@@ -532,6 +758,9 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 			err := cli.ContainerStart(startGroupCtx, c.containerID, types.ContainerStartOptions{})
 			if err == nil {
 				log.Debugw("started container", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx)
+				if port, err := pprofHostPortOf(startGroupCtx, cli, c.containerID); err == nil {
+					log.Infow("pprof available", "group", c.groupID, "group_index", c.groupIdx, "url", fmt.Sprintf("http://localhost:%s/debug/pprof/", port))
+				}
 				select {
 				case <-startGroupCtx.Done():
 				default:
@@ -546,7 +775,7 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 
 	// Third we start the pretty printer
 	if !cfg.Background {
-		pretty := NewPrettyPrinter(ow)
+		pretty := NewPrettyPrinter(ow, cfg.PrettyPrinterOpts)
 
 		// Tail the sidecar container logs and appends them to the pretty printer.
 		go func() {
@@ -581,6 +810,15 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 			for {
 				select {
 				case c := <-started:
+					if cfg.TailPerGroup > 0 && c.groupIdx >= cfg.TailPerGroup {
+						go func(c testContainerInstance) {
+							if err := collectLogsToFiles(runCtx, cli, c.containerID, c.outputsDir); err != nil && runCtx.Err() == nil {
+								log.Warnw("failed to collect container logs", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx, "error", err)
+							}
+						}(c)
+						continue
+					}
+
 					log.Infow("attaching container", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx)
 					stream, err := cli.ContainerLogs(runCtx, c.containerID, types.ContainerLogsOptions{
 						ShowStdout: true,
@@ -603,9 +841,17 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 						_ = wstderr.CloseWithError(err)
 					}()
 
+					var stderr io.ReadCloser = rstderr
+					if cfg.StderrToFile {
+						if stderr, err = teeStderrToFile(rstderr, filepath.Join(c.outputsDir, "stderr.log")); err != nil {
+							log.Warnw("failed to write stderr to file", "id", c.containerID, "error", err)
+							stderr = rstderr
+						}
+					}
+
 					// instance tag in output: << group[zero_padded_i] >> (container_id[0:6]), e.g. << miner[003] (a1b2c3) >>
 					tag := fmt.Sprintf("%s[%03d] (%s)", c.groupID, c.groupIdx, c.containerID[0:6])
-					pretty.Manage(tag, rstdout, rstderr)
+					pretty.Manage(tag, rstdout, stderr)
 				case <-runCtx.Done():
 					// Exit
 					return
@@ -621,6 +867,11 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 		return
 	}
 
+	ow.WriteStage(rpc.StageRunning)
+	if detach != nil {
+		close(detach)
+	}
+
 	// Finally, we're going to follow our containers until they are done
 
 	for _, c := range containers {
@@ -628,21 +879,52 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 		f := func() error {
 			log.Infow("waiting for container", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx)
 
-			statusCh, errCh := cli.ContainerWait(runCtx, c.containerID, container.WaitConditionNotRunning)
-
-			select {
-			case err := <-errCh:
-				log.Infow("container failed", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx, "error", err)
+			// Capture the requested profiles until the container exits, or
+			// right before it's torn down.
+			if len(c.profiles) > 0 {
+				port, err := pprofHostPortOf(runCtx, cli, c.containerID)
 				if err != nil {
-					return err
+					log.Warnw("not capturing profiles", "id", c.containerID, "error", err)
+				} else {
+					pctx, pcancel := context.WithCancel(runCtx)
+					done := make(chan struct{})
+					go func() {
+						defer close(done)
+						captureProfiles(pctx, log, "http://localhost:"+port, c.profiles, c.outputsDir)
+					}()
+					defer func() {
+						pcancel()
+						<-done
+					}()
+				}
+			}
+
+			for {
+				statusCh, errCh := cli.ContainerWait(runCtx, c.containerID, container.WaitConditionNotRunning)
+
+				select {
+				case err := <-errCh:
+					log.Infow("container failed", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx, "error", err)
+					if err != nil {
+						return err
+					}
+					return nil
+				case status := <-statusCh:
+					log.Infow("container exited", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx, "status", status.StatusCode)
+					if restartPolicy.IsNone() {
+						return nil
+					}
+					if !containerRestarting(runGroupCtx, cli, c.containerID) {
+						if status.StatusCode != 0 {
+							exited <- c.groupID
+						}
+						return nil
+					}
+					log.Infow("container restarting", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx, "policy", cfg.PlanRestartPolicy)
+				case <-runGroupCtx.Done(): // race with the group
+					log.Infow("container group exited", "err", runGroupCtx.Err())
+					return nil
 				}
-				return nil
-			case status := <-statusCh:
-				log.Infow("container exited", "id", c.containerID, "group", c.groupID, "group_index", c.groupIdx, "status", status.StatusCode)
-				return nil
-			case <-runGroupCtx.Done(): // race with the group
-				log.Infow("container group exited", "err", runGroupCtx.Err())
-				return nil
 			}
 		}
 		runGroup.Go(f)
@@ -675,6 +957,7 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 		select {
 		case <-containersAreCompleteCh:
 			log.Infow("all containers are complete")
+			ow.WriteStage(rpc.StageCollecting)
 			waitingForContainers = false
 			go startOutcomesCollectTimeout()
 		case <-outcomesCollectIsCompleteCh:
@@ -684,6 +967,10 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 			log.Infow("we timeout'd waiting for outcomes")
 			waitingForOutcomes = false
 		case <-runCtx.Done():
+			if atomic.LoadInt32(&aborted) == 1 {
+				ow.Warnw("the test run was aborted after an instance failed")
+				return
+			}
 			log.Infow("the test run ended early", "err", runCtx.Err())
 			return
 		}
@@ -692,43 +979,152 @@ func (r *LocalDockerRunner) Run(ctx context.Context, input *api.RunInput, ow *rp
 	return
 }
 
-func newDataNetwork(ctx context.Context, cli *client.Client, rw *rpc.OutputWriter, env *api.RunInput, name string) (id string, subnet *net.IPNet, err error) {
-	// Find a free network.
-	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{
-		Filters: filters.NewArgs(
-			filters.Arg(
-				"label",
-				"testground.name=default",
-			),
-		),
+// parseRestartPolicy parses a restart policy in Docker's format, e.g. "no" or
+// "on-failure:3".
+func parseRestartPolicy(policy string) (container.RestartPolicy, error) {
+	var p container.RestartPolicy
+	if policy == "" {
+		return p, nil
+	}
+
+	name, retries := policy, ""
+	if i := strings.Index(policy, ":"); i >= 0 {
+		name, retries = policy[:i], policy[i+1:]
+	}
+	p.Name = name
+
+	switch name {
+	case "always", "unless-stopped":
+		return p, fmt.Errorf("restart policy %q is not supported: plan containers must be able to exit", name)
+	case "no":
+		if retries != "" {
+			return p, fmt.Errorf("restart policy %q does not accept a maximum retry count", name)
+		}
+	case "on-failure":
+		if retries != "" {
+			n, err := strconv.Atoi(retries)
+			if err != nil || n < 0 {
+				return p, fmt.Errorf("invalid maximum retry count in restart policy %q", policy)
+			}
+			p.MaximumRetryCount = n
+		}
+	default:
+		return p, fmt.Errorf("invalid restart policy %q", policy)
+	}
+	return p, nil
+}
+
+// containerRestarting returns whether a container that exited is being
+// restarted by the Docker daemon.
+func containerRestarting(ctx context.Context, cli *client.Client, containerID string) bool {
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return false
+	}
+	return info.State.Restarting || info.State.Running
+}
+
+// imageIDPattern matches short and full image IDs.
+var imageIDPattern = regexp.MustCompile(`^(sha256:)?([0-9a-f]{12}|[0-9a-f]{64})$`)
+
+// pullPlanImage pulls image according to the image pull policy. Image IDs
+// are local to the Docker daemon, and can't be pulled, nor go stale.
+func pullPlanImage(ctx context.Context, ow *rpc.OutputWriter, cli *client.Client, image string, policy string) error {
+	if imageIDPattern.MatchString(image) {
+		return nil
+	}
+
+	switch policy {
+	case "", PullPolicyNever:
+		return nil
+	case PullPolicyIfNotPresent:
+		_, _, err := cli.ImageInspectWithRaw(ctx, image)
+		if err == nil {
+			return nil
+		}
+		if !client.IsErrNotFound(err) {
+			return err
+		}
+	}
+
+	ow.Infow("pulling image", "image", image, "policy", policy)
+	out, err := cli.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+	_, err = docker.PipeOutput(out, ow.StdoutWriter())
+	return err
+}
+
+// collectLogsToFiles follows the logs of a container until it exits, and
+// writes its stdout and stderr to files in dir.
+func collectLogsToFiles(ctx context.Context, cli *client.Client, containerID string, dir string) error {
+	stream, err := cli.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Since:      "2019-01-01T00:00:00",
+		Follow:     true,
 	})
 	if err != nil {
-		return "", nil, err
+		return err
 	}
+	defer stream.Close()
 
-	subnet, gateway, err := nextDataNetwork(len(networks))
+	stdout, err := os.Create(filepath.Join(dir, "stdout.log"))
 	if err != nil {
-		return "", nil, err
-	}
-
-	id, err = docker.NewBridgeNetwork(
-		ctx,
-		cli,
-		fmt.Sprintf("tg-%s-%s-%s-%s", env.TestPlan, env.TestCase, env.RunID, name),
-		true,
-		map[string]string{
-			"testground.plan":     env.TestPlan,
-			"testground.testcase": env.TestCase,
-			"testground.run_id":   env.RunID,
-			"testground.name":     name,
-		},
-		network.IPAMConfig{
-			Subnet:  subnet.String(),
-			Gateway: gateway,
-		},
-	)
+		return err
+	}
+	defer stdout.Close()
+
+	stderr, err := os.Create(filepath.Join(dir, "stderr.log"))
+	if err != nil {
+		return err
+	}
+	defer stderr.Close()
 
-	return id, subnet, err
+	_, err = stdcopy.StdCopy(stdout, stderr, stream)
+	return err
+}
+
+// newDataNetwork creates a data network on a subnet obtained from the
+// allocator. The returned function releases the subnet, and must only be
+// called once the network has been removed.
+func newDataNetwork(ctx context.Context, cli *client.Client, rw *rpc.OutputWriter, subnets SubnetAllocator, env *api.RunInput, name string) (id string, subnet *net.IPNet, release func(), err error) {
+	// Subnets may be taken by networks that outlived a previous daemon; keep
+	// those reserved and move on to the next one.
+	for attempt := 0; attempt < 16; attempt++ {
+		var gateway string
+		subnet, gateway, release, err = subnets.Allocate()
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		id, err = docker.NewBridgeNetwork(
+			ctx,
+			cli,
+			fmt.Sprintf("tg-%s-%s-%s-%s", env.TestPlan, env.TestCase, env.RunID, name),
+			true,
+			map[string]string{
+				"testground.plan":     env.TestPlan,
+				"testground.testcase": env.TestCase,
+				"testground.run_id":   env.RunID,
+				"testground.name":     name,
+			},
+			network.IPAMConfig{
+				Subnet:  subnet.String(),
+				Gateway: gateway,
+			},
+		)
+		if err == nil {
+			return id, subnet, release, nil
+		}
+		if !strings.Contains(err.Error(), "overlaps") {
+			release()
+			return "", nil, nil, err
+		}
+		rw.Debugw("subnet already in use; trying another", "subnet", subnet, "error", err)
+	}
+	return "", nil, nil, err
 }
 
 func (r *LocalDockerRunner) CollectOutputs(ctx context.Context, input *api.CollectionInput, ow *rpc.OutputWriter) error {
@@ -739,6 +1135,19 @@ func (r *LocalDockerRunner) CollectOutputs(ctx context.Context, input *api.Colle
 	return gzipRunOutputs(ctx, dir, input, ow)
 }
 
+// pprofHostPortOf returns the host port the pprof port of the provided
+// container is published on.
+func pprofHostPortOf(ctx context.Context, cli *client.Client, containerID string) (string, error) {
+	ci, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	if ci.NetworkSettings == nil || len(ci.NetworkSettings.Ports[pprofPort]) == 0 {
+		return "", fmt.Errorf("pprof port not published for container %s", containerID)
+	}
+	return ci.NetworkSettings.Ports[pprofPort][0].HostPort, nil
+}
+
 // attachContainerToNetwork attaches the provided container to the specified
 // network.
 func attachContainerToNetwork(ctx context.Context, cli *client.Client, containerID string, networkID string) error {
@@ -747,13 +1156,62 @@ func attachContainerToNetwork(ctx context.Context, cli *client.Client, container
 	return cli.NetworkConnect(ctx, networkID, containerID, nil)
 }
 
-// nolint this function is unused, but it may come in handy.
+// detachContainerFromNetwork forcefully disconnects the provided container
+// from the specified network.
 func detachContainerFromNetwork(ctx context.Context, cli *client.Client, containerID string, networkID string) error {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	return cli.NetworkDisconnect(ctx, networkID, containerID, true)
 }
 
+// removeDataNetwork removes the data network, retrying with exponential
+// backoff while endpoints are still being released by deleted containers. If
+// the network still has active endpoints after all attempts, they are
+// forcefully disconnected before a final removal attempt.
+func removeDataNetwork(ctx context.Context, cli *client.Client, ow *rpc.OutputWriter, networkID string) error {
+	remove := func() error {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		return cli.NetworkRemove(ctx, networkID)
+	}
+
+	var err error
+	for i, sleep := 0, 500*time.Millisecond; i < 5; i, sleep = i+1, sleep*2 {
+		if err = remove(); err == nil || client.IsErrNotFound(err) {
+			return nil
+		}
+		ow.Debugw("network removal failed; retrying", "network", networkID, "attempt", i+1, "error", err)
+		time.Sleep(sleep)
+	}
+
+	ow.Warnw("network still has active endpoints; force-disconnecting", "network", networkID)
+
+	ictx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	nw, err := cli.NetworkInspect(ictx, networkID, types.NetworkInspectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to inspect network: %w", err)
+	}
+	for id := range nw.Containers {
+		if err := detachContainerFromNetwork(ctx, cli, id, networkID); err != nil && !client.IsErrNotFound(err) {
+			ow.Warnw("failed to detach container from network", "container", id, "network", networkID, "error", err)
+		}
+	}
+	return remove()
+}
+
+// Ping checks that the Docker daemon is reachable.
+func (*LocalDockerRunner) Ping(ctx context.Context) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	_, err = cli.Ping(ctx)
+	return err
+}
+
 func (*LocalDockerRunner) ID() string {
 	return "local:docker"
 }
@@ -763,7 +1221,7 @@ func (*LocalDockerRunner) ConfigType() reflect.Type {
 }
 
 func (*LocalDockerRunner) CompatibleBuilders() []string {
-	return []string{"docker:go", "docker:node", "docker:generic"}
+	return []string{"docker:go", "docker:node", "docker:rust", "docker:generic"}
 }
 
 // This method deletes the testground containers.
@@ -818,6 +1276,68 @@ func (*LocalDockerRunner) TerminateAll(ctx context.Context, ow *rpc.OutputWriter
 		return fmt.Errorf("failed to list testground containers: %w", err)
 	}
 
-	ow.Info("to delete networks and images, you may want to run `docker system prune`")
+	ow.Info("to delete networks and images, you may want to run `testground prune --runner local:docker`")
 	return nil
 }
+
+// Prune removes the resources that previous runs may have left behind:
+// exited plan containers, unused data networks, and dangling images. Networks
+// are matched by the `testground.name` label, which we apply to all data
+// networks created by this runner.
+func (*LocalDockerRunner) Prune(ctx context.Context, ow *rpc.OutputWriter) (*api.PruneReport, error) {
+	ow.Info("prune local:docker requested")
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+
+	report := new(api.PruneReport)
+
+	// Plan containers that are no longer running. Running containers are left
+	// alone; use `testground terminate` for those.
+	planOpts := types.ContainerListOptions{All: true}
+	planOpts.Filters = filters.NewArgs()
+	planOpts.Filters.Add("label", "testground.purpose=plan")
+	planOpts.Filters.Add("status", "created")
+	planOpts.Filters.Add("status", "exited")
+	planOpts.Filters.Add("status", "dead")
+
+	plancontainers, err := cli.ContainerList(ctx, planOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list test plan containers: %w", err)
+	}
+
+	containers := make([]string, 0, len(plancontainers))
+	for _, container := range plancontainers {
+		containers = append(containers, container.ID)
+	}
+
+	if err := docker.DeleteContainers(cli, ow, containers); err != nil {
+		return nil, fmt.Errorf("failed to delete test plan containers: %w", err)
+	}
+	report.Containers = containers
+
+	// Data networks without any endpoints attached.
+	nres, err := cli.NetworksPrune(ctx, filters.NewArgs(filters.Arg("label", "testground.name")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune data networks: %w", err)
+	}
+	report.Networks = nres.NetworksDeleted
+	ow.Infow("pruned data networks", "count", len(nres.NetworksDeleted))
+
+	// Dangling images left behind by builds.
+	ires, err := cli.ImagesPrune(ctx, filters.NewArgs(filters.Arg("dangling", "true")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune dangling images: %w", err)
+	}
+	for _, img := range ires.ImagesDeleted {
+		if img.Deleted != "" {
+			report.Images = append(report.Images, img.Deleted)
+		}
+	}
+	report.SpaceReclaimed = ires.SpaceReclaimed
+	ow.Infow("pruned dangling images", "count", len(report.Images), "space_reclaimed", ires.SpaceReclaimed)
+
+	return report, nil
+}
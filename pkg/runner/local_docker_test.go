@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/testground/sdk-go/runtime"
+
+	"github.com/testground/testground/pkg/task"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	cases := []struct {
+		policy string
+		want   container.RestartPolicy
+	}{
+		{"", container.RestartPolicy{}},
+		{"no", container.RestartPolicy{Name: "no"}},
+		{"on-failure", container.RestartPolicy{Name: "on-failure"}},
+		{"on-failure:3", container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}},
+	}
+	for _, c := range cases {
+		got, err := parseRestartPolicy(c.policy)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", c.policy, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%q: got %+v, want %+v", c.policy, got, c.want)
+		}
+	}
+
+	for _, policy := range []string{"sometimes", "always", "unless-stopped", "no:3", "on-failure:x", "on-failure:-1"} {
+		if _, err := parseRestartPolicy(policy); err == nil {
+			t.Errorf("%q: expected an error", policy)
+		}
+	}
+}
+
+func TestValidateAbortOnFailure(t *testing.T) {
+	cfg := LocalDockerRunnerConfig{AbortOnFailure: true, PlanRestartPolicy: "no"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg.PlanRestartPolicy = "on-failure:3"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected an error for abort_on_failure with a restart policy")
+	}
+}
+
+func TestTallyOutcomesRestarts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result := &Result{Outcomes: map[string]*GroupOutcome{
+		"a": {Total: 2},
+		"b": {Total: 1},
+	}}
+	events := make(chan *runtime.Event, 8)
+	exited := make(chan string, 3)
+
+	// a crashes, is restarted and succeeds; the other instance of a
+	// succeeds; b fails twice, then exits for good.
+	events <- &runtime.Event{CrashEvent: &runtime.CrashEvent{TestGroupID: "a"}}
+	events <- &runtime.Event{SuccessEvent: &runtime.SuccessEvent{TestGroupID: "a"}}
+	events <- &runtime.Event{FailureEvent: &runtime.FailureEvent{TestGroupID: "b"}}
+	events <- &runtime.Event{FailureEvent: &runtime.FailureEvent{TestGroupID: "b"}}
+	events <- &runtime.Event{SuccessEvent: &runtime.SuccessEvent{TestGroupID: "a"}}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tallyOutcomes(ctx, result, events, exited, nil)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("the outcomes were complete before b exited")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	exited <- "b"
+	<-done
+	if ctx.Err() != nil {
+		t.Fatal("timed out tallying the outcomes")
+	}
+
+	result.updateOutcome()
+	if result.Outcomes["a"].Ok != 2 || result.Outcomes["b"].Ok != 0 {
+		t.Errorf("unexpected outcomes: a %+v, b %+v", *result.Outcomes["a"], *result.Outcomes["b"])
+	}
+	if result.Outcome != task.OutcomeFailure {
+		t.Errorf("expected a failure, got %s", result.Outcome)
+	}
+}
+
+func TestImageIDPattern(t *testing.T) {
+	for _, ref := range []string{
+		"0123456789ab",
+		"sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	} {
+		if !imageIDPattern.MatchString(ref) {
+			t.Errorf("%q: expected an image ID", ref)
+		}
+	}
+	for _, ref := range []string{
+		"ipfs/go-ipfs:latest",
+		"registry.example.com/plan:0123456789ab",
+		"deadbeef",
+	} {
+		if imageIDPattern.MatchString(ref) {
+			t.Errorf("%q: expected an image reference", ref)
+		}
+	}
+}
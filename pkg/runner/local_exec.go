@@ -44,7 +44,20 @@ type LocalExecutableRunner struct {
 }
 
 // LocalExecutableRunnerCfg is the configuration struct for this runner.
-type LocalExecutableRunnerCfg struct{}
+type LocalExecutableRunnerCfg struct {
+	PrettyPrinterOpts
+	// StderrToFile additionally writes the stderr of each instance to a
+	// stderr.log file in its outputs directory (default: false).
+	StderrToFile bool `toml:"stderr_to_file"`
+	// NetworkNamespaces runs each instance in its own network namespace,
+	// connected to the host through a bridge, and has the local sidecar
+	// (`testground sidecar --runner local`) shape its traffic. It requires
+	// linux, root privileges and iproute2 (default: false).
+	NetworkNamespaces bool `toml:"network_namespaces"`
+	// NamespaceSubnet is the subnet of the bridge connecting the network
+	// namespaces (default: 10.87.0.0/16).
+	NamespaceSubnet string `toml:"namespace_subnet"`
+}
 
 func (r *LocalExecutableRunner) Healthcheck(ctx context.Context, engine api.Engine, ow *rpc.OutputWriter, fix bool) (*api.HealthcheckReport, error) {
 	r.lk.Lock()
@@ -64,8 +77,13 @@ func (r *LocalExecutableRunner) Healthcheck(ctx context.Context, engine api.Engi
 		healthcheck.RequiresManualFixing(),
 	)
 
+	controlIPAM, err := controlNetworkIPAM(engine.EnvConfig().Daemon)
+	if err != nil {
+		return nil, err
+	}
+
 	// setup infra which is common between local:docker and local:exec
-	localCommonHealthcheck(ctx, hh, cli, ow, "testground-control", r.outputsDir)
+	localCommonHealthcheck(ctx, hh, cli, ow, "testground-control", controlIPAM, r.outputsDir)
 
 	// RunChecks will fill the report and return any errors.
 	return hh.RunChecks(ctx, fix)
@@ -90,8 +108,33 @@ func (r *LocalExecutableRunner) Run(ctx context.Context, input *api.RunInput, ow
 		TestSubnet:         &ptypes.IPNet{IPNet: *localSubnet},
 	}
 
+	var cfg LocalExecutableRunnerCfg
+	if c, ok := input.RunnerConfig.(*LocalExecutableRunnerCfg); ok && c != nil {
+		cfg = *c
+	}
+
+	// the host running the infrastructure, as seen by the instances.
+	infraHost := "localhost"
+
+	var netns *localExecNetns
+	if cfg.NetworkNamespaces {
+		var err error
+		if netns, err = newLocalExecNetns(input.RunID, cfg.NamespaceSubnet); err != nil {
+			return nil, fmt.Errorf("failed to set up network namespaces: %w", err)
+		}
+		defer func() {
+			if err := netns.Close(); err != nil {
+				ow.Warnw("failed to clean up network namespaces", "error", err)
+			}
+		}()
+
+		infraHost = netns.gateway.String()
+		template.TestSidecar = true
+		template.TestSubnet = &ptypes.IPNet{IPNet: *netns.subnet}
+	}
+
 	// Spawn as many instances as the input parameters require.
-	pretty := NewPrettyPrinter(ow)
+	pretty := NewPrettyPrinter(ow, cfg.PrettyPrinterOpts)
 	commands := make([]*exec.Cmd, 0, input.TotalInstances)
 	defer func() {
 		for _, cmd := range commands {
@@ -103,12 +146,22 @@ func (r *LocalExecutableRunner) Run(ctx context.Context, input *api.RunInput, ow
 		_ = pretty.Wait()
 	}()
 
+	if err := reviewRunResources(input, api.Resources{}, false, nil, ow); err != nil {
+		return nil, err
+	}
+
+	if err := writeRunMetadata(filepath.Join(r.outputsDir, input.TestPlan, input.RunID), input); err != nil {
+		ow.Warnw("failed to write run metadata", "error", err)
+	}
+
 	var (
-		total   int
-		tmpdirs []string
+		total     int
+		tmpdirs   []string
+		scheduled = time.Now()
 	)
 	for _, g := range input.Groups {
 		reviewResources(g, ow)
+		reviewInitContainers(g, ow)
 
 		for i := 0; i < g.Instances; i++ {
 			total++
@@ -136,19 +189,36 @@ func (r *LocalExecutableRunner) Run(ctx context.Context, input *api.RunInput, ow
 			runenv.TestInstanceParams = g.Parameters
 			runenv.TestOutputsPath = odir
 			runenv.TestTempPath = tmpdir
-			runenv.TestStartTime = time.Now()
+			runenv.TestStartTime = input.StartTime
 			runenv.TestCaptureProfiles = g.Profiles
+			runenv.TestInstanceRole = g.Role
 
 			env := conv.ToOptionsSlice(runenv.ToEnvVars())
-			env = append(env, "INFLUXDB_URL=http://localhost:8086")
+			env = append(env, "INFLUXDB_URL=http://"+infraHost+":8086")
 			// NOTE: we export REDIS_HOST for compatibility with older sdk versions.
-			env = append(env, "REDIS_HOST=localhost")
-			env = append(env, "SYNC_SERVICE_HOST=localhost")
+			env = append(env, "REDIS_HOST="+infraHost)
+			env = append(env, "SYNC_SERVICE_HOST="+infraHost)
 			env = append(env, "PATH="+os.Getenv("PATH"))
+			env = append(env, conv.ToOptionsSlice(instanceEnvVars(i, total-1))...)
+			env = append(env, fmt.Sprintf("%s=%d", EnvTestRunSeed, runSeed(input.RunID)))
+			env = append(env, fmt.Sprintf("%s=%s", EnvTestRequestID, input.RequestID))
+			env = append(env, conv.ToOptionsSlice(topologyEnvVars(input, g))...)
+			env = append(env, conv.ToOptionsSlice(lifecycleEnvVars(input.StartTime, scheduled, time.Now()))...)
 
 			ow.Infow("starting test case instance", "plan", input.TestPlan, "group", g.ID, "number", i, "total", total)
 
 			cmd := exec.CommandContext(ctx, g.ArtifactPath)
+			if netns != nil {
+				name, err := netns.Add(total - 1)
+				if err == nil {
+					err = netns.Register(name, env)
+				}
+				if err != nil {
+					pretty.FailStart(tag, fmt.Errorf("failed to set up network namespace: %w", err))
+					continue
+				}
+				cmd = exec.CommandContext(ctx, "ip", "netns", "exec", name, g.ArtifactPath)
+			}
 			stdout, _ := cmd.StdoutPipe()
 			stderr, _ := cmd.StderrPipe()
 			cmd.Env = env
@@ -160,6 +230,14 @@ func (r *LocalExecutableRunner) Run(ctx context.Context, input *api.RunInput, ow
 
 			commands = append(commands, cmd)
 
+			if cfg.StderrToFile {
+				if tee, err := teeStderrToFile(stderr, filepath.Join(odir, "stderr.log")); err == nil {
+					stderr = tee
+				} else {
+					ow.Warnw("failed to write stderr to file", "group", g.ID, "number", i, "error", err)
+				}
+			}
+
 			// instance tag in output: << group[zero_padded_i] >>, e.g. << miner[003] >>
 			pretty.Manage(tag, stdout, stderr)
 		}
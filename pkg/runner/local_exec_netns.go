@@ -0,0 +1,229 @@
+package runner
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"sync"
+
+	"github.com/testground/testground/pkg/sidecar"
+)
+
+const (
+	// localExecBridge is the bridge connecting the network namespaces of
+	// local:exec instances to the host.
+	localExecBridge = "tg-exec0"
+
+	// localExecLink is the name of the interface in the namespace of a
+	// local:exec instance. It carries both the data traffic and the traffic
+	// to the infrastructure on the host.
+	localExecLink = "eth0"
+
+	defaultLocalExecSubnet = "10.87.0.0/16"
+)
+
+// localExecAddrs tracks the addresses of the namespace subnet in use by
+// instances, across concurrent runs. Addresses are offsets in the subnet.
+var localExecAddrs = struct {
+	sync.Mutex
+	inuse map[int]struct{}
+}{inuse: make(map[int]struct{})}
+
+// localExecNetns sets up and tears down the network namespaces of the
+// instances of a local:exec run, and registers them with the local sidecar.
+// Each instance gets a veth pair, with one end in its namespace and the other
+// attached to a bridge on the host, whose address is the gateway.
+//
+// It shells out to iproute2, and requires root privileges.
+type localExecNetns struct {
+	runID   string
+	subnet  *net.IPNet
+	gateway net.IP
+	dir     string
+
+	namespaces    []string
+	registrations []string
+	addrs         []int
+}
+
+func newLocalExecNetns(runID string, subnet string) (*localExecNetns, error) {
+	if goruntime.GOOS != "linux" {
+		return nil, fmt.Errorf("network namespaces are only supported on linux, not %s", goruntime.GOOS)
+	}
+
+	if subnet == "" {
+		subnet = defaultLocalExecSubnet
+	}
+	_, ipnet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace subnet %q: %w", subnet, err)
+	}
+	if ipnet.IP.To4() == nil {
+		return nil, fmt.Errorf("namespace subnet %s is not an ipv4 subnet", subnet)
+	}
+
+	n := &localExecNetns{
+		runID:   runID,
+		subnet:  ipnet,
+		gateway: ipAt(ipnet, 1),
+		dir:     sidecar.LocalSidecarDir(),
+	}
+	if err := os.MkdirAll(n.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create local sidecar directory: %w", err)
+	}
+	if err := n.ensureBridge(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// ipAt returns the nth address of the subnet.
+func ipAt(subnet *net.IPNet, n int) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(subnet.IP.To4())+uint32(n))
+	return ip
+}
+
+func ipCmd(args ...string) error {
+	out, err := exec.Command("ip", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ip %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ensureBridge creates the bridge on the host, unless it already exists.
+func (n *localExecNetns) ensureBridge() error {
+	if exec.Command("ip", "link", "show", localExecBridge).Run() == nil {
+		return nil
+	}
+
+	ones, _ := n.subnet.Mask.Size()
+	for _, args := range [][]string{
+		{"link", "add", localExecBridge, "type", "bridge"},
+		{"addr", "add", fmt.Sprintf("%s/%d", n.gateway, ones), "dev", localExecBridge},
+		{"link", "set", localExecBridge, "up"},
+	} {
+		if err := ipCmd(args...); err != nil {
+			return fmt.Errorf("failed to create bridge %s: %w", localExecBridge, err)
+		}
+	}
+	return nil
+}
+
+// allocate reserves a free address in the subnet, returning its offset.
+func (n *localExecNetns) allocate() (int, error) {
+	localExecAddrs.Lock()
+	defer localExecAddrs.Unlock()
+
+	ones, bits := n.subnet.Mask.Size()
+	// the network address, the gateway and the broadcast address are taken.
+	for offset := 2; offset < 1<<uint(bits-ones)-1; offset++ {
+		if _, ok := localExecAddrs.inuse[offset]; !ok {
+			localExecAddrs.inuse[offset] = struct{}{}
+			n.addrs = append(n.addrs, offset)
+			return offset, nil
+		}
+	}
+	return 0, fmt.Errorf("namespace subnet %s is exhausted", n.subnet)
+}
+
+// Add creates the network namespace of the seq-th instance of the run, and
+// returns its name, to be used with `ip netns exec`.
+func (n *localExecNetns) Add(seq int) (name string, err error) {
+	offset, err := n.allocate()
+	if err != nil {
+		return "", err
+	}
+	addr := ipAt(n.subnet, offset)
+	ones, _ := n.subnet.Mask.Size()
+
+	name = fmt.Sprintf("tg-%s-%d", n.runID, seq)
+	// interface names are limited to 15 characters.
+	host := fmt.Sprintf("tg%x", offset)
+
+	if err := ipCmd("netns", "add", name); err != nil {
+		return "", err
+	}
+	n.namespaces = append(n.namespaces, name)
+
+	for _, args := range [][]string{
+		{"link", "add", host, "type", "veth", "peer", "name", localExecLink, "netns", name},
+		{"link", "set", host, "master", localExecBridge},
+		{"link", "set", host, "up"},
+		{"-n", name, "addr", "add", fmt.Sprintf("%s/%d", addr, ones), "dev", localExecLink},
+		{"-n", name, "link", "set", localExecLink, "up"},
+		{"-n", name, "link", "set", "lo", "up"},
+		{"-n", name, "route", "add", "default", "via", n.gateway.String()},
+	} {
+		if err := ipCmd(args...); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// Register registers the instance running in the namespace with the local
+// sidecar.
+func (n *localExecNetns) Register(name string, env []string) error {
+	b, err := json.Marshal(sidecar.LocalInstance{
+		Hostname: name,
+		Netns:    name,
+		Link:     localExecLink,
+		Env:      env,
+	})
+	if err != nil {
+		return err
+	}
+
+	// write atomically, so that the sidecar never reads a partial file.
+	path := filepath.Join(n.dir, name+".json")
+	tmp, err := ioutil.TempFile(n.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	n.registrations = append(n.registrations, path)
+	return nil
+}
+
+// Close unregisters the instances from the local sidecar, and deletes their
+// namespaces, along with their veth pairs.
+func (n *localExecNetns) Close() error {
+	var err error
+	for _, path := range n.registrations {
+		if rerr := os.Remove(path); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+			err = rerr
+		}
+	}
+	for _, name := range n.namespaces {
+		if derr := ipCmd("netns", "del", name); derr != nil && err == nil {
+			err = derr
+		}
+	}
+
+	localExecAddrs.Lock()
+	for _, offset := range n.addrs {
+		delete(localExecAddrs.inuse, offset)
+	}
+	localExecAddrs.Unlock()
+	return err
+}
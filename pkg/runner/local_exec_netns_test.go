@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"net"
+	"testing"
+)
+
+func TestLocalExecNetnsAllocate(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.87.0.0/29")
+
+	a := &localExecNetns{subnet: subnet}
+	b := &localExecNetns{subnet: subnet}
+
+	// a /29 has 5 addresses for instances, shared by concurrent runs.
+	var got []string
+	for _, n := range []*localExecNetns{a, a, a, b, b} {
+		offset, err := n.allocate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ipAt(subnet, offset).String())
+	}
+	want := []string{"10.87.0.2", "10.87.0.3", "10.87.0.4", "10.87.0.5", "10.87.0.6"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("allocation %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+
+	if _, err := b.allocate(); err == nil {
+		t.Fatal("expected the subnet to be exhausted")
+	}
+
+	// closing a run releases its addresses.
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	offset, err := b.allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip := ipAt(subnet, offset).String(); ip != "10.87.0.2" {
+		t.Errorf("expected a released address, got %s", ip)
+	}
+	_ = b.Close()
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -30,17 +31,39 @@ const (
 	Metric
 	Other
 	InternalErr
+	Stderr
 )
 
 func (et eventType) String() string {
-	return [...]string{"Error", "Start", "Ok", "Fail", "Crash", "Incomplete", "Message", "Metric", "Other", "InternalErr"}[et]
+	return [...]string{"Error", "Start", "Ok", "Fail", "Crash", "Incomplete", "Message", "Metric", "Other", "InternalErr", "Stderr"}[et]
+}
+
+// PrettyPrinterOpts configures how the PrettyPrinter renders instance output.
+// It is embedded in the configuration of the runners that use it.
+type PrettyPrinterOpts struct {
+	// StderrOnly suppresses the unstructured stdout lines and messages of
+	// instances, only showing their stderr and lifecycle events (default:
+	// false).
+	StderrOnly bool `toml:"stderr_only"`
+	// HighlightStderr colorizes the stderr lines of instances, in addition to
+	// tagging them (default: false).
+	HighlightStderr bool `toml:"highlight_stderr"`
+	// DedupWindowMs collapses identical log lines emitted by any instance
+	// within a window of this many milliseconds into a single line with a
+	// count (default: 0, disabled).
+	DedupWindowMs int `toml:"dedup_window_ms"`
+	// RateLimit caps the number of log lines shown per instance per second;
+	// excess lines are dropped and counted (default: 0, unlimited).
+	RateLimit int `toml:"rate_limit"`
 }
 
 // PrettyPrinter is a logger that sends output to the console.
 type PrettyPrinter struct {
 	aurora  aurora.Aurora
-	classes [10]aurora.Value
+	classes [11]aurora.Value
 	ow      *rpc.OutputWriter
+	opts    PrettyPrinterOpts
+	filter  *lineFilter
 
 	// guarded by atomic.
 	failed uint32
@@ -51,7 +74,7 @@ type PrettyPrinter struct {
 }
 
 // NewPrettyPrinter constructs a new console logger.
-func NewPrettyPrinter(ow *rpc.OutputWriter) *PrettyPrinter {
+func NewPrettyPrinter(ow *rpc.OutputWriter, opts PrettyPrinterOpts) *PrettyPrinter {
 	au := aurora.NewAurora(logging.IsTerminal())
 	return &PrettyPrinter{
 		aurora: au,
@@ -66,9 +89,12 @@ func NewPrettyPrinter(ow *rpc.OutputWriter) *PrettyPrinter {
 			aurora.BgBlue("METRIC").White(),
 			aurora.BgMagenta("OTHER").White(),
 			aurora.BgBrightRed("INTERNAL_ERR").White(),
+			aurora.BgYellow("STDERR").Black(),
 		},
-		start: time.Now(),
-		ow:    ow,
+		start:  time.Now(),
+		ow:     ow,
+		opts:   opts,
+		filter: newLineFilter(opts),
 	}
 }
 
@@ -78,6 +104,7 @@ func (c *PrettyPrinter) Wait() <-chan error {
 	ch := make(chan error)
 	go func() {
 		c.wg.Wait()
+		c.flushFiltered(true)
 		if f := atomic.LoadUint32(&c.failed); f > 0 {
 			ch <- fmt.Errorf("%d nodes failed", f)
 		}
@@ -101,7 +128,11 @@ func (c *PrettyPrinter) processStderr(idx uint32, id string, stderr io.ReadClose
 	scanner := bufio.NewScanner(stderr)
 
 	for scanner.Scan() {
-		c.print(idx, id, time.Now(), Error, scanner.Text())
+		if c.opts.HighlightStderr {
+			c.print(idx, id, time.Now(), Stderr, c.aurora.Red(scanner.Text()))
+		} else {
+			c.print(idx, id, time.Now(), Stderr, scanner.Text())
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -142,7 +173,9 @@ func (c *PrettyPrinter) processStdout(idx uint32, id string, stdout io.ReadClose
 		case io.EOF, context.Canceled:
 			return
 		default:
-			c.print(idx, id, time.Now(), Other, string(line))
+			if !c.opts.StderrOnly {
+				c.print(idx, id, time.Now(), Other, string(line))
+			}
 			continue
 		}
 
@@ -156,7 +189,9 @@ func (c *PrettyPrinter) processStdout(idx uint32, id string, stdout io.ReadClose
 		ts = time.Unix(0, nanos)
 
 		if err := json.Unmarshal(all["event"], &evt); err != nil {
-			c.print(idx, id, time.Now(), Other, string(line))
+			if !c.opts.StderrOnly {
+				c.print(idx, id, time.Now(), Other, string(line))
+			}
 			continue
 		}
 
@@ -171,7 +206,9 @@ func (c *PrettyPrinter) processStdout(idx uint32, id string, stdout io.ReadClose
 			failed = true
 			c.print(idx, id, ts, Crash, evt.CrashEvent.Error, evt.CrashEvent.Stacktrace)
 		case evt.MessageEvent != nil:
-			c.print(idx, id, ts, Message, evt.Message)
+			if !c.opts.StderrOnly {
+				c.print(idx, id, ts, Message, evt.Message)
+			}
 		case evt.StartEvent != nil:
 			m, _ := json.Marshal(evt.StartEvent.Runenv)
 			c.print(idx, id, ts, Start, string(m))
@@ -214,11 +251,58 @@ func (c *PrettyPrinter) Append(id string, stdout, stderr io.ReadCloser) {
 	}()
 }
 
+// teeStderrToFile returns a ReadCloser that copies everything read from
+// stderr to a file at path. Closing it closes both stderr and the file.
+func teeStderrToFile(stderr io.ReadCloser, path string) (io.ReadCloser, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &teeReadCloser{Reader: io.TeeReader(stderr, f), closers: []io.Closer{stderr, f}}, nil
+}
+
+type teeReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (t *teeReadCloser) Close() error {
+	var err error
+	for _, c := range t.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
 func (c *PrettyPrinter) print(idx uint32, id string, now time.Time, evtType eventType, message ...interface{}) {
+	msg := fmt.Sprint(message...)
+
+	if c.filter != nil {
+		c.flushFiltered(false)
+		if !c.filter.admit(idx, id, evtType, msg, now) {
+			return
+		}
+	}
+	c.emit(idx, id, now, evtType, msg)
+}
+
+// flushFiltered prints the lines collapsed by the filter, either once their
+// window has elapsed, or all of them if force is set.
+func (c *PrettyPrinter) flushFiltered(force bool) {
+	if c.filter == nil {
+		return
+	}
+	for _, l := range c.filter.flush(time.Now(), force) {
+		c.emit(l.idx, l.id, time.Now(), l.evtType, l.msg)
+	}
+}
+
+func (c *PrettyPrinter) emit(idx uint32, id string, now time.Time, evtType eventType, msg string) {
 	var (
 		elapsed = now.Sub(c.start)
 		class   = c.classes[evtType]
-		msg     = fmt.Sprint(message...)
 	)
 
 	if elapsed < 0 {
@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// lineFilter deduplicates and rate limits the lines printed by the
+// PrettyPrinter. Lifecycle events (start, success, failure, etc.) are never
+// filtered.
+type lineFilter struct {
+	lk sync.Mutex
+
+	window time.Duration
+	seen   map[string]*seenLine
+
+	rate    int
+	buckets map[uint32]*rateBucket
+
+	// nextFlush avoids scanning all tracked lines on every print.
+	nextFlush time.Time
+}
+
+// seenLine tracks a line that was printed, and how many times it has been
+// repeated since.
+type seenLine struct {
+	idx     uint32
+	id      string
+	evtType eventType
+	msg     string
+	repeats int
+	expires time.Time
+}
+
+// rateBucket counts the lines of an instance within the current second.
+type rateBucket struct {
+	id      string
+	second  int64
+	count   int
+	dropped int
+}
+
+// filteredLine is a line produced by the filter, to be printed.
+type filteredLine struct {
+	idx     uint32
+	id      string
+	evtType eventType
+	msg     string
+}
+
+// newLineFilter returns a filter for the supplied options, or nil if no
+// filtering is enabled.
+func newLineFilter(opts PrettyPrinterOpts) *lineFilter {
+	if opts.DedupWindowMs <= 0 && opts.RateLimit <= 0 {
+		return nil
+	}
+	return &lineFilter{
+		window:  time.Duration(opts.DedupWindowMs) * time.Millisecond,
+		seen:    make(map[string]*seenLine),
+		rate:    opts.RateLimit,
+		buckets: make(map[uint32]*rateBucket),
+	}
+}
+
+func filterable(evtType eventType) bool {
+	switch evtType {
+	case Other, Message, Stderr:
+		return true
+	default:
+		return false
+	}
+}
+
+// admit returns whether a line should be printed right away.
+func (f *lineFilter) admit(idx uint32, id string, evtType eventType, msg string, now time.Time) bool {
+	if !filterable(evtType) {
+		return true
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	if f.rate > 0 {
+		b, ok := f.buckets[idx]
+		if !ok {
+			b = &rateBucket{id: id}
+			f.buckets[idx] = b
+		}
+		if sec := now.Unix(); sec != b.second {
+			b.second, b.count = sec, 0
+		}
+		if b.count >= f.rate {
+			b.dropped++
+			return false
+		}
+		b.count++
+	}
+
+	if f.window > 0 {
+		key := fmt.Sprintf("%d:%s", evtType, msg)
+		if l, ok := f.seen[key]; ok && now.Before(l.expires) {
+			l.repeats++
+			return false
+		}
+		f.seen[key] = &seenLine{idx: idx, id: id, evtType: evtType, msg: msg, expires: now.Add(f.window)}
+	}
+	return true
+}
+
+// flush returns the summaries of the lines whose dedup window has elapsed, as
+// well as notices of lines dropped by the rate limiter. If force is set, all
+// pending summaries and notices are returned.
+func (f *lineFilter) flush(now time.Time, force bool) []filteredLine {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	if !force && now.Before(f.nextFlush) {
+		return nil
+	}
+	f.nextFlush = now.Add(time.Second)
+	if f.window > 0 && f.window < time.Second {
+		f.nextFlush = now.Add(f.window)
+	}
+
+	var out []filteredLine
+	for key, l := range f.seen {
+		if !force && now.Before(l.expires) {
+			continue
+		}
+		if l.repeats > 0 {
+			out = append(out, filteredLine{l.idx, l.id, l.evtType, fmt.Sprintf("(x%d) %s", l.repeats+1, l.msg)})
+		}
+		delete(f.seen, key)
+	}
+
+	for idx, b := range f.buckets {
+		if b.dropped == 0 || (!force && now.Unix() == b.second) {
+			continue
+		}
+		out = append(out, filteredLine{idx, b.id, Other, fmt.Sprintf("rate limit exceeded; dropped %d lines", b.dropped)})
+		b.dropped = 0
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineFilterDedup(t *testing.T) {
+	f := newLineFilter(PrettyPrinterOpts{DedupWindowMs: 100})
+	now := time.Now()
+
+	if !f.admit(0, "a", Other, "peer connected", now) {
+		t.Fatal("expected first line to be admitted")
+	}
+	for i := uint32(1); i < 5; i++ {
+		if f.admit(i, "b", Other, "peer connected", now) {
+			t.Fatal("expected duplicate line to be collapsed")
+		}
+	}
+	if !f.admit(0, "a", Other, "peer disconnected", now) {
+		t.Fatal("expected distinct line to be admitted")
+	}
+	if !f.admit(0, "a", Ok, "", now) || !f.admit(1, "b", Ok, "", now) {
+		t.Fatal("expected lifecycle events to never be filtered")
+	}
+
+	if out := f.flush(now, false); len(out) != 0 {
+		t.Fatalf("expected nothing to flush within the window, got %v", out)
+	}
+
+	out := f.flush(now.Add(200*time.Millisecond), false)
+	if len(out) != 1 || out[0].msg != "(x5) peer connected" || out[0].id != "a" {
+		t.Fatalf("unexpected flushed lines: %v", out)
+	}
+
+	if !f.admit(1, "b", Other, "peer connected", now.Add(200*time.Millisecond)) {
+		t.Fatal("expected line to be admitted after the window elapsed")
+	}
+}
+
+func TestLineFilterRateLimit(t *testing.T) {
+	f := newLineFilter(PrettyPrinterOpts{RateLimit: 2})
+	now := time.Unix(1000, 0)
+
+	for i, want := range []bool{true, true, false, false} {
+		if got := f.admit(0, "a", Stderr, "line", now); got != want {
+			t.Fatalf("line %d: admitted=%v, want %v", i, got, want)
+		}
+	}
+	if !f.admit(1, "b", Stderr, "line", now) {
+		t.Fatal("expected rate limit to be per instance")
+	}
+
+	out := f.flush(now.Add(time.Second), false)
+	if len(out) != 1 || out[0].msg != "rate limit exceeded; dropped 2 lines" || out[0].id != "a" {
+		t.Fatalf("unexpected flushed lines: %v", out)
+	}
+	if !f.admit(0, "a", Stderr, "line", now.Add(time.Second)) {
+		t.Fatal("expected line to be admitted in the next second")
+	}
+}
+
+func TestLineFilterDisabled(t *testing.T) {
+	if f := newLineFilter(PrettyPrinterOpts{}); f != nil {
+		t.Fatal("expected no filter when dedup and rate limiting are disabled")
+	}
+}
@@ -11,13 +11,18 @@ import (
 	"github.com/docker/docker/client"
 )
 
+// pushToDockerRegistry pushes the images of the groups of a run to the
+// registry at uri, and replaces their artifact paths by the pushed images. The
+// pushed images are referred to by digest when the registry reports one, so
+// that the instances run exactly the images that were pushed, even if their
+// tags are overwritten.
 func (c *ClusterK8sRunner) pushToDockerRegistry(ctx context.Context, ow *rpc.OutputWriter, client *client.Client, in *api.RunInput, ipo types.ImagePushOptions, uri string) error {
 	for _, g := range in.Groups {
 		tag := uri + ":" + g.ArtifactPath
 
-		if _, ok := c.imagesLRU.Get(tag); ok {
-			ow.Infow("image already pushed and tagged", "group_id", g.ID, "tag", tag)
-			g.ArtifactPath = tag
+		if ref, ok := c.imagesLRU.Get(tag); ok {
+			ow.Infow("image already pushed and tagged", "group_id", g.ID, "tag", tag, "ref", ref)
+			g.ArtifactPath = ref.(string)
 			continue
 		}
 
@@ -32,16 +37,28 @@ func (c *ClusterK8sRunner) pushToDockerRegistry(ctx context.Context, ow *rpc.Out
 			return err
 		}
 
-		if _, err := docker.PipeOutput(rc, ow.StdoutWriter()); err != nil {
+		digest, err := docker.PipePushOutput(rc, ow.StdoutWriter())
+		rc.Close()
+		if err != nil {
 			return err
 		}
 
-		c.imagesLRU.Add(tag, struct{}{})
+		ref := pushedImageRef(uri, tag, digest)
+		c.imagesLRU.Add(tag, ref)
 
 		// replace the artifact path by the pushed image.
-		g.ArtifactPath = tag
-		ow.Infow("pushed image for group", "group_id", g.ID, "tag", tag)
+		g.ArtifactPath = ref
+		ow.Infow("pushed image for group", "group_id", g.ID, "tag", tag, "digest", digest)
 	}
 
 	return nil
 }
+
+// pushedImageRef returns the reference of an image pushed to the repository
+// at uri: by digest, if known, or else by tag.
+func pushedImageRef(uri, tag, digest string) string {
+	if digest == "" {
+		return tag
+	}
+	return uri + "@" + digest
+}
@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func retry(attempts int, sleep time.Duration, f func() error) (err error) {
+	for i := 0; ; i++ {
+		err = f()
+		if err == nil {
+			return
+		}
+
+		if i >= (attempts - 1) {
+			break
+		}
+
+		time.Sleep(sleep)
+	}
+	return fmt.Errorf("after %d attempts, last error: %s", attempts, err)
+}
+
+// retryBackoff calls f until it succeeds, up to attempts times, as long as
+// its errors are retryable. The sleep between attempts starts at sleep and
+// doubles after each attempt. It stops early when ctx is done. The returned
+// error wraps the last error of f, so that callers can inspect it.
+func retryBackoff(ctx context.Context, attempts int, sleep time.Duration, retryable func(error) bool, f func() error) (err error) {
+	for i := 0; ; i++ {
+		err = f()
+		if err == nil || !retryable(err) {
+			return err
+		}
+
+		if i >= (attempts - 1) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (after %d attempts, last error: %s)", ctx.Err(), i+1, err)
+		case <-time.After(sleep):
+		}
+		sleep *= 2
+	}
+	return fmt.Errorf("after %d attempts, last error: %w", attempts, err)
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	retryable := func(err error) bool { return errors.Is(err, errTransient) }
+
+	var calls int
+	err := retryBackoff(context.Background(), 5, time.Millisecond, retryable, func() error {
+		calls++
+		if calls < 3 {
+			return errTransient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("expected success after 3 calls, got %d calls and err %v", calls, err)
+	}
+
+	calls = 0
+	err = retryBackoff(context.Background(), 5, time.Millisecond, retryable, func() error {
+		calls++
+		return errPermanent
+	})
+	if !errors.Is(err, errPermanent) || calls != 1 {
+		t.Errorf("expected a permanent error not to be retried, got %d calls and err %v", calls, err)
+	}
+
+	calls = 0
+	err = retryBackoff(context.Background(), 3, time.Millisecond, retryable, func() error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) || calls != 3 {
+		t.Errorf("expected 3 attempts wrapping the last error, got %d calls and err %v", calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	err = retryBackoff(ctx, 5, time.Hour, retryable, func() error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("expected a canceled context to stop retries, got %d calls and err %v", calls, err)
+	}
+}
@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// maxDataNetworks is the number of distinct data network subnets that
+// nextDataNetwork can produce.
+const maxDataNetworks = 4096
+
+// ErrSubnetsExhausted is returned by a SubnetAllocator when all data network
+// subnets are in use.
+var ErrSubnetsExhausted = errors.New("data network subnets exhausted")
+
+// SubnetAllocator hands out data network subnets to test runs. Each
+// allocation is exclusive until its release function is called.
+type SubnetAllocator interface {
+	// Allocate reserves a free subnet, returning it along with its gateway
+	// and a function that returns the subnet to the pool.
+	Allocate() (subnet *net.IPNet, gateway string, release func(), err error)
+}
+
+// SubnetAllocatorSetter is implemented by runners that allocate data network
+// subnets, so that the engine can inject a shared allocator.
+type SubnetAllocatorSetter interface {
+	SetSubnetAllocator(SubnetAllocator)
+}
+
+// MemorySubnetAllocator allocates subnets within a single process. It hands
+// out subnets round-robin, so a released subnet is only reused after all
+// others have been handed out.
+type MemorySubnetAllocator struct {
+	lk     sync.Mutex
+	cursor int
+	inuse  map[int]struct{}
+}
+
+var _ SubnetAllocator = (*MemorySubnetAllocator)(nil)
+
+// NewMemorySubnetAllocator returns an in-memory allocator that starts handing
+// out subnets at the provided offset.
+func NewMemorySubnetAllocator(offset int) *MemorySubnetAllocator {
+	return &MemorySubnetAllocator{
+		cursor: offset % maxDataNetworks,
+		inuse:  make(map[int]struct{}),
+	}
+}
+
+func (a *MemorySubnetAllocator) Allocate() (*net.IPNet, string, func(), error) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	for i := 0; i < maxDataNetworks; i++ {
+		idx := (a.cursor + i) % maxDataNetworks
+		if _, ok := a.inuse[idx]; ok {
+			continue
+		}
+
+		subnet, gateway, err := nextDataNetwork(idx)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		a.inuse[idx] = struct{}{}
+		a.cursor = (idx + 1) % maxDataNetworks
+
+		var once sync.Once
+		release := func() {
+			once.Do(func() {
+				a.lk.Lock()
+				delete(a.inuse, idx)
+				a.lk.Unlock()
+			})
+		}
+		return subnet, gateway, release, nil
+	}
+	return nil, "", nil, ErrSubnetsExhausted
+}
+
+// redisSubnetLeaseTTL bounds how long a subnet stays reserved in Redis if
+// its owner dies without releasing it.
+const redisSubnetLeaseTTL = 24 * time.Hour
+
+// RedisSubnetAllocator coordinates subnet allocation across multiple
+// daemons through Redis, by holding a lease key per allocated subnet.
+type RedisSubnetAllocator struct {
+	client *redis.Client
+	prefix string
+	local  *MemorySubnetAllocator
+}
+
+var _ SubnetAllocator = (*RedisSubnetAllocator)(nil)
+
+// NewRedisSubnetAllocator returns an allocator that stores subnet leases
+// under the provided key prefix in Redis.
+func NewRedisSubnetAllocator(client *redis.Client, prefix string) *RedisSubnetAllocator {
+	return &RedisSubnetAllocator{
+		client: client,
+		prefix: prefix,
+		local:  NewMemorySubnetAllocator(0),
+	}
+}
+
+func (a *RedisSubnetAllocator) Allocate() (*net.IPNet, string, func(), error) {
+	// Subnets leased by other daemons are held locally until we find a free
+	// one, and then returned so that they're probed again next time.
+	var skipped []func()
+	defer func() {
+		for _, release := range skipped {
+			release()
+		}
+	}()
+
+	for i := 0; i < maxDataNetworks; i++ {
+		subnet, gateway, release, err := a.local.Allocate()
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		key := fmt.Sprintf("%s:%s", a.prefix, subnet)
+		ok, err := a.client.SetNX(key, true, redisSubnetLeaseTTL).Result()
+		if err != nil {
+			release()
+			return nil, "", nil, fmt.Errorf("failed to lease subnet %s: %w", subnet, err)
+		}
+		if !ok {
+			skipped = append(skipped, release)
+			continue
+		}
+
+		var once sync.Once
+		return subnet, gateway, func() {
+			once.Do(func() {
+				a.client.Del(key)
+				release()
+			})
+		}, nil
+	}
+	return nil, "", nil, ErrSubnetsExhausted
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"testing"
+)
+
+func TestMemorySubnetAllocator(t *testing.T) {
+	a := NewMemorySubnetAllocator(4094)
+
+	first, gateway, releaseFirst, err := a.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != "31.254.0.0/16" || gateway != "31.254.0.1" {
+		t.Errorf("got subnet %s gateway %s, want 31.254.0.0/16 and 31.254.0.1", first, gateway)
+	}
+
+	second, _, _, err := a.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.String() != "31.255.0.0/16" {
+		t.Errorf("got subnet %s, want 31.255.0.0/16", second)
+	}
+
+	// allocation wraps around.
+	third, _, _, err := a.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.String() != "16.0.0.0/16" {
+		t.Errorf("got subnet %s, want 16.0.0.0/16", third)
+	}
+
+	// exhaust the remaining subnets.
+	for i := 3; i < maxDataNetworks; i++ {
+		if _, _, _, err := a.Allocate(); err != nil {
+			t.Fatalf("allocation %d failed: %s", i, err)
+		}
+	}
+	if _, _, _, err := a.Allocate(); err != ErrSubnetsExhausted {
+		t.Fatalf("expected %v, got %v", ErrSubnetsExhausted, err)
+	}
+
+	// a released subnet becomes available again.
+	releaseFirst()
+	releaseFirst()
+	again, _, _, err := a.Allocate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != first.String() {
+		t.Errorf("got subnet %s, want %s", again, first)
+	}
+}
@@ -4,13 +4,16 @@ package sidecar
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"syscall"
 
 	sdknw "github.com/testground/sdk-go/network"
 	"github.com/testground/testground/pkg/docker"
 
 	"github.com/docker/docker/api/types/network"
+	"github.com/hashicorp/go-multierror"
 	"github.com/vishvananda/netlink"
 )
 
@@ -24,12 +27,33 @@ type DockerNetwork struct {
 	activeLinks     map[string]*dockerLink // name -> link handle
 	availableLinks  map[string]string      // name -> id
 	externalRouting map[string]*route      // id -> routes
+	controlRoutes   []netlink.Route        // routes added to reach the infra
 	nl              *netlink.Handle
 }
 
+// Close restores the routes of the container, and releases the network.
 func (dn *DockerNetwork) Close() error {
+	err := dn.restoreRoutes()
 	dn.nl.Delete()
-	return nil
+	return err
+}
+
+// restoreRoutes restores the route table of the container as it was before
+// the sidecar modified it, so that containers don't lose connectivity when
+// they're no longer managed, e.g. when the sidecar restarts.
+func (dn *DockerNetwork) restoreRoutes() error {
+	var merr *multierror.Error
+	for _, route := range dn.controlRoutes {
+		if err := dn.nl.RouteDel(&route); err != nil && !errors.Is(err, syscall.ESRCH) {
+			merr = multierror.Append(merr, fmt.Errorf("failed to remove control route %s: %w", route, err))
+		}
+	}
+	dn.controlRoutes = nil
+
+	for _, routing := range dn.externalRouting {
+		merr = multierror.Append(merr, routing.restore(dn.nl))
+	}
+	return merr.ErrorOrNil()
 }
 
 func (dn *DockerNetwork) ListAvailable() []string {
@@ -51,12 +75,12 @@ func (dn *DockerNetwork) ListActive() []string {
 func (dn *DockerNetwork) ConfigureNetwork(ctx context.Context, cfg *sdknw.Config) error {
 	netId, available := dn.availableLinks[cfg.Network]
 	if !available {
-		return fmt.Errorf("unsupported network: %s", cfg.Network)
+		return networkFailure(FailureUnsupportedNetwork, fmt.Errorf("unsupported network: %s", cfg.Network))
 	}
 
 	err := handleRoutingPolicy(dn.externalRouting, cfg.RoutingPolicy, dn.nl)
 	if err != nil {
-		return err
+		return networkFailure(FailureRoutingPolicy, err)
 	}
 
 	link, online := dn.activeLinks[cfg.Network]
@@ -107,7 +131,7 @@ func (dn *DockerNetwork) ConfigureNetwork(ctx context.Context, cfg *sdknw.Config
 				IPAMConfig: &ipamConfig,
 			},
 		); err != nil {
-			return err
+			return networkFailure(FailureConnect, err)
 		}
 		info, err := dn.container.Inspect(ctx)
 		if err != nil {
@@ -137,11 +161,11 @@ func (dn *DockerNetwork) ConfigureNetwork(ctx context.Context, cfg *sdknw.Config
 	}
 
 	if err := link.Shape(cfg.Default); err != nil {
-		return err
+		return networkFailure(FailureShaping, err)
 	}
 
 	if err := link.AddRules(cfg.Rules); err != nil {
-		return err
+		return networkFailure(FailureRules, err)
 	}
 
 	return nil
@@ -40,6 +40,10 @@ type DockerReactor struct {
 	servicesRoutes []net.IP
 	manager        *docker.Manager
 	runidsCache    *lru.Cache
+
+	// managed tracks the containers being handled, by container ID.
+	managedLk gosync.Mutex
+	managed   map[string]struct{}
 }
 
 func NewDockerReactor() (Reactor, error) {
@@ -59,6 +63,7 @@ func NewDockerReactor() (Reactor, error) {
 		client:      client,
 		manager:     docker,
 		runidsCache: cache,
+		managed:     make(map[string]struct{}),
 	}
 
 	r.ResolveServices("constructor")
@@ -105,6 +110,15 @@ func (d *DockerReactor) ResolveServices(runid string) {
 func (d *DockerReactor) Handle(globalctx context.Context, handler InstanceHandler) error {
 	return d.manager.Watch(globalctx, func(ctx context.Context, container *docker.ContainerRef) error {
 		logging.S().Debugw("got container", "container", container.ID)
+
+		// a container can be picked up again, e.g. if the watch is restarted.
+		// Re-running the setup would tear down routes twice.
+		if !d.claim(container.ID) {
+			logging.S().Warnw("container is already managed; ignoring", "container", container.ID)
+			return nil
+		}
+		defer d.release(container.ID)
+
 		inst, err := d.handleContainer(ctx, container)
 		if err != nil {
 			return fmt.Errorf("failed to initialise the container: %w", err)
@@ -122,6 +136,31 @@ func (d *DockerReactor) Handle(globalctx context.Context, handler InstanceHandle
 	}, "testground.run_id")
 }
 
+// claim marks a container as managed, and returns false if it already is.
+func (d *DockerReactor) claim(id string) bool {
+	d.managedLk.Lock()
+	defer d.managedLk.Unlock()
+
+	if _, ok := d.managed[id]; ok {
+		return false
+	}
+	d.managed[id] = struct{}{}
+	return true
+}
+
+// release marks a container as no longer managed.
+func (d *DockerReactor) release(id string) {
+	d.managedLk.Lock()
+	delete(d.managed, id)
+	d.managedLk.Unlock()
+}
+
+var _ HealthChecker = (*DockerReactor)(nil)
+
+func (d *DockerReactor) Health(ctx context.Context) *Health {
+	return managerHealth(ctx, d.manager)
+}
+
 func (d *DockerReactor) Close() error {
 	var err *multierror.Error
 	err = multierror.Append(err, d.manager.Close())
@@ -155,7 +194,7 @@ func (d *DockerReactor) handleContainer(ctx context.Context, container *docker.C
 		return nil, nil
 	}
 
-	logging.S().Debugw("handle container", "name", info.Name, "image", info.Image)
+	logging.S().Infow("handle container", "name", info.Name, "image", info.Image, "run_id", params.TestRun, "req_id", requestID(info.Config.Env))
 
 	// Resolve allowed services, so that we update network routes
 	d.ResolveServices(params.TestRun)
@@ -263,6 +302,7 @@ func (d *DockerReactor) handleContainer(ctx context.Context, container *docker.C
 			if err := netlinkHandle.RouteAdd(&route); err != nil {
 				return nil, fmt.Errorf("failed to add new route: %w", err)
 			}
+			network.controlRoutes = append(network.controlRoutes, route)
 		}
 	}
 
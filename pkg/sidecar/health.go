@@ -0,0 +1,86 @@
+//go:build linux
+// +build linux
+
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/testground/testground/pkg/docker"
+)
+
+const (
+	defaultSyncServicePort = "5050"
+	healthCheckTimeout     = 5 * time.Second
+)
+
+// Health reports on the ability of the sidecar to manage instances.
+type Health struct {
+	// Docker and SyncService are "ok", or the error reaching the service.
+	Docker      string `json:"docker"`
+	SyncService string `json:"sync_service"`
+	// ManagedContainers is the number of instances currently managed.
+	ManagedContainers int `json:"managed_containers"`
+}
+
+// Ready returns whether all the services the sidecar depends on are
+// reachable.
+func (h *Health) Ready() bool {
+	return h.Docker == "ok" && h.SyncService == "ok"
+}
+
+// HealthChecker is implemented by reactors that can report on their health.
+type HealthChecker interface {
+	Health(ctx context.Context) *Health
+}
+
+// managerHealth checks the connectivity to Docker, through the supplied
+// manager, and to the sync service.
+func managerHealth(ctx context.Context, m *docker.Manager) *Health {
+	h := &Health{Docker: "ok", SyncService: "ok", ManagedContainers: m.Managed()}
+
+	if _, err := m.Ping(ctx); err != nil {
+		h.Docker = err.Error()
+	}
+
+	port := os.Getenv(EnvSyncServicePort)
+	if port == "" {
+		port = defaultSyncServicePort
+	}
+	var d net.Dialer
+	if conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(os.Getenv(EnvSyncServiceHost), port)); err != nil {
+		h.SyncService = err.Error()
+	} else {
+		_ = conn.Close()
+	}
+	return h
+}
+
+// RegisterHealthHandlers registers the /healthz and /readyz endpoints of the
+// sidecar on mux. /healthz fails when the sidecar has lost its connection to
+// Docker, and so can't manage any instance; /readyz also fails when the sync
+// service is unreachable; the pods of cluster:k8s wait for it before starting.
+// Both respond with the Health report.
+func RegisterHealthHandlers(mux *http.ServeMux, hc HealthChecker) {
+	handler := func(ok func(*Health) bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+			defer cancel()
+
+			h := hc.Health(ctx)
+			w.Header().Set("Content-Type", "application/json")
+			if !ok(h) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			_ = json.NewEncoder(w).Encode(h)
+		}
+	}
+
+	mux.HandleFunc("/healthz", handler(func(h *Health) bool { return h.Docker == "ok" }))
+	mux.HandleFunc("/readyz", handler((*Health).Ready))
+}
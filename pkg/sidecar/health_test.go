@@ -0,0 +1,53 @@
+//go:build linux
+// +build linux
+
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type staticHealth Health
+
+func (s *staticHealth) Health(context.Context) *Health {
+	h := Health(*s)
+	return &h
+}
+
+func TestHealthHandlers(t *testing.T) {
+	cases := []struct {
+		health    Health
+		liveness  int
+		readiness int
+	}{
+		{Health{Docker: "ok", SyncService: "ok", ManagedContainers: 2}, http.StatusOK, http.StatusOK},
+		{Health{Docker: "ok", SyncService: "connection refused"}, http.StatusOK, http.StatusServiceUnavailable},
+		{Health{Docker: "connection refused", SyncService: "ok"}, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+	}
+
+	for _, c := range cases {
+		mux := http.NewServeMux()
+		h := staticHealth(c.health)
+		RegisterHealthHandlers(mux, &h)
+
+		for path, code := range map[string]int{"/healthz": c.liveness, "/readyz": c.readiness} {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+			if rec.Code != code {
+				t.Errorf("%s with %+v: expected status %d, got %d", path, c.health, code, rec.Code)
+			}
+
+			var got Health
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != c.health {
+				t.Errorf("%s: expected report %+v, got %+v", path, c.health, got)
+			}
+		}
+	}
+}
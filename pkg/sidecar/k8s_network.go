@@ -4,11 +4,15 @@
 package sidecar
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/testground/sdk-go/network"
@@ -17,6 +21,7 @@ import (
 	"github.com/testground/testground/pkg/logging"
 
 	"github.com/containernetworking/cni/libcni"
+	"github.com/hashicorp/go-multierror"
 	"github.com/vishvananda/netlink"
 )
 
@@ -39,9 +44,15 @@ type K8sNetwork struct {
 	initialized     bool
 }
 
+// Close restores the routes disabled by the routing policy, and releases the
+// network.
 func (n *K8sNetwork) Close() error {
+	var merr *multierror.Error
+	for _, routing := range n.externalRouting {
+		merr = multierror.Append(merr, routing.restore(n.nl))
+	}
 	n.nl.Delete()
-	return nil
+	return merr.ErrorOrNil()
 }
 
 // getExistingIpRange returns an IP address
@@ -113,7 +124,7 @@ func (n *K8sNetwork) InitializeNetwork(ctx context.Context) error {
 
 func (n *K8sNetwork) ConfigureNetwork(ctx context.Context, cfg *network.Config) error {
 	if cfg.Network != defaultDataNetwork {
-		return fmt.Errorf("configured network is not `%s`", defaultDataNetwork)
+		return networkFailure(FailureUnsupportedNetwork, fmt.Errorf("configured network is not `%s`", defaultDataNetwork))
 	}
 
 	if !n.initialized {
@@ -201,10 +212,10 @@ func (n *K8sNetwork) ConfigureNetwork(ctx context.Context, cfg *network.Config)
 		select {
 		case err := <-errc:
 			if err != nil {
-				return fmt.Errorf("failed to add network through cni plugin: %w", err)
+				return networkFailure(FailureConnect, fmt.Errorf("failed to add network through cni plugin: %w", err))
 			}
 		case <-time.After(30 * time.Second):
-			return fmt.Errorf("timeout waiting on cninet.AddNetworkList")
+			return networkFailure(FailureTimeout, fmt.Errorf("timeout waiting on cninet.AddNetworkList"))
 		}
 
 		netlinkByName, err := n.nl.LinkByName(dataNetworkIfname)
@@ -244,13 +255,13 @@ func (n *K8sNetwork) ConfigureNetwork(ctx context.Context, cfg *network.Config)
 	}
 
 	if err := link.Shape(cfg.Default); err != nil {
-		return fmt.Errorf("failed to shape link: %w", err)
+		return networkFailure(FailureShaping, fmt.Errorf("failed to shape link: %w", err))
 	}
 	if err := link.AddRules(cfg.Rules); err != nil {
-		return err
+		return networkFailure(FailureRules, err)
 	}
 	if err := handleRoutingPolicy(n.externalRouting, cfg.RoutingPolicy, n.nl); err != nil {
-		return err
+		return networkFailure(FailureRoutingPolicy, err)
 	}
 	return nil
 }
@@ -263,54 +274,94 @@ func (n *K8sNetwork) ListActive() []string {
 	return networks
 }
 
-func newNetworkConfigList(t string, addr string) (*libcni.NetworkConfigList, error) {
-	switch t {
-	case "net":
-		bytes := []byte(`
+const (
+	// EnvCNIPlugin sets the CNI plugin managing the data network
+	// (default: weave-net).
+	EnvCNIPlugin = "TESTGROUND_CNI_PLUGIN"
+	// EnvCNIConfigTemplate points to a CNI network configuration list
+	// template, replacing the default one. It is a Go template, executed with
+	// a cniConfigParams.
+	EnvCNIConfigTemplate = "TESTGROUND_CNI_CONFIG_TEMPLATE"
+
+	defaultCNIPlugin = "weave-net"
+)
+
+// defaultCNIConfigTemplate is the configuration of the data network, for CNI
+// plugins that accept a subnet or static addresses in their ipam settings,
+// such as weave.
+const defaultCNIConfigTemplate = `
 {
-		"cniVersion": "0.3.0",
-		"name": "weave-net",
-		"plugins": [
-				{
-						"name": "weave-net",
-						"type": "weave-net",
-						"ipam": {
-								"subnet": "` + addr + `"
-						},
-						"hairpinMode": true
-				}
-		]
+	"cniVersion": "0.3.0",
+	"name": "{{ .Plugin }}",
+	"plugins": [
+		{
+			"name": "{{ .Plugin }}",
+			"type": "{{ .Plugin }}",
+			"ipam": {
+			{{- if .Address }}
+				"ips": [
+					{
+						"version": "4",
+						"address": "{{ .Address }}"
+					}
+				]
+			{{- else }}
+				"subnet": "{{ .Subnet }}"
+			{{- end }}
+			},
+			"hairpinMode": true
+		}
+	]
+}
+`
+
+// cniConfigParams are the parameters of the CNI configuration template. One
+// of Subnet and Address is set: the data network subnet, to allocate an
+// address from, or the address requested by the instance.
+type cniConfigParams struct {
+	Plugin  string
+	Subnet  string
+	Address string
 }
-`)
-		return libcni.ConfListFromBytes(bytes)
 
-	case "ip":
-		bytes := []byte(`
-{
-		"cniVersion": "0.3.0",
-		"name": "weave-net",
-		"plugins": [
-				{
-						"name": "weave-net",
-						"type": "weave-net",
-						"ipam": {
-								"ips": [
-								  {
-									  "version": "4",
-										"address": "` + addr + `"
-								  }
-								]
-						},
-						"hairpinMode": true
-				}
-		]
+// cniConfigTemplate returns the template of the CNI configuration of the data
+// network.
+func cniConfigTemplate() (*template.Template, error) {
+	text := defaultCNIConfigTemplate
+	if path := os.Getenv(EnvCNIConfigTemplate); path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cni config template: %w", err)
+		}
+		text = string(b)
+	}
+	return template.New("cni").Parse(text)
 }
-`)
-		return libcni.ConfListFromBytes(bytes)
 
+func newNetworkConfigList(t string, addr string) (*libcni.NetworkConfigList, error) {
+	params := cniConfigParams{Plugin: os.Getenv(EnvCNIPlugin)}
+	if params.Plugin == "" {
+		params.Plugin = defaultCNIPlugin
+	}
+
+	switch t {
+	case "net":
+		params.Subnet = addr
+	case "ip":
+		params.Address = addr
 	default:
 		return nil, errors.New("unknown type")
 	}
+
+	tmpl, err := cniConfigTemplate()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return nil, fmt.Errorf("failed to execute cni config template: %w", err)
+	}
+	return libcni.ConfListFromBytes(buf.Bytes())
 }
 
 func retry(attempts int, sleep time.Duration, f func() error) (err error) {
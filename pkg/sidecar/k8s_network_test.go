@@ -0,0 +1,73 @@
+//go:build linux
+// +build linux
+
+package sidecar
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/testground/testground/pkg/testutil"
+)
+
+func TestNewNetworkConfigList(t *testing.T) {
+	ipam := func(t *testing.T, typ, addr string) (string, map[string]interface{}) {
+		t.Helper()
+
+		conf, err := newNetworkConfigList(typ, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(conf.Plugins) != 1 {
+			t.Fatalf("expected a single plugin, got %d", len(conf.Plugins))
+		}
+		var plugin struct {
+			IPAM map[string]interface{} `json:"ipam"`
+		}
+		if err := json.Unmarshal(conf.Plugins[0].Bytes, &plugin); err != nil {
+			t.Fatal(err)
+		}
+		return conf.Plugins[0].Network.Type, plugin.IPAM
+	}
+
+	t.Run("default", func(t *testing.T) {
+		typ, cfg := ipam(t, "net", "16.0.0.0/16")
+		if typ != "weave-net" || cfg["subnet"] != "16.0.0.0/16" {
+			t.Errorf("unexpected plugin %s with ipam %v", typ, cfg)
+		}
+		if _, cfg = ipam(t, "ip", "16.0.1.2/16"); cfg["ips"] == nil {
+			t.Errorf("expected static ips, got ipam %v", cfg)
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		tmpl := filepath.Join(t.TempDir(), "cni.tmpl")
+		err := ioutil.WriteFile(tmpl, []byte(`{
+			"cniVersion": "0.3.1",
+			"name": "data",
+			"plugins": [{"type": "{{ .Plugin }}", "ipam": {"type": "host-local", "subnet": "{{ .Subnet }}{{ .Address }}"}}]
+		}`), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testutil.Setenv(t, EnvCNIPlugin, "calico")
+		testutil.Setenv(t, EnvCNIConfigTemplate, tmpl)
+
+		typ, cfg := ipam(t, "net", "16.0.0.0/16")
+		if typ != "calico" || cfg["type"] != "host-local" || cfg["subnet"] != "16.0.0.0/16" {
+			t.Errorf("unexpected plugin %s with ipam %v", typ, cfg)
+		}
+	})
+}
+
+func TestPodNamespace(t *testing.T) {
+	labels := map[string]string{
+		"io.kubernetes.pod.name":      "tg-placebo-c1-single-0",
+		"io.kubernetes.pod.namespace": "plans",
+	}
+	if got := podNamespace(labels); got != "plans" {
+		t.Errorf("expected the namespace of the pod label, got %q", got)
+	}
+}
@@ -6,9 +6,11 @@ package sidecar
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	gosync "sync"
 	"time"
 
@@ -37,6 +39,24 @@ var (
 	kubeDnsClusterIP = net.IPv4(10, 32, 0, 0)
 )
 
+// k8sNamespaceFile holds the namespace of the sidecar pod, mounted along with
+// its service account token.
+const k8sNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// podNamespace returns the namespace of the pod of a container, from the
+// labels set by the kubelet. It falls back to the namespace the sidecar runs
+// in, from k8sNamespaceFile, and then to "default".
+func podNamespace(labels map[string]string) string {
+	if ns := labels["io.kubernetes.pod.namespace"]; ns != "" {
+		return ns
+	}
+	b, err := ioutil.ReadFile(k8sNamespaceFile)
+	if ns := strings.TrimSpace(string(b)); err == nil && ns != "" {
+		return ns
+	}
+	return "default"
+}
+
 type K8sReactor struct {
 	gosync.Mutex
 
@@ -130,6 +150,12 @@ func (d *K8sReactor) Handle(ctx context.Context, handler InstanceHandler) error
 	})
 }
 
+var _ HealthChecker = (*K8sReactor)(nil)
+
+func (d *K8sReactor) Health(ctx context.Context) *Health {
+	return managerHealth(ctx, d.manager)
+}
+
 func (d *K8sReactor) Close() error {
 	var err *multierror.Error
 	err = multierror.Append(err, d.manager.Close())
@@ -162,11 +188,14 @@ func (d *K8sReactor) manageContainer(ctx context.Context, container *docker.Cont
 	if !ok {
 		return nil, fmt.Errorf("couldn't get pod name from container labels for: %s", container.ID)
 	}
+	podNs := podNamespace(info.Config.Labels)
+
+	logging.S().Infow("handle container", "pod", podName, "run_id", params.TestRun, "req_id", requestID(info.Config.Env))
 
 	// Resolve allowed services, so that we update network routes
 	d.ResolveServices(params.TestRun)
 
-	err = waitForPodRunningPhase(ctx, podName)
+	err = waitForPodRunningPhase(ctx, podNs, podName)
 	if err != nil {
 		return nil, err
 	}
@@ -245,7 +274,7 @@ func (d *K8sReactor) manageContainer(ctx context.Context, container *docker.Cont
 	return NewInstance(d.client, runenv, info.Config.Hostname, network)
 }
 
-func waitForPodRunningPhase(ctx context.Context, podName string) error {
+func waitForPodRunningPhase(ctx context.Context, namespace, podName string) error {
 	k8scfg, err := clientcmd.BuildConfigFromFlags("", "")
 	if err != nil {
 		return fmt.Errorf("error in wait for pod running phase: %v", err)
@@ -266,7 +295,7 @@ func waitForPodRunningPhase(ctx context.Context, podName string) error {
 			if phase == "Running" {
 				return nil
 			}
-			pod, err := k8sClientset.CoreV1().Pods("default").Get(ctx, podName, metav1.GetOptions{})
+			pod, err := k8sClientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 			if err != nil {
 				return fmt.Errorf("error in wait for pod running phase: %v", err)
 			}
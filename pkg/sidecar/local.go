@@ -0,0 +1,38 @@
+package sidecar
+
+import (
+	"os"
+)
+
+const (
+	// EnvLocalSidecarDir overrides the directory through which the local:exec
+	// runner registers instances with the local sidecar.
+	EnvLocalSidecarDir = "TESTGROUND_LOCAL_SIDECAR_DIR"
+
+	// DefaultLocalSidecarDir is the default directory through which the
+	// local:exec runner registers instances with the local sidecar.
+	DefaultLocalSidecarDir = "/run/testground/sidecar"
+)
+
+// LocalInstance registers a local:exec instance with the local sidecar. The
+// runner writes one <name>.json file per instance to the local sidecar
+// directory, and removes it when the instance exits.
+type LocalInstance struct {
+	Hostname string `json:"hostname"`
+	// Netns is the name of the network namespace of the instance, as created
+	// by `ip netns add`.
+	Netns string `json:"netns"`
+	// Link is the name of the data network interface in the namespace.
+	Link string `json:"link"`
+	// Env is the environment of the instance, carrying its run parameters.
+	Env []string `json:"env"`
+}
+
+// LocalSidecarDir returns the directory through which the local:exec runner
+// registers instances with the local sidecar.
+func LocalSidecarDir() string {
+	if dir := os.Getenv(EnvLocalSidecarDir); dir != "" {
+		return dir
+	}
+	return DefaultLocalSidecarDir
+}
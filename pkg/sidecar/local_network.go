@@ -0,0 +1,66 @@
+//go:build linux
+// +build linux
+
+package sidecar
+
+import (
+	"context"
+	"fmt"
+
+	sdknw "github.com/testground/sdk-go/network"
+
+	"github.com/vishvananda/netlink"
+)
+
+// LocalNetwork is the network of a local:exec instance, i.e. the link in its
+// network namespace. Routing policies are ignored: instances can always reach
+// the host, where the infrastructure runs, and nothing else.
+//
+// The link carries the traffic to the sync service and InfluxDB as well as the
+// data traffic, so it can't be taken down, and shaping it also slows down the
+// coordination of the instance.
+type LocalNetwork struct {
+	link *NetlinkLink
+	nl   *netlink.Handle
+}
+
+func (n *LocalNetwork) Close() error {
+	n.nl.Delete()
+	return nil
+}
+
+func (n *LocalNetwork) ConfigureNetwork(_ context.Context, cfg *sdknw.Config) error {
+	if cfg.Network != defaultDataNetwork {
+		return networkFailure(FailureUnsupportedNetwork, fmt.Errorf("unsupported network: %s", cfg.Network))
+	}
+
+	if cfg.IPv6 != nil {
+		return networkFailure(FailureConnect, fmt.Errorf("ipv6 is not supported by the local sidecar"))
+	}
+	if cfg.IPv4 != nil {
+		addrs, err := n.link.ListV4()
+		if err != nil {
+			return networkFailure(FailureConnect, err)
+		}
+		if len(addrs) == 0 || !addrs[0].IP.Equal(cfg.IPv4.IP) {
+			return networkFailure(FailureConnect, fmt.Errorf("changing the address of an instance is not supported by the local sidecar"))
+		}
+	}
+
+	// taking the link down would cut the instance off from the sync service.
+	if !cfg.Enable {
+		return networkFailure(FailureConnect, fmt.Errorf("disabling the network is not supported by the local sidecar"))
+	}
+
+	if err := n.link.Shape(cfg.Default); err != nil {
+		return networkFailure(FailureShaping, err)
+	}
+	if err := n.link.AddRules(cfg.Rules); err != nil {
+		return networkFailure(FailureRules, err)
+	}
+	return nil
+}
+
+func (n *LocalNetwork) ListActive() []string {
+	return []string{defaultDataNetwork}
+}
@@ -0,0 +1,174 @@
+//go:build linux
+// +build linux
+
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/testground/sdk-go/runtime"
+	"github.com/testground/sdk-go/sync"
+
+	"github.com/testground/testground/pkg/logging"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// localPollInterval is how often the local sidecar looks for new and
+// finished instances.
+const localPollInterval = time.Second
+
+// LocalReactor manages the instances of local:exec runs that run in their
+// own network namespace. Instances are registered by the runner in the local
+// sidecar directory; see LocalInstance.
+//
+// It only supports a reduced set of network configurations: shaping and
+// rules, and enabling or disabling the data network. Routing policies and
+// address changes are not supported.
+type LocalReactor struct {
+	client sync.Client
+	dir    string
+}
+
+func NewLocalReactor() (Reactor, error) {
+	dir := LocalSidecarDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create local sidecar directory: %w", err)
+	}
+
+	client, err := sync.NewGenericClient(context.Background(), logging.S())
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalReactor{client: client, dir: dir}, nil
+}
+
+func (r *LocalReactor) Handle(ctx context.Context, handler InstanceHandler) error {
+	type worker struct {
+		cancel context.CancelFunc
+		done   chan struct{}
+	}
+
+	workers := make(map[string]worker)
+	stop := func(path string) {
+		w := workers[path]
+		w.cancel()
+		<-w.done
+		delete(workers, path)
+	}
+	defer func() {
+		for path := range workers {
+			stop(path)
+		}
+	}()
+
+	ticker := time.NewTicker(localPollInterval)
+	defer ticker.Stop()
+
+	for {
+		paths, err := filepath.Glob(filepath.Join(r.dir, "*.json"))
+		if err != nil {
+			return err
+		}
+
+		registered := make(map[string]struct{}, len(paths))
+		for _, path := range paths {
+			registered[path] = struct{}{}
+			if _, ok := workers[path]; ok {
+				continue
+			}
+
+			wctx, cancel := context.WithCancel(ctx)
+			w := worker{cancel: cancel, done: make(chan struct{})}
+			workers[path] = w
+
+			go func(path string) {
+				defer close(w.done)
+				err := r.manage(wctx, path, handler)
+				if err != nil && !errors.Is(err, context.Canceled) {
+					logging.S().Errorw("sidecar worker failed", "instance", path, "err", err)
+				}
+			}(path)
+		}
+
+		// instances whose registration was removed have exited.
+		for path := range workers {
+			if _, ok := registered[path]; !ok {
+				stop(path)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// manage runs the handler for the instance registered at path.
+func (r *LocalReactor) manage(ctx context.Context, path string, handler InstanceHandler) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var li LocalInstance
+	if err := json.Unmarshal(b, &li); err != nil {
+		return fmt.Errorf("failed to decode instance registration: %w", err)
+	}
+
+	params, err := runtime.ParseRunParams(li.Env)
+	if err != nil {
+		return fmt.Errorf("failed to parse run environment: %w", err)
+	}
+	if !params.TestSidecar {
+		return nil
+	}
+
+	// Remove the TestOutputsPath. We can't store anything from the sidecar.
+	params.TestOutputsPath = ""
+	runenv := runtime.NewRunEnv(*params)
+
+	nshandle, err := netns.GetFromName(li.Netns)
+	if err != nil {
+		return fmt.Errorf("failed to lookup the net namespace %s: %w", li.Netns, err)
+	}
+	defer nshandle.Close()
+
+	nl, err := netlink.NewHandleAt(nshandle)
+	if err != nil {
+		return fmt.Errorf("failed to get handle to network namespace: %w", err)
+	}
+
+	link, err := nl.LinkByName(li.Link)
+	if err != nil {
+		nl.Delete()
+		return fmt.Errorf("failed to find link %s: %w", li.Link, err)
+	}
+	handle, err := NewNetlinkLink(nl, link)
+	if err != nil {
+		nl.Delete()
+		return err
+	}
+
+	network := &LocalNetwork{link: handle, nl: nl}
+	inst, err := NewInstance(r.client, runenv, li.Hostname, network)
+	if err != nil {
+		_ = network.Close()
+		return err
+	}
+	return handler(ctx, inst)
+}
+
+func (r *LocalReactor) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,178 @@
+package sidecar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reasons for failed network reconfigurations, as reported by the
+// testground_sidecar_network_reconfigure_failures_total metric.
+const (
+	FailureUnsupportedNetwork = "unsupported_network"
+	FailureConnect            = "connect"
+	FailureRoutingPolicy      = "routing_policy"
+	FailureShaping            = "shaping"
+	FailureRules              = "rules"
+	FailureTimeout            = "timeout"
+	FailureCanceled           = "canceled"
+	FailureOther              = "other"
+)
+
+// reconfigureBuckets are the upper bounds, in seconds, of the network
+// reconfiguration latency histogram.
+var reconfigureBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// networkError is an error configuring the network of an instance, tagged with
+// the reason of the failure.
+type networkError struct {
+	reason string
+	err    error
+}
+
+func (e *networkError) Error() string { return e.err.Error() }
+func (e *networkError) Unwrap() error { return e.err }
+
+// networkFailure tags err with the supplied failure reason.
+func networkFailure(reason string, err error) error {
+	return &networkError{reason: reason, err: err}
+}
+
+// failureReason returns the reason of a network reconfiguration failure.
+func failureReason(err error) string {
+	var nerr *networkError
+	switch {
+	case errors.As(err, &nerr):
+		return nerr.reason
+	case errors.Is(err, context.DeadlineExceeded):
+		return FailureTimeout
+	case errors.Is(err, context.Canceled):
+		return FailureCanceled
+	default:
+		return FailureOther
+	}
+}
+
+// sidecarMetrics tracks what the sidecar is doing, and exposes it in the
+// Prometheus text format.
+type sidecarMetrics struct {
+	lk sync.Mutex
+
+	managed int
+	rules   int
+
+	// network reconfiguration latency histogram; counts are per bucket, not
+	// cumulative.
+	buckets []uint64
+	count   uint64
+	sum     float64
+
+	failures map[string]uint64
+}
+
+// metrics are the metrics of this sidecar process, served on /metrics.
+var metrics = newSidecarMetrics()
+
+func newSidecarMetrics() *sidecarMetrics {
+	return &sidecarMetrics{
+		buckets:  make([]uint64, len(reconfigureBuckets)),
+		failures: make(map[string]uint64),
+	}
+}
+
+// addManaged adjusts the count of managed containers by delta.
+func (m *sidecarMetrics) addManaged(delta int) {
+	m.lk.Lock()
+	m.managed += delta
+	m.lk.Unlock()
+}
+
+// addRules adjusts the count of active shaping rules by delta.
+func (m *sidecarMetrics) addRules(delta int) {
+	m.lk.Lock()
+	m.rules += delta
+	m.lk.Unlock()
+}
+
+// observeReconfigure records a network reconfiguration that took d, and
+// failed with err, if not nil.
+func (m *sidecarMetrics) observeReconfigure(d time.Duration, err error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	secs := d.Seconds()
+	for i, le := range reconfigureBuckets {
+		if secs <= le {
+			m.buckets[i]++
+			break
+		}
+	}
+	m.count++
+	m.sum += secs
+
+	if err != nil {
+		m.failures[failureReason(err)]++
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *sidecarMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	var (
+		n   int64
+		err error
+	)
+	printf := func(format string, args ...interface{}) {
+		if err != nil {
+			return
+		}
+		var c int
+		c, err = fmt.Fprintf(w, format, args...)
+		n += int64(c)
+	}
+
+	printf("# HELP testground_sidecar_managed_containers Number of containers managed by the sidecar.\n")
+	printf("# TYPE testground_sidecar_managed_containers gauge\n")
+	printf("testground_sidecar_managed_containers %d\n", m.managed)
+
+	printf("# HELP testground_sidecar_shaping_rules Number of active traffic shaping rules.\n")
+	printf("# TYPE testground_sidecar_shaping_rules gauge\n")
+	printf("testground_sidecar_shaping_rules %d\n", m.rules)
+
+	printf("# HELP testground_sidecar_network_reconfigure_seconds Latency of network reconfigurations.\n")
+	printf("# TYPE testground_sidecar_network_reconfigure_seconds histogram\n")
+	var cumulative uint64
+	for i, le := range reconfigureBuckets {
+		cumulative += m.buckets[i]
+		printf("testground_sidecar_network_reconfigure_seconds_bucket{le=\"%g\"} %d\n", le, cumulative)
+	}
+	printf("testground_sidecar_network_reconfigure_seconds_bucket{le=\"+Inf\"} %d\n", m.count)
+	printf("testground_sidecar_network_reconfigure_seconds_sum %g\n", m.sum)
+	printf("testground_sidecar_network_reconfigure_seconds_count %d\n", m.count)
+
+	printf("# HELP testground_sidecar_network_reconfigure_failures_total Number of failed network reconfigurations, per reason.\n")
+	printf("# TYPE testground_sidecar_network_reconfigure_failures_total counter\n")
+	reasons := make([]string, 0, len(m.failures))
+	for reason := range m.failures {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		printf("testground_sidecar_network_reconfigure_failures_total{reason=%q} %d\n", reason, m.failures[reason])
+	}
+
+	return n, err
+}
+
+// ServeHTTP serves the metrics to Prometheus.
+func (m *sidecarMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = m.WriteTo(w)
+}
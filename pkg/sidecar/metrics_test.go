@@ -0,0 +1,45 @@
+package sidecar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSidecarMetrics(t *testing.T) {
+	m := newSidecarMetrics()
+
+	m.addManaged(2)
+	m.addManaged(-1)
+	m.addRules(3)
+	m.observeReconfigure(20*time.Millisecond, nil)
+	m.observeReconfigure(2*time.Second, networkFailure(FailureShaping, errors.New("no qdisc")))
+	m.observeReconfigure(time.Minute, fmt.Errorf("failed to update network: %w", networkFailure(FailureShaping, errors.New("no qdisc"))))
+	m.observeReconfigure(2*time.Minute, context.DeadlineExceeded)
+
+	var b strings.Builder
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	for _, line := range []string{
+		"testground_sidecar_managed_containers 1\n",
+		"testground_sidecar_shaping_rules 3\n",
+		`testground_sidecar_network_reconfigure_seconds_bucket{le="0.01"} 0` + "\n",
+		`testground_sidecar_network_reconfigure_seconds_bucket{le="0.05"} 1` + "\n",
+		`testground_sidecar_network_reconfigure_seconds_bucket{le="2.5"} 2` + "\n",
+		`testground_sidecar_network_reconfigure_seconds_bucket{le="60"} 3` + "\n",
+		`testground_sidecar_network_reconfigure_seconds_bucket{le="+Inf"} 4` + "\n",
+		"testground_sidecar_network_reconfigure_seconds_count 4\n",
+		`testground_sidecar_network_reconfigure_failures_total{reason="shaping"} 2` + "\n",
+		`testground_sidecar_network_reconfigure_failures_total{reason="timeout"} 1` + "\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+}
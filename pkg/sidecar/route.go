@@ -99,6 +99,25 @@ func (routing *route) enable(handle *netlink.Handle) error {
 	return nil
 }
 
+// restore reinstates the captured routes, whether or not they're currently
+// enabled.
+func (routing *route) restore(handle *netlink.Handle) error {
+	var merr *multierror.Error
+	for _, route := range routing.routes {
+		// getDockerRoutes records a zero route for links without a default
+		// route.
+		if route.LinkIndex == 0 {
+			continue
+		}
+		if err := handle.RouteReplace(&route); err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("failed to restore route %s: %w", route, err))
+		}
+	}
+
+	routing.enabled = true
+	return merr.ErrorOrNil()
+}
+
 func handleRoutingPolicy(routes map[string]*route, policy network.RoutingPolicyType, handle *netlink.Handle) error {
 	var err *multierror.Error
 
@@ -3,6 +3,7 @@ package sidecar
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/testground/sdk-go/network"
 	"github.com/testground/sdk-go/sync"
@@ -12,6 +13,24 @@ const (
 	defaultDataNetwork = "default"
 )
 
+// NetworkInitialisedState is the sync state signalled by the sidecar for
+// every instance once its network has been configured.
+const NetworkInitialisedState = sync.State("network-initialized")
+
+// NetworkInitialisationTopic is the topic on which the sidecar publishes the
+// outcome of the network initialisation of every instance. Runners watch it to
+// monitor the progress of a run, and to name the instances that fail or are
+// late.
+var NetworkInitialisationTopic = sync.NewTopic("network-initialization", &NetworkInitialisation{})
+
+// NetworkInitialisation is the outcome of the network initialisation of an
+// instance.
+type NetworkInitialisation struct {
+	Hostname string `json:"hostname"`
+	// Error is the reason the initialisation failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
 func handler(ctx context.Context, instance *Instance) error {
 	instance.S().Debugw("managing instance", "instance", instance.Hostname)
 
@@ -22,24 +41,58 @@ func handler(ctx context.Context, instance *Instance) error {
 		}
 	}()
 
+	ctx = sync.WithRunParams(ctx, &instance.RunEnv.RunParams)
+
+	metrics.addManaged(1)
+	defer metrics.addManaged(-1)
+
+	// active shaping rules, per network.
+	rules := make(map[string]int)
+	defer func() {
+		for _, n := range rules {
+			metrics.addRules(-n)
+		}
+	}()
+
+	configure := func(cfg *network.Config) error {
+		start := time.Now()
+		err := instance.Network.ConfigureNetwork(ctx, cfg)
+		metrics.observeReconfigure(time.Since(start), err)
+		if err != nil {
+			return err
+		}
+
+		n := len(cfg.Rules)
+		if !cfg.Enable {
+			n = 0
+		}
+		metrics.addRules(n - rules[cfg.Network])
+		rules[cfg.Network] = n
+		return nil
+	}
+
 	// Network configuration loop.
-	err := instance.Network.ConfigureNetwork(ctx, &network.Config{
+	err := configure(&network.Config{
 		Network: defaultDataNetwork,
 		Enable:  true,
 	})
 
+	outcome := &NetworkInitialisation{Hostname: instance.Hostname}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+	if _, perr := instance.Client.Publish(ctx, NetworkInitialisationTopic, outcome); perr != nil {
+		instance.S().Warnw("failed to publish network initialisation outcome", "err", perr)
+	}
 	if err != nil {
 		return err
 	}
 
-	ctx = sync.WithRunParams(ctx, &instance.RunEnv.RunParams)
-
 	// Wait for all the sidecars to enter the "network-initialized" state.
 	instance.S().Infof("waiting for all networks to be ready")
 
-	const netInitState = "network-initialized"
 	total := instance.RunEnv.TestInstanceCount
-	if _, err := instance.Client.SignalAndWait(ctx, netInitState, total); err != nil {
+	if _, err := instance.Client.SignalAndWait(ctx, NetworkInitialisedState, total); err != nil {
 		return fmt.Errorf("failed to signal network ready: %w", err)
 	}
 
@@ -68,7 +121,7 @@ func handler(ctx context.Context, instance *Instance) error {
 			}
 
 			instance.S().Infow("applying network change", "network", cfg)
-			if err := instance.Network.ConfigureNetwork(ctx, cfg); err != nil {
+			if err := configure(cfg); err != nil {
 				return fmt.Errorf("failed to update network %s: %w", cfg.Network, err)
 			}
 
@@ -5,7 +5,13 @@ package sidecar
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/testground/testground/pkg/logging"
 )
@@ -13,15 +19,29 @@ import (
 const (
 	EnvRedisHost       = "REDIS_HOST" // NOTE: kept for backwards compatibility with older SDKs.
 	EnvSyncServiceHost = "SYNC_SERVICE_HOST"
+	EnvSyncServicePort = "SYNC_SERVICE_PORT"
 	EnvInfluxdbHost    = "INFLUXDB_HOST"
 	EnvAdditionalHosts = "ADDITIONAL_HOSTS"
+	EnvTestRequestID   = "TEST_REQUEST_ID"
 )
 
+// requestID returns the ID of the daemon request that created the instance
+// with the given environment, if set.
+func requestID(env []string) string {
+	prefix := EnvTestRequestID + "="
+	for _, v := range env {
+		if strings.HasPrefix(v, prefix) {
+			return strings.TrimPrefix(v, prefix)
+		}
+	}
+	return ""
+}
+
 var runners = map[string]func() (Reactor, error){
 	"docker": NewDockerReactor,
 	"k8s":    NewK8sReactor,
 	"mock":   NewMockReactor,
-	// TODO: local
+	"local":  NewLocalReactor,
 }
 
 // GetRunners lists the available sidecar environments.
@@ -53,7 +73,31 @@ func Run(runnerName string) error {
 
 	defer reactor.Close()
 
+	// health endpoints and metrics are served by the sidecar's http server,
+	// along with pprof.
+	if hc, ok := reactor.(HealthChecker); ok {
+		RegisterHealthHandlers(http.DefaultServeMux, hc)
+	}
+	http.Handle("/metrics", metrics)
+
+	// on termination, stop managing instances, so that their networking is
+	// restored before exiting.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case sig := <-sigs:
+			logging.S().Infow("received signal, shutting down", "signal", sig)
+			cancel()
+		case <-globalctx.Done():
+		}
+	}()
+
 	// this call blocks.
 	err = reactor.Handle(globalctx, handler)
+	if globalctx.Err() != nil && errors.Is(err, context.Canceled) {
+		err = nil
+	}
 	return err
 }
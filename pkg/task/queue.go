@@ -3,6 +3,7 @@ package task
 import (
 	"container/heap"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -117,6 +118,83 @@ func (q *Queue) Pop() (*Task, error) {
 	return tsk, nil
 }
 
+// PopFair pops the next task, favouring submitters with the fewest tasks
+// currently running, as reported by load. Among tasks whose submitters carry
+// the same load, the usual priority and age ordering applies, so a submitter's
+// own tasks are still processed in priority order.
+func (q *Queue) PopFair(load func(submitter string) int) (*Task, error) {
+	q.Lock()
+	defer q.Unlock()
+	if q.tq.Len() == 0 {
+		return nil, ErrQueueEmpty
+	}
+	logging.S().Debugw("queue.pop-fair", "len", q.tq.Len())
+
+	loads := make(map[string]int)
+	best, bestLoad := -1, 0
+	for i, tsk := range *q.tq {
+		s := tsk.Submitter()
+		l, ok := loads[s]
+		if !ok {
+			l = load(s)
+			loads[s] = l
+		}
+		if best == -1 || l < bestLoad || (l == bestLoad && q.tq.Less(i, best)) {
+			best, bestLoad = i, l
+		}
+	}
+	tsk := heap.Remove(q.tq, best).(*Task)
+
+	logging.S().Debugw("queue.pop-fair.got-task", "id", tsk.ID, "taskname", tsk.Name(), "submitter", tsk.Submitter())
+	err := q.ts.ProcessTask(tsk)
+	if err != nil {
+		return nil, err
+	}
+	return tsk, nil
+}
+
+// Len returns the number of tasks in the queue.
+func (q *Queue) Len() int {
+	q.Lock()
+	defer q.Unlock()
+	return q.tq.Len()
+}
+
+// Position returns the 1-based position of the task with the supplied ID in
+// the queue, and the tasks ahead of it in processing order. ok is false if the
+// task is not in the queue.
+func (q *Queue) Position(id string) (pos int, ahead []*Task, ok bool) {
+	q.Lock()
+	defer q.Unlock()
+
+	sorted := make(taskQueue, q.tq.Len())
+	copy(sorted, *q.tq)
+	sort.Sort(sorted)
+
+	for i, tsk := range sorted {
+		if tsk.ID == id {
+			return i + 1, sorted[:i], true
+		}
+	}
+	return 0, nil, false
+}
+
+// Cancel cancels the scheduled task with the supplied ID, removing it from the
+// queue. ok is false if the task is not in the queue, e.g. because a worker
+// is processing it already.
+func (q *Queue) Cancel(id string) (ok bool, err error) {
+	q.Lock()
+	defer q.Unlock()
+
+	for i, tsk := range *q.tq {
+		if tsk.ID == id {
+			heap.Remove(q.tq, i)
+			return true, q.cancelTask(tsk)
+		}
+	}
+	return false, nil
+}
+
 // Remove all existing tasks from the queue that match the given branch/string
 func (q *Queue) removeExisting(branch string, repo string) error {
 	var err error
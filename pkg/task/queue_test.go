@@ -242,6 +242,147 @@ func TestQueueDoesNotRemoveTasksWithoutBranchOrRepo(t *testing.T) {
 	assert.Equal(t, 2, q.tq.Len())
 }
 
+// Make sure PopFair favours idle submitters, and keeps priority order within
+// a submitter.
+func TestQueuePopFair(t *testing.T) {
+	inmem := storage.NewMemStorage()
+	db, err := leveldb.Open(inmem, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQueue(&Storage{db}, 100, convertTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	push := func(id, user string, priority int, age time.Duration) {
+		err := q.Push(&Task{
+			ID:        id,
+			CreatedBy: CreatedBy{User: user},
+			States:    []DatedState{{State: StateScheduled, Created: now.Add(-age)}},
+			Priority:  priority,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice1 := "bt4brhjpc98qra498s10"
+	alice2 := "bt4brhjpc98qra498s20"
+	bob1 := "bt4brhjpc98qra498s30"
+	push(alice1, "alice", 10, 3*time.Minute)
+	push(alice2, "alice", 20, 2*time.Minute)
+	push(bob1, "bob", 0, time.Minute)
+
+	running := map[string]int{"alice": 2}
+	load := func(submitter string) int { return running[submitter] }
+
+	// bob has nothing running, so his task goes first despite its priority.
+	tsk, err := q.PopFair(load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, bob1, tsk.ID)
+	running["bob"] = 3
+
+	// alice's tasks then follow in priority order.
+	tsk, err = q.PopFair(load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, alice2, tsk.ID)
+
+	tsk, err = q.PopFair(load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, alice1, tsk.ID)
+
+	_, err = q.PopFair(load)
+	assert.Equal(t, ErrQueueEmpty, err)
+}
+
+// Make sure Position reports tasks in processing order.
+func TestQueuePosition(t *testing.T) {
+	inmem := storage.NewMemStorage()
+	db, err := leveldb.Open(inmem, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQueue(&Storage{db}, 100, convertTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	low := "bt4brhjpc98qra498s40"
+	high := "bt4brhjpc98qra498s50"
+	older := "bt4brhjpc98qra498s60"
+	now := time.Now()
+	for _, tsk := range []*Task{
+		{ID: low, Priority: 0, States: []DatedState{{State: StateScheduled, Created: now}}},
+		{ID: high, Priority: 10, States: []DatedState{{State: StateScheduled, Created: now}}},
+		{ID: older, Priority: 0, States: []DatedState{{State: StateScheduled, Created: now.Add(-time.Minute)}}},
+	} {
+		if err := q.Push(tsk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pos, ahead, ok := q.Position(low)
+	assert.True(t, ok)
+	assert.Equal(t, 3, pos)
+	assert.Equal(t, []string{high, older}, []string{ahead[0].ID, ahead[1].ID})
+
+	pos, ahead, ok = q.Position(high)
+	assert.True(t, ok)
+	assert.Equal(t, 1, pos)
+	assert.Empty(t, ahead)
+
+	_, _, ok = q.Position("bt4brhjpc98qra498s70")
+	assert.False(t, ok)
+}
+
+func TestQueueCancel(t *testing.T) {
+	inmem := storage.NewMemStorage()
+	db, err := leveldb.Open(inmem, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &Storage{db}
+	q, err := NewQueue(ts, 100, convertTask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canceled := "bt4brhjpc98qra498s40"
+	kept := "bt4brhjpc98qra498s50"
+	now := time.Now()
+	for _, id := range []string{canceled, kept} {
+		if err := q.Push(&Task{ID: id, States: []DatedState{{State: StateScheduled, Created: now}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ok, err := q.Cancel(canceled)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	tsk, err := ts.Get(canceled)
+	assert.NoError(t, err)
+	assert.Equal(t, StateCanceled, tsk.State().State)
+
+	// the task left the queue.
+	ok, err = q.Cancel(canceled)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	next, err := q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, kept, next.ID)
+	_, err = q.Pop()
+	assert.Equal(t, ErrQueueEmpty, err)
+}
+
 func convertTask(taskData []byte) (*Task, error) {
 	tsk := &Task{}
 	err := json.Unmarshal(taskData, tsk)
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +23,8 @@ var (
 	prefixScheduled  = "queue"
 	prefixProcessing = "current"
 	prefixComplete   = "archive"
+	prefixIdempotent = "idempotency"
+	prefixLabel      = "label"
 
 	ErrNotFound = errors.New("task not found")
 )
@@ -114,11 +118,121 @@ func (s *Storage) delete(prefix string, tsk *Task) error {
 	if err != nil {
 		return err
 	}
+	if tsk.IdempotencyKey != "" {
+		err = s.db.Delete(idempotencyKey(tsk.IdempotencyKey), nil)
+		if err != nil {
+			return err
+		}
+	}
+	for k, v := range tsk.Labels {
+		err = s.db.Delete(labelKey(k, v, tsk.ID), nil)
+		if err != nil {
+			return err
+		}
+	}
 	return s.db.Delete(key, &opt.WriteOptions{
 		Sync: true,
 	})
 }
 
+// idempotencyKey derives the database key indexing tasks by the idempotency
+// key they were submitted with.
+func idempotencyKey(key string) []byte {
+	return []byte(prefixIdempotent + ":" + key)
+}
+
+// PersistIdempotencyKey indexes a task by its idempotency key.
+func (s *Storage) PersistIdempotencyKey(tsk *Task) error {
+	return s.db.Put(idempotencyKey(tsk.IdempotencyKey), []byte(tsk.ID), &opt.WriteOptions{
+		Sync: true,
+	})
+}
+
+// GetByIdempotencyKey returns the task submitted with the supplied
+// idempotency key, or ErrNotFound if there is none.
+func (s *Storage) GetByIdempotencyKey(key string) (*Task, error) {
+	id, err := s.db.Get(idempotencyKey(key), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(string(id))
+}
+
+// labelPrefix derives the database key prefix indexing tasks by a label. The
+// key and value are escaped, so that neither can run into the other.
+func labelPrefix(key, value string) []byte {
+	return []byte(prefixLabel + ":" + url.QueryEscape(key) + "=" + url.QueryEscape(value) + ":")
+}
+
+// labelKey derives the database key indexing a task by one of its labels.
+func labelKey(key, value, id string) []byte {
+	return append(labelPrefix(key, value), id...)
+}
+
+// PersistLabels indexes a task by its labels.
+func (s *Storage) PersistLabels(tsk *Task) error {
+	if len(tsk.Labels) == 0 {
+		return nil
+	}
+	batch := new(leveldb.Batch)
+	for k, v := range tsk.Labels {
+		batch.Put(labelKey(k, v, tsk.ID), nil)
+	}
+	return s.db.Write(batch, &opt.WriteOptions{
+		Sync: true,
+	})
+}
+
+// FindByLabels returns the IDs of the tasks carrying all the supplied labels,
+// oldest first.
+func (s *Storage) FindByLabels(labels map[string]string) ([]string, error) {
+	var ids []string
+	first := true
+	for k, v := range labels {
+		prefix := labelPrefix(k, v)
+		found := make(map[string]bool)
+
+		iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+		for iter.Next() {
+			found[string(iter.Key()[len(prefix):])] = true
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return nil, err
+		}
+
+		if first {
+			for id := range found {
+				ids = append(ids, id)
+			}
+			first = false
+			continue
+		}
+		kept := ids[:0]
+		for _, id := range ids {
+			if found[id] {
+				kept = append(kept, id)
+			}
+		}
+		ids = kept
+	}
+	// task IDs sort by creation time.
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Ping checks that the storage can be read from.
+func (s *Storage) Ping() error {
+	_, err := s.db.Get([]byte(prefixScheduled), nil)
+	if err == leveldb.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
 func (s *Storage) Get(id string) (*Task, error) {
 	tsk, err := s.get(prefixComplete, id)
 	if err == nil {
@@ -132,3 +132,89 @@ func TestArchive(t *testing.T) {
 
 	assert.Equal(t, 3, len(between))
 }
+
+// Make sure tasks can be found by their idempotency key until deleted.
+func TestIdempotencyKey(t *testing.T) {
+	inmem := storage.NewMemStorage()
+	db, err := leveldb.Open(inmem, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &Storage{db}
+
+	tsk := &Task{
+		ID:             "bt4brhjpc98qra498sg0",
+		IdempotencyKey: "ci-job-1234",
+	}
+	_, err = ts.GetByIdempotencyKey(tsk.IdempotencyKey)
+	assert.Equal(t, ErrNotFound, err)
+
+	err = ts.PersistScheduled(tsk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ts.PersistIdempotencyKey(tsk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := ts.GetByIdempotencyKey(tsk.IdempotencyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tsk.ID, found.ID)
+
+	err = ts.Delete(tsk.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ts.GetByIdempotencyKey(tsk.IdempotencyKey)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+// Make sure tasks can be found by their labels until deleted.
+func TestFindByLabels(t *testing.T) {
+	inmem := storage.NewMemStorage()
+	db, err := leveldb.Open(inmem, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &Storage{db}
+
+	tsks := []*Task{
+		{ID: "bt4brhjpc98qra498sg0", Labels: map[string]string{"experiment": "foo", "commit": "abc"}},
+		{ID: "bt4brhjpc98qra498sgg", Labels: map[string]string{"experiment": "foo", "commit": "def"}},
+		{ID: "bt4brhjpc98qra498sh0", Labels: map[string]string{"experiment": "foo:bar"}},
+		{ID: "bt4brhjpc98qra498shg"},
+	}
+	for _, tsk := range tsks {
+		if err := ts.PersistScheduled(tsk); err != nil {
+			t.Fatal(err)
+		}
+		if err := ts.PersistLabels(tsk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids, err := ts.FindByLabels(map[string]string{"experiment": "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{tsks[0].ID, tsks[1].ID}, ids)
+
+	ids, err = ts.FindByLabels(map[string]string{"experiment": "foo", "commit": "def"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{tsks[1].ID}, ids)
+
+	err = ts.Delete(tsks[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err = ts.FindByLabels(map[string]string{"experiment": "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{tsks[1].ID}, ids)
+}
@@ -33,13 +33,38 @@ const (
 // TypeBuild -- which functions similarly to `testground build`. The result of this task will contain
 // a build ID which can be used in a subsequent run.
 // TypeRun -- which functions similarly to `testground run`
+// TypeExperiment -- a run of a composition with a parameter matrix; its children are the runs of
+// the cells of the matrix.
 type Type string
 
 const (
-	TypeBuild Type = "build"
-	TypeRun   Type = "run"
+	TypeBuild      Type = "build"
+	TypeRun        Type = "run"
+	TypeExperiment Type = "experiment"
 )
 
+// Task priorities are bounded to [MinPriority, MaxPriority]. Tasks with higher
+// priorities are processed first; tasks submitted without an explicit priority
+// run at DefaultPriority, or at WaitPriority when the client waits on them.
+const (
+	MinPriority     = -100
+	MaxPriority     = 100
+	DefaultPriority = 0
+	WaitPriority    = 1
+)
+
+// ClampPriority bounds p to the [MinPriority, MaxPriority] range.
+func ClampPriority(p int) int {
+	switch {
+	case p < MinPriority:
+		return MinPriority
+	case p > MaxPriority:
+		return MaxPriority
+	default:
+		return p
+	}
+}
+
 // DatedState (kind: struct) is a State with a timestamp.
 type DatedState struct {
 	Created time.Time `json:"created"`
@@ -58,7 +83,7 @@ type CreatedBy struct {
 // state of a running or scheduled task.
 type Task struct {
 	Version     int          `json:"version"`     // Schema version
-	Priority    int          `json:"priority"`    // Scheduling priority
+	Priority    int          `json:"priority"`    // Scheduling priority; higher runs first
 	ID          string       `json:"id"`          // Unique identifier for this task
 	Runner      string       `json:"runner"`      // Runner that ran this task
 	Plan        string       `json:"plan"`        // Test plan
@@ -70,6 +95,17 @@ type Task struct {
 	Result      interface{}  `json:"result"`      // Result of the task, when terminal.
 	Error       string       `json:"error"`       // Error from Testground
 	CreatedBy   CreatedBy    `json:"created_by"`  // Who created the task
+	Callback    string       `json:"callback"`    // URL notified when the task completes
+	Parent      string       `json:"parent"`      // Task this task is a phase of, if any
+	Children    []string     `json:"children"`    // Tasks for the phases of this task
+	// IdempotencyKey is the key the task was submitted with, if any; other
+	// submissions with the same key resolve to this task.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// RequestID is the ID of the daemon request that created the task.
+	RequestID string `json:"request_id,omitempty"`
+	// Labels are arbitrary key-value pairs the task was submitted with, to
+	// group related tasks, e.g. the runs of an experiment.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 func (t *Task) Created() time.Time {
@@ -88,7 +124,7 @@ func (t *Task) Name() string {
 	switch t.Type {
 	case TypeBuild:
 		return "build"
-	case TypeRun:
+	case TypeRun, TypeExperiment:
 		return fmt.Sprintf("%s:%s", t.Plan, t.Case)
 	default:
 		return "not supported"
@@ -110,6 +146,15 @@ func (t *Task) CreatedByCI() bool {
 	return t.CreatedBy.Repo != "" && t.CreatedBy.Commit != "" && t.CreatedBy.Branch != ""
 }
 
+// Submitter identifies who a task is scheduled on behalf of, for fair-share
+// scheduling: the repository for CI-created tasks, and the user otherwise.
+func (t *Task) Submitter() string {
+	if t.CreatedByCI() {
+		return t.CreatedBy.Repo
+	}
+	return t.CreatedBy.User
+}
+
 func (t *Task) RenderCreatedBy() string {
 	if t.CreatedByCI() {
 		return fmt.Sprintf(`<a href="https://github.com/%s/commit/%s" target="_blank">%s<br/>%s</a>`, t.CreatedBy.Repo, t.CreatedBy.Commit, t.CreatedBy.Repo, t.CreatedBy.Branch)
@@ -56,3 +56,10 @@ func TestQueueSortsPriorityAndTime(t *testing.T) {
 		head = next
 	}
 }
+
+func TestClampPriority(t *testing.T) {
+	assert.Equal(t, MinPriority, ClampPriority(MinPriority-1))
+	assert.Equal(t, MaxPriority, ClampPriority(MaxPriority+50))
+	assert.Equal(t, WaitPriority, ClampPriority(WaitPriority))
+	assert.Equal(t, -5, ClampPriority(-5))
+}
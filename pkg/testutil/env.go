@@ -0,0 +1,25 @@
+// Package testutil provides helpers shared by the tests of the daemon
+// packages.
+package testutil
+
+import (
+	"os"
+	"testing"
+)
+
+// Setenv sets an environment variable for the duration of the test, like
+// testing.T.Setenv does from go 1.17 on.
+func Setenv(t *testing.T, key, value string) {
+	t.Helper()
+	prev, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(key, prev)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
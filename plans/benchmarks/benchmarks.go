@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"os"
 	"strings"
 	"time"
 
@@ -18,11 +19,29 @@ import (
 // This relies on the testground daemon to inject the time when the plan is scheduled
 // into the runtime environment
 func StartTimeBench(runenv *runtime.RunEnv, initCtx *run.InitContext) error {
-	elapsed := time.Since(runenv.TestStartTime)
+	started := time.Now()
+	elapsed := started.Sub(runenv.TestStartTime)
 	runenv.R().RecordPoint("time_to_start_secs", elapsed.Seconds())
+
+	// Break the time to start down into the lifecycle phases, when the runner
+	// reports them.
+	admitted, ok1 := lifecycleTime("TEST_ADMITTED_TIME")
+	scheduled, ok2 := lifecycleTime("TEST_SCHEDULED_TIME")
+	created, ok3 := lifecycleTime("TEST_CONTAINER_CREATED_TIME")
+	if ok1 && ok2 && ok3 {
+		runenv.R().RecordPoint("time_to_schedule_secs", scheduled.Sub(admitted).Seconds())
+		runenv.R().RecordPoint("time_to_create_secs", created.Sub(scheduled).Seconds())
+		runenv.R().RecordPoint("time_from_create_to_start_secs", started.Sub(created).Seconds())
+	}
 	return nil
 }
 
+// lifecycleTime parses a lifecycle timestamp set by the runner.
+func lifecycleTime(env string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, os.Getenv(env))
+	return t, err == nil
+}
+
 // NetworkInitBench starts and waits for the network to initialize
 // The metric it emits represents the time between plan start and when the network initialization
 // is completed.